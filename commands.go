@@ -0,0 +1,702 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PierreKieffer/http-tanker/pkg/cli"
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+/*
+Headless subcommands
+For shell scripts and CI: each one runs without any prompt and returns the
+exit code of the process.
+*/
+
+// Exit codes of the subcommands
+const (
+	exitOK          = 0
+	exitError       = 1 // request not found, network error, invalid file...
+	exitUsage       = 2
+	exitFailed      = 3 // assertion failed
+	exitClientError = 4 // HTTP 4xx
+	exitServerError = 5 // HTTP 5xx
+)
+
+var commands = map[string]func(database *core.Database, args []string) int{
+	"run":    runCommand,
+	"list":   listCommand,
+	"show":   showCommand,
+	"curl":   curlCommand,
+	"import": importCommand,
+	"export": exportCommand,
+	"delete": deleteCommand,
+	"test":   testCommand,
+	"http":   runHTTPFile,
+}
+
+const commandsUsage = `Commands:
+  tanker run [-env name] [-output pretty|json|body] <request>
+  tanker list [-collection path] [-tag tag] [-query text] [-output pretty|json]
+  tanker show [-resolved] [-output pretty|json] <request>
+  tanker curl [-language curl|go|python|...] [-shell posix|powershell|cmd] [-secrets redact|env] [-single-line] <request>
+  tanker import [-format curl|postman|openapi|har|http] [-collection path] [-overwrite] <file|->
+  tanker export [-format tanker|postman|http|har] [-collection path] [-file path]
+  tanker delete <request>...
+  tanker test [-collection path] [-tag tag] [-env name] [-parallel n] [-bail] [-report junit|tap|json] [-report-file path]
+  tanker http [-env name] <file> [request]
+  tanker [METHOD] <url> [item...] [-form] [-auth user:pass] [-save name] [-output pretty|json|body]
+      items: Header:Value, param==value, field=value, field:=json, field=@file, field:=@file, field@file
+      e.g. tanker POST :8080/users name=bob age:=42 Authorization:"Bearer x"
+  tanker init [-format json|yaml] [dir]
+
+Exit codes: 0 success, 1 error, 2 invalid usage, 3 assertion failed, 4 HTTP 4xx, 5 HTTP 5xx.
+A request with assertions exits with 0 or 3 whatever its status.
+`
+
+var outputModes = []string{"pretty", "json", "body"}
+
+/*
+parseFlags
+Parses the flags wherever they are among the arguments, e.g. after the
+request name, and returns the other arguments
+*/
+func parseFlags(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func newFlagSet(name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: tanker %s %s\n", name, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+func checkOutput(output string, modes []string) bool {
+	for _, mode := range modes {
+		if output == mode {
+			return true
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Invalid output %q, expected %s\n", output, strings.Join(modes, ", "))
+	return false
+}
+
+// useEnvironment resolves the variables from an environment for this process
+// only, the active environment saved in the database is left unchanged
+func useEnvironment(database *core.Database, env string) bool {
+	if env == "" {
+		return true
+	}
+	if _, ok := database.Environments[env]; !ok {
+		fmt.Fprintf(os.Stderr, "Environment %q not found, available: %s\n", env, strings.Join(database.EnvironmentNames(), ", "))
+		return false
+	}
+	database.EnvironmentUsed = env
+	return true
+}
+
+// statusExitCode is the exit code of an HTTP status
+func statusExitCode(statusCode int) int {
+	switch {
+	case statusCode >= 500:
+		return exitServerError
+	case statusCode >= 400:
+		return exitClientError
+	}
+	return exitOK
+}
+
+// History name of the ad-hoc requests that are not saved, so that they are
+// not taken for a saved request of the same name
+const adHocHistoryPrefix = "adhoc:"
+
+// send executes a resolved request, records it in the history and marks a
+// saved request as used
+func send(database *core.Database, name string, r core.Request) (core.Response, error) {
+	response, err := r.CallHTTP()
+	var recorded *core.Response
+	if err == nil {
+		recorded = &response
+	}
+	if _, recordErr := database.RecordHistory(name, r, recorded, err); recordErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record history: %v\n", recordErr)
+	}
+	if _, saved := database.Data[name]; saved && err == nil && !strings.HasPrefix(name, adHocHistoryPrefix) {
+		if touchErr := database.Touch(name); touchErr != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save database: %v\n", touchErr)
+		}
+	}
+	return response, err
+}
+
+// writeResponse prints a response in an output mode
+func writeResponse(response core.Response, output string) error {
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	case "body":
+		return response.WriteBody(os.Stdout)
+	}
+	core.DisplayResponse(response)
+	return nil
+}
+
+/*
+runCommand
+tanker run [-env name] [-output pretty|json|body] <request>: execute a saved
+request. The exit code follows the HTTP status.
+*/
+func runCommand(database *core.Database, args []string) int {
+	flags := newFlagSet("run", "[-env name] [-output pretty|json|body] <request>")
+	env := flags.String("env", "", "environment to use instead of the active one")
+	output := flags.String("output", "pretty", "pretty, json (status, headers and body) or body (the body only, as received)")
+	names := parseFlags(flags, args)
+	if len(names) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	if !checkOutput(*output, outputModes) || !useEnvironment(database, *env) {
+		return exitUsage
+	}
+
+	name := names[0]
+	r, err := database.Resolve(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if missing := core.MissingVariables(r); len(missing) > 0 {
+		fmt.Fprintln(os.Stderr, "Warning: undefined variables: "+strings.Join(missing, ", "))
+	}
+
+	response, err := send(database, name, r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Request failed: %v\n", err)
+		return exitError
+	}
+	defer response.Cleanup()
+	if err := writeResponse(response, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write the response: %v\n", err)
+		return exitError
+	}
+	if len(r.Assertions) == 0 {
+		return statusExitCode(response.StatusCode)
+	}
+	// Written apart from the response, which may be piped
+	passed := true
+	for _, result := range core.CheckAssertions(r.Assertions, response) {
+		passed = passed && result.Passed
+		fmt.Fprintln(os.Stderr, assertionLine(result))
+	}
+	if !passed {
+		return exitFailed
+	}
+	return exitOK
+}
+
+func assertionLine(result core.AssertionResult) string {
+	if result.Passed {
+		return "  PASS " + result.Assertion.String()
+	}
+	return "  FAIL " + result.Message
+}
+
+/*
+listCommand
+tanker list [-collection path] [-tag tag] [-query text] [-output pretty|json]:
+list the saved requests
+*/
+func listCommand(database *core.Database, args []string) int {
+	flags := newFlagSet("list", "[-collection path] [-tag tag] [-query text] [-sort order] [-output pretty|json]")
+	collection := flags.String("collection", "", "only the requests of this folder and its subfolders")
+	tag := flags.String("tag", "", "only the requests carrying this tag")
+	query := flags.String("query", "", "fuzzy search on name, URL and tags")
+	by := flags.String("sort", "", "sort order: "+strings.Join(core.SortOrders, ", "))
+	output := flags.String("output", "pretty", "pretty or json")
+	if len(parseFlags(flags, args)) > 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	if !checkOutput(*output, outputModes[:2]) {
+		return exitUsage
+	}
+
+	requests := []core.Request{}
+	for _, name := range database.Search(*query, *tag, *by) {
+		if r := database.Data[name]; core.InCollection(r.Collection, core.CleanCollection(*collection)) {
+			requests = append(requests, r)
+		}
+	}
+
+	if *output == "json" {
+		data, _ := json.MarshalIndent(requests, "", "  ")
+		fmt.Println(string(data))
+		return exitOK
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMETHOD\tURL\tFOLDER")
+	for _, r := range requests {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.Method, r.URL, core.CleanCollection(r.Collection))
+	}
+	w.Flush()
+	return exitOK
+}
+
+/*
+showCommand
+tanker show [-resolved] [-output pretty|json] <request>: display a saved
+request
+*/
+func showCommand(database *core.Database, args []string) int {
+	flags := newFlagSet("show", "[-resolved] [-env name] [-output pretty|json] <request>")
+	resolved := flags.Bool("resolved", false, "apply the folder defaults, the auth profile and the variables")
+	env := flags.String("env", "", "environment to use with -resolved instead of the active one")
+	output := flags.String("output", "pretty", "pretty or json")
+	names := parseFlags(flags, args)
+	if len(names) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	if !checkOutput(*output, outputModes[:2]) || !useEnvironment(database, *env) {
+		return exitUsage
+	}
+
+	name := names[0]
+	r, ok := database.Data[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "request %q not found\n", name)
+		return exitError
+	}
+	if *resolved {
+		var err error
+		if r, err = database.Resolve(name); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+
+	if *output == "json" {
+		data, _ := json.MarshalIndent(r, "", "  ")
+		fmt.Println(string(data))
+		return exitOK
+	}
+	if *resolved {
+		// Display reads the saved request, show the resolved one in its place
+		r.AuthProfile = ""
+		display := &core.Database{Data: map[string]core.Request{name: r}}
+		display.Display(name)
+		return exitOK
+	}
+	database.Display(name)
+	return exitOK
+}
+
+/*
+curlCommand
+tanker curl [-language name] [-shell posix|powershell|cmd] [-secrets redact|env]
+[-single-line] <request>: print a saved request as a curl command, or as code
+*/
+func curlCommand(database *core.Database, args []string) int {
+	flags := newFlagSet("curl", "[-language name] [-shell posix|powershell|cmd] [-secrets redact|env] [-single-line] [-env name] <request>")
+	language := flags.String("language", "curl", "curl or the language of the code: "+strings.Join(core.CodeLanguageNames(), ", "))
+	shell := flags.String("shell", "posix", "shell the curl command is quoted for: "+strings.Join(core.CurlShells, ", "))
+	secrets := flags.String("secrets", "", "mask the tokens, passwords and API keys (redact) or read them from environment variables such as $TOKEN (env)")
+	singleLine := flags.Bool("single-line", false, "write the curl command on a single line")
+	env := flags.String("env", "", "environment to use instead of the active one")
+	names := parseFlags(flags, args)
+	if len(names) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	if !useEnvironment(database, *env) {
+		return exitUsage
+	}
+
+	r, err := database.Resolve(names[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	var code string
+	if *language == "curl" {
+		code, err = r.Curl(core.CurlOptions{Shell: *shell, Secrets: *secrets, SingleLine: *singleLine})
+	} else {
+		code, err = r.GenerateCodeWith(*language, core.CodeOptions{Secrets: *secrets})
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	fmt.Println(strings.TrimSuffix(code, "\n"))
+	return exitOK
+}
+
+/*
+importCommand
+tanker import [-format name] [-collection path] [-overwrite] <file|->: import
+requests, the format being guessed from the file when not given
+*/
+func importCommand(database *core.Database, args []string) int {
+	flags := newFlagSet("import", "[-format curl|postman|openapi|har|http] [-collection path] [-overwrite] <file|->")
+	format := flags.String("format", "", "curl, postman, openapi, har or http (default: guessed from the file)")
+	collection := flags.String("collection", "", "folder of the imported requests")
+	overwrite := flags.Bool("overwrite", false, "replace the existing requests and environments with the same name")
+	files := parseFlags(flags, args)
+	if len(files) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	path := files[0]
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", path, err)
+		return exitError
+	}
+	if *format == "" {
+		*format = importFormat(path, data)
+	}
+
+	var imp core.Import
+	switch *format {
+	case "curl":
+		r, warnings, parseErr := core.ParseCurlWith(string(data), core.CurlImportOptions{ReadFiles: true})
+		r.Name = core.DefaultRequestName(r.Method, r.URL)
+		r.Collection = core.CleanCollection(*collection)
+		imp, err = core.Import{Requests: []core.Request{r}, Warnings: warnings}, parseErr
+	case "postman":
+		imp, err = core.ImportPostman(data, *collection)
+	case "openapi":
+		imp, err = core.ImportOpenAPI(data, *collection)
+	case "har":
+		imp, err = core.ImportHAR(data, *collection, core.HARFilter{})
+	case "http":
+		imp, err = core.ImportHTTPFile(data, *collection)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format %q, expected curl, postman, openapi, har or http\n", *format)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid %s file %s: %v\n", *format, path, err)
+		return exitError
+	}
+
+	report, err := database.SaveImport(imp, *overwrite)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save the import: %v\n", err)
+		return exitError
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintln(os.Stderr, "Warning: "+warning)
+	}
+	fmt.Printf("%d request(s) imported, %d replaced\n", len(report.Added), len(report.Updated))
+	if len(report.Skipped) > 0 {
+		fmt.Println("Skipped, already existing: " + strings.Join(report.Skipped, ", "))
+	}
+	if len(report.Kept) > 0 {
+		fmt.Println("Kept, edited locally: " + strings.Join(report.Kept, ", "))
+	}
+	if len(report.Removed) > 0 {
+		fmt.Println("No longer in the source, not deleted: " + strings.Join(report.Removed, ", "))
+	}
+	return exitOK
+}
+
+// importFormat guesses the format of a file from its extension and content
+func importFormat(path string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".har":
+		return "har"
+	case ".http", ".rest":
+		return "http"
+	case ".yaml", ".yml":
+		return "openapi"
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "curl ") {
+		return "curl"
+	}
+	var document map[string]json.RawMessage
+	if json.Unmarshal(data, &document) == nil {
+		switch {
+		case document["openapi"] != nil || document["swagger"] != nil:
+			return "openapi"
+		case document["log"] != nil:
+			return "har"
+		case document["item"] != nil || document["values"] != nil:
+			return "postman"
+		}
+	}
+	return "http"
+}
+
+/*
+exportCommand
+tanker export [-format tanker|postman|http|har] [-collection path] [-file path]:
+write requests, or the history for HAR, to a file or the standard output
+*/
+func exportCommand(database *core.Database, args []string) int {
+	flags := newFlagSet("export", "[-format tanker|postman|http|har] [-collection path] [-request name] [-limit n] [-file path]")
+	format := flags.String("format", "tanker", "tanker (database file), postman, http, or har (history)")
+	collection := flags.String("collection", "", "folder to export (default: every request)")
+	request := flags.String("request", "", "har: executions of this request only")
+	limit := flags.Int("limit", 100, "har: most recent executions")
+	file := flags.String("file", "", "file to write (default: the standard output)")
+	if len(parseFlags(flags, args)) > 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var data []byte
+	var warnings []string
+	var err error
+	switch *format {
+	case "tanker":
+		var names []string
+		for _, name := range database.Search("", "", "") {
+			if core.InCollection(database.Data[name].Collection, core.CleanCollection(*collection)) {
+				names = append(names, name)
+			}
+		}
+		data, err = database.ExportRequests(names)
+	case "postman":
+		data, warnings, err = database.ExportPostman(*collection)
+	case "http":
+		data, warnings, err = database.ExportHTTPFile(*collection)
+	case "har":
+		var entries []core.HistoryEntry
+		if entries, err = database.ListHistory(*request, *limit); err == nil {
+			data, err = core.ExportHAR(entries, cli.Version())
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format %q, expected tanker, postman, http or har\n", *format)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		return exitError
+	}
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning: "+warning)
+	}
+
+	if *file == "" {
+		os.Stdout.Write(data)
+		return exitOK
+	}
+	if err := os.WriteFile(*file, data, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", *file, err)
+		return exitError
+	}
+	return exitOK
+}
+
+/*
+deleteCommand
+tanker delete <request>...: move saved requests to the trash
+*/
+func deleteCommand(database *core.Database, args []string) int {
+	flags := newFlagSet("delete", "<request>...")
+	names := parseFlags(flags, args)
+	if len(names) == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	if err := database.DeleteRequests(names); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	fmt.Printf("%d request(s) moved to the trash\n", len(names))
+	return exitOK
+}
+
+/*
+adHocCommand
+tanker [METHOD] <url> [item...]: send a one-off request written with the
+HTTPie syntax, a body piped on the standard input being sent as is
+*/
+func adHocCommand(database *core.Database, args []string) int {
+	flags := newFlagSet("[METHOD]", "<url> [item...] [flags]")
+	form := flags.Bool("form", false, "send the fields as an urlencoded form instead of JSON")
+	multipart := flags.Bool("multipart", false, "send the fields as multipart/form-data, implied by a file field@path")
+	auth := flags.String("auth", "", "credentials user:pass, or the token with -auth-type bearer")
+	authType := flags.String("auth-type", "basic", "basic, digest or bearer")
+	insecure := flags.Bool("insecure", false, "skip the TLS certificate verification")
+	ignoreStdin := flags.Bool("ignore-stdin", false, "don't read the body on the standard input")
+	save := flags.String("save", "", "save the request under this name")
+	collection := flags.String("collection", "", "folder of the saved request")
+	env := flags.String("env", "", "environment providing the {{variables}} instead of the active one")
+	output := flags.String("output", "pretty", "pretty, json (status, headers and body) or body (the body only, as received)")
+	items := parseFlags(flags, args)
+	if !checkOutput(*output, outputModes) || !useEnvironment(database, *env) {
+		return exitUsage
+	}
+
+	opts := core.AdHocOptions{Form: *form, Multipart: *multipart}
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 && !*ignoreStdin {
+		if opts.Body, err = io.ReadAll(os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read the standard input: %v\n", err)
+			return exitError
+		}
+	}
+	r, err := core.ParseAdHoc(items, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	r.Insecure = *insecure
+	if *auth != "" {
+		switch *authType {
+		case "basic", "digest":
+			username, password, _ := strings.Cut(*auth, ":")
+			r.Auth = &core.AuthConfig{Type: *authType, Username: username, Password: password}
+		case "bearer":
+			r.Auth = &core.AuthConfig{Type: "bearer", Token: *auth}
+		default:
+			fmt.Fprintf(os.Stderr, "Invalid auth type %q, expected basic, digest or bearer\n", *authType)
+			return exitUsage
+		}
+	}
+
+	name := adHocHistoryPrefix + core.DefaultRequestName(r.Method, r.URL)
+	if *save != "" {
+		name = *save
+		r.Name = name
+		r.Collection = core.CleanCollection(*collection)
+		if err := database.Add(r); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		fmt.Fprintf(os.Stderr, "Saved as %s\n", name)
+	}
+
+	resolved, err := database.ResolveRequest(r)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if missing := core.MissingVariables(resolved); len(missing) > 0 {
+		fmt.Fprintln(os.Stderr, "Warning: undefined variables: "+strings.Join(missing, ", "))
+	}
+	response, err := send(database, name, resolved)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Request failed: %v\n", err)
+		return exitError
+	}
+	defer response.Cleanup()
+	if err := writeResponse(response, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write the response: %v\n", err)
+		return exitError
+	}
+	return statusExitCode(response.StatusCode)
+}
+
+/*
+testCommand
+tanker test [-collection path] [-tag tag] [-parallel n] [-report format]: run
+a collection as a test suite, exits with 3 when a request fails
+*/
+func testCommand(database *core.Database, args []string) int {
+	flags := newFlagSet("test", "[-collection path] [-tag tag] [-env name] [-parallel n] [-bail] [-report junit|tap|json] [-report-file path]")
+	collection := flags.String("collection", "", "folder to run with its subfolders (default: every request)")
+	tag := flags.String("tag", "", "only the requests carrying this tag")
+	env := flags.String("env", "", "environment to use instead of the active one")
+	parallel := flags.Int("parallel", 1, "requests sent at the same time, 1 runs them in the order of the collection")
+	bail := flags.Bool("bail", false, "stop at the first failure")
+	format := flags.String("report", "", "write a report: junit, tap or json")
+	reportFile := flags.String("report-file", "", "file of the report (default: the standard output)")
+	if len(parseFlags(flags, args)) > 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	switch *format {
+	case "", "junit", "tap", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown report format %q, expected junit, tap or json\n", *format)
+		return exitUsage
+	}
+	if !useEnvironment(database, *env) {
+		return exitUsage
+	}
+
+	// The progress goes to stderr when the report takes the standard output
+	out := os.Stdout
+	if *format != "" && *reportFile == "" {
+		out = os.Stderr
+	}
+	report, err := database.RunCollection(*collection, core.RunOptions{Tag: *tag, Parallel: *parallel, Bail: *bail}, func(result core.RunResult) {
+		status := "PASS"
+		if !result.Passed() {
+			status = "FAIL"
+		}
+		if result.Error != "" {
+			fmt.Fprintf(out, "%s %s %s\n  ERROR %s\n", status, result.Method, result.Name, result.Error)
+		} else {
+			fmt.Fprintf(out, "%s %s %s (%d, %d ms)\n", status, result.Method, result.Name, result.StatusCode, result.TimeMillisec)
+		}
+		for _, a := range result.Assertions {
+			if !a.Passed {
+				fmt.Fprintln(out, assertionLine(a))
+			}
+		}
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	fmt.Fprintf(out, "\n%d passed, %d failed, %d skipped in %d ms\n", report.Passed, report.Failed, report.Skipped, report.DurationMillisec)
+
+	var data []byte
+	switch *format {
+	case "junit":
+		data, err = report.JUnit()
+	case "tap":
+		data = report.TAP()
+	case "json":
+		data, err = report.JSON()
+	}
+	if err == nil && data != nil {
+		if *reportFile == "" {
+			_, err = os.Stdout.Write(data)
+		} else {
+			err = os.WriteFile(*reportFile, data, 0600)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write the report: %v\n", err)
+		return exitError
+	}
+	if report.Failed > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
 	"strings"
+	"time"
 
 	surveyCore "github.com/AlecAivazis/survey/v2/core"
 	"github.com/PierreKieffer/http-tanker/pkg/cli"
@@ -38,13 +40,57 @@ func main() {
 		os.Exit(1)
 	}
 
-	databaseDir := flag.String("db", fmt.Sprintf("%v/.http-tanker", localUser.HomeDir), "tanker database directory")
+	homeDir := fmt.Sprintf("%v/.http-tanker", localUser.HomeDir)
+
+	databaseDir := flag.String("db", homeDir, "tanker database directory, disables the workspace discovery")
 	mcpMode := flag.Bool("mcp", false, "start as MCP server (stdio transport)")
+	storageDir := flag.String("storage-dir", "", "store requests as one file per request in this directory (git friendly) instead of the database file")
+	storageFormat := flag.String("storage-format", "json", "file format used by -storage-dir: json or yaml")
+	historyRetention := flag.Duration("history-retention", 30*24*time.Hour, "how long executions are kept in the history, 0 keeps everything")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: tanker [flags] [command]\n\nWithout command, starts the interactive interface.\n\n%s\nFlags:\n", commandsUsage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	if *mcpMode && flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "-mcp starts the MCP server, it takes no command: %s\n", strings.Join(flag.Args(), " "))
+		os.Exit(exitUsage)
+	}
+	// Before the database is opened, the workspace does not exist yet
+	if flag.Arg(0) == "init" {
+		initWorkspace(*databaseDir, flag.Args()[1:])
+		return
+	}
+
 	database := &core.Database{
-		DatabaseDir:  *databaseDir,
-		DatabaseFile: fmt.Sprintf("%s/http-tanker-data.json", *databaseDir),
+		DatabaseDir:      *databaseDir,
+		DatabaseFile:     fmt.Sprintf("%s/http-tanker-data.json", *databaseDir),
+		HistoryRetention: *historyRetention,
+	}
+	if *storageDir != "" {
+		if *storageFormat != "json" && *storageFormat != "yaml" {
+			fmt.Fprintf(os.Stderr, "Invalid storage format %q, expected json or yaml\n", *storageFormat)
+			os.Exit(1)
+		}
+		database.Storage = &core.DirStorage{Root: *storageDir, Format: *storageFormat}
+	}
+
+	// A .tanker/ workspace in the current directory or a parent takes
+	// precedence over the global database, unless a database is given
+	global := database
+	workspace := ""
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		explicit = explicit || f.Name == "db" || f.Name == "storage-dir"
+	})
+	if cwd, err := os.Getwd(); err == nil && !explicit {
+		if path, found := core.FindWorkspace(cwd); found {
+			workspace = path
+			database = core.OpenWorkspace(path)
+			database.HistoryRetention = *historyRetention
+			core.RememberWorkspace(homeDir, path)
+		}
 	}
 
 	err = database.InitDB()
@@ -53,6 +99,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	if command, ok := commands[flag.Arg(0)]; ok {
+		os.Exit(command(database, flag.Args()[1:]))
+	}
+	if core.IsAdHocRequest(flag.Args()) {
+		os.Exit(adHocCommand(database, flag.Args()))
+	}
+
 	if *mcpMode {
 		if err := tankerMcp.Serve(database); err != nil {
 			fmt.Fprintf(os.Stderr, "MCP server error: %v\n", err)
@@ -62,10 +115,119 @@ func main() {
 	}
 
 	app := &cli.App{
-		SigChan:  make(chan cli.Signal),
-		Database: database,
+		SigChan:   make(chan cli.Signal),
+		Database:  database,
+		Global:    global,
+		HomeDir:   homeDir,
+		Workspace: workspace,
 	}
 
 	app.Run()
 
 }
+
+/*
+initWorkspace
+tanker init [-format json|yaml] [dir]: create a workspace in dir, the
+current directory by default
+*/
+func initWorkspace(globalDir string, args []string) {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	format := flags.String("format", "json", "file format of the requests: json or yaml")
+	flags.Parse(args)
+	if *format != "json" && *format != "yaml" {
+		fmt.Fprintf(os.Stderr, "Invalid format %q, expected json or yaml\n", *format)
+		os.Exit(1)
+	}
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+
+	path, err := core.InitWorkspace(dir, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize workspace: %v\n", err)
+		os.Exit(1)
+	}
+	core.RememberWorkspace(globalDir, path)
+	fmt.Printf("Initialized http-tanker workspace in %s\n", path)
+}
+
+/*
+runHTTPFile
+tanker http [-env name] <file> [request]: run a request of a .http file, or
+list the requests of the file when no name is given. Returns the exit code.
+*/
+func runHTTPFile(database *core.Database, args []string) int {
+	flags := flag.NewFlagSet("http", flag.ExitOnError)
+	env := flags.String("env", "", "environment providing the variables not defined in the file (default: the active one)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: tanker http [-env name] <file> [request]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", path, err)
+		return 1
+	}
+	file, err := core.ParseHTTPFile(data, filepath.Dir(path))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid file %s: %v\n", path, err)
+		return 1
+	}
+	for _, warning := range file.Warnings {
+		fmt.Fprintln(os.Stderr, "Warning: "+warning)
+	}
+	if flags.NArg() < 2 {
+		for _, r := range file.Requests {
+			fmt.Printf("%-30s %-7s %s\n", r.Name, r.Method, r.URL)
+		}
+		return 0
+	}
+
+	if *env == "" {
+		*env = database.Environment
+	}
+	vars := map[string]string{}
+	if *env != "" {
+		environment, ok := database.Environments[*env]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Environment %q not found\n", *env)
+			return 1
+		}
+		vars = environment.Variables
+	}
+	name := flags.Arg(1)
+	r, err := file.Request(name, vars)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, available: %s\n", err, strings.Join(file.Names(), ", "))
+		return 1
+	}
+	if missing := core.MissingVariables(r); len(missing) > 0 {
+		fmt.Fprintln(os.Stderr, "Warning: undefined variables: "+strings.Join(missing, ", "))
+	}
+
+	response, err := r.CallHTTP()
+	var recorded *core.Response
+	if err == nil {
+		recorded = &response
+	}
+	// Recorded apart from a saved request with the same name
+	if _, recordErr := database.RecordHistory(filepath.Base(path)+"#"+name, r, recorded, err); recordErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record history: %v\n", recordErr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Request failed: %v\n", err)
+		return 1
+	}
+	core.DisplayResponse(response)
+	response.Cleanup()
+	return 0
+}
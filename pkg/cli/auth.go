@@ -0,0 +1,462 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/PierreKieffer/http-tanker/pkg/color"
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const (
+	authNone         = "None"
+	authProfile      = "Auth profile"
+	authBearer       = "Bearer Token"
+	authBasic        = "Basic Auth"
+	authDigest       = "Digest Auth"
+	authAPIKey       = "API Key"
+	authAWSSigV4     = "AWS Signature V4"
+	authHMAC         = "HMAC signature"
+	authJWT          = "JWT (signed locally)"
+	authOAuth2Client = "OAuth 2.0 (client credentials)"
+	authOAuth2Code   = "OAuth 2.0 (authorization code + PKCE)"
+)
+
+/*
+askRequestAuth
+Authentication step of the request creation workflow: either an existing
+auth profile, or an auth config embedded in the request
+*/
+func askRequestAuth(profiles []string) (*core.AuthConfig, string, error) {
+	if len(profiles) == 0 {
+		auth, err := askAuthConfig(authNone)
+		return auth, "", err
+	}
+
+	var source string
+	err := survey.AskOne(&survey.Select{
+		Message: "Authentication :",
+		Options: []string{authNone, authProfile, "Request specific"},
+		Default: authNone,
+	}, &source)
+	if err != nil {
+		return nil, "", err
+	}
+	switch source {
+	case authProfile:
+		var profile string
+		if err := survey.AskOne(&survey.Select{Message: "Auth profile :", Options: profiles}, &profile); err != nil {
+			return nil, "", err
+		}
+		return nil, profile, nil
+	case authNone:
+		return nil, "", nil
+	}
+	auth, err := askAuthConfig("")
+	return auth, "", err
+}
+
+/*
+askAuthConfig
+Authentication prompts shared by the request and auth profile workflows
+*/
+func askAuthConfig(defaultType string) (*core.AuthConfig, error) {
+	options := []string{authBearer, authBasic, authDigest, authAPIKey, authOAuth2Client, authOAuth2Code, authAWSSigV4, authHMAC, authJWT}
+	if defaultType == authNone {
+		options = append([]string{authNone}, options...)
+	}
+	authTypeAnswer := ""
+	authTypePrompt := &survey.Select{
+		Message: "Authentication :",
+		Options: options,
+	}
+	if defaultType != "" {
+		authTypePrompt.Default = defaultType
+	}
+	if err := survey.AskOne(authTypePrompt, &authTypeAnswer); err != nil {
+		return nil, err
+	}
+
+	switch authTypeAnswer {
+	case authBearer:
+		var token string
+		if err := survey.AskOne(&survey.Password{Message: "Token :"}, &token); err != nil {
+			return nil, err
+		}
+		return &core.AuthConfig{Type: "bearer", Token: token}, nil
+	case authBasic, authDigest:
+		var username string
+		if err := survey.AskOne(&survey.Input{Message: "Username :"}, &username); err != nil {
+			return nil, err
+		}
+		var password string
+		if err := survey.AskOne(&survey.Password{Message: "Password :"}, &password); err != nil {
+			return nil, err
+		}
+		authType := "basic"
+		if authTypeAnswer == authDigest {
+			authType = "digest"
+		}
+		return &core.AuthConfig{Type: authType, Username: username, Password: password}, nil
+	case authAPIKey:
+		var header string
+		if err := survey.AskOne(&survey.Input{Message: "Header name :", Default: "X-API-Key"}, &header); err != nil {
+			return nil, err
+		}
+		var key string
+		if err := survey.AskOne(&survey.Password{Message: "API Key :"}, &key); err != nil {
+			return nil, err
+		}
+		return &core.AuthConfig{Type: "api-key", Key: key, Header: header}, nil
+	case authOAuth2Client:
+		oauthAnswers := struct {
+			TokenURL     string
+			ClientID     string
+			ClientSecret string
+			Scopes       string
+			Audience     string
+		}{}
+		oauthMenu := []*survey.Question{
+			{Name: "tokenURL", Prompt: &survey.Input{Message: "Token URL :"}, Validate: survey.Required},
+			{Name: "clientID", Prompt: &survey.Input{Message: "Client ID :"}, Validate: survey.Required},
+			{Name: "clientSecret", Prompt: &survey.Password{Message: "Client secret :"}},
+			{Name: "scopes", Prompt: &survey.Input{Message: "Scopes (space separated) :"}},
+			{Name: "audience", Prompt: &survey.Input{Message: "Audience :"}},
+		}
+		if err := survey.Ask(oauthMenu, &oauthAnswers); err != nil {
+			return nil, err
+		}
+		return &core.AuthConfig{
+			Type:         "oauth2",
+			GrantType:    "client_credentials",
+			TokenURL:     oauthAnswers.TokenURL,
+			ClientID:     oauthAnswers.ClientID,
+			ClientSecret: oauthAnswers.ClientSecret,
+			Scopes:       strings.Fields(oauthAnswers.Scopes),
+			Audience:     oauthAnswers.Audience,
+		}, nil
+	case authOAuth2Code:
+		oauthAnswers := struct {
+			AuthURL      string
+			TokenURL     string
+			ClientID     string
+			ClientSecret string
+			Scopes       string
+			RedirectURL  string
+		}{}
+		oauthMenu := []*survey.Question{
+			{Name: "authURL", Prompt: &survey.Input{Message: "Authorize URL :"}, Validate: survey.Required},
+			{Name: "tokenURL", Prompt: &survey.Input{Message: "Token URL :"}, Validate: survey.Required},
+			{Name: "clientID", Prompt: &survey.Input{Message: "Client ID :"}, Validate: survey.Required},
+			{Name: "clientSecret", Prompt: &survey.Password{Message: "Client secret (empty for public clients) :"}},
+			{Name: "scopes", Prompt: &survey.Input{Message: "Scopes (space separated) :"}},
+			{Name: "redirectURL", Prompt: &survey.Input{Message: "Redirect URL :", Default: core.DefaultRedirectURL}},
+		}
+		if err := survey.Ask(oauthMenu, &oauthAnswers); err != nil {
+			return nil, err
+		}
+		return &core.AuthConfig{
+			Type:         "oauth2",
+			GrantType:    "authorization_code",
+			AuthURL:      oauthAnswers.AuthURL,
+			TokenURL:     oauthAnswers.TokenURL,
+			ClientID:     oauthAnswers.ClientID,
+			ClientSecret: oauthAnswers.ClientSecret,
+			Scopes:       strings.Fields(oauthAnswers.Scopes),
+			RedirectURL:  oauthAnswers.RedirectURL,
+		}, nil
+	case authAWSSigV4:
+		awsAnswers := struct {
+			Service      string
+			Region       string
+			AccessKey    string
+			SecretKey    string
+			SessionToken string
+		}{}
+		awsMenu := []*survey.Question{
+			{Name: "service", Prompt: &survey.Input{Message: "Service (execute-api, s3, es...) :"}, Validate: survey.Required},
+			{Name: "region", Prompt: &survey.Input{Message: "Region (empty for AWS_REGION) :"}},
+			{Name: "accessKey", Prompt: &survey.Input{Message: "Access key (empty for AWS environment / shared credentials) :"}},
+		}
+		if err := survey.Ask(awsMenu, &awsAnswers); err != nil {
+			return nil, err
+		}
+		auth := &core.AuthConfig{
+			Type:      "aws-sigv4",
+			Service:   awsAnswers.Service,
+			Region:    awsAnswers.Region,
+			AccessKey: awsAnswers.AccessKey,
+		}
+		if auth.AccessKey == "" {
+			var profile string
+			if err := survey.AskOne(&survey.Input{Message: "Shared credentials profile (empty for default) :"}, &profile); err != nil {
+				return nil, err
+			}
+			auth.Profile = profile
+			return auth, nil
+		}
+		secretMenu := []*survey.Question{
+			{Name: "secretKey", Prompt: &survey.Password{Message: "Secret key :"}, Validate: survey.Required},
+			{Name: "sessionToken", Prompt: &survey.Password{Message: "Session token (optional) :"}},
+		}
+		if err := survey.Ask(secretMenu, &awsAnswers); err != nil {
+			return nil, err
+		}
+		auth.SecretKey = awsAnswers.SecretKey
+		auth.SessionToken = awsAnswers.SessionToken
+		return auth, nil
+	case authHMAC:
+		hmacAnswers := struct {
+			Secret          string
+			Algorithm       string
+			Encoding        string
+			Header          string
+			Prefix          string
+			TimestampHeader string
+			Template        string
+		}{}
+		hmacMenu := []*survey.Question{
+			{Name: "secret", Prompt: &survey.Password{Message: "Secret :"}, Validate: survey.Required},
+			{Name: "algorithm", Prompt: &survey.Select{Message: "Algorithm :", Options: []string{"sha256", "sha512", "sha1"}}},
+			{Name: "encoding", Prompt: &survey.Select{Message: "Encoding :", Options: []string{"hex", "base64", "base64url"}}},
+			{Name: "header", Prompt: &survey.Input{Message: "Signature header :", Default: "X-Signature"}},
+			{Name: "prefix", Prompt: &survey.Input{Message: "Signature prefix (e.g. sha256=) :"}},
+			{Name: "timestampHeader", Prompt: &survey.Input{Message: "Timestamp header (empty if not sent) :"}},
+			{
+				Name: "template",
+				Prompt: &survey.Editor{
+					Message:       "Canonical string ({method} {path} {query} {host} {timestamp} {nonce} {body} {bodySha256}) :",
+					FileName:      "http-tanker-hmac-template*.txt",
+					Default:       core.DefaultHMACTemplate,
+					HideDefault:   true,
+					AppendDefault: true,
+				},
+			},
+		}
+		if err := survey.Ask(hmacMenu, &hmacAnswers); err != nil {
+			return nil, err
+		}
+		return &core.AuthConfig{
+			Type:            "hmac",
+			Secret:          hmacAnswers.Secret,
+			Algorithm:       hmacAnswers.Algorithm,
+			Encoding:        hmacAnswers.Encoding,
+			Header:          hmacAnswers.Header,
+			SignaturePrefix: hmacAnswers.Prefix,
+			TimestampHeader: hmacAnswers.TimestampHeader,
+			Template:        strings.TrimRight(hmacAnswers.Template, "\n"),
+		}, nil
+	case authJWT:
+		jwtAnswers := struct {
+			Algorithm string
+			Key       string
+			KeyID     string
+			TTL       string
+			Header    string
+			Claims    string
+		}{}
+		jwtMenu := []*survey.Question{
+			{Name: "algorithm", Prompt: &survey.Select{Message: "Algorithm :", Options: []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "EdDSA"}}},
+			{Name: "key", Prompt: &survey.Input{Message: "Private key file (PEM, or secret file for HS*) :"}},
+			{Name: "keyID", Prompt: &survey.Input{Message: "Key ID (kid, optional) :"}},
+			{Name: "ttl", Prompt: &survey.Input{Message: "Token TTL :", Default: "5m"}},
+			{Name: "header", Prompt: &survey.Input{Message: "Header (empty for Authorization: Bearer) :"}},
+			{
+				Name: "claims",
+				Prompt: &survey.Editor{
+					Message:       "Claims template ({now} {exp} {jti}) :",
+					FileName:      "http-tanker-jwt-claims*.json",
+					Default:       core.DefaultJWTClaims,
+					HideDefault:   true,
+					AppendDefault: true,
+				},
+			},
+		}
+		if err := survey.Ask(jwtMenu, &jwtAnswers); err != nil {
+			return nil, err
+		}
+		auth := &core.AuthConfig{
+			Type:      "jwt",
+			Algorithm: jwtAnswers.Algorithm,
+			KeyFile:   jwtAnswers.Key,
+			KeyID:     jwtAnswers.KeyID,
+			TTL:       jwtAnswers.TTL,
+			Header:    jwtAnswers.Header,
+			Claims:    strings.TrimSpace(jwtAnswers.Claims),
+		}
+		if strings.HasPrefix(auth.Algorithm, "HS") && auth.KeyFile == "" {
+			if err := survey.AskOne(&survey.Password{Message: "Secret :"}, &auth.Secret, survey.WithValidator(survey.Required)); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := core.MintJWT(auth); err != nil {
+			return nil, err
+		}
+		return auth, nil
+	}
+	return nil, nil
+}
+
+/*
+Login
+Run the OAuth 2.0 authorization-code flow of a request and store the tokens
+*/
+func (app *App) Login(reqName string) error {
+
+	r, err := app.Database.Resolve(reqName)
+	if err == nil && (r.Auth == nil || r.Auth.Type != "oauth2") {
+		err = fmt.Errorf("request %s has no OAuth 2.0 authentication", reqName)
+	}
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	auth := *r.Auth
+	err = core.Authorize(ctx, &auth, r.Insecure, func(authorizeURL string) {
+		fmt.Println("Open the following URL in your browser to log in :")
+		fmt.Println()
+		fmt.Println(color.Cyan.Render(authorizeURL))
+		fmt.Println()
+		openBrowser(authorizeURL)
+		fmt.Println(color.Yellow.Render("Waiting for authorization..."))
+	})
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	// Tokens go where the auth config lives: the profile, the folder or the request
+	target := "request " + reqName
+	folder := app.Database.AuthFolder(app.Database.Data[reqName])
+	switch {
+	case r.AuthProfile != "":
+		target = "auth profile " + r.AuthProfile
+		err = app.Database.SaveProfile(r.AuthProfile, withTokens(app.Database.Profiles[r.AuthProfile], auth))
+	case folder != "":
+		target = "folder " + folder
+		f := app.Database.Folders[folder]
+		stored := withTokens(*f.Auth, auth)
+		f.Auth = &stored
+		err = app.Database.SaveFolder(folder, f)
+	default:
+		saved := app.Database.Data[reqName]
+		stored := withTokens(*saved.Auth, auth)
+		saved.Auth = &stored
+		app.Database.Data[reqName] = saved
+		err = app.Database.Save()
+	}
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	fmt.Println(color.Green.Render("Login successful, tokens stored in " + target))
+	fmt.Println()
+	app.SigChan <- Signal{Meta: reqName, Sig: SigReqSelect, Display: true}
+	return nil
+}
+
+// withTokens copies the tokens obtained with the resolved auth into the
+// stored one, whose {{variables}} must not be replaced by their values
+func withTokens(stored, auth core.AuthConfig) core.AuthConfig {
+	stored.AccessToken = auth.AccessToken
+	stored.RefreshToken = auth.RefreshToken
+	stored.TokenExpiry = auth.TokenExpiry
+	return stored
+}
+
+func openBrowser(target string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	// Best effort, the URL is printed anyway
+	if cmd.Start() == nil {
+		go cmd.Wait()
+	}
+}
+
+/*
+OAuthTokens
+Inspect and clear the OAuth 2.0 token cache
+*/
+func (app *App) OAuthTokens() error {
+
+	const clearAll = "Clear all tokens"
+
+	tokens, err := core.Tokens.List()
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	var lines []string
+	options := make([]string, 0, len(tokens)+3)
+	labelToKey := make(map[string]string, len(tokens))
+	for _, t := range tokens {
+		expiry := "no expiry"
+		switch {
+		case t.AccessToken == "":
+			expiry = "invalidated"
+		case !t.Expiry.IsZero() && t.Expired():
+			expiry = "expired " + t.Expiry.Format(time.RFC3339)
+		case !t.Expiry.IsZero():
+			expiry = "expires " + t.Expiry.Format(time.RFC3339)
+		}
+		lines = append(lines, fmt.Sprintf("[%s] %s @ %s", t.Key, t.ClientID, t.TokenURL))
+		lines = append(lines, "    "+expiry)
+		if t.RefreshToken != "" {
+			lines = append(lines, "    refresh token available")
+		}
+		label := "Clear " + t.Key + " (" + t.ClientID + ")"
+		options = append(options, label)
+		labelToKey[label] = t.Key
+	}
+	if len(tokens) == 0 {
+		lines = append(lines, "No cached token")
+	} else {
+		options = append(options, clearAll)
+	}
+	options = append(options, SigBackHome, SigExit)
+
+	core.DrawBox("OAuth tokens", lines)
+
+	var choice string
+	err = survey.AskOne(&survey.Select{Message: "Select :", Options: options}, &choice)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch choice {
+	case SigBackHome, SigExit:
+		app.SigChan <- Signal{Sig: choice}
+		return nil
+	case clearAll:
+		err = core.Tokens.Clear("")
+	default:
+		err = core.Tokens.Clear(labelToKey[choice])
+	}
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	fmt.Println(color.Green.Render("Token cache updated"))
+	app.SigChan <- Signal{Sig: SigTokens}
+	return nil
+}
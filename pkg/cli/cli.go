@@ -14,19 +14,22 @@ import (
 )
 
 const (
-	SigHome        = "Home"
-	SigBackHome    = "Back to Home Menu"
-	SigBrowse      = "Browse requests"
+	SigHome         = "Home"
+	SigBackHome     = "Back to Home Menu"
+	SigBrowse       = "Browse requests"
 	SigBackRequests = "Back to requests"
-	SigExit        = "Exit"
-	SigCreate      = "Create request"
-	SigRun         = "Run"
-	SigReqSelect   = "reqSelect"
-	SigReqCreate   = "reqCreate"
-	SigEdit        = "Edit"
-	SigDelete      = "Delete"
-	SigCurl        = "cURL"
-	SigAbout       = "About"
+	SigExit         = "Exit"
+	SigCreate       = "Create request"
+	SigRun          = "Run"
+	SigReqSelect    = "reqSelect"
+	SigReqCreate    = "reqCreate"
+	SigEdit         = "Edit"
+	SigDelete       = "Delete"
+	SigCode         = "Export as code"
+	SigAbout        = "About"
+	SigTokens       = "OAuth tokens"
+	SigLogin        = "OAuth login"
+	SigProfiles     = "Auth profiles"
 )
 
 var (
@@ -40,8 +43,12 @@ var (
 )
 
 type App struct {
-	SigChan  chan Signal
-	Database *core.Database
+	SigChan   chan Signal
+	Database  *core.Database
+	SortBy    string
+	Global    *core.Database // database used outside of the workspaces
+	HomeDir   string         // directory of the global database, keeps the recent workspaces
+	Workspace string         // active workspace, empty for the global database
 }
 
 type httpResult struct {
@@ -117,6 +124,10 @@ Consumes the channel, and acts accordingly
 */
 func (app *App) Run() {
 
+	if app.Global == nil {
+		app.Global = app.Database
+	}
+	activeWorkspace = app.Workspace
 	Banner()
 	go app.Home()
 
@@ -140,9 +151,9 @@ func (app *App) Run() {
 		case SigReqCreate:
 			Banner()
 			go app.Request(sig.Meta, sig.Display)
-		case SigCurl:
+		case SigCode:
 			Banner()
-			go app.ShowCurl(sig.Meta)
+			go app.ShowCode(sig.Meta)
 		case SigEdit:
 			Banner()
 			go app.Edit(sig.Meta)
@@ -151,6 +162,69 @@ func (app *App) Run() {
 			go app.Delete(sig.Meta)
 		case SigAbout:
 			go app.About()
+		case SigTokens:
+			Banner()
+			go app.OAuthTokens()
+		case SigLogin:
+			Banner()
+			go app.Login(sig.Meta)
+		case SigProfiles:
+			Banner()
+			go app.AuthProfiles()
+		case SigProfileSelect:
+			Banner()
+			go app.AuthProfile(sig.Meta)
+		case SigProfileCreate:
+			Banner()
+			go app.SaveAuthProfile(sig.Meta)
+		case SigFolderSelect:
+			Banner()
+			go app.Folder(sig.Meta)
+		case SigFolderEdit:
+			Banner()
+			go app.EditFolder(sig.Meta)
+		case SigSearch:
+			Banner()
+			go app.Search()
+		case SigHistory:
+			Banner()
+			go app.History("")
+		case SigReqHistory:
+			Banner()
+			go app.History(sig.Meta)
+		case SigHistoryEntry:
+			Banner()
+			go app.HistoryEntry(sig.Meta)
+		case SigDuplicate:
+			Banner()
+			go app.Duplicate(sig.Meta)
+		case SigRename:
+			Banner()
+			go app.Rename(sig.Meta)
+		case SigBulk:
+			Banner()
+			go app.Bulk(sig.Meta)
+		case SigRevisions:
+			Banner()
+			go app.Revisions(sig.Meta)
+		case SigRevisionEntry:
+			Banner()
+			go app.Revision(sig.Meta)
+		case SigTrash:
+			Banner()
+			go app.Trash()
+		case SigImportExport:
+			Banner()
+			go app.ImportExport()
+		case SigEnvironments, SigBackEnvironments:
+			Banner()
+			go app.Environments()
+		case SigEnvironmentSelect:
+			Banner()
+			go app.Environment(sig.Meta)
+		case SigWorkspaces:
+			Banner()
+			go app.Workspaces()
 		}
 	}
 }
@@ -164,10 +238,35 @@ func Banner() {
 	fmt.Println(color.Grey.Render(" " + hLine))
 	fmt.Print(string(bannerBytes))
 	fmt.Println(color.Grey.Render(fmt.Sprintf(" version: %v", version)))
+	if activeWorkspace != "" {
+		fmt.Println(color.Grey.Render(fmt.Sprintf(" workspace: %v", workspaceLabel(activeWorkspace))))
+	}
 	fmt.Println(color.Grey.Render(" " + hLine))
 	fmt.Println()
 }
 
+/*
+Version
+Version of the build
+*/
+func Version() string {
+	return version
+}
+
+/*
+sync
+Reload the database when another process (e.g. an MCP server) changed it
+*/
+func (app *App) sync() {
+	reloaded, err := app.Database.Sync()
+	if err != nil {
+		fmt.Println(color.Red.Render("ERROR : " + err.Error()))
+	} else if reloaded {
+		fmt.Println(color.Yellow.Render(" Database reloaded, it was changed by another process"))
+		fmt.Println()
+	}
+}
+
 /*
 Error handler
 */
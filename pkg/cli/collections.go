@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/PierreKieffer/http-tanker/pkg/color"
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const (
+	SigFolderSelect = "folderSelect"
+	SigFolderEdit   = "folderEdit"
+	SigFolderUp     = ".."
+	SigFolderConfig = "Folder defaults"
+	SigSearch       = "Search all requests"
+	SigSortPrefix   = "Sort by : "
+)
+
+/*
+Folder
+Browse a collection folder: subfolders first, then the requests it holds
+*/
+func (app *App) Folder(folder string) error {
+
+	app.sync()
+	folder = core.CleanCollection(folder)
+	subfolders := app.Database.Subfolders(folder)
+	requests := app.Database.FolderRequests(folder)
+	app.Database.SortRequests(requests, app.SortBy)
+
+	options := make([]string, 0, len(subfolders)+len(requests)+5)
+	options = append(options, SigBackHome, SigSearch, SigBulk, SigSortPrefix+app.sortOrder())
+	if folder != "" {
+		options = append(options, SigFolderUp, SigFolderConfig)
+	}
+	labelToFolder := make(map[string]string, len(subfolders))
+	for _, sub := range subfolders {
+		label := "▸ " + path.Base(sub) + "/"
+		options = append(options, label)
+		labelToFolder[label] = sub
+	}
+	labelToName := make(map[string]string, len(requests))
+	for _, name := range requests {
+		label := requestLabel(app.Database.Data[name], false)
+		options = append(options, label)
+		labelToName[label] = name
+	}
+
+	title := "Requests"
+	if folder != "" {
+		title = "Requests / " + strings.ReplaceAll(folder, "/", " / ")
+	}
+	core.DrawBox(title, nil)
+
+	var choice string
+	err := survey.AskOne(&survey.Select{Message: "Select (type to search) :", Options: options, PageSize: 15, Filter: fuzzyFilter}, &choice)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch {
+	case strings.HasPrefix(choice, SigSortPrefix):
+		app.nextSortOrder()
+		app.SigChan <- Signal{Sig: SigFolderSelect, Meta: folder}
+	case choice == SigFolderUp:
+		parent := path.Dir(folder)
+		if parent == "." {
+			parent = ""
+		}
+		app.SigChan <- Signal{Sig: SigFolderSelect, Meta: parent}
+	case choice == SigBulk:
+		app.SigChan <- Signal{Sig: SigBulk, Meta: folder}
+	case choice == SigFolderConfig:
+		app.SigChan <- Signal{Sig: SigFolderEdit, Meta: folder}
+	case labelToFolder[choice] != "":
+		app.SigChan <- Signal{Sig: SigFolderSelect, Meta: labelToFolder[choice]}
+	case labelToName[choice] != "":
+		app.SigChan <- Signal{Sig: SigReqSelect, Meta: labelToName[choice], Display: true}
+	default:
+		app.SigChan <- Signal{Sig: choice}
+	}
+	return nil
+}
+
+/*
+EditFolder
+Edit the defaults (base URL, headers, auth) inherited by the requests of a folder
+*/
+func (app *App) EditFolder(folder string) error {
+
+	current, ok := app.Database.Folders[folder]
+	if !ok {
+		current = core.Folder{Headers: map[string]interface{}{}}
+	}
+	editorDefault, _ := json.MarshalIndent(current, "", "    ")
+
+	for {
+		content := ""
+		err := survey.AskOne(&survey.Editor{
+			Message:       "Defaults of " + folder + " (baseUrl, headers, auth, authProfile, variables) :",
+			FileName:      "http-tanker-folder*.json",
+			Default:       string(editorDefault),
+			AppendDefault: true,
+			HideDefault:   true,
+		}, &content)
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+
+		var updated core.Folder
+		if err := json.Unmarshal([]byte(content), &updated); err != nil {
+			fmt.Println(color.Red.Render(fmt.Sprintf("Invalid JSON: %v", err.Error())))
+			editorDefault = []byte(content)
+			continue
+		}
+		if _, ok := app.Database.Profiles[updated.AuthProfile]; updated.AuthProfile != "" && !ok {
+			fmt.Println(color.Red.Render(fmt.Sprintf("Auth profile %q not found", updated.AuthProfile)))
+			editorDefault = []byte(content)
+			continue
+		}
+
+		if err := app.Database.SaveFolder(folder, updated); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+
+		fmt.Println(color.Green.Render("The defaults of " + folder + " have been saved"))
+		fmt.Println()
+		app.SigChan <- Signal{Sig: SigFolderSelect, Meta: folder}
+		return nil
+	}
+}
+
+/*
+Search
+Flat list of every saved request, filtered as you type with a fuzzy match on
+the name, URL, folder and tags
+*/
+func (app *App) Search() error {
+
+	app.sync()
+	names := app.Database.Search("", "", app.SortBy)
+
+	options := make([]string, 0, len(names)+2)
+	options = append(options, SigBackRequests, SigSortPrefix+app.sortOrder())
+	labelToName := make(map[string]string, len(names))
+	for _, name := range names {
+		label := requestLabel(app.Database.Data[name], true)
+		options = append(options, label)
+		labelToName[label] = name
+	}
+
+	lines := []string{fmt.Sprintf("%d request(s)", len(names))}
+	if tags := app.Database.Tags(); len(tags) > 0 {
+		lines = append(lines, "Tags : #"+strings.Join(tags, " #"))
+	}
+	core.DrawBox("Search requests", lines)
+
+	var choice string
+	err := survey.AskOne(&survey.Select{Message: "Search :", Options: options, PageSize: 15, Filter: fuzzyFilter}, &choice)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch {
+	case strings.HasPrefix(choice, SigSortPrefix):
+		app.nextSortOrder()
+		app.SigChan <- Signal{Sig: SigSearch}
+	case labelToName[choice] != "":
+		app.SigChan <- Signal{Sig: SigReqSelect, Meta: labelToName[choice], Display: true}
+	default:
+		app.SigChan <- Signal{Sig: choice}
+	}
+	return nil
+}
+
+func requestLabel(r core.Request, withFolder bool) string {
+	label := "[" + r.Method + "] " + r.Name
+	if folder := core.CleanCollection(r.Collection); withFolder && folder != "" {
+		label = "[" + r.Method + "] " + folder + "/" + r.Name
+	}
+	label += " - " + r.URL
+	if len(r.Tags) > 0 {
+		label += " #" + strings.Join(r.Tags, " #")
+	}
+	return label
+}
+
+// Navigation entries are hidden while typing, requests and folders are fuzzy matched
+func fuzzyFilter(filter string, value string, index int) bool {
+	switch {
+	case filter == "":
+		return true
+	case value == SigBackHome, value == SigBackRequests, value == SigSearch, strings.HasPrefix(value, SigSortPrefix):
+		return false
+	}
+	return core.FuzzyScore(filter, value) >= 0
+}
+
+func (app *App) sortOrder() string {
+	if app.SortBy == "" {
+		return core.SortByName
+	}
+	return app.SortBy
+}
+
+func (app *App) nextSortOrder() {
+	for i, order := range core.SortOrders {
+		if order == app.sortOrder() {
+			app.SortBy = core.SortOrders[(i+1)%len(core.SortOrders)]
+			return
+		}
+	}
+}
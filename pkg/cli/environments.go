@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/PierreKieffer/http-tanker/pkg/color"
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const (
+	SigEnvironments      = "Environments"
+	SigEnvironmentSelect = "environmentSelect"
+	SigEnvironmentCreate = "Create environment"
+	SigEnvironmentUse    = "Activate"
+	SigEnvironmentOff    = "Deactivate"
+	SigEnvironmentEdit   = "Edit variables"
+	SigEnvironmentDelete = "Delete environment"
+	SigEnvironmentExport = "Export as Postman environment"
+	SigBackEnvironments  = "Back to environments"
+)
+
+/*
+Environments
+Display the environments, the active one providing the {{variables}} of the requests
+*/
+func (app *App) Environments() error {
+
+	app.sync()
+	names := app.Database.EnvironmentNames()
+
+	var lines []string
+	options := make([]string, 0, len(names)+3)
+	options = append(options, SigEnvironmentCreate)
+	labelToName := make(map[string]string, len(names))
+	for _, name := range names {
+		label := fmt.Sprintf("%s - %d variable(s)", name, len(app.Database.Environments[name].Variables))
+		if name == app.Database.Environment {
+			label += " (active)"
+		}
+		options = append(options, label)
+		labelToName[label] = name
+	}
+	if len(names) == 0 {
+		lines = append(lines, "No environment")
+	}
+	options = append(options, SigBackHome, SigExit)
+
+	core.DrawBox("Environments", lines)
+
+	var choice string
+	err := survey.AskOne(&survey.Select{Message: "Select :", Options: options}, &choice)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	if name, ok := labelToName[choice]; ok {
+		app.SigChan <- Signal{Sig: SigEnvironmentSelect, Meta: name}
+		return nil
+	}
+	if choice == SigEnvironmentCreate {
+		return app.EditEnvironment("")
+	}
+	app.SigChan <- Signal{Sig: choice}
+	return nil
+}
+
+/*
+Environment
+Display the variables of an environment and its options
+*/
+func (app *App) Environment(name string) error {
+
+	env, ok := app.Database.Environments[name]
+	if !ok {
+		app.SigChan <- Signal{Sig: SigEnvironments}
+		return nil
+	}
+	lines := []string{"Name     : " + name}
+	keys := make([]string, 0, len(env.Variables))
+	for k := range env.Variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		lines = append(lines, "  "+k+" = "+env.Variables[k])
+	}
+	core.DrawBox("Environment details", lines)
+
+	toggle := SigEnvironmentUse
+	if app.Database.Environment == name {
+		toggle = SigEnvironmentOff
+	}
+	var choice string
+	err := survey.AskOne(&survey.Select{
+		Options: []string{toggle, SigEnvironmentEdit, SigEnvironmentExport, SigEnvironmentDelete, SigBackEnvironments, SigBackHome},
+	}, &choice)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch choice {
+	case SigEnvironmentUse, SigEnvironmentOff:
+		active := name
+		if choice == SigEnvironmentOff {
+			active = ""
+		}
+		if err := app.Database.UseEnvironment(active); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		app.SigChan <- Signal{Sig: SigEnvironments}
+	case SigEnvironmentEdit:
+		return app.EditEnvironment(name)
+	case SigEnvironmentExport:
+		data, err := app.Database.ExportPostmanEnvironment(name)
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		path, err := askExportPath(name + ".postman_environment.json")
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			fmt.Println(color.Red.Render("ERROR : " + err.Error()))
+		} else {
+			fmt.Println(color.Green.Render("Environment exported to " + path))
+		}
+		app.SigChan <- Signal{Sig: SigEnvironmentSelect, Meta: name}
+	case SigEnvironmentDelete:
+		confirm := false
+		if err := survey.AskOne(&survey.Confirm{Message: "Delete the environment " + name + " ?"}, &confirm); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		if confirm {
+			if err := app.Database.DeleteEnvironment(name); err != nil {
+				app.ErrorHandler(err)
+				return err
+			}
+		}
+		app.SigChan <- Signal{Sig: SigEnvironments}
+	default:
+		app.SigChan <- Signal{Sig: choice}
+	}
+	return nil
+}
+
+/*
+EditEnvironment
+Create or edit the variables of an environment as a JSON object
+*/
+func (app *App) EditEnvironment(name string) error {
+
+	creating := name == ""
+	if creating {
+		err := survey.AskOne(&survey.Input{Message: "Name : "}, &name, survey.WithValidator(survey.Required))
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		if _, exists := app.Database.Environments[name]; exists {
+			fmt.Println(color.Red.Render(fmt.Sprintf("An environment named %q already exists", name)))
+			creating = false
+		}
+	}
+
+	variables := app.Database.Environments[name].Variables
+	if variables == nil {
+		variables = map[string]string{"baseUrl": "http://localhost:8080"}
+	}
+	editorDefault, _ := json.MarshalIndent(variables, "", "  ")
+
+	for {
+		content := ""
+		err := survey.AskOne(&survey.Editor{
+			Message:       "Variables of " + name + " :",
+			FileName:      "http-tanker-environment*.json",
+			Default:       string(editorDefault),
+			AppendDefault: true,
+			HideDefault:   true,
+		}, &content)
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+
+		var updated map[string]string
+		if err := json.Unmarshal([]byte(content), &updated); err != nil {
+			fmt.Println(color.Red.Render(fmt.Sprintf("Invalid JSON, expected string values: %v", err.Error())))
+			editorDefault = []byte(content)
+			continue
+		}
+		if err := app.Database.SaveEnvironment(name, core.Environment{Variables: updated}); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		break
+	}
+	if creating && app.Database.Environment == "" {
+		app.Database.UseEnvironment(name)
+	}
+	fmt.Println(color.Green.Render("The environment " + name + " has been saved"))
+	app.SigChan <- Signal{Sig: SigEnvironmentSelect, Meta: name}
+	return nil
+}
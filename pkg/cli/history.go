@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/PierreKieffer/http-tanker/pkg/color"
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const (
+	SigHistory        = "History"
+	SigReqHistory     = "Run history"
+	SigHistoryEntry   = "historyEntry"
+	SigHistoryRerun   = "Re-run"
+	SigHistoryInspect = "Inspect in editor"
+	SigHistoryClear   = "Clear history"
+	SigBackHistory    = "Back to history"
+)
+
+// Number of executions listed by the history browser
+const historyPageSize = 200
+
+/*
+History
+Browse the recorded executions, most recent first, of every request or of
+a single request when reqName is set
+*/
+func (app *App) History(reqName string) error {
+
+	entries, err := app.Database.ListHistory(reqName, historyPageSize)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	options := make([]string, 0, len(entries)+3)
+	if reqName != "" {
+		options = append(options, "Back to "+reqName+" request")
+	}
+	options = append(options, SigBackHome)
+	labelToID := make(map[string]string, len(entries))
+	for i, entry := range entries {
+		// Numbered, two executions can share the same second and status
+		label := fmt.Sprintf("%d. %s", i+1, entry.Summary())
+		options = append(options, label)
+		labelToID[label] = entry.ID
+	}
+	if reqName == "" && len(entries) > 0 {
+		options = append(options, SigHistoryClear)
+	}
+
+	title := "History"
+	if reqName != "" {
+		title = "History of " + reqName
+	}
+	var lines []string
+	if len(entries) == 0 {
+		lines = append(lines, "No execution recorded")
+	}
+	core.DrawBox(title, lines)
+
+	var choice string
+	err = survey.AskOne(&survey.Select{Message: "Select :", Options: options, PageSize: 15, Filter: fuzzyFilter}, &choice)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch {
+	case labelToID[choice] != "":
+		app.SigChan <- Signal{Sig: SigHistoryEntry, Meta: labelToID[choice]}
+	case choice == SigHistoryClear:
+		confirm := false
+		if err := survey.AskOne(&survey.Confirm{Message: "This will delete every recorded execution. Continue ?"}, &confirm); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		if confirm {
+			if err := app.Database.ClearHistory(); err != nil {
+				app.ErrorHandler(err)
+				return err
+			}
+		}
+		app.SigChan <- Signal{Sig: SigHistory}
+	case choice == SigBackHome:
+		app.SigChan <- Signal{Sig: choice}
+	default:
+		app.SigChan <- Signal{Sig: SigReqSelect, Meta: reqName, Display: true}
+	}
+	return nil
+}
+
+/*
+HistoryEntry
+Display a recorded execution, to inspect it or send it again as it was sent
+*/
+func (app *App) HistoryEntry(id string) error {
+
+	entry, err := app.Database.GetHistory(id)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	r := entry.Request
+	lines := []string{
+		"Date   : " + entry.Time.Local().Format("2006-01-02 15:04:05"),
+		"Method : " + color.MethodStyle(r.Method).Render(r.Method),
+		"URL    : " + r.URL,
+	}
+	if entry.Name != "" {
+		lines = append([]string{"Name   : " + entry.Name}, lines...)
+	}
+	if entry.Error != "" {
+		lines = append(lines, "Error  : "+color.Red.Render(entry.Error))
+	}
+	core.DrawBox("Execution", lines)
+	if entry.Response != nil {
+		core.DisplayResponse(*entry.Response)
+		if entry.BodyTruncated {
+			fmt.Println(color.Yellow.Render(" Body truncated in the history"))
+			fmt.Println()
+		}
+	}
+
+	options := []string{SigHistoryRerun, SigHistoryInspect, SigBackHistory, SigBackHome}
+	if _, saved := app.Database.Data[entry.Name]; saved {
+		options = append(options[:3], "Back to "+entry.Name+" request", SigBackHome)
+	}
+	var choice string
+	if err := survey.AskOne(&survey.Select{Options: options}, &choice); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch choice {
+	case SigHistoryRerun:
+		r, err = app.Database.HistoryRequest(entry)
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		// Failures are recorded too, the new entry shows the error
+		if response, err := app.execute(entry.Name, r); err == nil {
+			response.Cleanup()
+		}
+		latest, err := app.Database.ListHistory(entry.Name, 1)
+		if err != nil || len(latest) == 0 {
+			app.SigChan <- Signal{Sig: SigHistory}
+			return err
+		}
+		app.SigChan <- Signal{Sig: SigHistoryEntry, Meta: latest[0].ID}
+	case SigHistoryInspect:
+		content, _ := json.MarshalIndent(entry, "", "    ")
+		var ignored string
+		err := survey.AskOne(&survey.Editor{
+			FileName:      "http-tanker-history*.json",
+			Default:       string(content),
+			AppendDefault: true,
+			HideDefault:   true,
+		}, &ignored)
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		app.SigChan <- Signal{Sig: SigHistoryEntry, Meta: id}
+	case SigBackHistory:
+		app.SigChan <- Signal{Sig: SigHistory}
+	case SigBackHome:
+		app.SigChan <- Signal{Sig: choice}
+	default:
+		if strings.HasPrefix(choice, "Back to ") {
+			app.SigChan <- Signal{Sig: SigReqSelect, Meta: entry.Name, Display: true}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,419 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/PierreKieffer/http-tanker/pkg/color"
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const (
+	SigImportExport  = "Import / export"
+	SigImportCurl    = "Import from cURL"
+	SigImportPostman = "Import Postman collection or environment"
+	SigImportOpenAPI = "Import OpenAPI / Swagger spec"
+	SigImportHAR     = "Import HAR file"
+	SigExportHAR     = "Export history as HAR"
+	SigExportPostman = "Export as Postman collection"
+	SigImportHTTP    = "Import .http / .rest file"
+	SigExportHTTP    = "Export as .http file"
+)
+
+/*
+ImportExport
+Menu of the importers and exporters
+*/
+func (app *App) ImportExport() error {
+
+	core.DrawBox("Import / export", nil)
+	var choice string
+	err := survey.AskOne(&survey.Select{
+		Message: "Select :",
+		Options: []string{SigImportCurl, SigImportPostman, SigImportOpenAPI, SigImportHAR, SigImportHTTP, SigExportPostman, SigExportHTTP, SigExportHAR, SigBackHome, SigExit},
+	}, &choice)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch choice {
+	case SigImportCurl:
+		return app.ImportCurl()
+	case SigImportPostman:
+		return app.ImportFile("Import from Postman", "Collection v2.1 or environment exported from Postman", core.ImportPostman)
+	case SigImportOpenAPI:
+		return app.ImportFile("Import from OpenAPI", "OpenAPI 3 or Swagger 2 spec, JSON or YAML. Re-importing a spec keeps local edits", core.ImportOpenAPI)
+	case SigImportHAR:
+		return app.ImportHAR()
+	case SigImportHTTP:
+		return app.ImportFile("Import .http file", "VS Code REST Client or JetBrains HTTP client file, file variables become folder variables", core.ImportHTTPFile)
+	case SigExportPostman:
+		return app.ExportPostman()
+	case SigExportHTTP:
+		return app.ExportHTTPFile()
+	case SigExportHAR:
+		return app.ExportHAR()
+	}
+	app.SigChan <- Signal{Sig: choice}
+	return nil
+}
+
+/*
+ImportFile
+Read a file with an importer and save its requests, folders and environments
+*/
+func (app *App) ImportFile(title, hint string, importer func(data []byte, collection string) (core.Import, error)) error {
+
+	core.DrawBox(title, []string{hint})
+
+	var imp core.Import
+	for {
+		path := ""
+		if err := survey.AskOne(&survey.Input{Message: "File : "}, &path); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		if strings.TrimSpace(path) == "" {
+			app.SigChan <- Signal{Sig: SigImportExport}
+			return nil
+		}
+		data, err := os.ReadFile(strings.TrimSpace(path))
+		if err == nil {
+			imp, err = importer(data, "")
+		}
+		if err != nil {
+			fmt.Println(color.Red.Render(err.Error()))
+			continue
+		}
+		break
+	}
+
+	lines := []string{
+		fmt.Sprintf("Requests     : %d", len(imp.Requests)),
+		fmt.Sprintf("Folders      : %d", len(imp.Folders)),
+		fmt.Sprintf("Environments : %d", len(imp.Environments)),
+	}
+	for _, warning := range imp.Warnings {
+		lines = append(lines, color.Yellow.Render(warning))
+	}
+	core.DrawBox("Content", lines)
+
+	// Requests remembering their source are merged with their previous import
+	merged := len(imp.Requests) > 0
+	for _, r := range imp.Requests {
+		merged = merged && r.Source != nil
+	}
+	overwrite := false
+	if !merged {
+		err := survey.AskOne(&survey.Confirm{Message: "Replace the existing requests and environments with the same name ?"}, &overwrite)
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+	}
+	report, err := app.Database.SaveImport(imp, overwrite)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	fmt.Println(color.Green.Render(fmt.Sprintf("%d request(s) imported, %d replaced", len(report.Added), len(report.Updated))))
+	if len(report.Skipped) > 0 {
+		fmt.Println(color.Yellow.Render("Skipped, already existing : " + strings.Join(report.Skipped, ", ")))
+	}
+	if len(report.Kept) > 0 {
+		fmt.Println(color.Yellow.Render("Kept, edited locally : " + strings.Join(report.Kept, ", ")))
+	}
+	if len(report.Removed) > 0 {
+		fmt.Println(color.Yellow.Render("No longer in the source, not deleted : " + strings.Join(report.Removed, ", ")))
+	}
+	app.SigChan <- Signal{Sig: SigBrowse}
+	return nil
+}
+
+/*
+ExportPostman
+Write a folder, or every request, as a Postman collection v2.1
+*/
+func (app *App) ExportPostman() error {
+
+	collection, err := app.askExportFolder()
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	data, warnings, err := app.Database.ExportPostman(collection)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Println(color.Yellow.Render(" " + warning))
+	}
+	name := "http-tanker"
+	if collection != "" {
+		name = strings.ReplaceAll(collection, "/", "-")
+	}
+	path, err := askExportPath(name + ".postman_collection.json")
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		fmt.Println(color.Red.Render("ERROR : " + err.Error()))
+	} else {
+		fmt.Println(color.Green.Render("Collection exported to " + path))
+	}
+	app.SigChan <- Signal{Sig: SigImportExport}
+	return nil
+}
+
+/*
+ExportHTTPFile
+Write a folder, or every request, as a .http file
+*/
+func (app *App) ExportHTTPFile() error {
+
+	collection, err := app.askExportFolder()
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	data, warnings, err := app.Database.ExportHTTPFile(collection)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Println(color.Yellow.Render(" " + warning))
+	}
+	name := "http-tanker"
+	if collection != "" {
+		name = strings.ReplaceAll(collection, "/", "-")
+	}
+	path, err := askExportPath(name + ".http")
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		fmt.Println(color.Red.Render("ERROR : " + err.Error()))
+	} else {
+		fmt.Println(color.Green.Render("Requests exported to " + path))
+	}
+	app.SigChan <- Signal{Sig: SigImportExport}
+	return nil
+}
+
+/*
+ImportHAR
+Ask the filters, then import the matching entries of a HAR file
+*/
+func (app *App) ImportHAR() error {
+
+	core.DrawBox("Import from HAR", []string{"Filters are comma separated lists, leave empty to import everything"})
+	answers := struct {
+		Domains      string
+		Methods      string
+		ContentTypes string
+	}{}
+	err := survey.Ask([]*survey.Question{
+		{Name: "domains", Prompt: &survey.Input{Message: "Domains (e.g. api.example.com) : "}},
+		{Name: "methods", Prompt: &survey.Input{Message: "Methods (e.g. GET,POST) : "}},
+		{Name: "contentTypes", Prompt: &survey.Input{Message: "Response content types (e.g. application/json) : ", Default: "application/json"}},
+	}, &answers)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	filter := core.HARFilter{
+		Domains:      splitList(answers.Domains),
+		Methods:      splitList(answers.Methods),
+		ContentTypes: splitList(answers.ContentTypes),
+	}
+	return app.ImportFile("Import from HAR", "HAR file saved from the browser devtools", func(data []byte, collection string) (core.Import, error) {
+		return core.ImportHAR(data, collection, filter)
+	})
+}
+
+/*
+ExportHAR
+Write the recorded executions, with their timings, as a HAR 1.2 file
+*/
+func (app *App) ExportHAR() error {
+
+	answers := struct {
+		Name  string
+		Limit int
+	}{}
+	err := survey.Ask([]*survey.Question{
+		{Name: "name", Prompt: &survey.Input{Message: "Request (default = every execution) : ", Suggest: func(toComplete string) []string {
+			var matches []string
+			for name := range app.Database.Data {
+				if strings.HasPrefix(name, toComplete) {
+					matches = append(matches, name)
+				}
+			}
+			return matches
+		}}},
+		{Name: "limit", Prompt: &survey.Input{Message: "Most recent executions : ", Default: "100"}},
+	}, &answers)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	entries, err := app.Database.ListHistory(answers.Name, answers.Limit)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	data, err := core.ExportHAR(entries, version)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	path, err := askExportPath("http-tanker.har")
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		fmt.Println(color.Red.Render("ERROR : " + err.Error()))
+	} else {
+		fmt.Println(color.Green.Render(fmt.Sprintf("%d execution(s) exported to %s", len(entries), path)))
+	}
+	app.SigChan <- Signal{Sig: SigImportExport}
+	return nil
+}
+
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// askExportFolder returns the folder to export, "" for every request
+func (app *App) askExportFolder() (string, error) {
+	collections := app.Database.Collections()
+	if len(collections) == 0 {
+		return "", nil
+	}
+	options := append([]string{"All requests"}, collections...)
+	choice := ""
+	if err := survey.AskOne(&survey.Select{Message: "Folder :", Options: options}, &choice); err != nil {
+		return "", err
+	}
+	if choice == options[0] {
+		return "", nil
+	}
+	return choice, nil
+}
+
+func askExportPath(defaultPath string) (string, error) {
+	path := ""
+	err := survey.AskOne(&survey.Input{Message: "Export to file : ", Default: defaultPath}, &path)
+	return path, err
+}
+
+/*
+ImportCurl
+Create a request from a curl command pasted in the editor
+*/
+func (app *App) ImportCurl() error {
+
+	core.DrawBox("Import from cURL", []string{"Paste a curl command, multi-line commands are supported"})
+
+	var r core.Request
+	content := ""
+	for {
+		err := survey.AskOne(&survey.Editor{
+			Message:       "curl command :",
+			FileName:      "http-tanker-curl*.sh",
+			Default:       content,
+			AppendDefault: true,
+			HideDefault:   true,
+		}, &content)
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		if strings.TrimSpace(content) == "" {
+			app.SigChan <- Signal{Sig: SigImportExport}
+			return nil
+		}
+
+		var warnings []string
+		r, warnings, err = core.ParseCurlWith(content, core.CurlImportOptions{ReadFiles: true})
+		if err != nil {
+			fmt.Println(color.Red.Render(err.Error()))
+			continue
+		}
+		for _, warning := range warnings {
+			fmt.Println(color.Yellow.Render(" " + warning))
+		}
+		break
+	}
+
+	lines := []string{
+		"Method : " + color.MethodStyle(r.Method).Render(r.Method),
+		"URL    : " + r.URL,
+	}
+	if r.Auth != nil {
+		lines = append(lines, core.AuthLines(r.Auth)...)
+	}
+	core.DrawBox("Parsed request", lines)
+
+	name := ""
+	for {
+		err := survey.AskOne(&survey.Input{
+			Message: "Name : ",
+			Default: core.DefaultRequestName(r.Method, r.URL),
+		}, &name, survey.WithValidator(survey.Required))
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		if _, exists := app.Database.Data[name]; !exists {
+			break
+		}
+		fmt.Println(color.Red.Render(fmt.Sprintf("A request named %q already exists", name)))
+	}
+	r.Name = name
+
+	collection := ""
+	collections := app.Database.Collections()
+	err := survey.AskOne(&survey.Input{
+		Message: "Folder (e.g. shop/orders, default = none) : ",
+		Suggest: func(toComplete string) []string {
+			var matches []string
+			for _, c := range collections {
+				if strings.HasPrefix(c, toComplete) {
+					matches = append(matches, c)
+				}
+			}
+			return matches
+		},
+	}, &collection)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	r.Collection = core.CleanCollection(collection)
+
+	if err := app.Database.Add(r); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	fmt.Println(color.Green.Render("The request " + name + " has been imported"))
+	app.SigChan <- Signal{Sig: SigReqCreate, Meta: name, Display: true}
+	return nil
+}
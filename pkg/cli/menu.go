@@ -2,24 +2,43 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/PierreKieffer/http-tanker/pkg/color"
 	"github.com/PierreKieffer/http-tanker/pkg/core"
 )
 
+const (
+	SigDuplicate  = "Duplicate"
+	SigRename     = "Rename"
+	SigBulk       = "Bulk actions"
+	SigBulkDelete = "Delete selected"
+	SigBulkMove   = "Move selected to a folder"
+	SigBulkTag    = "Tag selected"
+	SigBulkUntag  = "Untag selected"
+	SigBulkExport = "Export selected"
+	SigBulkRun    = "Run selected"
+)
+
 /*
 Home
 Display Home menu options
 */
 func (app *App) Home() error {
 
-	core.DrawBox("Home Menu", nil)
+	var lines []string
+	if app.Database.Environment != "" {
+		lines = append(lines, "Environment : "+app.Database.Environment)
+	}
+	core.DrawBox("Home Menu", lines)
 	var menu = []*survey.Question{
 		{
 			Name: "home",
 			Prompt: &survey.Select{
 				Message: "Select :",
-				Options: []string{SigBrowse, SigCreate, SigAbout, SigExit},
+				Options: []string{SigBrowse, SigCreate, SigImportExport, SigHistory, SigEnvironments, SigProfiles, SigTokens, SigTrash, SigWorkspaces, SigAbout, SigExit},
 			},
 			Validate: survey.Required,
 		},
@@ -48,56 +67,10 @@ func (app *App) Home() error {
 
 /*
 Requests
-Display all available requests previously created by the user
+Display the requests previously created by the user, starting from the root folder
 */
 func (app *App) Requests() error {
-
-	reqList := make([]string, 0, len(app.Database.Data)+1)
-	reqList = append(reqList, SigBackHome)
-	displayToName := make(map[string]string, len(app.Database.Data))
-	for name, r := range app.Database.Data {
-		label := "[" + r.Method + "] " + name + " - " + r.URL
-		reqList = append(reqList, label)
-		displayToName[label] = name
-	}
-
-	core.DrawBox("Requests", nil)
-	var menu = []*survey.Question{
-		{
-			Name: "requests",
-			Prompt: &survey.Select{
-				Message: "Select :",
-				Options: reqList,
-			},
-			Validate: survey.Required,
-		},
-	}
-
-	answers := struct {
-		Requests string
-	}{}
-
-	err := survey.Ask(menu, &answers)
-
-	if err != nil {
-		app.ErrorHandler(err)
-		return err
-	}
-
-	selected := answers.Requests
-	if name, ok := displayToName[selected]; ok {
-		selected = name
-	}
-
-	sig := Signal{
-		Sig:     SigReqSelect,
-		Meta:    selected,
-		Display: true,
-	}
-
-	app.SigChan <- sig
-
-	return nil
+	return app.Folder("")
 }
 
 /*
@@ -106,11 +79,23 @@ Display options after selecting a request
 */
 func (app *App) Request(reqName string, display bool) error {
 
+	app.sync()
+	if _, ok := app.Database.Data[reqName]; !ok {
+		err := fmt.Errorf("request %s not found, it may have been deleted by another process", reqName)
+		app.ErrorHandler(err)
+		return err
+	}
+
+	options := []string{SigRun, SigCode, SigReqHistory, SigEdit, SigDuplicate, SigRename, SigRevisions, SigDelete, SigBackRequests, SigExit}
+	if r, err := app.Database.Resolve(reqName); err == nil && r.Auth != nil && r.Auth.Type == "oauth2" && r.Auth.GrantType == "authorization_code" {
+		options = append([]string{SigRun, SigLogin}, options[1:]...)
+	}
+
 	var menu = []*survey.Question{
 		{
 			Name: "request",
 			Prompt: &survey.Select{
-				Options: []string{SigRun, SigCurl, SigEdit, SigDelete, SigBackRequests, SigExit},
+				Options: options,
 			},
 			Validate: survey.Required,
 		},
@@ -135,6 +120,9 @@ func (app *App) Request(reqName string, display bool) error {
 		Meta: reqName,
 		Sig:  answers.Request,
 	}
+	if collection := app.Database.Data[reqName].Collection; sig.Sig == SigBackRequests && collection != "" {
+		sig = Signal{Sig: SigFolderSelect, Meta: collection}
+	}
 
 	app.SigChan <- sig
 
@@ -178,3 +166,215 @@ func (app *App) About() error {
 	app.SigChan <- sig
 	return nil
 }
+
+/*
+Duplicate
+Save a copy of a request under a new name
+*/
+func (app *App) Duplicate(reqName string) error {
+
+	newName := ""
+	err := survey.AskOne(&survey.Input{
+		Message: "Name of the copy : ",
+		Default: app.Database.CopyName(reqName),
+	}, &newName, survey.WithValidator(survey.Required))
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	if err := app.Database.Duplicate(reqName, newName); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	fmt.Println(color.Green.Render("The request " + reqName + " has been duplicated as " + newName))
+	app.SigChan <- Signal{Sig: SigReqCreate, Meta: newName, Display: true}
+	return nil
+}
+
+/*
+Rename
+Rename a request, another request is never overwritten
+*/
+func (app *App) Rename(reqName string) error {
+
+	for {
+		newName := ""
+		err := survey.AskOne(&survey.Input{
+			Message: "New name : ",
+			Default: reqName,
+		}, &newName, survey.WithValidator(survey.Required))
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		if newName == reqName {
+			app.SigChan <- Signal{Sig: SigReqSelect, Meta: reqName, Display: true}
+			return nil
+		}
+
+		if err := app.Database.Rename(reqName, newName); err != nil {
+			fmt.Println(color.Red.Render(err.Error()))
+			continue
+		}
+		fmt.Println(color.Green.Render("The request " + reqName + " has been renamed to " + newName))
+		app.SigChan <- Signal{Sig: SigReqCreate, Meta: newName, Display: true}
+		return nil
+	}
+}
+
+/*
+Bulk
+Apply an action to several requests of a folder and its subfolders
+*/
+func (app *App) Bulk(folder string) error {
+
+	app.sync()
+	var names []string
+	for _, name := range app.Database.Search("", "", app.SortBy) {
+		if core.InCollection(app.Database.Data[name].Collection, folder) {
+			names = append(names, name)
+		}
+	}
+	labels := make([]string, 0, len(names))
+	labelToName := make(map[string]string, len(names))
+	for _, name := range names {
+		label := requestLabel(app.Database.Data[name], true)
+		labels = append(labels, label)
+		labelToName[label] = name
+	}
+
+	var selected []string
+	err := survey.AskOne(&survey.MultiSelect{
+		Message:  "Select requests (space to select, type to filter) :",
+		Options:  labels,
+		PageSize: 15,
+		Filter:   fuzzyFilter,
+	}, &selected)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	back := Signal{Sig: SigFolderSelect, Meta: folder}
+	if len(selected) == 0 {
+		app.SigChan <- back
+		return nil
+	}
+	selection := make([]string, 0, len(selected))
+	for _, label := range selected {
+		selection = append(selection, labelToName[label])
+	}
+
+	var action string
+	err = survey.AskOne(&survey.Select{
+		Message: fmt.Sprintf("%d request(s) selected :", len(selection)),
+		Options: []string{SigBulkRun, SigBulkMove, SigBulkTag, SigBulkUntag, SigBulkExport, SigBulkDelete, SigBackRequests},
+	}, &action)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch action {
+	case SigBulkRun:
+		app.runAll(selection)
+	case SigBulkMove:
+		collection := ""
+		collections := app.Database.Collections()
+		err = survey.AskOne(&survey.Input{
+			Message: "Folder (empty = root) : ",
+			Suggest: func(toComplete string) []string {
+				var matches []string
+				for _, c := range collections {
+					if strings.HasPrefix(c, toComplete) {
+						matches = append(matches, c)
+					}
+				}
+				return matches
+			},
+		}, &collection)
+		if err == nil {
+			err = app.Database.MoveRequests(selection, collection)
+			back.Meta = core.CleanCollection(collection)
+		}
+	case SigBulkTag, SigBulkUntag:
+		tags := ""
+		if err = survey.AskOne(&survey.Input{Message: "Tags (comma separated) : "}, &tags); err == nil {
+			if action == SigBulkTag {
+				err = app.Database.TagRequests(selection, core.ParseTags(tags), nil)
+			} else {
+				err = app.Database.TagRequests(selection, nil, core.ParseTags(tags))
+			}
+		}
+	case SigBulkExport:
+		file := ""
+		err = survey.AskOne(&survey.Input{Message: "Export to file : ", Default: "http-tanker-export.json"}, &file)
+		if err == nil {
+			var content []byte
+			if content, err = app.Database.ExportRequests(selection); err == nil {
+				err = os.WriteFile(file, content, 0600)
+			}
+		}
+	case SigBulkDelete:
+		confirm := false
+		err = survey.AskOne(&survey.Confirm{Message: fmt.Sprintf("This will move %d request(s) to the trash. Continue ?", len(selection))}, &confirm)
+		if err == nil && !confirm {
+			app.SigChan <- back
+			return nil
+		}
+		if err == nil {
+			err = app.Database.DeleteRequests(selection)
+		}
+	default:
+		app.SigChan <- back
+		return nil
+	}
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	if action != SigBulkRun {
+		fmt.Println(color.Green.Render(fmt.Sprintf("%s : done for %d request(s)", action, len(selection))))
+	}
+
+	var choice string
+	if err := survey.AskOne(&survey.Select{Options: []string{SigBackRequests, SigBackHome}}, &choice); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	if choice == SigBackRequests {
+		app.SigChan <- back
+		return nil
+	}
+	app.SigChan <- Signal{Sig: choice}
+	return nil
+}
+
+// runAll sends the requests one after the other and prints a line per request
+func (app *App) runAll(names []string) {
+	failures := 0
+	for _, name := range names {
+		r, err := app.Database.Resolve(name)
+		if err != nil {
+			failures++
+			fmt.Println(color.Red.Render("✗ " + name + " : " + err.Error()))
+			continue
+		}
+		response, err := app.execute(name, r)
+		if err != nil {
+			failures++
+			fmt.Println(color.Red.Render("✗ " + name + " : " + err.Error()))
+			continue
+		}
+		response.Cleanup()
+		line := fmt.Sprintf("%s %s → %s (%d ms)", name, r.Method, response.Status, response.ExecutionTimeMillisec)
+		if response.StatusCode >= 400 {
+			failures++
+			fmt.Println(color.Red.Render("✗ " + line))
+		} else {
+			fmt.Println(color.Green.Render("✓ " + line))
+		}
+	}
+	fmt.Println()
+	fmt.Printf(" %d request(s), %d failure(s)\n\n", len(names), failures)
+}
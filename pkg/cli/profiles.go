@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/PierreKieffer/http-tanker/pkg/color"
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const (
+	SigProfileCreate = "Create auth profile"
+	SigProfileSelect = "profileSelect"
+	SigProfileEdit   = "Edit auth profile"
+	SigProfileDelete = "Delete auth profile"
+	SigBackProfiles  = "Back to auth profiles"
+)
+
+/*
+AuthProfiles
+Display the auth profiles shared by requests
+*/
+func (app *App) AuthProfiles() error {
+
+	app.sync()
+	names := app.Database.ProfileNames()
+
+	var lines []string
+	options := make([]string, 0, len(names)+3)
+	options = append(options, SigProfileCreate)
+	labelToName := make(map[string]string, len(names))
+	for _, name := range names {
+		label := fmt.Sprintf("%s [%s] - used by %d request(s)", name, app.Database.Profiles[name].Type, len(app.Database.ProfileUsers(name)))
+		options = append(options, label)
+		labelToName[label] = name
+	}
+	if len(names) == 0 {
+		lines = append(lines, "No auth profile")
+	}
+	options = append(options, SigBackHome, SigExit)
+
+	core.DrawBox("Auth profiles", lines)
+
+	var choice string
+	err := survey.AskOne(&survey.Select{Message: "Select :", Options: options}, &choice)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	if name, ok := labelToName[choice]; ok {
+		app.SigChan <- Signal{Sig: SigProfileSelect, Meta: name}
+		return nil
+	}
+	app.SigChan <- Signal{Sig: choice}
+	return nil
+}
+
+/*
+AuthProfile
+Display an auth profile and its options
+*/
+func (app *App) AuthProfile(name string) error {
+
+	auth := app.Database.Profiles[name]
+	lines := []string{"Name     : " + name}
+	lines = append(lines, core.AuthLines(&auth)...)
+	if users := app.Database.ProfileUsers(name); len(users) > 0 {
+		lines = append(lines, "Used by  : "+strings.Join(users, ", "))
+	}
+	core.DrawBox("Auth profile details", lines)
+
+	var choice string
+	err := survey.AskOne(&survey.Select{
+		Options: []string{SigProfileEdit, SigProfileDelete, SigBackProfiles, SigBackHome},
+	}, &choice)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch choice {
+	case SigProfileEdit:
+		app.SigChan <- Signal{Sig: SigProfileCreate, Meta: name}
+	case SigProfileDelete:
+		if err := app.Database.DeleteProfile(name); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		fmt.Println(color.Green.Render("The auth profile " + name + " was successfully deleted"))
+		fmt.Println()
+		app.SigChan <- Signal{Sig: SigProfiles}
+	case SigBackProfiles:
+		app.SigChan <- Signal{Sig: SigProfiles}
+	default:
+		app.SigChan <- Signal{Sig: choice}
+	}
+	return nil
+}
+
+/*
+SaveAuthProfile
+Auth profile creation workflow, or edition when name is set
+*/
+func (app *App) SaveAuthProfile(name string) error {
+
+	if name == "" {
+		err := survey.AskOne(&survey.Input{Message: "Profile name :"}, &name, survey.WithValidator(survey.Required))
+		if err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		if _, exists := app.Database.Profiles[name]; exists {
+			err := fmt.Errorf("auth profile %q already exists", name)
+			app.ErrorHandler(err)
+			return err
+		}
+	}
+
+	auth, err := askAuthConfig("")
+	if err == nil && auth == nil {
+		err = fmt.Errorf("no authentication selected")
+	}
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	if err := app.Database.SaveProfile(name, *auth); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	fmt.Println(color.Green.Render("The auth profile " + name + " has been saved"))
+	fmt.Println()
+	app.SigChan <- Signal{Sig: SigProfileSelect, Meta: name}
+	return nil
+}
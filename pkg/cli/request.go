@@ -24,25 +24,16 @@ RunRequest
 Execute HTTP request, display response
 */
 func (app *App) RunRequest(reqName string) error {
-	r := app.Database.Data[reqName]
-
-	s := spinner.New()
-	s.Spinner = spinner.Points
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
-
-	m := spinnerModel{
-		spinner: s,
-		callFn:  r.CallHTTP,
+	r, err := app.Database.Resolve(reqName)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
 	}
-
-	finalModel, teaErr := tea.NewProgram(m).Run()
-	if teaErr != nil {
-		fmt.Println(color.Red.Render("ERROR : " + teaErr.Error()))
-		return teaErr
+	if missing := core.MissingVariables(r); len(missing) > 0 {
+		fmt.Println(color.Yellow.Render(" Undefined variables: " + strings.Join(missing, ", ")))
 	}
 
-	result := finalModel.(spinnerModel).result
-	response, err := result.resp, result.err
+	response, err := app.execute(reqName, r)
 	if err != nil {
 		fmt.Println(color.Red.Render("ERROR : " + err.Error()))
 
@@ -77,6 +68,17 @@ func (app *App) RunRequest(reqName string) error {
 	}
 
 	core.DisplayResponse(response)
+	if len(r.Assertions) > 0 {
+		var lines []string
+		for _, result := range core.CheckAssertions(r.Assertions, response) {
+			if result.Passed {
+				lines = append(lines, color.Green.Render("PASS ")+result.Assertion.String())
+			} else {
+				lines = append(lines, color.Red.Render("FAIL ")+result.Message)
+			}
+		}
+		core.DrawBox("Assertions", lines)
+	}
 
 	if response.IsBinaryContent() {
 		// Propose to save binary content
@@ -174,14 +176,76 @@ func (app *App) RunRequest(reqName string) error {
 }
 
 /*
-ShowCurl
-Display formatted curl command for a request
+execute
+Run a resolved request behind a spinner and record it in the history
+*/
+func (app *App) execute(reqName string, r core.Request) (core.Response, error) {
+	s := spinner.New()
+	s.Spinner = spinner.Points
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+
+	m := spinnerModel{
+		spinner: s,
+		callFn:  r.CallHTTP,
+	}
+
+	finalModel, teaErr := tea.NewProgram(m).Run()
+	if teaErr != nil {
+		return core.Response{}, teaErr
+	}
+
+	result := finalModel.(spinnerModel).result
+	var recorded *core.Response
+	if result.err == nil {
+		recorded = &result.resp
+	}
+	if _, err := app.Database.RecordHistory(reqName, r, recorded, result.err); err != nil {
+		fmt.Println(color.Red.Render("ERROR : history not recorded : " + err.Error()))
+	}
+	if _, saved := app.Database.Data[reqName]; saved && result.err == nil {
+		if err := app.Database.Touch(reqName); err != nil {
+			fmt.Println(color.Red.Render("ERROR : " + err.Error()))
+		}
+	}
+	return result.resp, result.err
+}
+
+/*
+ShowCode
+Display a request as code of the language picked, cURL by default
 */
-func (app *App) ShowCurl(reqName string) error {
-	r := app.Database.Data[reqName]
-	curlCmd := r.CurlCommand()
+func (app *App) ShowCode(reqName string) error {
+	r, err := app.Database.Resolve(reqName)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	labels := make([]string, len(core.CodeLanguages))
+	for i, l := range core.CodeLanguages {
+		labels[i] = l.Label
+	}
+	choice := 0
+	if err := survey.AskOne(&survey.Select{Message: "Language :", Options: labels}, &choice); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	language := core.CodeLanguages[choice]
+	var code string
+	if language.Name == "curl" {
+		code, err = app.askCurl(r)
+	} else {
+		secrets := 0
+		if err = survey.AskOne(&survey.Select{Message: "Tokens, passwords and API keys :", Options: secretsLabels}, &secrets); err == nil {
+			code, err = r.GenerateCodeWith(language.Name, core.CodeOptions{Secrets: core.SecretModes[secrets]})
+		}
+	}
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
 
-	core.DrawBox("cURL command", []string{curlCmd})
+	core.DrawBox(language.Label, []string{strings.TrimSuffix(code, "\n")})
 
 	var menu = []*survey.Question{
 		{
@@ -197,7 +261,7 @@ func (app *App) ShowCurl(reqName string) error {
 		Back string
 	}{}
 
-	err := survey.Ask(menu, &answers)
+	err = survey.Ask(menu, &answers)
 	if err != nil {
 		app.ErrorHandler(err)
 		return err
@@ -212,6 +276,35 @@ func (app *App) ShowCurl(reqName string) error {
 	return nil
 }
 
+// Labels of core.SecretModes
+var secretsLabels = []string{"In clear", "Redacted (***)", "From environment variables ($TOKEN)"}
+
+/*
+askCurl
+Asks the shell and how to write the secrets of the cURL command
+*/
+func (app *App) askCurl(r core.Request) (string, error) {
+	shells := []string{"Bash / zsh", "PowerShell", "Windows cmd"}
+	answers := struct {
+		Shell      int
+		Secrets    int
+		SingleLine bool
+	}{}
+	questions := []*survey.Question{
+		{Name: "shell", Prompt: &survey.Select{Message: "Shell :", Options: shells}},
+		{Name: "secrets", Prompt: &survey.Select{Message: "Tokens, passwords and API keys :", Options: secretsLabels}},
+		{Name: "singleline", Prompt: &survey.Confirm{Message: "On a single line ?"}},
+	}
+	if err := survey.Ask(questions, &answers); err != nil {
+		return "", err
+	}
+	return r.Curl(core.CurlOptions{
+		Shell:      core.CurlShells[answers.Shell],
+		Secrets:    core.SecretModes[answers.Secrets],
+		SingleLine: answers.SingleLine,
+	})
+}
+
 /*
 Create
 Request creation workflow
@@ -257,6 +350,26 @@ func (app *App) Create() error {
 		return err
 	}
 
+	// Ask for the collection folder, relative URLs inherit its base URL
+	var collection string
+	collections := app.Database.Collections()
+	err = survey.AskOne(&survey.Input{
+		Message: "Folder (e.g. shop/orders, default = none) : ",
+		Suggest: func(toComplete string) []string {
+			var matches []string
+			for _, c := range collections {
+				if strings.HasPrefix(c, toComplete) {
+					matches = append(matches, c)
+				}
+			}
+			return matches
+		},
+	}, &collection)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
 	// Enter request body and headers values
 	var body = []*survey.Question{}
 
@@ -322,57 +435,22 @@ func (app *App) Create() error {
 		return err
 	}
 
-	// Ask for authentication
-	var authConfig *core.AuthConfig
-	authTypeAnswer := ""
-	authTypePrompt := &survey.Select{
-		Message: "Authentication :",
-		Options: []string{"None", "Bearer Token", "Basic Auth", "API Key"},
-		Default: "None",
-	}
-	err = survey.AskOne(authTypePrompt, &authTypeAnswer)
+	// Ask for tags
+	var tagsAnswer string
+	err = survey.AskOne(&survey.Input{
+		Message: "Tags (comma separated, default = none) : ",
+		Help:    "Existing tags : " + strings.Join(app.Database.Tags(), ", "),
+	}, &tagsAnswer)
 	if err != nil {
 		app.ErrorHandler(err)
 		return err
 	}
 
-	switch authTypeAnswer {
-	case "Bearer Token":
-		var token string
-		err = survey.AskOne(&survey.Password{Message: "Token :"}, &token)
-		if err != nil {
-			app.ErrorHandler(err)
-			return err
-		}
-		authConfig = &core.AuthConfig{Type: "bearer", Token: token}
-	case "Basic Auth":
-		var username string
-		err = survey.AskOne(&survey.Input{Message: "Username :"}, &username)
-		if err != nil {
-			app.ErrorHandler(err)
-			return err
-		}
-		var password string
-		err = survey.AskOne(&survey.Password{Message: "Password :"}, &password)
-		if err != nil {
-			app.ErrorHandler(err)
-			return err
-		}
-		authConfig = &core.AuthConfig{Type: "basic", Username: username, Password: password}
-	case "API Key":
-		var header string
-		err = survey.AskOne(&survey.Input{Message: "Header name :", Default: "X-API-Key"}, &header)
-		if err != nil {
-			app.ErrorHandler(err)
-			return err
-		}
-		var key string
-		err = survey.AskOne(&survey.Password{Message: "API Key :"}, &key)
-		if err != nil {
-			app.ErrorHandler(err)
-			return err
-		}
-		authConfig = &core.AuthConfig{Type: "api-key", Key: key, Header: header}
+	// Ask for authentication
+	authConfig, authProfileName, err := askRequestAuth(app.Database.ProfileNames())
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
 	}
 
 	// Ask for headers
@@ -421,11 +499,14 @@ func (app *App) Create() error {
 
 	// Build Request object
 	var R = core.Request{
-		Name:     genericAnswer.Name,
-		Method:   genericAnswer.Method,
-		URL:      genericAnswer.Url,
-		Insecure: insecureAnswer.Insecure,
-		Auth:     authConfig,
+		Name:        genericAnswer.Name,
+		Method:      genericAnswer.Method,
+		URL:         genericAnswer.Url,
+		Insecure:    insecureAnswer.Insecure,
+		Auth:        authConfig,
+		AuthProfile: authProfileName,
+		Collection:  core.CleanCollection(collection),
+		Tags:        core.ParseTags(tagsAnswer),
 	}
 
 	switch R.Method {
@@ -500,13 +581,17 @@ func (app *App) Edit(reqName string) error {
 			editorDefault = []byte(content)
 			continue
 		}
-
-		if updateReq.Name != reqName {
-			delete(app.Database.Data, reqName)
+		if err := core.ValidateAssertions(updateReq.Assertions); err != nil {
+			fmt.Println(color.Red.Render(err.Error()))
+			editorDefault = []byte(content)
+			continue
 		}
-		app.Database.Data[updateReq.Name] = updateReq
-		if err := app.Database.Save(); err != nil {
-			return err
+
+		// Refuses to clobber another request when renamed
+		if err := app.Database.Update(reqName, updateReq); err != nil {
+			fmt.Println(color.Red.Render(err.Error()))
+			editorDefault = []byte(content)
+			continue
 		}
 
 		sig := Signal{
@@ -555,7 +640,7 @@ func (app *App) Delete(reqName string) error {
 			app.ErrorHandler(err)
 			return err
 		}
-		message := "The request " + reqName + " was successfully deleted"
+		message := "The request " + reqName + " was moved to the trash"
 		fmt.Println()
 		fmt.Println(color.Green.Render(message))
 		fmt.Println()
@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/PierreKieffer/http-tanker/pkg/color"
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const (
+	SigRevisions     = "Revisions"
+	SigRevisionEntry = "revisionEntry"
+	SigRollback      = "Roll back to this revision"
+	SigUndo          = "Undo last change"
+	SigBackRevisions = "Back to revisions"
+	SigTrash         = "Trash"
+	SigEmptyTrash    = "Empty trash"
+)
+
+/*
+Revisions
+Browse the revisions of a request, most recent first
+*/
+func (app *App) Revisions(reqName string) error {
+
+	revisions, err := app.Database.Revisions(reqName)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	var lines []string
+	if len(revisions) == 0 {
+		lines = append(lines, "No revision recorded")
+	}
+	core.DrawBox("Revisions of "+reqName, lines)
+
+	options := make([]string, 0, len(revisions)+3)
+	if len(revisions) > 1 {
+		options = append(options, SigUndo)
+	}
+	labelToID := make(map[string]string, len(revisions))
+	for _, rev := range revisions {
+		label := rev.Summary()
+		options = append(options, label)
+		labelToID[label] = rev.ID
+	}
+	options = append(options, "Back to "+reqName+" request", SigBackHome)
+
+	var choice string
+	if err := survey.AskOne(&survey.Select{Message: "Select :", Options: options, PageSize: 15}, &choice); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch {
+	case labelToID[choice] != "":
+		app.SigChan <- Signal{Sig: SigRevisionEntry, Meta: labelToID[choice] + ":" + reqName}
+	case choice == SigUndo:
+		if err := app.Database.Undo(reqName); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		app.SigChan <- Signal{Sig: SigReqSelect, Meta: reqName, Display: true}
+	case choice == SigBackHome:
+		app.SigChan <- Signal{Sig: choice}
+	default:
+		app.SigChan <- Signal{Sig: SigReqSelect, Meta: reqName, Display: true}
+	}
+	return nil
+}
+
+/*
+Revision
+Display the changes between a revision and the current request
+*/
+func (app *App) Revision(meta string) error {
+
+	id, reqName, _ := strings.Cut(meta, ":")
+	rev, err := app.Database.GetRevision(reqName, id)
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	diff, err := app.Database.DiffRevisions(reqName, id, "")
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	core.DrawBox("Revision", []string{rev.Summary()})
+	fmt.Println(color.Grey.Render(" Changes since this revision :"))
+	printDiff(diff)
+
+	var choice string
+	options := []string{SigRollback, SigBackRevisions, SigBackHome}
+	if err := survey.AskOne(&survey.Select{Options: options}, &choice); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch choice {
+	case SigRollback:
+		if err := app.Database.Rollback(reqName, id); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		fmt.Println(color.Green.Render("The request " + reqName + " has been rolled back"))
+		app.SigChan <- Signal{Sig: SigReqSelect, Meta: reqName, Display: true}
+	case SigBackRevisions:
+		app.SigChan <- Signal{Sig: SigRevisions, Meta: reqName}
+	default:
+		app.SigChan <- Signal{Sig: choice}
+	}
+	return nil
+}
+
+func printDiff(diff string) {
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+ "):
+			fmt.Println(color.Green.Render(line))
+		case strings.HasPrefix(line, "- "):
+			fmt.Println(color.Red.Render(line))
+		default:
+			fmt.Println(color.Grey.Render(line))
+		}
+	}
+	fmt.Println()
+}
+
+/*
+Trash
+Restore the deleted requests
+*/
+func (app *App) Trash() error {
+
+	app.sync()
+	trash, err := app.Database.Trash()
+	if err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	var lines []string
+	if len(trash) == 0 {
+		lines = append(lines, "The trash is empty")
+	}
+	core.DrawBox("Trash", lines)
+
+	options := make([]string, 0, len(trash)+3)
+	labelToName := make(map[string]string, len(trash))
+	for _, rev := range trash {
+		label := fmt.Sprintf("%s - deleted %s", rev.Name, rev.Time.Local().Format("2006-01-02 15:04:05"))
+		options = append(options, label)
+		labelToName[label] = rev.Name
+	}
+	if len(trash) > 0 {
+		options = append(options, SigEmptyTrash)
+	}
+	options = append(options, SigBackHome, SigExit)
+
+	var choice string
+	if err := survey.AskOne(&survey.Select{Message: "Select a request to restore :", Options: options, PageSize: 15, Filter: fuzzyFilter}, &choice); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	switch {
+	case labelToName[choice] != "":
+		name := labelToName[choice]
+		if err := app.Database.Restore(name); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		app.SigChan <- Signal{Sig: SigReqCreate, Meta: name, Display: true}
+	case choice == SigEmptyTrash:
+		confirm := false
+		if err := survey.AskOne(&survey.Confirm{Message: "This will permanently delete the requests in the trash. Continue ?"}, &confirm); err != nil {
+			app.ErrorHandler(err)
+			return err
+		}
+		if confirm {
+			if err := app.Database.EmptyTrash(); err != nil {
+				app.ErrorHandler(err)
+				return err
+			}
+		}
+		app.SigChan <- Signal{Sig: SigTrash}
+	default:
+		app.SigChan <- Signal{Sig: choice}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"path/filepath"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const (
+	SigWorkspaces = "Switch workspace"
+	SigGlobalDB   = "Global database"
+)
+
+// Active workspace shown by the Banner, empty for the global database
+var activeWorkspace string
+
+/*
+Workspaces
+Switch between the global database and the recently opened workspaces
+*/
+func (app *App) Workspaces() error {
+
+	recent := core.RecentWorkspaces(app.HomeDir)
+
+	lines := []string{"Active : " + workspaceLabel(app.Workspace)}
+	if len(recent) == 0 {
+		lines = append(lines, "No recent workspace, run `http-tanker init` in a project")
+	}
+	core.DrawBox("Workspaces", lines)
+
+	options := make([]string, 0, len(recent)+3)
+	labelToPath := make(map[string]string, len(recent))
+	if app.Workspace != "" {
+		options = append(options, SigGlobalDB)
+	}
+	for _, w := range recent {
+		if w.Path == app.Workspace {
+			continue
+		}
+		label := filepath.Dir(w.Path)
+		options = append(options, label)
+		labelToPath[label] = w.Path
+	}
+	options = append(options, SigBackHome, SigExit)
+
+	var choice string
+	if err := survey.AskOne(&survey.Select{Message: "Select :", Options: options, PageSize: 15, Filter: fuzzyFilter}, &choice); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+
+	path, ok := labelToPath[choice]
+	if !ok && choice != SigGlobalDB {
+		app.SigChan <- Signal{Sig: choice}
+		return nil
+	}
+	if err := app.SwitchWorkspace(path); err != nil {
+		app.ErrorHandler(err)
+		return err
+	}
+	app.SigChan <- Signal{Sig: SigHome}
+	return nil
+}
+
+/*
+SwitchWorkspace
+Open the workspace at path, or the global database when path is empty
+*/
+func (app *App) SwitchWorkspace(path string) error {
+	database := app.Global
+	if path != "" {
+		database = core.OpenWorkspace(path)
+		database.HistoryRetention = app.Global.HistoryRetention
+	}
+	if err := database.InitDB(); err != nil {
+		return err
+	}
+	if path != "" {
+		if err := core.RememberWorkspace(app.HomeDir, path); err != nil {
+			return err
+		}
+	}
+	app.Database = database
+	app.Workspace = path
+	activeWorkspace = path
+	return nil
+}
+
+func workspaceLabel(path string) string {
+	if path == "" {
+		return SigGlobalDB
+	}
+	return filepath.Dir(path)
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+/*
+Ad-hoc requests
+ParseAdHoc reads a request written with the HTTPie syntax, for one-off calls
+from the command line: [METHOD] URL [ITEM...], e.g.
+tanker POST :8080/users name=bob age:=42 Authorization:"Bearer x"
+*/
+
+/*
+AdHocOptions
+How the data items of an ad-hoc request are sent
+*/
+type AdHocOptions struct {
+	Form      bool   // champs en application/x-www-form-urlencoded au lieu de JSON
+	Multipart bool   // champs en multipart/form-data, même sans fichier
+	Body      []byte // body lu sur l'entrée standard, exclusif avec les champs
+}
+
+// Separators of the request items, the longest first for a same position
+var adHocSeparators = []string{":=@", "==", "=@", ":=", "=", "@", ":"}
+
+// Hosts of a URL written without scheme: example.com, localhost:8080,
+// api:8080 (a compose service) or [::1]
+var bareHost = regexp.MustCompile(`^(localhost|[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+|[A-Za-z0-9-]+:[0-9]+|\[[0-9A-Fa-f:.]+\])(:[0-9]+)?$`)
+
+// adHocField is a data item, Value being a string or, for :=, any JSON value
+type adHocField struct {
+	Name        string
+	Value       interface{}
+	File        string
+	ContentType string
+}
+
+/*
+IsAdHocRequest reports whether command line arguments start like an ad-hoc
+request: an HTTP method, a URL, a host such as example.com/users or a
+localhost shorthand such as :8080/users
+*/
+func IsAdHocRequest(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	return httpMethods[strings.ToUpper(args[0])] || isAdHocURL(args[0])
+}
+
+// isAdHocURL reports whether adHocURL understands an argument as a URL
+func isAdHocURL(arg string) bool {
+	if strings.HasPrefix(arg, ":") || strings.HasPrefix(arg, "{{") || strings.Contains(arg, "://") {
+		return true
+	}
+	host, _, _ := strings.Cut(arg, "/")
+	host, _, _ = strings.Cut(host, "?")
+	return bareHost.MatchString(host)
+}
+
+/*
+ParseAdHoc converts the arguments of an ad-hoc request into a request. The
+items are:
+
+	Header:Value  header, Header; for an empty value
+	name==value   query param
+	name=value    string field of the JSON (or form) body
+	name:=json    raw JSON field: number, boolean, array, object
+	name=@file    string field read from a file
+	name:=@file   raw JSON field read from a file
+	name@file     file upload, sends a multipart body (name@file;type=image/png)
+
+A backslash escapes a separator in a name, e.g. a\=b=c. The method
+defaults to POST when there is a body, GET otherwise.
+*/
+func ParseAdHoc(args []string, opts AdHocOptions) (Request, error) {
+	r := Request{Headers: map[string]interface{}{}}
+	if len(args) > 0 && httpMethods[strings.ToUpper(args[0])] {
+		r.Method = strings.ToUpper(args[0])
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return Request{}, fmt.Errorf("missing URL")
+	}
+	if !isAdHocURL(args[0]) {
+		return Request{}, fmt.Errorf("invalid URL %q, expected e.g. https://example.com/users, example.com/users or :8080/users", args[0])
+	}
+	r.URL = adHocURL(args[0])
+
+	var fields []adHocField
+	hasFile := false
+	for _, item := range args[1:] {
+		name, separator, value, ok := splitAdHocItem(item)
+		if !ok {
+			return Request{}, fmt.Errorf("invalid request item %q, expected Header:Value, name==value, name=value, name:=json or name@file", item)
+		}
+		switch separator {
+		case ":":
+			r.Headers[name] = value
+		case ";":
+			r.Headers[name] = ""
+		case "==":
+			if r.Params == nil {
+				r.Params = map[string]interface{}{}
+			}
+			r.Params[name] = value
+		case "=":
+			fields = append(fields, adHocField{Name: name, Value: value})
+		case "=@", ":=@":
+			data, err := os.ReadFile(value)
+			if err != nil {
+				return Request{}, fmt.Errorf("item %q: %w", item, err)
+			}
+			if separator == "=@" {
+				fields = append(fields, adHocField{Name: name, Value: string(data)})
+				break
+			}
+			field, err := adHocJSONField(name, data, item)
+			if err != nil {
+				return Request{}, err
+			}
+			fields = append(fields, field)
+		case ":=":
+			field, err := adHocJSONField(name, []byte(value), item)
+			if err != nil {
+				return Request{}, err
+			}
+			fields = append(fields, field)
+		case "@":
+			path, contentType, _ := strings.Cut(value, ";type=")
+			if _, err := os.Stat(path); err != nil {
+				return Request{}, fmt.Errorf("item %q: %w", item, err)
+			}
+			fields = append(fields, adHocField{Name: name, File: path, ContentType: contentType})
+			hasFile = true
+		}
+	}
+
+	if len(opts.Body) > 0 && len(fields) > 0 {
+		return Request{}, fmt.Errorf("a body on the standard input can't be mixed with data items")
+	}
+	if r.Method == "" {
+		r.Method = "GET"
+		if len(fields) > 0 || len(opts.Body) > 0 {
+			r.Method = "POST"
+		}
+	}
+
+	switch {
+	case len(opts.Body) > 0:
+		r.BodyType, r.Body = "raw", string(opts.Body)
+		if json.Valid(opts.Body) {
+			setDefaultHeader(r.Headers, "Content-Type", "application/json")
+		}
+	case hasFile || opts.Multipart || opts.Form:
+		r.BodyType = "form"
+		if hasFile || opts.Multipart {
+			r.BodyType = "multipart"
+		}
+		for _, f := range fields {
+			value, ok := f.Value.(string)
+			if !ok && f.File == "" {
+				return Request{}, fmt.Errorf("the JSON field %q can't be sent in a form", f.Name)
+			}
+			r.Form = append(r.Form, FormField{Name: f.Name, Value: value, File: f.File, ContentType: f.ContentType})
+		}
+	case len(fields) > 0:
+		payload := map[string]interface{}{}
+		for _, f := range fields {
+			payload[f.Name] = f.Value
+		}
+		setDefaultHeader(r.Headers, "Content-Type", "application/json")
+		setDefaultHeader(r.Headers, "Accept", "application/json, */*;q=0.5")
+		switch r.Method {
+		case "POST", "PUT", "PATCH":
+			r.Payload = payload
+		default:
+			// A JSON payload is only sent with POST, PUT and PATCH
+			data, _ := json.Marshal(payload)
+			r.BodyType, r.Body = "raw", string(data)
+		}
+	}
+	return r, nil
+}
+
+// adHocURL completes the URL shorthands: :8080/users is
+// http://localhost:8080/users, example.com is http://example.com
+func adHocURL(rawURL string) string {
+	if strings.HasPrefix(rawURL, ":") {
+		rest := strings.TrimPrefix(rawURL, ":")
+		if strings.HasPrefix(rest, "/") || rest == "" {
+			return "http://localhost" + rest
+		}
+		return "http://localhost:" + rest
+	}
+	if !strings.Contains(rawURL, "://") && !strings.HasPrefix(rawURL, "{{") {
+		return "http://" + rawURL
+	}
+	return rawURL
+}
+
+// splitAdHocItem splits an item at its first separator not escaped by a
+// backslash
+func splitAdHocItem(item string) (name, separator, value string, ok bool) {
+	var b strings.Builder
+	for i := 0; i < len(item); i++ {
+		if item[i] == '\\' && i+1 < len(item) && strings.IndexByte(`:=@;\`, item[i+1]) >= 0 {
+			b.WriteByte(item[i+1])
+			i++
+			continue
+		}
+		for _, separator := range adHocSeparators {
+			if strings.HasPrefix(item[i:], separator) {
+				return b.String(), separator, item[i+len(separator):], b.Len() > 0
+			}
+		}
+		if item[i] == ';' && i == len(item)-1 {
+			return b.String(), ";", "", b.Len() > 0
+		}
+		b.WriteByte(item[i])
+	}
+	return "", "", "", false
+}
+
+func adHocJSONField(name string, data []byte, item string) (adHocField, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return adHocField{}, fmt.Errorf("item %q: invalid JSON: %w", item, err)
+	}
+	return adHocField{Name: name, Value: value}, nil
+}
+
+// setDefaultHeader sets a header unless it is already set, whatever its case
+func setDefaultHeader(headers map[string]interface{}, name, value string) {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return
+		}
+	}
+	headers[name] = value
+}
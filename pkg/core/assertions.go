@@ -0,0 +1,352 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+Assertions
+Checks of the response evaluated after each execution of a request, by the
+collection runner and the headless run
+*/
+
+/*
+Assertion
+Check of a response, e.g. {"type": "jsonpath", "target": "$.items[0].id",
+"operator": "exists"}
+*/
+type Assertion struct {
+	Type     string `json:"type"`               // "status", "header", "jsonpath", "time" ou "body"
+	Target   string `json:"target,omitempty"`   // nom du header ou chemin JSONPath
+	Operator string `json:"operator,omitempty"` // défaut: "lt" pour time, "contains" pour body, "equals" sinon
+	Value    string `json:"value,omitempty"`    // valeur attendue, regex pour matches, millisecondes pour time
+}
+
+/*
+AssertionResult
+Outcome of an assertion, Actual being the value found in the response
+*/
+type AssertionResult struct {
+	Assertion Assertion `json:"assertion"`
+	Passed    bool      `json:"passed"`
+	Actual    string    `json:"actual,omitempty"`
+	Message   string    `json:"message,omitempty"` // pourquoi l'assertion a échoué
+}
+
+var (
+	AssertionTypes     = []string{"status", "header", "jsonpath", "time", "body"}
+	AssertionOperators = []string{"equals", "not_equals", "contains", "not_contains", "matches", "exists", "not_exists", "lt", "lte", "gt", "gte"}
+)
+
+// Status classes such as 2xx
+var statusClass = regexp.MustCompile(`^[1-5]xx$`)
+
+func (a Assertion) operator() string {
+	switch {
+	case a.Operator != "":
+		return a.Operator
+	case a.Type == "time":
+		return "lt"
+	case a.Type == "body":
+		return "contains"
+	}
+	return "equals"
+}
+
+/*
+String the assertion as displayed, e.g. "header Content-Type contains json"
+*/
+func (a Assertion) String() string {
+	parts := []string{a.Type}
+	if a.Target != "" {
+		parts = append(parts, a.Target)
+	}
+	parts = append(parts, a.operator())
+	switch a.operator() {
+	case "exists", "not_exists":
+	default:
+		value := a.Value
+		if a.Type == "time" {
+			value += " ms"
+		}
+		parts = append(parts, value)
+	}
+	return strings.Join(parts, " ")
+}
+
+/*
+Validate reports an assertion that can't be evaluated
+*/
+func (a Assertion) Validate() error {
+	known := false
+	for _, t := range AssertionTypes {
+		known = known || a.Type == t
+	}
+	if !known {
+		return fmt.Errorf("unknown assertion type %q, expected %s", a.Type, strings.Join(AssertionTypes, ", "))
+	}
+	operator := a.operator()
+	known = false
+	for _, o := range AssertionOperators {
+		known = known || operator == o
+	}
+	if !known {
+		return fmt.Errorf("unknown assertion operator %q, expected %s", operator, strings.Join(AssertionOperators, ", "))
+	}
+
+	switch a.Type {
+	case "header", "jsonpath":
+		if a.Target == "" {
+			return fmt.Errorf("%s assertion without target", a.Type)
+		}
+		if a.Type == "jsonpath" {
+			if _, err := parseJSONPath(a.Target); err != nil {
+				return err
+			}
+		}
+	case "time":
+		if !isNumericOperator(operator) {
+			return fmt.Errorf("time assertions compare with lt, lte, gt or gte, not %s", operator)
+		}
+	}
+	switch {
+	case operator == "matches":
+		if _, err := regexp.Compile(a.Value); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", a.Value, err)
+		}
+	case isNumericOperator(operator):
+		if _, err := strconv.ParseFloat(a.Value, 64); err != nil {
+			return fmt.Errorf("%s expects a number, got %q", operator, a.Value)
+		}
+	}
+	return nil
+}
+
+/*
+ValidateAssertions reports the first invalid assertion
+*/
+func ValidateAssertions(assertions []Assertion) error {
+	for i, a := range assertions {
+		if err := a.Validate(); err != nil {
+			return fmt.Errorf("assertion %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func isNumericOperator(operator string) bool {
+	switch operator {
+	case "lt", "lte", "gt", "gte":
+		return true
+	}
+	return false
+}
+
+/*
+CheckAssertions evaluates the assertions of a request on its response
+*/
+func CheckAssertions(assertions []Assertion, resp Response) []AssertionResult {
+	results := make([]AssertionResult, 0, len(assertions))
+	for _, a := range assertions {
+		results = append(results, a.Check(resp))
+	}
+	return results
+}
+
+/*
+Check evaluates the assertion on a response
+*/
+func (a Assertion) Check(resp Response) AssertionResult {
+	result := AssertionResult{Assertion: a}
+	if err := a.Validate(); err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	var found bool
+	switch a.Type {
+	case "status":
+		result.Actual, found = strconv.Itoa(resp.StatusCode), true
+	case "header":
+		values := resp.Headers.Values(a.Target)
+		result.Actual, found = strings.Join(values, ", "), len(values) > 0
+	case "jsonpath":
+		var value interface{}
+		value, found = resp.jsonPath(a.Target)
+		if s, ok := value.(string); ok {
+			result.Actual = s
+		} else if found {
+			data, _ := json.Marshal(value)
+			result.Actual = string(data)
+		}
+	case "time":
+		result.Actual, found = strconv.FormatInt(resp.ExecutionTimeMillisec, 10), true
+	case "body":
+		result.Actual, found = resp.text(), true
+	}
+
+	operator := a.operator()
+	switch operator {
+	case "equals":
+		result.Passed = found && (result.Actual == a.Value ||
+			a.Type == "status" && statusClass.MatchString(a.Value) && result.Actual[:1] == a.Value[:1])
+	case "not_equals":
+		result.Passed = !found || result.Actual != a.Value
+	case "contains":
+		result.Passed = found && strings.Contains(result.Actual, a.Value)
+	case "not_contains":
+		result.Passed = !strings.Contains(result.Actual, a.Value)
+	case "matches":
+		result.Passed = found && regexp.MustCompile(a.Value).MatchString(result.Actual)
+	case "exists":
+		result.Passed = found
+	case "not_exists":
+		result.Passed = !found
+	default:
+		actual, err := strconv.ParseFloat(result.Actual, 64)
+		expected, _ := strconv.ParseFloat(a.Value, 64)
+		switch {
+		case !found || err != nil:
+		case operator == "lt":
+			result.Passed = actual < expected
+		case operator == "lte":
+			result.Passed = actual <= expected
+		case operator == "gt":
+			result.Passed = actual > expected
+		case operator == "gte":
+			result.Passed = actual >= expected
+		}
+	}
+
+	if !result.Passed {
+		switch {
+		case !found && operator != "not_exists":
+			result.Message = fmt.Sprintf("expected %s, not found", a)
+		case a.Type == "body":
+			// The body can be long, the actual value is left out of the message
+			result.Message = fmt.Sprintf("expected %s", a)
+		default:
+			result.Message = fmt.Sprintf("expected %s, got %s", a, result.Actual)
+		}
+	}
+	if a.Type == "body" && len(result.Actual) > 200 {
+		result.Actual = result.Actual[:200] + "..."
+	}
+	return result
+}
+
+// text the response body as text, as received when it is known
+func (r *Response) text() string {
+	switch {
+	case r.raw != "":
+		return r.raw
+	case r.JsonBody != nil:
+		data, _ := json.Marshal(r.JsonBody)
+		return string(data)
+	}
+	return r.Body
+}
+
+// jsonPath evaluates a JSONPath on the JSON body, arrays included
+func (r *Response) jsonPath(path string) (interface{}, bool) {
+	var document interface{}
+	if r.JsonBody != nil {
+		document = r.JsonBody
+	} else if json.Unmarshal([]byte(r.text()), &document) != nil {
+		return nil, false
+	}
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false
+	}
+	for _, step := range steps {
+		switch node := document.(type) {
+		case map[string]interface{}:
+			value, ok := node[step]
+			if !ok && step == "length()" {
+				value, ok = float64(len(node)), true
+			}
+			if !ok {
+				return nil, false
+			}
+			document = value
+		case []interface{}:
+			if step == "length()" {
+				document = float64(len(node))
+				continue
+			}
+			i, err := strconv.Atoi(step)
+			if err != nil {
+				return nil, false
+			}
+			if i < 0 {
+				i += len(node)
+			}
+			if i < 0 || i >= len(node) {
+				return nil, false
+			}
+			document = node[i]
+		case string:
+			if step != "length()" {
+				return nil, false
+			}
+			document = float64(len([]rune(node)))
+		default:
+			return nil, false
+		}
+	}
+	return document, true
+}
+
+/*
+parseJSONPath splits a JSONPath into its keys and indexes. The supported
+subset is $.key, $['key'], $.items[0], $.items[-1] and $.items.length().
+*/
+func parseJSONPath(path string) ([]string, error) {
+	invalid := fmt.Errorf("invalid JSONPath %q, expected e.g. $.items[0].id", path)
+	if !strings.HasPrefix(path, "$") {
+		return nil, invalid
+	}
+	var steps []string
+	rest := path[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "['") || strings.HasPrefix(rest, `["`):
+			quote := rest[1:2]
+			end := strings.Index(rest[2:], quote+"]")
+			if end < 0 {
+				return nil, invalid
+			}
+			steps = append(steps, rest[2:2+end])
+			rest = rest[2+end+2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, invalid
+			}
+			if _, err := strconv.Atoi(rest[1:end]); err != nil {
+				return nil, invalid
+			}
+			steps = append(steps, rest[1:end])
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "."):
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : 1+end]
+			if key == "" {
+				return nil, invalid
+			}
+			steps = append(steps, key)
+			rest = rest[1+end:]
+		default:
+			return nil, invalid
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultRedirectURL = "http://127.0.0.1:8085/callback"
+
+/*
+Authorize
+Run the OAuth 2.0 authorization-code flow with PKCE (RFC 7636).
+A temporary HTTP listener is started on the loopback redirect URL, the
+authorize URL is handed to openURL, and once the browser is redirected the
+code is exchanged. The resulting tokens are stored in the auth config and
+in the token cache.
+*/
+func Authorize(ctx context.Context, auth *AuthConfig, insecure bool, openURL func(string)) error {
+	if auth.AuthURL == "" {
+		return fmt.Errorf("missing authorize URL")
+	}
+	if auth.TokenURL == "" {
+		return fmt.Errorf("missing token URL")
+	}
+
+	redirect, err := url.Parse(auth.RedirectURL)
+	if auth.RedirectURL == "" {
+		redirect, err = url.Parse(DefaultRedirectURL)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid redirect URL: %w", err)
+	}
+	if redirect.Scheme != "http" || !isLoopback(redirect.Hostname()) {
+		return fmt.Errorf("redirect URL must be an http loopback address, got %s", redirect)
+	}
+	if redirect.Path == "" {
+		redirect.Path = "/"
+	}
+
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return fmt.Errorf("failed to start loopback listener: %w", err)
+	}
+	defer listener.Close()
+	// Port 0 asks for any free port, the actual one goes in redirect_uri
+	redirect.Host = net.JoinHostPort(redirect.Hostname(), fmt.Sprint(listener.Addr().(*net.TCPAddr).Port))
+	redirectURI := redirect.String()
+
+	verifier := randomString(32)
+	state := randomString(16)
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authorizeURL, err := url.Parse(auth.AuthURL)
+	if err != nil {
+		return fmt.Errorf("invalid authorize URL: %w", err)
+	}
+	q := authorizeURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", auth.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("state", state)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	if len(auth.Scopes) > 0 {
+		q.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+	if auth.Audience != "" {
+		q.Set("audience", auth.Audience)
+	}
+	authorizeURL.RawQuery = q.Encode()
+
+	type callback struct {
+		code string
+		err  error
+	}
+	done := make(chan callback, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(redirect.Path, func(w http.ResponseWriter, req *http.Request) {
+		params := req.URL.Query()
+		// Not the redirect of this login (prefetch, stray request): keep waiting
+		if params.Get("state") != state {
+			http.Error(w, "state mismatch in authorization response", http.StatusBadRequest)
+			return
+		}
+		var result callback
+		switch {
+		case params.Get("error") != "":
+			result.err = fmt.Errorf("authorization denied: %s %s", params.Get("error"), params.Get("error_description"))
+		case params.Get("code") == "":
+			result.err = fmt.Errorf("no code in authorization response")
+		default:
+			result.code = params.Get("code")
+		}
+		if result.err != nil {
+			http.Error(w, result.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprint(w, "http-tanker: login complete, you can close this window.")
+		}
+		select {
+		case done <- result:
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	openURL(authorizeURL.String())
+
+	var result callback
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("authorization aborted: %w", ctx.Err())
+	}
+	if result.err != nil {
+		return result.err
+	}
+
+	client := defaultClient
+	if insecure {
+		client = insecureClient
+	}
+	token, err := requestToken(auth, client, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {result.code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	})
+	if err != nil {
+		return err
+	}
+
+	auth.GrantType = "authorization_code"
+	auth.AccessToken = token.AccessToken
+	auth.RefreshToken = token.RefreshToken
+	auth.TokenExpiry = nil
+	if !token.Expiry.IsZero() {
+		expiry := token.Expiry
+		auth.TokenExpiry = &expiry
+	}
+
+	Tokens.mu.Lock()
+	defer Tokens.mu.Unlock()
+	Tokens.loadLocked()
+	_, err = Tokens.putLocked(auth, token)
+	return err
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
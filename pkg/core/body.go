@@ -0,0 +1,105 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+/*
+FormField
+Field of a form body, File being the path of a file to upload for multipart
+*/
+type FormField struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	File        string `json:"file,omitempty"`
+	ContentType string `json:"contentType,omitempty"` // pour un fichier multipart
+}
+
+/*
+String the field as written by curl -F
+*/
+func (f FormField) String() string {
+	if f.File == "" {
+		return f.Name + "=" + f.Value
+	}
+	s := f.Name + "=@" + f.File
+	if f.ContentType != "" {
+		s += ";type=" + f.ContentType
+	}
+	return s
+}
+
+/*
+EncodeBody returns the body to send and its content type, empty when the
+headers are left to decide. JSON payloads are only sent with POST, PUT and
+PATCH; raw, form and multipart bodies are sent whatever the method.
+*/
+func (r *Request) EncodeBody() ([]byte, string, error) {
+	switch r.BodyType {
+	case "raw":
+		if r.Body == "" {
+			return nil, "", nil
+		}
+		return []byte(r.Body), "", nil
+	case "form":
+		values := url.Values{}
+		for _, field := range r.Form {
+			values.Add(field.Name, field.Value)
+		}
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+	case "multipart":
+		return r.encodeMultipart()
+	case "", "json":
+		switch r.Method {
+		case "POST", "PUT", "PATCH":
+			payload, err := json.Marshal(r.Payload)
+			return payload, "", err
+		}
+		return nil, "", nil
+	}
+	return nil, "", fmt.Errorf("unknown body type %q", r.BodyType)
+}
+
+func (r *Request) encodeMultipart() ([]byte, string, error) {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	for _, field := range r.Form {
+		if field.File == "" {
+			if err := writer.WriteField(field.Name, field.Value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		file, err := os.Open(field.File)
+		if err != nil {
+			return nil, "", fmt.Errorf("form field %s: %w", field.Name, err)
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, field.Name, filepath.Base(field.File)))
+		contentType := field.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		file.Close()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buffer.Bytes(), writer.FormDataContentType(), nil
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+/*
+Bulk operations
+Changes applied to several requests at once are saved in a single write,
+they either all apply or none does.
+*/
+
+// checkNames refuses unknown request names, so that nothing is half applied
+func (db *Database) checkNames(names []string) error {
+	var missing []string
+	for _, name := range names {
+		if _, ok := db.Data[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("requests not found: %v", missing)
+	}
+	return nil
+}
+
+// cloneRequest deep copies a request, its maps included
+func cloneRequest(r Request) Request {
+	var clone Request
+	buffer, _ := json.Marshal(r)
+	json.Unmarshal(buffer, &clone)
+	return clone
+}
+
+/*
+Duplicate saves a copy of a request under a new name, in the same collection
+*/
+func (db *Database) Duplicate(name, newName string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	r, ok := db.Data[name]
+	if !ok {
+		return fmt.Errorf("request %q not found", name)
+	}
+	if newName == "" {
+		return fmt.Errorf("request name can't be empty")
+	}
+	if _, exists := db.Data[newName]; exists {
+		return fmt.Errorf("a request named %q already exists", newName)
+	}
+	clone := cloneRequest(r)
+	clone.Name = newName
+	clone.LastUsed = nil
+	// Only the original is updated by a re-import
+	clone.Source = nil
+	db.Data[newName] = clone
+	return db.saveLocked()
+}
+
+/*
+CopyName first free "<name> copy", "<name> copy 2"... name
+*/
+func (db *Database) CopyName(name string) string {
+	candidate := name + " copy"
+	for i := 2; ; i++ {
+		if _, exists := db.Data[candidate]; !exists {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s copy %d", name, i)
+	}
+}
+
+/*
+DeleteRequests moves several requests to the trash
+*/
+func (db *Database) DeleteRequests(names []string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := db.checkNames(names); err != nil {
+		return err
+	}
+	for _, name := range names {
+		delete(db.Data, name)
+	}
+	return db.saveLocked()
+}
+
+/*
+MoveRequests moves several requests to a collection, "" being the root
+*/
+func (db *Database) MoveRequests(names []string, collection string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := db.checkNames(names); err != nil {
+		return err
+	}
+	collection = CleanCollection(collection)
+	for _, name := range names {
+		r := db.Data[name]
+		r.Collection = collection
+		db.Data[name] = r
+	}
+	return db.saveLocked()
+}
+
+/*
+TagRequests adds and removes tags on several requests
+*/
+func (db *Database) TagRequests(names []string, add, remove []string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := db.checkNames(names); err != nil {
+		return err
+	}
+	removed := map[string]bool{}
+	for _, tag := range remove {
+		removed[strings.ToLower(tag)] = true
+	}
+	for _, name := range names {
+		r := db.Data[name]
+		seen := map[string]bool{}
+		var tags []string
+		for _, tag := range append(append([]string{}, r.Tags...), add...) {
+			key := strings.ToLower(tag)
+			if tag != "" && !removed[key] && !seen[key] {
+				seen[key] = true
+				tags = append(tags, tag)
+			}
+		}
+		r.Tags = tags
+		db.Data[name] = r
+	}
+	return db.saveLocked()
+}
+
+/*
+ExportRequests encodes several requests as a database file, with the auth
+profiles and folder defaults they use
+*/
+func (db *Database) ExportRequests(names []string) ([]byte, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := db.checkNames(names); err != nil {
+		return nil, err
+	}
+	env := envelope{
+		Version:  SchemaVersion,
+		Requests: map[string]Request{},
+		Profiles: map[string]AuthConfig{},
+		Folders:  map[string]Folder{},
+	}
+	for _, name := range names {
+		r := db.Data[name]
+		r.LastUsed = nil
+		env.Requests[name] = r
+		if r.AuthProfile != "" {
+			env.Profiles[r.AuthProfile] = db.Profiles[r.AuthProfile]
+		}
+		for path, folder := range db.Folders {
+			if InCollection(r.Collection, path) {
+				env.Folders[path] = folder
+				if folder.AuthProfile != "" {
+					env.Profiles[folder.AuthProfile] = db.Profiles[folder.AuthProfile]
+				}
+			}
+		}
+	}
+	return json.MarshalIndent(env, "", "  ")
+}
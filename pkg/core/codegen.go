@@ -0,0 +1,999 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+Code generation
+Snippets sending a request from other languages and tools. Auth computed
+when sending (JWT, OAuth2, HMAC, AWS SigV4) is written as a placeholder with
+a note, a snippet only holds the secrets typed in the request, in clear,
+masked or read from environment variables.
+*/
+
+/*
+CodeLanguage
+Target of the code generation
+*/
+type CodeLanguage struct {
+	Name  string // identifiant passé à GenerateCode, ex: "python"
+	Label string // pour les menus, ex: "Python (requests)"
+	write func(c codeRequest) string
+	env   func(name string) string // lit une variable d'environnement au milieu d'une chaîne écrite par write
+}
+
+// CodeLanguages lists the targets of GenerateCode, cURL first
+var CodeLanguages = []CodeLanguage{
+	{Name: "curl", Label: "cURL", write: curlCode},
+	{Name: "go", Label: "Go (net/http)", write: goCode, env: func(name string) string { return `" + os.Getenv("` + name + `") + "` }},
+	{Name: "python", Label: "Python (requests)", write: pythonCode, env: func(name string) string { return `" + os.environ["` + name + `"] + "` }},
+	{Name: "fetch", Label: "JavaScript (fetch)", write: fetchCode, env: jsEnv},
+	{Name: "axios", Label: "Node.js (axios)", write: axiosCode, env: jsEnv},
+	{Name: "httpie", Label: "HTTPie", write: httpieCode, env: shellEnv},
+	{Name: "wget", Label: "wget", write: wgetCode, env: shellEnv},
+	{Name: "powershell", Label: "PowerShell (Invoke-RestMethod)", write: powershellCode, env: func(name string) string { return "${env:" + name + "}" }},
+}
+
+/*
+CodeOptions
+How a snippet is written
+*/
+type CodeOptions struct {
+	Secrets string // comme pour CurlOptions : "" les écrit en clair, "redact" les masque, "env" les lit dans des variables d'environnement
+}
+
+/*
+CodeLanguageNames lists the names accepted by GenerateCode
+*/
+func CodeLanguageNames() []string {
+	names := make([]string, len(CodeLanguages))
+	for i, l := range CodeLanguages {
+		names[i] = l.Name
+	}
+	return names
+}
+
+/*
+GenerateCode writes a resolved request as a snippet of the language, secrets
+included
+*/
+func (r *Request) GenerateCode(language string) (string, error) {
+	return r.GenerateCodeWith(language, CodeOptions{})
+}
+
+/*
+GenerateCodeWith writes a resolved request as a snippet of the language, its
+secrets written as set by the options
+*/
+func (r *Request) GenerateCodeWith(language string, opts CodeOptions) (string, error) {
+	switch opts.Secrets {
+	case "", "redact", "env":
+	default:
+		return "", fmt.Errorf("unknown secrets mode %q, expected redact or env", opts.Secrets)
+	}
+	for _, l := range CodeLanguages {
+		if l.Name == strings.ToLower(language) {
+			c := r.codeRequest()
+			c.hideSecrets(opts.Secrets)
+			code := l.write(c)
+			if l.env != nil {
+				code = envPlaceholders.ReplaceAllStringFunc(code, func(placeholder string) string {
+					return l.env(envPlaceholders.FindStringSubmatch(placeholder)[1])
+				})
+				for _, e := range emptyConcatenations {
+					code = e.pattern.ReplaceAllString(code, e.replacement)
+				}
+			}
+			// gofmt spaces the concatenations by their depth
+			if l.Name == "go" {
+				if formatted, err := format.Source([]byte(code)); err == nil {
+					code = string(formatted)
+				}
+			}
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("unknown language %q, expected one of %s", language, strings.Join(CodeLanguageNames(), ", "))
+}
+
+// codeRequest is a request as sent by CallHTTP, ready to be written
+type codeRequest struct {
+	request  *Request
+	Method   string
+	URL      string      // sans les params
+	Params   [][2]string // triés
+	Headers  [][2]string // triés, ceux de l'auth en dernier
+	Auth     string      // "basic", "digest" ou "" quand l'auth est dans les headers
+	Username string
+	Password string
+	Body     string // "json", "raw", "form", "multipart" ou "" sans body
+	Payload  map[string]interface{}
+	Raw      string
+	Form     []FormField
+	Insecure bool
+	Notes    []string // ce que la cible ne peut pas exprimer, écrit en commentaire
+	Secrets  string   // mode de CodeOptions
+}
+
+func (r *Request) codeRequest() codeRequest {
+	c := codeRequest{request: r, Method: r.Method, URL: r.URL, Insecure: r.Insecure}
+	for _, k := range sortedKeys(r.Params) {
+		if s, ok := r.Params[k].(string); ok {
+			c.Params = append(c.Params, [2]string{k, s})
+		}
+	}
+
+	headers := map[string]string{}
+	for k, v := range r.Headers {
+		if s, ok := v.(string); ok {
+			headers[k] = s
+		}
+	}
+	for _, k := range sortedKeys(headers) {
+		c.Headers = append(c.Headers, [2]string{k, headers[k]})
+	}
+	authHeaders, notes := r.authHeaders()
+	for _, h := range authHeaders {
+		c.setHeader(h[0], h[1])
+	}
+	c.Notes = notes
+	if r.Auth != nil && (r.Auth.Type == "basic" || r.Auth.Type == "digest") {
+		c.Auth, c.Username, c.Password = r.Auth.Type, r.Auth.Username, r.Auth.Password
+	}
+
+	// Same rules as EncodeBody
+	switch r.BodyType {
+	case "raw":
+		if r.Body != "" {
+			c.Body, c.Raw = "raw", r.Body
+		}
+	case "form", "multipart":
+		c.Body, c.Form = r.BodyType, r.Form
+	default:
+		switch r.Method {
+		case "POST", "PUT", "PATCH":
+			if len(r.Payload) > 0 {
+				c.Body, c.Payload = "json", r.Payload
+			}
+		}
+	}
+	return c
+}
+
+// Stands for an environment variable in the strings of a snippet until the
+// snippet is written
+const envPlaceholder = "__tanker_env_%s__"
+
+var envPlaceholders = regexp.MustCompile(`__tanker_env_([A-Z0-9_]+?)__`)
+
+// Empty strings left around a variable read at the start or the end of a
+// string: "" + os.Getenv("TOKEN") in a program, ''"$TOKEN" in a shell
+var emptyConcatenations = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`([^\\])"" \+ `), "$1"},
+	{regexp.MustCompile(` \+ ""([^"])`), "$1"},
+	{regexp.MustCompile(`(?m)(^|[\s=])''("\$)`), "$1$2"},
+	{regexp.MustCompile(`(?m)("\$[A-Z0-9_]+")''(\s|$)`), "$1$2"},
+}
+
+/*
+hideSecrets writes the secrets as set by the mode, the same ones as in a
+curl command: headers of the auth or named like a secret (Authorization,
+X-API-Key...), params and body fields named like a secret, and passwords
+*/
+func (c *codeRequest) hideSecrets(mode string) {
+	c.Secrets = mode
+	if mode == "" {
+		return
+	}
+	w := curlWriter{opts: CurlOptions{Secrets: mode}}
+	text := func(word shellWord) string {
+		var b strings.Builder
+		for _, p := range word {
+			if p.env != "" {
+				fmt.Fprintf(&b, envPlaceholder, p.env)
+				continue
+			}
+			b.WriteString(p.text)
+		}
+		return b.String()
+	}
+
+	// In the order of the curl command, so are the variable names
+	for i, p := range c.Params {
+		if secretNamePattern.MatchString(p[0]) {
+			c.Params[i][1] = text(w.secret(p[1], secretEnvName(p[0])))
+		}
+	}
+	authHeaders, _ := c.request.authHeaders()
+	for i, h := range c.Headers {
+		if h[1] != "" && (isAuthHeader(authHeaders, h[0]) || secretNamePattern.MatchString(h[0])) {
+			c.Headers[i][1] = text(w.secretValue(h[1], secretEnvName(h[0])))
+		}
+	}
+	if c.Password != "" {
+		c.Password = text(w.secret(c.Password, "PASSWORD"))
+	}
+
+	var mask func(v interface{}) interface{}
+	mask = func(v interface{}) interface{} {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			masked := make(map[string]interface{}, len(node))
+			for _, k := range sortedKeys(node) {
+				switch value := node[k].(type) {
+				case map[string]interface{}, []interface{}, nil:
+					masked[k] = mask(value)
+				default:
+					if secretNamePattern.MatchString(k) {
+						masked[k] = text(w.secret(fmt.Sprint(value), secretEnvName(k)))
+						continue
+					}
+					masked[k] = value
+				}
+			}
+			return masked
+		case []interface{}:
+			masked := make([]interface{}, len(node))
+			for i, value := range node {
+				masked[i] = mask(value)
+			}
+			return masked
+		}
+		return v
+	}
+	switch c.Body {
+	case "json":
+		c.Payload = mask(c.Payload).(map[string]interface{})
+	case "raw":
+		var payload interface{}
+		if json.Unmarshal([]byte(c.Raw), &payload) == nil && hasSecretField(payload) {
+			buffer, _ := json.Marshal(mask(payload))
+			c.Raw = string(buffer)
+		}
+	case "form", "multipart":
+		form := make([]FormField, len(c.Form))
+		for i, f := range c.Form {
+			if f.File == "" && secretNamePattern.MatchString(f.Name) {
+				f.Value = text(w.secret(f.Value, secretEnvName(f.Name)))
+			}
+			form[i] = f
+		}
+		c.Form = form
+	}
+}
+
+// setHeader replaces a header whatever its case, or appends it
+func (c *codeRequest) setHeader(name, value string) {
+	for i, h := range c.Headers {
+		if strings.EqualFold(h[0], name) {
+			c.Headers = append(c.Headers[:i], c.Headers[i+1:]...)
+			break
+		}
+	}
+	c.Headers = append(c.Headers, [2]string{name, value})
+}
+
+// header returns the value of a header whatever its case
+func (c codeRequest) header(name string) (string, bool) {
+	for _, h := range c.Headers {
+		if strings.EqualFold(h[0], name) {
+			return h[1], true
+		}
+	}
+	return "", false
+}
+
+// FullURL is the URL with the params as query string
+func (c codeRequest) FullURL() string {
+	if len(c.Params) == 0 {
+		return c.URL
+	}
+	var query []string
+	for _, p := range c.Params {
+		query = append(query, url.QueryEscape(p[0])+"="+url.QueryEscape(p[1]))
+	}
+	separator := "?"
+	if strings.Contains(c.URL, "?") {
+		separator = "&"
+	}
+	return c.URL + separator + strings.Join(query, "&")
+}
+
+// formString encodes a form body like EncodeBody
+func (c codeRequest) formString() string {
+	values := url.Values{}
+	for _, f := range c.Form {
+		values.Add(f.Name, f.Value)
+	}
+	return values.Encode()
+}
+
+// payloadJSON indents the JSON payload, each line but the first starting with prefix
+func (c codeRequest) payloadJSON(prefix string) string {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(prefix, "  ")
+	encoder.Encode(c.Payload)
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+/*
+authHeaders returns the headers carrying the auth of a request, except
+basic and digest that every target handles by itself, and notes on what
+must be done by hand
+*/
+func (r *Request) authHeaders() ([][2]string, []string) {
+	if r.Auth == nil {
+		return nil, nil
+	}
+	switch r.Auth.Type {
+	case "bearer":
+		return [][2]string{{"Authorization", "Bearer " + r.Auth.Token}}, nil
+	case "api-key":
+		header := r.Auth.Header
+		if header == "" {
+			header = "X-API-Key"
+		}
+		return [][2]string{{header, r.Auth.Key}}, nil
+	case "oauth2":
+		return [][2]string{{"Authorization", "Bearer <oauth2-access-token>"}}, []string{"the OAuth2 access token is not written out, get one from " + r.Auth.TokenURL}
+	case "jwt":
+		token := "<jwt>"
+		notes := []string{"the JWT is signed when sending, sign one with the key of the auth"}
+		if r.Auth.Header != "" {
+			return [][2]string{{r.Auth.Header, token}}, notes
+		}
+		return [][2]string{{"Authorization", "Bearer " + token}}, notes
+	case "hmac":
+		header := r.Auth.Header
+		if header == "" {
+			header = "X-Signature"
+		}
+		return [][2]string{{header, "<hmac-signature>"}}, []string{"the HMAC signature depends on the time of sending, compute it before each call"}
+	case "aws-sigv4":
+		return nil, []string{fmt.Sprintf("sign the request with AWS Signature V4 (region %s, service %s)", awsRegion(r.Auth), r.Auth.Service)}
+	}
+	return nil, nil
+}
+
+// --- Go ---
+
+func goCode(c codeRequest) string {
+	imports := map[string]bool{"fmt": true, "io": true, "net/http": true}
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, "\t"+format+"\n", args...)
+	}
+	for _, note := range c.Notes {
+		line("// TODO: %s", note)
+	}
+	if c.Auth == "digest" {
+		line("// TODO: net/http has no digest auth, answer the WWW-Authenticate challenge")
+	}
+
+	body := "nil"
+	switch c.Body {
+	case "json", "raw":
+		imports["strings"] = true
+		content := c.Raw
+		if c.Body == "json" {
+			content = c.payloadJSON("")
+		}
+		line("body := strings.NewReader(%s)", goString(content))
+		body = "body"
+	case "form":
+		imports["net/url"], imports["strings"] = true, true
+		line("form := url.Values{}")
+		for _, f := range c.Form {
+			line("form.Add(%s, %s)", goString(f.Name), goString(f.Value))
+		}
+		line("body := strings.NewReader(form.Encode())")
+		body = "body"
+	case "multipart":
+		imports["bytes"], imports["mime/multipart"] = true, true
+		line("body := &bytes.Buffer{}")
+		line("writer := multipart.NewWriter(body)")
+		files := 0
+		for _, f := range c.Form {
+			if f.File == "" {
+				line("writer.WriteField(%s, %s)", goString(f.Name), goString(f.Value))
+				continue
+			}
+			imports["os"], imports["net/textproto"] = true, true
+			files++
+			line("file%d, err := os.ReadFile(%s)", files, goString(f.File))
+			line("if err != nil {\n\t\tpanic(err)\n\t}")
+			// Same part headers as EncodeBody
+			contentType := f.ContentType
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			line("header%d := textproto.MIMEHeader{}", files)
+			line("header%d.Set(\"Content-Disposition\", %s)", files, goString(fmt.Sprintf(`form-data; name=%q; filename=%q`, f.Name, filepath.Base(f.File))))
+			line("header%d.Set(\"Content-Type\", %s)", files, goString(contentType))
+			line("part%d, err := writer.CreatePart(header%d)", files, files)
+			line("if err != nil {\n\t\tpanic(err)\n\t}")
+			line("part%d.Write(file%d)", files, files)
+		}
+		line("writer.Close()")
+		body = "body"
+	}
+
+	line("req, err := http.NewRequest(%s, %s, %s)", goString(c.Method), goString(c.FullURL()), body)
+	line("if err != nil {\n\t\tpanic(err)\n\t}")
+	for _, h := range c.Headers {
+		line("req.Header.Set(%s, %s)", goString(h[0]), goString(h[1]))
+	}
+	switch c.Body {
+	case "form":
+		if _, ok := c.header("Content-Type"); !ok {
+			line(`req.Header.Set("Content-Type", "application/x-www-form-urlencoded")`)
+		}
+	case "multipart":
+		line(`req.Header.Set("Content-Type", writer.FormDataContentType())`)
+	}
+	if c.Auth == "basic" {
+		line("req.SetBasicAuth(%s, %s)", goString(c.Username), goString(c.Password))
+	}
+
+	if c.Insecure {
+		imports["crypto/tls"] = true
+		line("client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}")
+	} else {
+		line("client := &http.Client{}")
+	}
+	line("resp, err := client.Do(req)")
+	line("if err != nil {\n\t\tpanic(err)\n\t}")
+	line("defer resp.Body.Close()")
+	line("data, err := io.ReadAll(resp.Body)")
+	line("if err != nil {\n\t\tpanic(err)\n\t}")
+	line("fmt.Println(resp.Status)")
+	line("fmt.Println(string(data))")
+
+	if envPlaceholders.MatchString(b.String()) {
+		imports["os"] = true
+	}
+	names := sortedKeys(imports)
+	var out strings.Builder
+	out.WriteString("package main\n\nimport (\n")
+	for _, name := range names {
+		fmt.Fprintf(&out, "\t%q\n", name)
+	}
+	out.WriteString(")\n\nfunc main() {\n")
+	out.WriteString(b.String())
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// goString quotes s, as a raw string when it spans several lines and reads
+// no environment variable
+func goString(s string) string {
+	if strings.Contains(s, "\n") && !strings.Contains(s, "`") && !strings.Contains(s, "\r") && !envPlaceholders.MatchString(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+// --- Python ---
+
+func pythonCode(c codeRequest) string {
+	var b strings.Builder
+	for _, note := range c.Notes {
+		fmt.Fprintf(&b, "# TODO: %s\n", note)
+	}
+	b.WriteString("import requests\n")
+	if c.Auth == "digest" {
+		b.WriteString("from requests.auth import HTTPDigestAuth\n")
+	}
+	if c.Insecure {
+		b.WriteString("import urllib3\n\nurllib3.disable_warnings()\n")
+	}
+	fmt.Fprintf(&b, "\nurl = %s\n", pyString(c.URL))
+
+	args := []string{pyString(c.Method), "url"}
+	if len(c.Params) > 0 {
+		b.WriteString("params = " + pyPairs(c.Params) + "\n")
+		args = append(args, "params=params")
+	}
+	if len(c.Headers) > 0 {
+		b.WriteString("headers = " + pyPairs(c.Headers) + "\n")
+		args = append(args, "headers=headers")
+	}
+	switch c.Body {
+	case "json":
+		b.WriteString("payload = " + pyValue(c.Payload, "") + "\n")
+		args = append(args, "json=payload")
+	case "raw":
+		b.WriteString("data = " + pyString(c.Raw) + "\n")
+		args = append(args, "data=data")
+	case "form":
+		b.WriteString("data = [\n")
+		for _, f := range c.Form {
+			fmt.Fprintf(&b, "    (%s, %s),\n", pyString(f.Name), pyString(f.Value))
+		}
+		b.WriteString("]\n")
+		args = append(args, "data=data")
+	case "multipart":
+		b.WriteString("files = [\n")
+		for _, f := range c.Form {
+			if f.File == "" {
+				fmt.Fprintf(&b, "    (%s, (None, %s)),\n", pyString(f.Name), pyString(f.Value))
+				continue
+			}
+			file := fmt.Sprintf("%s, open(%s, \"rb\")", pyString(filepath.Base(f.File)), pyString(f.File))
+			if f.ContentType != "" {
+				file += ", " + pyString(f.ContentType)
+			}
+			fmt.Fprintf(&b, "    (%s, (%s)),\n", pyString(f.Name), file)
+		}
+		b.WriteString("]\n")
+		args = append(args, "files=files")
+	}
+	switch c.Auth {
+	case "basic":
+		args = append(args, fmt.Sprintf("auth=(%s, %s)", pyString(c.Username), pyString(c.Password)))
+	case "digest":
+		args = append(args, fmt.Sprintf("auth=HTTPDigestAuth(%s, %s)", pyString(c.Username), pyString(c.Password)))
+	}
+	if c.Insecure {
+		args = append(args, "verify=False")
+	}
+
+	b.WriteString("\nresponse = requests.request(\n")
+	for _, arg := range args {
+		fmt.Fprintf(&b, "    %s,\n", arg)
+	}
+	b.WriteString(")\nprint(response.status_code)\nprint(response.text)\n")
+	if envPlaceholders.MatchString(b.String()) {
+		return strings.Replace(b.String(), "import requests\n", "import os\nimport requests\n", 1)
+	}
+	return b.String()
+}
+
+// pyString quotes s, Go and Python sharing their escape sequences
+func pyString(s string) string {
+	return strconv.Quote(s)
+}
+
+func pyPairs(pairs [][2]string) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, p := range pairs {
+		fmt.Fprintf(&b, "    %s: %s,\n", pyString(p[0]), pyString(p[1]))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// pyValue writes a decoded JSON value as a Python literal
+func pyValue(v interface{}, indent string) string {
+	switch value := v.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if value {
+			return "True"
+		}
+		return "False"
+	case string:
+		return pyString(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case map[string]interface{}:
+		if len(value) == 0 {
+			return "{}"
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, k := range sortedKeys(value) {
+			fmt.Fprintf(&b, "%s    %s: %s,\n", indent, pyString(k), pyValue(value[k], indent+"    "))
+		}
+		return b.String() + indent + "}"
+	case []interface{}:
+		if len(value) == 0 {
+			return "[]"
+		}
+		var b strings.Builder
+		b.WriteString("[\n")
+		for _, item := range value {
+			fmt.Fprintf(&b, "%s    %s,\n", indent, pyValue(item, indent+"    "))
+		}
+		return b.String() + indent + "]"
+	}
+	return pyString(fmt.Sprint(v))
+}
+
+// --- JavaScript ---
+
+func jsEnv(name string) string {
+	return `" + process.env.` + name + ` + "`
+}
+
+// jsString quotes s as a JSON string, valid in JavaScript
+func jsString(s string) string {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+func jsPairs(pairs [][2]string, indent string) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, p := range pairs {
+		fmt.Fprintf(&b, "%s  %s: %s,\n", indent, jsString(p[0]), jsString(p[1]))
+	}
+	return b.String() + indent + "}"
+}
+
+// jsForm writes the statements filling a FormData named form
+func jsForm(b *strings.Builder, form []FormField, file func(f FormField) string) {
+	b.WriteString("const form = new FormData();\n")
+	for _, f := range form {
+		if f.File == "" {
+			fmt.Fprintf(b, "form.append(%s, %s);\n", jsString(f.Name), jsString(f.Value))
+			continue
+		}
+		fmt.Fprintf(b, "form.append(%s, %s);\n", jsString(f.Name), file(f))
+	}
+	b.WriteString("\n")
+}
+
+func fetchCode(c codeRequest) string {
+	var b strings.Builder
+	for _, note := range c.Notes {
+		fmt.Fprintf(&b, "// TODO: %s\n", note)
+	}
+	if c.Auth == "digest" {
+		b.WriteString("// TODO: fetch has no digest auth, answer the WWW-Authenticate challenge\n")
+	}
+	if c.Insecure {
+		b.WriteString("// fetch can't skip the certificate checks, run Node.js with NODE_TLS_REJECT_UNAUTHORIZED=0\n")
+	}
+	if c.Body == "multipart" {
+		b.WriteString("import { openAsBlob } from \"node:fs\";\n\n")
+		jsForm(&b, c.Form, func(f FormField) string {
+			options := ""
+			if f.ContentType != "" {
+				options = fmt.Sprintf(", { type: %s }", jsString(f.ContentType))
+			}
+			return fmt.Sprintf("await openAsBlob(%s%s), %s", jsString(f.File), options, jsString(filepath.Base(f.File)))
+		})
+	} else if len(c.Notes) > 0 || c.Auth == "digest" || c.Insecure {
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "const response = await fetch(%s, {\n", jsString(c.FullURL()))
+	fmt.Fprintf(&b, "  method: %s,\n", jsString(c.Method))
+	if len(c.Headers) > 0 || c.Auth == "basic" {
+		b.WriteString("  headers: {\n")
+		for _, h := range c.Headers {
+			fmt.Fprintf(&b, "    %s: %s,\n", jsString(h[0]), jsString(h[1]))
+		}
+		if c.Auth == "basic" {
+			fmt.Fprintf(&b, "    \"Authorization\": \"Basic \" + btoa(%s),\n", jsString(c.Username+":"+c.Password))
+		}
+		b.WriteString("  },\n")
+	}
+	switch c.Body {
+	case "json":
+		fmt.Fprintf(&b, "  body: JSON.stringify(%s),\n", c.payloadJSON("  "))
+	case "raw":
+		fmt.Fprintf(&b, "  body: %s,\n", jsString(c.Raw))
+	case "form":
+		fmt.Fprintf(&b, "  body: new URLSearchParams(%s),\n", jsString(c.formString()))
+	case "multipart":
+		b.WriteString("  body: form,\n")
+	}
+	b.WriteString("});\nconsole.log(response.status);\nconsole.log(await response.text());\n")
+	return b.String()
+}
+
+func axiosCode(c codeRequest) string {
+	var b strings.Builder
+	for _, note := range c.Notes {
+		fmt.Fprintf(&b, "// TODO: %s\n", note)
+	}
+	if c.Auth == "digest" {
+		b.WriteString("// TODO: axios has no digest auth, answer the WWW-Authenticate challenge\n")
+	}
+	b.WriteString("import axios from \"axios\";\n")
+	if c.Body == "multipart" {
+		b.WriteString("import FormData from \"form-data\";\nimport fs from \"node:fs\";\n")
+	}
+	if c.Insecure {
+		b.WriteString("import https from \"node:https\";\n")
+	}
+	b.WriteString("\n")
+	if c.Body == "multipart" {
+		jsForm(&b, c.Form, func(f FormField) string {
+			options := jsString(filepath.Base(f.File))
+			if f.ContentType != "" {
+				options = fmt.Sprintf("{ filename: %s, contentType: %s }", jsString(filepath.Base(f.File)), jsString(f.ContentType))
+			}
+			return fmt.Sprintf("fs.createReadStream(%s), %s", jsString(f.File), options)
+		})
+	}
+
+	b.WriteString("const response = await axios({\n")
+	fmt.Fprintf(&b, "  method: %s,\n", jsString(strings.ToLower(c.Method)))
+	fmt.Fprintf(&b, "  url: %s,\n", jsString(c.URL))
+	if len(c.Params) > 0 {
+		fmt.Fprintf(&b, "  params: %s,\n", jsPairs(c.Params, "  "))
+	}
+	if c.Body == "multipart" {
+		b.WriteString("  headers: {\n    ...form.getHeaders(),\n")
+		for _, h := range c.Headers {
+			fmt.Fprintf(&b, "    %s: %s,\n", jsString(h[0]), jsString(h[1]))
+		}
+		b.WriteString("  },\n")
+	} else if len(c.Headers) > 0 {
+		fmt.Fprintf(&b, "  headers: %s,\n", jsPairs(c.Headers, "  "))
+	}
+	if c.Auth == "basic" {
+		fmt.Fprintf(&b, "  auth: { username: %s, password: %s },\n", jsString(c.Username), jsString(c.Password))
+	}
+	switch c.Body {
+	case "json":
+		fmt.Fprintf(&b, "  data: %s,\n", c.payloadJSON("  "))
+	case "raw":
+		fmt.Fprintf(&b, "  data: %s,\n", jsString(c.Raw))
+	case "form":
+		fmt.Fprintf(&b, "  data: new URLSearchParams(%s),\n", jsString(c.formString()))
+	case "multipart":
+		b.WriteString("  data: form,\n")
+	}
+	if c.Insecure {
+		b.WriteString("  httpsAgent: new https.Agent({ rejectUnauthorized: false }),\n")
+	}
+	b.WriteString("});\nconsole.log(response.status);\nconsole.log(response.data);\n")
+	return b.String()
+}
+
+// --- Command line tools ---
+
+func curlCode(c codeRequest) string {
+	command, _ := c.request.Curl(CurlOptions{Secrets: c.Secrets})
+	return command
+}
+
+func shellEnv(name string) string {
+	return `'"$` + name + `"'`
+}
+
+// shellQuote quotes s for a POSIX shell, leaving safe words as they are
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@%+,") == "" && !envPlaceholders.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellCommand writes a command, one option per line, each note as a comment
+func shellCommand(notes []string, parts []string) string {
+	var b strings.Builder
+	for _, note := range notes {
+		fmt.Fprintf(&b, "# TODO: %s\n", note)
+	}
+	b.WriteString(strings.Join(parts, " \\\n  "))
+	return b.String()
+}
+
+func httpieCode(c codeRequest) string {
+	parts := []string{"http"}
+	switch c.Body {
+	case "form":
+		parts = append(parts, "--form")
+	case "multipart":
+		parts = append(parts, "--multipart")
+	}
+	switch c.Auth {
+	case "basic":
+		parts = append(parts, "--auth="+shellQuote(c.Username+":"+c.Password))
+	case "digest":
+		parts = append(parts, "--auth-type=digest", "--auth="+shellQuote(c.Username+":"+c.Password))
+	}
+	if c.Insecure {
+		parts = append(parts, "--verify=no")
+	}
+	if c.Body == "raw" {
+		parts = append(parts, "--raw="+shellQuote(c.Raw))
+	}
+	parts = append(parts, c.Method, shellQuote(c.URL))
+	for _, p := range c.Params {
+		parts = append(parts, shellQuote(p[0]+"=="+p[1]))
+	}
+	for _, h := range c.Headers {
+		if h[1] == "" {
+			parts = append(parts, shellQuote(h[0]+";"))
+			continue
+		}
+		parts = append(parts, shellQuote(h[0]+":"+h[1]))
+	}
+	switch c.Body {
+	case "json":
+		for _, k := range sortedKeys(c.Payload) {
+			if s, ok := c.Payload[k].(string); ok {
+				parts = append(parts, shellQuote(k+"="+s))
+				continue
+			}
+			value, _ := json.Marshal(c.Payload[k])
+			parts = append(parts, shellQuote(k+":="+string(value)))
+		}
+	case "form", "multipart":
+		for _, f := range c.Form {
+			if f.File == "" {
+				parts = append(parts, shellQuote(f.Name+"="+f.Value))
+				continue
+			}
+			item := f.Name + "@" + f.File
+			if f.ContentType != "" {
+				item += ";type=" + f.ContentType
+			}
+			parts = append(parts, shellQuote(item))
+		}
+	}
+	return shellCommand(c.Notes, parts)
+}
+
+func wgetCode(c codeRequest) string {
+	notes := c.Notes
+	parts := []string{"wget", "--quiet", "--output-document=-", "--method=" + c.Method}
+	if c.Insecure {
+		parts = append(parts, "--no-check-certificate")
+	}
+	switch c.Auth {
+	case "basic":
+		// Sent with the first call, not after a 401
+		parts = append(parts, "--auth-no-challenge", "--user="+shellQuote(c.Username), "--password="+shellQuote(c.Password))
+	case "digest":
+		parts = append(parts, "--user="+shellQuote(c.Username), "--password="+shellQuote(c.Password))
+	}
+	for _, h := range c.Headers {
+		parts = append(parts, "--header="+shellQuote(h[0]+": "+h[1]))
+	}
+	switch c.Body {
+	case "json":
+		parts = append(parts, "--body-data="+shellQuote(c.payloadJSON("")))
+	case "raw":
+		parts = append(parts, "--body-data="+shellQuote(c.Raw))
+	case "form":
+		if _, ok := c.header("Content-Type"); !ok {
+			parts = append(parts, "--header="+shellQuote("Content-Type: application/x-www-form-urlencoded"))
+		}
+		parts = append(parts, "--body-data="+shellQuote(c.formString()))
+	case "multipart":
+		notes = append(notes, "wget can't send multipart bodies, use curl or HTTPie")
+	}
+	parts = append(parts, shellQuote(c.FullURL()))
+	return shellCommand(notes, parts)
+}
+
+// --- PowerShell ---
+
+// psString quotes s as a verbatim PowerShell string, or an expandable one
+// when it reads an environment variable
+func psString(s string) string {
+	if envPlaceholders.MatchString(s) {
+		return `"` + strings.NewReplacer("`", "``", `"`, "`\"", "$", "`$").Replace(s) + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// psHereStringEnds reports whether a line of content would end a
+// here-string, PowerShell 7 allows whitespace before the terminator
+func psHereStringEnds(content string) bool {
+	for _, l := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimLeft(l, " \t"), "'@") {
+			return true
+		}
+	}
+	return false
+}
+
+// Methods of the -Method parameter, the others need -CustomMethod
+var psMethods = map[string]string{
+	"GET": "Get", "HEAD": "Head", "POST": "Post", "PUT": "Put", "DELETE": "Delete",
+	"TRACE": "Trace", "OPTIONS": "Options", "PATCH": "Patch",
+}
+
+func powershellCode(c codeRequest) string {
+	var b strings.Builder
+	for _, note := range c.Notes {
+		fmt.Fprintf(&b, "# TODO: %s\n", note)
+	}
+	args := []string{"-Uri " + psString(c.FullURL())}
+	if method, ok := psMethods[c.Method]; ok {
+		args = append(args, "-Method "+method)
+	} else {
+		args = append(args, "-CustomMethod "+psString(c.Method))
+	}
+
+	// Content-Type is a parameter of its own
+	var headers [][2]string
+	contentType := ""
+	for _, h := range c.Headers {
+		if strings.EqualFold(h[0], "Content-Type") {
+			contentType = h[1]
+			continue
+		}
+		headers = append(headers, h)
+	}
+	if len(headers) > 0 || c.Auth == "basic" {
+		b.WriteString("$headers = @{\n")
+		for _, h := range headers {
+			fmt.Fprintf(&b, "    %s = %s\n", psString(h[0]), psString(h[1]))
+		}
+		if c.Auth == "basic" {
+			fmt.Fprintf(&b, "    'Authorization' = 'Basic ' + [Convert]::ToBase64String([Text.Encoding]::UTF8.GetBytes(%s))\n", psString(c.Username+":"+c.Password))
+		}
+		b.WriteString("}\n")
+		args = append(args, "-Headers $headers")
+	}
+	if c.Auth == "digest" {
+		fmt.Fprintf(&b, "$credential = New-Object System.Management.Automation.PSCredential(%s, (ConvertTo-SecureString %s -AsPlainText -Force))\n", psString(c.Username), psString(c.Password))
+		args = append(args, "-Credential $credential")
+	}
+
+	switch c.Body {
+	case "json", "raw":
+		content := c.Raw
+		if c.Body == "json" {
+			content = c.payloadJSON("")
+		}
+		// A verbatim here-string can't hold its own terminator, nor read a variable
+		if psHereStringEnds(content) || envPlaceholders.MatchString(content) {
+			fmt.Fprintf(&b, "$body = %s\n", psString(content))
+		} else {
+			fmt.Fprintf(&b, "$body = @'\n%s\n'@\n", content)
+		}
+		args = append(args, "-Body $body")
+	case "form":
+		b.WriteString("$body = [ordered]@{\n")
+		for _, f := range c.Form {
+			fmt.Fprintf(&b, "    %s = %s\n", psString(f.Name), psString(f.Value))
+		}
+		b.WriteString("}\n")
+		args = append(args, "-Body $body")
+		if contentType == "" {
+			contentType = "application/x-www-form-urlencoded"
+		}
+	case "multipart":
+		b.WriteString("$form = @{\n")
+		for _, f := range c.Form {
+			if f.File == "" {
+				fmt.Fprintf(&b, "    %s = %s\n", psString(f.Name), psString(f.Value))
+				continue
+			}
+			fmt.Fprintf(&b, "    %s = Get-Item -Path %s\n", psString(f.Name), psString(f.File))
+		}
+		b.WriteString("}\n")
+		args = append(args, "-Form $form")
+		contentType = ""
+	}
+	if contentType != "" {
+		args = append(args, "-ContentType "+psString(contentType))
+	}
+	if c.Insecure {
+		args = append(args, "-SkipCertificateCheck")
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("$response = Invoke-RestMethod " + strings.Join(args, " `\n    ") + "\n$response\n")
+	return b.String()
+}
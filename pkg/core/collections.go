@@ -0,0 +1,204 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+Collections
+Requests are organized in nested folders through Request.Collection, a
+slash separated path such as "shop/orders". Each folder can carry defaults
+(headers, auth, base URL, variables) inherited by the requests below it.
+*/
+type Folder struct {
+	BaseURL     string                 `json:"baseUrl,omitempty"`
+	Headers     map[string]interface{} `json:"headers,omitempty"`
+	Auth        *AuthConfig            `json:"auth,omitempty"`
+	AuthProfile string                 `json:"authProfile,omitempty"`
+	Variables   map[string]string      `json:"variables,omitempty"`
+}
+
+/*
+CleanCollection normalizes a collection path: "/shop//orders/" becomes "shop/orders"
+*/
+func CleanCollection(path string) string {
+	var parts []string
+	for _, part := range strings.Split(path, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+/*
+InCollection reports whether a collection path is path itself or one of its subfolders
+*/
+func InCollection(collection, path string) bool {
+	collection, path = CleanCollection(collection), CleanCollection(path)
+	return path == "" || collection == path || strings.HasPrefix(collection, path+"/")
+}
+
+/*
+Collections returns every folder path, from the folder settings and the
+requests, including intermediate folders, sorted alphabetically
+*/
+func (db *Database) Collections() []string {
+	seen := map[string]bool{}
+	add := func(path string) {
+		parts := strings.Split(CleanCollection(path), "/")
+		for i := range parts {
+			if parts[i] != "" {
+				seen[strings.Join(parts[:i+1], "/")] = true
+			}
+		}
+	}
+	for path := range db.Folders {
+		add(path)
+	}
+	for _, r := range db.Data {
+		add(r.Collection)
+	}
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+/*
+Subfolders returns the direct children of a folder, sorted alphabetically
+*/
+func (db *Database) Subfolders(path string) []string {
+	path = CleanCollection(path)
+	var children []string
+	for _, c := range db.Collections() {
+		if !InCollection(c, path) || c == path {
+			continue
+		}
+		rest := strings.TrimPrefix(strings.TrimPrefix(c, path), "/")
+		if !strings.Contains(rest, "/") {
+			children = append(children, c)
+		}
+	}
+	return children
+}
+
+/*
+FolderRequests returns the names of the requests directly in a folder, sorted alphabetically
+*/
+func (db *Database) FolderRequests(path string) []string {
+	path = CleanCollection(path)
+	var names []string
+	for name, r := range db.Data {
+		if CleanCollection(r.Collection) == path {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+SaveFolder creates or replaces the defaults of a folder
+*/
+func (db *Database) SaveFolder(path string, folder Folder) error {
+	path = CleanCollection(path)
+	if path == "" {
+		return fmt.Errorf("collection path is required")
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.Folders == nil {
+		db.Folders = map[string]Folder{}
+	}
+	db.Folders[path] = folder
+	return db.saveLocked()
+}
+
+/*
+DeleteFolder removes the defaults of a folder that no request uses anymore
+*/
+func (db *Database) DeleteFolder(path string) error {
+	path = CleanCollection(path)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for name, r := range db.Data {
+		if InCollection(r.Collection, path) {
+			return fmt.Errorf("collection %q is not empty (request %q)", path, name)
+		}
+	}
+	for p := range db.Folders {
+		if InCollection(p, path) {
+			delete(db.Folders, p)
+		}
+	}
+	return db.saveLocked()
+}
+
+/*
+applyFolders merges the defaults of every folder from the collection root
+down to the request folder. Deeper folders override their parents and the
+request always wins.
+*/
+func (db *Database) applyFolders(r Request) Request {
+	parts := strings.Split(CleanCollection(r.Collection), "/")
+	if parts[0] == "" {
+		return r
+	}
+
+	headers := map[string]interface{}{}
+	var baseURL, authProfile string
+	var auth *AuthConfig
+	for i := range parts {
+		folder, ok := db.Folders[strings.Join(parts[:i+1], "/")]
+		if !ok {
+			continue
+		}
+		for k, v := range folder.Headers {
+			headers[k] = v
+		}
+		if folder.BaseURL != "" {
+			baseURL = folder.BaseURL
+		}
+		if folder.Auth != nil || folder.AuthProfile != "" {
+			auth, authProfile = folder.Auth, folder.AuthProfile
+		}
+	}
+
+	if len(headers) > 0 {
+		for k, v := range r.Headers {
+			headers[k] = v
+		}
+		r.Headers = headers
+	}
+	// A URL starting with a variable carries its own base
+	if baseURL != "" && !strings.Contains(r.URL, "://") && !strings.HasPrefix(r.URL, "{{") {
+		r.URL = strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(r.URL, "/")
+	}
+	if r.Auth == nil && r.AuthProfile == "" {
+		r.Auth, r.AuthProfile = auth, authProfile
+	}
+	return r
+}
+
+/*
+AuthFolder returns the folder a request inherits its auth from, or "" when
+the request defines its own auth or has none
+*/
+func (db *Database) AuthFolder(r Request) string {
+	if r.Auth != nil || r.AuthProfile != "" {
+		return ""
+	}
+	parts := strings.Split(CleanCollection(r.Collection), "/")
+	for i := len(parts); i > 0 && parts[0] != ""; i-- {
+		path := strings.Join(parts[:i], "/")
+		if f, ok := db.Folders[path]; ok && (f.Auth != nil || f.AuthProfile != "") {
+			return path
+		}
+	}
+	return ""
+}
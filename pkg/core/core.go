@@ -3,40 +3,91 @@ package core
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/PierreKieffer/http-tanker/pkg/color"
 	"github.com/charmbracelet/lipgloss"
 )
 
 type AuthConfig struct {
-	Type     string `json:"type"`               // "bearer", "basic", "api-key"
-	Token    string `json:"token,omitempty"`     // pour bearer
-	Username string `json:"username,omitempty"`  // pour basic
-	Password string `json:"password,omitempty"`  // pour basic
-	Key      string `json:"key,omitempty"`       // pour api-key
-	Header   string `json:"header,omitempty"`    // nom du header pour api-key (défaut: "X-API-Key")
+	Type            string     `json:"type"`                      // "bearer", "basic", "api-key", "oauth2", "digest", "aws-sigv4", "hmac", "jwt"
+	Token           string     `json:"token,omitempty"`           // pour bearer
+	Username        string     `json:"username,omitempty"`        // pour basic et digest
+	Password        string     `json:"password,omitempty"`        // pour basic et digest
+	Key             string     `json:"key,omitempty"`             // pour api-key
+	Header          string     `json:"header,omitempty"`          // nom du header pour api-key (défaut: "X-API-Key"), hmac (défaut: "X-Signature") et jwt (défaut: Authorization Bearer)
+	GrantType       string     `json:"grantType,omitempty"`       // pour oauth2 : "client_credentials" (défaut), "refresh_token", "authorization_code"
+	TokenURL        string     `json:"tokenUrl,omitempty"`        // pour oauth2
+	ClientID        string     `json:"clientId,omitempty"`        // pour oauth2
+	ClientSecret    string     `json:"clientSecret,omitempty"`    // pour oauth2
+	Scopes          []string   `json:"scopes,omitempty"`          // pour oauth2
+	Audience        string     `json:"audience,omitempty"`        // pour oauth2
+	RefreshToken    string     `json:"refreshToken,omitempty"`    // pour oauth2 (grants refresh_token et authorization_code)
+	AuthURL         string     `json:"authUrl,omitempty"`         // pour oauth2 (grant authorization_code)
+	RedirectURL     string     `json:"redirectUrl,omitempty"`     // pour oauth2 (grant authorization_code), loopback uniquement
+	AccessToken     string     `json:"accessToken,omitempty"`     // pour oauth2, obtenu après login
+	TokenExpiry     *time.Time `json:"tokenExpiry,omitempty"`     // pour oauth2, expiration de AccessToken
+	AccessKey       string     `json:"accessKey,omitempty"`       // pour aws-sigv4 (défaut: variables d'environnement AWS)
+	SecretKey       string     `json:"secretKey,omitempty"`       // pour aws-sigv4
+	SessionToken    string     `json:"sessionToken,omitempty"`    // pour aws-sigv4
+	Profile         string     `json:"profile,omitempty"`         // pour aws-sigv4, profil du fichier ~/.aws/credentials
+	Region          string     `json:"region,omitempty"`          // pour aws-sigv4
+	Service         string     `json:"service,omitempty"`         // pour aws-sigv4 : "execute-api", "s3", "es"...
+	Secret          string     `json:"secret,omitempty"`          // pour hmac et jwt (HS256...)
+	Algorithm       string     `json:"algorithm,omitempty"`       // pour hmac : "sha256" (défaut), "sha1", "sha512" ; pour jwt : "HS256" (défaut), "RS256", "ES256", "PS256", "EdDSA"...
+	Template        string     `json:"template,omitempty"`        // pour hmac, chaîne canonique (défaut: DefaultHMACTemplate)
+	Encoding        string     `json:"encoding,omitempty"`        // pour hmac : "hex" (défaut), "base64", "base64url"
+	SignaturePrefix string     `json:"signaturePrefix,omitempty"` // pour hmac, préfixe de la signature (ex: "sha256=")
+	TimestampHeader string     `json:"timestampHeader,omitempty"` // pour hmac, header portant le timestamp signé
+	NonceHeader     string     `json:"nonceHeader,omitempty"`     // pour hmac, header portant le nonce signé
+	KeyFile         string     `json:"keyFile,omitempty"`         // pour jwt, clé privée PEM (ou secret pour HS*)
+	KeyID           string     `json:"keyId,omitempty"`           // pour jwt, header kid
+	Claims          string     `json:"claims,omitempty"`          // pour jwt, template JSON des claims (défaut: DefaultJWTClaims)
+	TTL             string     `json:"ttl,omitempty"`             // pour jwt, durée de validité (défaut: 5m)
 }
 
 type Request struct {
-	Name     string                 `json:"name"`
-	Method   string                 `json:"method"`
-	URL      string                 `json:"url"`
-	Params   map[string]interface{} `json:"params,omitempty"`
-	Payload  map[string]interface{} `json:"payload,omitempty"`
-	Headers  map[string]interface{} `json:"headers"`
-	Insecure bool                   `json:"insecure,omitempty"`
-	Auth     *AuthConfig            `json:"auth,omitempty"`
+	Name        string                 `json:"name"`
+	Method      string                 `json:"method"`
+	URL         string                 `json:"url"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+	Payload     map[string]interface{} `json:"payload,omitempty"`
+	BodyType    string                 `json:"bodyType,omitempty"` // "json" (défaut, Payload), "raw" (Body), "form" ou "multipart" (Form)
+	Body        string                 `json:"body,omitempty"`     // pour bodyType raw
+	Form        []FormField            `json:"form,omitempty"`     // pour bodyType form et multipart
+	Headers     map[string]interface{} `json:"headers"`
+	Insecure    bool                   `json:"insecure,omitempty"`
+	Auth        *AuthConfig            `json:"auth,omitempty"`
+	AuthProfile string                 `json:"authProfile,omitempty"`
+	Collection  string                 `json:"collection,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	LastUsed    *time.Time             `json:"lastUsed,omitempty"`
+	Source      *RequestSource         `json:"source,omitempty"`     // origine d'un import (OpenAPI), pour les ré-imports
+	Assertions  []Assertion            `json:"assertions,omitempty"` // vérifiées après chaque exécution
 }
 
 type Database struct {
-	DatabaseDir  string `json:"databaseDir"`
-	DatabaseFile string `json:"databaseFile"`
-	mu           sync.Mutex
-	Data         map[string]Request `json:"data"`
+	DatabaseDir      string        `json:"databaseDir"`
+	DatabaseFile     string        `json:"databaseFile"`
+	HistoryRetention time.Duration `json:"-"` // durée de conservation de l'historique, 0 pour tout conserver
+	HistoryBodyLimit int           `json:"-"` // taille max des réponses dans l'historique (défaut: DefaultHistoryBodyLimit)
+	RevisionLimit    int           `json:"-"` // nombre de révisions conservées par requête (défaut: DefaultRevisionLimit)
+	Storage          Storage       `json:"-"` // défaut: FileStorage sur DatabaseFile
+	EnvironmentUsed  string        `json:"-"` // environnement utilisé à la place de l'actif, sans être enregistré
+	mu               sync.Mutex
+	base             map[string]snapshot
+	stamp            string
+	notes            map[string]revisionNote // renames, restores and rollbacks waiting for the next save
+	baseEnvironment  string
+	Data             map[string]Request     `json:"data"`
+	Profiles         map[string]AuthConfig  `json:"profiles"`
+	Folders          map[string]Folder      `json:"folders"`
+	Environments     map[string]Environment `json:"environments"`
+	Environment      string                 `json:"environment"` // environnement actif, "" pour aucun
 }
 
 /*
@@ -57,7 +108,8 @@ func (db *Database) InitDB() error {
 		return err
 	}
 
-	return nil
+	// OAuth 2.0 tokens are shared by every process using this database
+	return Tokens.SetFile(filepath.Join(db.DatabaseDir, "http-tanker-tokens.json"))
 }
 
 /*
@@ -71,8 +123,20 @@ func (db *Database) Load() error {
 
 func (db *Database) loadLocked() error {
 
+	// Upgrade files written by older versions
+	if u, ok := db.storage().(interface{ Upgrade() error }); ok {
+		if err := u.Upgrade(); err != nil {
+			return err
+		}
+	}
+
+	contents, exists, err := db.storage().Load()
+	if err != nil {
+		return err
+	}
+
 	// Check if database file exists
-	if _, err := os.Stat(db.DatabaseFile); os.IsNotExist(err) {
+	if !exists {
 		// Initialize json database with example data
 		var data = map[string]Request{
 			"get-example": {
@@ -137,29 +201,32 @@ func (db *Database) loadLocked() error {
 			},
 		}
 		db.Data = data
+		db.Profiles = map[string]AuthConfig{}
+		db.Folders = map[string]Folder{}
+		db.Environments = map[string]Environment{}
 
 		return db.saveLocked()
 	}
 
 	// Get data
-	var data map[string]Request
-
-	jsonFile, err := os.Open(db.DatabaseFile)
-	if err != nil {
-		return err
+	db.Data = contents.Requests
+	db.Profiles = contents.Profiles
+	db.Folders = contents.Folders
+	db.Environments = contents.Environments
+	db.Environment = contents.Environment
+	if db.Data == nil {
+		db.Data = map[string]Request{}
 	}
-	defer jsonFile.Close()
-
-	byteValue, err := io.ReadAll(jsonFile)
-	if err != nil {
-		return err
+	if db.Profiles == nil {
+		db.Profiles = map[string]AuthConfig{}
 	}
-	err = json.Unmarshal(byteValue, &data)
-	if err != nil {
-		return err
+	if db.Folders == nil {
+		db.Folders = map[string]Folder{}
 	}
-
-	db.Data = data
+	if db.Environments == nil {
+		db.Environments = map[string]Environment{}
+	}
+	db.snapshotLocked()
 
 	return nil
 }
@@ -173,12 +240,9 @@ func (db *Database) Save() error {
 	return db.saveLocked()
 }
 
+// Local changes are merged with the changes saved by other processes
 func (db *Database) saveLocked() error {
-	buffer, err := json.Marshal(db.Data)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(db.DatabaseFile, buffer, 0600)
+	return db.commitLocked(true)
 }
 
 /*
@@ -188,7 +252,8 @@ func (db *Database) Reset() error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 	db.Data = map[string]Request{}
-	return db.saveLocked()
+	// Overwrite instead of merging, every request must go
+	return db.commitLocked(false)
 }
 
 /*
@@ -201,6 +266,22 @@ func (db *Database) Delete(reqName string) error {
 	return db.saveLocked()
 }
 
+/*
+Add saves a new request, refusing to overwrite an existing one
+*/
+func (db *Database) Add(r Request) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if r.Name == "" {
+		return fmt.Errorf("request name can't be empty")
+	}
+	if _, exists := db.Data[r.Name]; exists {
+		return fmt.Errorf("a request named %q already exists", r.Name)
+	}
+	db.Data[r.Name] = r
+	return db.saveLocked()
+}
+
 /*
 Display request
 */
@@ -210,6 +291,12 @@ func (db *Database) Display(requestName string) {
 
 	var lines []string
 	lines = append(lines, "Name   : "+r.Name)
+	if r.Collection != "" {
+		lines = append(lines, "Folder : "+CleanCollection(r.Collection))
+	}
+	if len(r.Tags) > 0 {
+		lines = append(lines, "Tags   : #"+strings.Join(r.Tags, " #"))
+	}
 	lines = append(lines, "Method : "+color.MethodStyle(r.Method).Render(r.Method))
 	lines = append(lines, "URL    : "+r.URL)
 	if len(r.Params) > 0 {
@@ -220,29 +307,194 @@ func (db *Database) Display(requestName string) {
 		jsonPayload, _ := json.MarshalIndent(r.Payload, "", "    ")
 		lines = append(lines, "Payload :\n"+string(jsonPayload))
 	}
+	if r.Body != "" {
+		lines = append(lines, "Body   : "+r.Body)
+	}
+	if len(r.Form) > 0 {
+		form := make([]string, 0, len(r.Form))
+		for _, field := range r.Form {
+			form = append(form, "  "+field.String())
+		}
+		lines = append(lines, "Form ("+r.BodyType+") :\n"+strings.Join(form, "\n"))
+	}
 	if len(r.Headers) > 0 {
 		jsonHeaders, _ := json.MarshalIndent(r.Headers, "", "    ")
 		lines = append(lines, "Headers :\n"+string(jsonHeaders))
 	}
-	if r.Auth != nil {
-		switch r.Auth.Type {
-		case "bearer":
-			lines = append(lines, "Auth     : Bearer "+maskSecret(r.Auth.Token))
-		case "basic":
-			lines = append(lines, "Auth     : Basic "+r.Auth.Username+":"+maskSecret(r.Auth.Password))
-		case "api-key":
-			header := r.Auth.Header
-			if header == "" {
-				header = "X-API-Key"
-			}
-			lines = append(lines, "Auth     : API Key ["+header+"] "+maskSecret(r.Auth.Key))
+	if r.AuthProfile != "" {
+		authType := "missing"
+		if profile, ok := db.Profiles[r.AuthProfile]; ok {
+			authType = profile.Type
 		}
+		lines = append(lines, "Auth     : profile "+r.AuthProfile+" ("+authType+")")
+	} else if r.Auth != nil {
+		lines = append(lines, AuthLines(r.Auth)...)
 	}
 	if r.Insecure {
 		lines = append(lines, "Insecure : true (TLS verification skipped)")
 	}
+	if len(r.Assertions) > 0 {
+		assertions := make([]string, 0, len(r.Assertions))
+		for _, a := range r.Assertions {
+			assertions = append(assertions, "  "+a.String())
+		}
+		lines = append(lines, "Assertions :\n"+strings.Join(assertions, "\n"))
+	}
+	if r.LastUsed != nil {
+		lines = append(lines, "Last used : "+r.LastUsed.Local().Format(time.RFC1123))
+	}
 	DrawBox("Request details", lines)
 }
+
+/*
+AuthLines
+Auth config summary with masked secrets
+*/
+func AuthLines(auth *AuthConfig) []string {
+	var lines []string
+	switch auth.Type {
+	case "bearer":
+		lines = append(lines, "Auth     : Bearer "+maskSecret(auth.Token))
+	case "basic":
+		lines = append(lines, "Auth     : Basic "+auth.Username+":"+maskSecret(auth.Password))
+	case "digest":
+		lines = append(lines, "Auth     : Digest "+auth.Username+":"+maskSecret(auth.Password))
+	case "aws-sigv4":
+		credentials := "from environment"
+		if auth.AccessKey != "" {
+			credentials = maskSecret(auth.AccessKey)
+		} else if auth.Profile != "" {
+			credentials = "profile " + auth.Profile
+		}
+		lines = append(lines, "Auth     : AWS SigV4 "+auth.Service+"/"+awsRegion(auth)+" "+credentials)
+	case "hmac":
+		header := auth.Header
+		if header == "" {
+			header = "X-Signature"
+		}
+		algorithm := auth.Algorithm
+		if algorithm == "" {
+			algorithm = "sha256"
+		}
+		lines = append(lines, "Auth     : HMAC-"+algorithm+" ["+header+"] "+maskSecret(auth.Secret))
+	case "jwt":
+		algorithm := auth.Algorithm
+		if algorithm == "" {
+			algorithm = "HS256"
+		}
+		key := auth.KeyFile
+		if key == "" {
+			key = maskSecret(auth.Secret)
+		}
+		lines = append(lines, "Auth     : JWT "+algorithm+" "+key)
+	case "api-key":
+		header := auth.Header
+		if header == "" {
+			header = "X-API-Key"
+		}
+		lines = append(lines, "Auth     : API Key ["+header+"] "+maskSecret(auth.Key))
+	case "oauth2":
+		lines = append(lines, "Auth     : OAuth2 "+grantType(auth)+" "+auth.ClientID+" @ "+auth.TokenURL)
+		if len(auth.Scopes) > 0 {
+			lines = append(lines, "Scopes   : "+strings.Join(auth.Scopes, " "))
+		}
+		if grantType(auth) == "authorization_code" {
+			switch {
+			case auth.AccessToken == "" && auth.RefreshToken == "":
+				lines = append(lines, "Login    : required")
+			case auth.TokenExpiry != nil:
+				lines = append(lines, "Login    : token "+maskSecret(auth.AccessToken)+" until "+auth.TokenExpiry.Format(time.RFC3339))
+			default:
+				lines = append(lines, "Login    : token "+maskSecret(auth.AccessToken))
+			}
+		}
+	}
+	return lines
+}
+
+/*
+MaskedAuth
+Copy of an auth config with its secrets masked, nil for no auth
+*/
+func MaskedAuth(auth *AuthConfig) *AuthConfig {
+	if auth == nil {
+		return nil
+	}
+	masked := *auth
+	for _, secret := range []*string{
+		&masked.Token, &masked.Password, &masked.Key, &masked.ClientSecret, &masked.RefreshToken,
+		&masked.AccessToken, &masked.SecretKey, &masked.SessionToken, &masked.Secret,
+	} {
+		if *secret != "" {
+			*secret = maskSecret(*secret)
+		}
+	}
+	return &masked
+}
+
+/*
+MaskedRequest
+Copy of a request with its secrets masked: the auth, and the headers,
+params, form fields and JSON body fields named like a secret
+*/
+func MaskedRequest(r Request) Request {
+	r.Auth = MaskedAuth(r.Auth)
+	r.Headers = maskedFields(r.Headers)
+	r.Params = maskedFields(r.Params)
+	r.Payload = maskedFields(r.Payload)
+	if r.Form != nil {
+		form := make([]FormField, len(r.Form))
+		for i, f := range r.Form {
+			if f.File == "" && f.Value != "" && secretNamePattern.MatchString(f.Name) {
+				f.Value = maskSecret(f.Value)
+			}
+			form[i] = f
+		}
+		r.Form = form
+	}
+	var body interface{}
+	if r.BodyType == "raw" && json.Unmarshal([]byte(r.Body), &body) == nil && hasSecretField(body) {
+		buffer, _ := json.Marshal(maskedValue(body))
+		r.Body = string(buffer)
+	}
+	return r
+}
+
+func maskedFields(fields map[string]interface{}) map[string]interface{} {
+	if fields == nil {
+		return nil
+	}
+	return maskedValue(fields).(map[string]interface{})
+}
+
+// maskedValue copies a JSON value, masking the fields named like a secret,
+// the objects and lists under such a name are walked as well
+func maskedValue(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(node))
+		for k, value := range node {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}, nil:
+				masked[k] = maskedValue(value)
+			default:
+				if secretNamePattern.MatchString(k) {
+					value = maskSecret(fmt.Sprint(value))
+				}
+				masked[k] = value
+			}
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(node))
+		for i, value := range node {
+			masked[i] = maskedValue(value)
+		}
+		return masked
+	}
+	return v
+}
+
 func maskSecret(s string) string {
 	if len(s) <= 4 {
 		return "****"
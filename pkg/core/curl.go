@@ -0,0 +1,883 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+)
+
+/*
+cURL import
+ParseCurl is the inverse of CurlCommand: it reads a curl command line, as
+copied from docs or browser devtools, into a Request.
+*/
+
+// Options taking a value that have no equivalent in a request
+var curlIgnoredWithValue = map[string]bool{
+	"-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+	"--retry": true, "--retry-delay": true, "--retry-max-time": true, "-w": true, "--write-out": true,
+	"-x": true, "--proxy": true, "--resolve": true, "--cacert": true, "--cert": true, "--key": true,
+	"-c": true, "--cookie-jar": true, "--limit-rate": true, "-r": true, "--range": true,
+}
+
+// Options without value that change nothing for a saved request
+var curlIgnored = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-v": true, "--verbose": true,
+	"-i": true, "--include": true, "-L": true, "--location": true, "-f": true, "--fail": true,
+	"-N": true, "--no-buffer": true, "-#": true, "--progress-bar": true, "-O": true, "--remote-name": true,
+	"--http1.1": true, "--http2": true, "-g": true, "--globoff": true,
+	// Go decompresses gzip responses by itself
+	"--compressed": true,
+}
+
+// Short options taking a value, used to split grouped flags like -sSLX
+const curlShortWithValue = "XHdFuAebomwxrc"
+
+/*
+CurlImportOptions
+How a curl command is read
+*/
+type CurlImportOptions struct {
+	ReadFiles bool // lit les fichiers de -d @file et -F name=<file, refusés sinon
+}
+
+/*
+ParseCurl converts a curl command into a request. The returned warnings list
+the options that were ignored. The files of -d @file and -F name=<file are
+not read, see ParseCurlWith.
+*/
+func ParseCurl(command string) (Request, []string, error) {
+	return ParseCurlWith(command, CurlImportOptions{})
+}
+
+/*
+ParseCurlWith converts a curl command into a request, reading the local
+files of the command when the options allow it
+*/
+func ParseCurlWith(command string, opts CurlImportOptions) (Request, []string, error) {
+	command = strings.ReplaceAll(command, "\r\n", "\n")
+	command = strings.TrimPrefix(strings.TrimSpace(command), "$ ")
+	args, err := shellquote.Split(command)
+	if err != nil {
+		return Request{}, nil, fmt.Errorf("invalid curl command: %w", err)
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return Request{}, nil, fmt.Errorf("not a curl command")
+	}
+
+	r := Request{Headers: map[string]interface{}{}}
+	var (
+		warnings  []string
+		data      []string
+		form      []FormField
+		user      string
+		digest    bool
+		getData   bool
+		head      bool
+		rawURL    string
+		urlencode bool
+	)
+
+	args = expandCurlFlags(args[1:])
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("missing value for %s", arg)
+			}
+			i++
+			return args[i], nil
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if rawURL == "" {
+				rawURL = arg
+			} else {
+				warnings = append(warnings, "extra URL ignored: "+arg)
+			}
+			continue
+		}
+
+		var v string
+		if strings.HasPrefix(arg, "--") {
+			if name, inline, ok := strings.Cut(arg, "="); ok {
+				arg, v = name, inline
+				args = append(args[:i+1], append([]string{v}, args[i+1:]...)...)
+			}
+		}
+
+		switch arg {
+		case "-X", "--request":
+			if v, err = value(); err != nil {
+				return r, warnings, err
+			}
+			r.Method = strings.ToUpper(v)
+		case "--url":
+			if rawURL, err = value(); err != nil {
+				return r, warnings, err
+			}
+		case "-H", "--header":
+			if v, err = value(); err != nil {
+				return r, warnings, err
+			}
+			name, headerValue, ok := strings.Cut(v, ":")
+			if !ok {
+				// "Name;" sends an empty header
+				if name, ok = strings.CutSuffix(v, ";"); !ok {
+					warnings = append(warnings, "invalid header ignored: "+v)
+					continue
+				}
+			}
+			r.Headers[strings.TrimSpace(name)] = strings.TrimSpace(headerValue)
+		case "-d", "--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode", "--json":
+			if v, err = value(); err != nil {
+				return r, warnings, err
+			}
+			if strings.HasPrefix(v, "@") && arg != "--data-raw" && arg != "--data-urlencode" {
+				if !opts.ReadFiles {
+					return r, warnings, fmt.Errorf("%s %s: local files are not read, paste the content instead", arg, v)
+				}
+				content, err := os.ReadFile(strings.TrimPrefix(v, "@"))
+				if err != nil {
+					return r, warnings, fmt.Errorf("%s %s: %w", arg, v, err)
+				}
+				v = string(content)
+				if arg != "--data-binary" && arg != "--json" {
+					// curl strips the newlines of -d @file
+					v = strings.NewReplacer("\r", "", "\n", "").Replace(v)
+				}
+			}
+			if arg == "--data-urlencode" {
+				urlencode = true
+				if name, content, ok := strings.Cut(v, "="); ok {
+					v = name + "=" + url.QueryEscape(content)
+				} else {
+					v = url.QueryEscape(v)
+				}
+			}
+			if arg == "--json" {
+				if _, ok := r.Headers["Content-Type"]; !ok {
+					r.Headers["Content-Type"] = "application/json"
+				}
+				if _, ok := r.Headers["Accept"]; !ok {
+					r.Headers["Accept"] = "application/json"
+				}
+			}
+			data = append(data, v)
+		case "-F", "--form", "--form-string":
+			if v, err = value(); err != nil {
+				return r, warnings, err
+			}
+			field, err := parseCurlFormField(v, arg == "--form-string", opts.ReadFiles)
+			if err != nil {
+				return r, warnings, err
+			}
+			form = append(form, field)
+		case "-u", "--user":
+			if user, err = value(); err != nil {
+				return r, warnings, err
+			}
+		case "--digest":
+			digest = true
+		case "--basic":
+			digest = false
+		case "--oauth2-bearer":
+			if v, err = value(); err != nil {
+				return r, warnings, err
+			}
+			r.Auth = &AuthConfig{Type: "bearer", Token: v}
+		case "-k", "--insecure":
+			r.Insecure = true
+		case "-G", "--get":
+			getData = true
+		case "-I", "--head":
+			head = true
+		case "-A", "--user-agent":
+			if v, err = value(); err != nil {
+				return r, warnings, err
+			}
+			r.Headers["User-Agent"] = v
+		case "-e", "--referer":
+			if v, err = value(); err != nil {
+				return r, warnings, err
+			}
+			r.Headers["Referer"] = v
+		case "-b", "--cookie":
+			if v, err = value(); err != nil {
+				return r, warnings, err
+			}
+			if !strings.Contains(v, "=") {
+				warnings = append(warnings, "cookie file ignored: "+v)
+				continue
+			}
+			r.Headers["Cookie"] = v
+		default:
+			if curlIgnoredWithValue[arg] {
+				if _, err = value(); err != nil {
+					return r, warnings, err
+				}
+			} else if !curlIgnored[arg] {
+				warnings = append(warnings, "unsupported option ignored: "+arg)
+			}
+		}
+	}
+
+	if rawURL == "" {
+		return r, warnings, fmt.Errorf("no URL in the curl command")
+	}
+	if !strings.Contains(rawURL, "://") {
+		// curl defaults to http
+		rawURL = "http://" + rawURL
+	}
+	r.URL = rawURL
+
+	switch {
+	case head:
+		r.Method = "HEAD"
+	case r.Method != "":
+	case (len(data) > 0 && !getData) || len(form) > 0:
+		r.Method = "POST"
+	default:
+		r.Method = "GET"
+	}
+
+	if user != "" {
+		username, password, _ := strings.Cut(user, ":")
+		r.Auth = &AuthConfig{Type: "basic", Username: username, Password: password}
+		if digest {
+			r.Auth.Type = "digest"
+		}
+	}
+	if r.Auth == nil {
+		liftBearerHeader(&r)
+	}
+
+	switch {
+	case len(form) > 0:
+		r.BodyType, r.Form = "multipart", form
+		// The boundary is generated when sending
+		for name, v := range r.Headers {
+			if strings.EqualFold(name, "Content-Type") && strings.HasPrefix(fmt.Sprint(v), "multipart/") {
+				delete(r.Headers, name)
+			}
+		}
+	case len(data) > 0 && getData:
+		separator := "?"
+		if strings.Contains(r.URL, "?") {
+			separator = "&"
+		}
+		r.URL += separator + strings.Join(data, "&")
+	case len(data) > 0:
+		setCurlBody(&r, strings.Join(data, "&"), urlencode)
+	}
+	return r, warnings, nil
+}
+
+// expandCurlFlags splits grouped short flags (-sSL, -XPOST) and keeps the rest
+func expandCurlFlags(args []string) []string {
+	var out []string
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") || len(arg) <= 2 {
+			out = append(out, arg)
+			continue
+		}
+		// Values of the previous option are never split
+		if i > 0 && len(out) > 0 && isCurlValueOption(out[len(out)-1]) {
+			out = append(out, arg)
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			flag := "-" + string(arg[j])
+			out = append(out, flag)
+			if strings.ContainsRune(curlShortWithValue, rune(arg[j])) {
+				if j+1 < len(arg) {
+					out = append(out, arg[j+1:])
+				}
+				break
+			}
+		}
+	}
+	return out
+}
+
+func isCurlValueOption(arg string) bool {
+	if len(arg) == 2 && arg[0] == '-' {
+		return strings.ContainsRune(curlShortWithValue, rune(arg[1]))
+	}
+	return false
+}
+
+func parseCurlFormField(v string, literal, readFiles bool) (FormField, error) {
+	name, content, ok := strings.Cut(v, "=")
+	if !ok {
+		return FormField{}, fmt.Errorf("invalid form field %q", v)
+	}
+	field := FormField{Name: name}
+	if literal || !strings.HasPrefix(content, "@") && !strings.HasPrefix(content, "<") {
+		field.Value = content
+		return field, nil
+	}
+	parts := strings.Split(content[1:], ";")
+	for _, option := range parts[1:] {
+		if t, ok := strings.CutPrefix(option, "type="); ok {
+			field.ContentType = t
+		}
+	}
+	if content[0] == '<' {
+		// Content of the file sent as a plain field
+		if !readFiles {
+			return FormField{}, fmt.Errorf("form field %s: local files are not read, paste the content instead", name)
+		}
+		buffer, err := os.ReadFile(parts[0])
+		if err != nil {
+			return FormField{}, fmt.Errorf("form field %s: %w", name, err)
+		}
+		field.Value = string(buffer)
+		return field, nil
+	}
+	field.File = parts[0]
+	return field, nil
+}
+
+// setCurlBody keeps a JSON object as an editable payload, key=value pairs as
+// form fields and anything else as a raw body
+func setCurlBody(r *Request, body string, urlencoded bool) {
+	contentType := ""
+	for name, v := range r.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			contentType = strings.ToLower(fmt.Sprint(v))
+		}
+	}
+
+	var payload map[string]interface{}
+	jsonMethod := r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH"
+	if jsonMethod && (contentType == "" || strings.Contains(contentType, "json")) && json.Unmarshal([]byte(body), &payload) == nil {
+		r.Payload = payload
+		if contentType == "" {
+			r.Headers["Content-Type"] = "application/json"
+		}
+		return
+	}
+
+	if contentType == "" || strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(body); err == nil && (urlencoded || strings.Contains(body, "=")) {
+			for _, pair := range strings.Split(body, "&") {
+				name, _, _ := strings.Cut(pair, "=")
+				name, _ = url.QueryUnescape(name)
+				if vs := values[name]; len(vs) > 0 {
+					r.Form = append(r.Form, FormField{Name: name, Value: vs[0]})
+					values[name] = vs[1:]
+				}
+			}
+			r.BodyType = "form"
+			return
+		}
+		if contentType == "" {
+			// What curl sends by default
+			r.Headers["Content-Type"] = "application/x-www-form-urlencoded"
+		}
+	}
+	r.BodyType, r.Body = "raw", body
+}
+
+// liftBearerHeader turns an Authorization: Bearer header into the bearer auth
+func liftBearerHeader(r *Request) {
+	for name, v := range r.Headers {
+		s := fmt.Sprint(v)
+		if strings.EqualFold(name, "Authorization") && len(s) > 7 && strings.EqualFold(s[:7], "Bearer ") {
+			r.Auth = &AuthConfig{Type: "bearer", Token: strings.TrimSpace(s[7:])}
+			delete(r.Headers, name)
+		}
+	}
+}
+
+/*
+DefaultRequestName name proposed for an imported request, e.g.
+"get api.example.com/users"
+*/
+func DefaultRequestName(method, rawURL string) string {
+	name := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		name = u.Host + strings.TrimSuffix(u.Path, "/")
+	}
+	return strings.ToLower(method) + " " + name
+}
+
+/*
+cURL export
+CurlCommand writes a resolved request as a curl command, quoted for a POSIX
+shell, PowerShell 7.3+ or the Windows cmd.
+*/
+
+/*
+CurlOptions
+How a curl command is written
+*/
+type CurlOptions struct {
+	Shell      string // "posix" (défaut), "powershell" ou "cmd"
+	Secrets    string // "" les écrit en clair, "redact" les masque, "env" les lit dans des variables d'environnement ($TOKEN)
+	SingleLine bool   // une seule ligne, pour un ticket ou un chat
+}
+
+var (
+	CurlShells  = []string{"posix", "powershell", "cmd"}
+	SecretModes = []string{"", "redact", "env"}
+)
+
+// Headers and params whose value is a secret
+var secretNamePattern = regexp.MustCompile(`(?i)^(authorization|proxy-authorization|cookie|key|sig|signature)$|token|secret|passw|api[-_]?key|session|credential`)
+
+var (
+	envNameSeparators = regexp.MustCompile(`[^A-Za-z0-9]+`)
+	authScheme        = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+)
+
+const redacted = "***"
+
+/*
+CurlCommand the request as a multi-line POSIX curl command, secrets included
+*/
+func (r *Request) CurlCommand() string {
+	command, _ := r.Curl(CurlOptions{})
+	return command
+}
+
+/*
+Curl writes the request as a curl command
+*/
+func (r *Request) Curl(opts CurlOptions) (string, error) {
+	switch opts.Shell {
+	case "":
+		opts.Shell = "posix"
+	case "posix", "powershell", "cmd":
+	default:
+		return "", fmt.Errorf("unknown shell %q, expected posix, powershell or cmd", opts.Shell)
+	}
+	switch opts.Secrets {
+	case "", "redact", "env":
+	default:
+		return "", fmt.Errorf("unknown secrets mode %q, expected redact or env", opts.Secrets)
+	}
+	w := curlWriter{opts: opts}
+	c := r.codeRequest()
+	// curl signs AWS requests itself, see --aws-sigv4 below
+	if r.Auth == nil || r.Auth.Type != "aws-sigv4" {
+		for _, note := range c.Notes {
+			w.comments = append(w.comments, "TODO: "+note)
+		}
+	}
+
+	program := "curl"
+	if opts.Shell == "powershell" {
+		// curl is an alias of Invoke-WebRequest in Windows PowerShell
+		program = "curl.exe"
+	}
+	target := shellWord{{text: c.URL}}
+	for i, p := range c.Params {
+		separator := "&"
+		if i == 0 && !strings.Contains(c.URL, "?") {
+			separator = "?"
+		}
+		target = append(target, shellPart{text: separator + url.QueryEscape(p[0]) + "="})
+		if secretNamePattern.MatchString(p[0]) {
+			target = append(target, w.secret(url.QueryEscape(p[1]), secretEnvName(p[0]))...)
+		} else {
+			target = append(target, shellPart{text: url.QueryEscape(p[1])})
+		}
+	}
+	w.line(literal(program), literal("-X"), literal(c.Method), target)
+	if c.Insecure {
+		w.line(literal("-k"))
+	}
+
+	authHeaders, _ := r.authHeaders()
+	for _, h := range c.Headers {
+		value := literal(h[1])
+		if isAuthHeader(authHeaders, h[0]) || secretNamePattern.MatchString(h[0]) {
+			value = w.secretValue(h[1], secretEnvName(h[0]))
+		}
+		word := append(literal(h[0]+": "), value...)
+		if h[1] == "" {
+			// "Name:" would remove the header
+			word = literal(h[0] + ";")
+		}
+		w.line(literal("-H"), word)
+	}
+	switch c.Auth {
+	case "basic":
+		w.line(literal("-u"), append(literal(c.Username+":"), w.secret(c.Password, "PASSWORD")...))
+	case "digest":
+		w.line(literal("--digest"), literal("-u"), append(literal(c.Username+":"), w.secret(c.Password, "PASSWORD")...))
+	}
+	if r.Auth != nil && r.Auth.Type == "aws-sigv4" {
+		w.line(literal("--aws-sigv4"), literal("aws:amz:"+awsRegion(r.Auth)+":"+r.Auth.Service))
+		creds, err := ResolveAWSCredentials(r.Auth)
+		if err != nil {
+			creds = AWSCredentials{}
+			w.opts.Secrets = "env"
+			w.comments = append(w.comments, "TODO: no AWS credentials configured, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		user := append(w.secret(creds.AccessKey, "AWS_ACCESS_KEY_ID"), shellPart{text: ":"})
+		w.line(literal("-u"), append(user, w.secret(creds.SecretKey, "AWS_SECRET_ACCESS_KEY")...))
+		if creds.SessionToken != "" || (err != nil && r.Auth.SessionToken != "") {
+			w.line(literal("-H"), append(literal("X-Amz-Security-Token: "), w.secret(creds.SessionToken, "AWS_SESSION_TOKEN")...))
+		}
+		w.opts.Secrets = opts.Secrets
+	}
+
+	switch c.Body {
+	case "json":
+		w.line(literal("--data-raw"), w.jsonBody(c.Payload))
+	case "raw":
+		if opts.Shell == "cmd" && strings.ContainsAny(c.Raw, "\r\n") {
+			w.comments = append(w.comments, "the body spans several lines, which cmd can't pass: save it to body.txt")
+			w.line(literal("--data-binary"), literal("@body.txt"))
+			break
+		}
+		var payload interface{}
+		if opts.Secrets != "" && json.Unmarshal([]byte(c.Raw), &payload) == nil && hasSecretField(payload) {
+			w.line(literal("--data-raw"), w.jsonBody(payload))
+			break
+		}
+		w.line(literal("--data-raw"), literal(c.Raw))
+	case "form":
+		for _, f := range c.Form {
+			w.line(literal("--data-urlencode"), w.field(f))
+		}
+	case "multipart":
+		for _, f := range c.Form {
+			if f.File == "" {
+				// -F would read a value starting with @ or < as a file
+				w.line(literal("--form-string"), w.field(f))
+				continue
+			}
+			file := f.File
+			if strings.ContainsAny(file, `;,"`) {
+				file = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(file) + `"`
+			}
+			field := f.Name + "=@" + file
+			if f.ContentType != "" {
+				field += ";type=" + f.ContentType
+			}
+			w.line(literal("-F"), literal(field))
+		}
+	}
+	return w.String(), nil
+}
+
+// isAuthHeader reports whether a header was added by the auth
+func isAuthHeader(headers [][2]string, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h[0], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretEnvName names the environment variable holding the secret of a
+// header, param or body field, e.g. X-API-Key gives API_KEY
+func secretEnvName(name string) string {
+	if strings.EqualFold(name, "Authorization") {
+		return "TOKEN"
+	}
+	env := strings.ToUpper(envNameSeparators.ReplaceAllString(name, "_"))
+	return strings.Trim(strings.TrimPrefix(env, "X_"), "_")
+}
+
+// shellWord is one argument, made of literal text and environment variables
+type shellWord []shellPart
+
+type shellPart struct {
+	text string
+	env  string // nom d'une variable d'environnement, à la place de text
+}
+
+func literal(s string) shellWord {
+	return shellWord{{text: s}}
+}
+
+// quote writes the word for a shell
+func (w shellWord) quote(shell string) string {
+	var b strings.Builder
+	switch shell {
+	case "powershell":
+		templated := false
+		for _, p := range w {
+			templated = templated || p.env != ""
+		}
+		if !templated {
+			return psWord(w.text())
+		}
+		b.WriteString(`"`)
+		for _, p := range w {
+			if p.env != "" {
+				b.WriteString("${env:" + p.env + "}")
+				continue
+			}
+			b.WriteString(strings.NewReplacer("`", "``", `"`, "`\"", "$", "`$").Replace(p.text))
+		}
+		b.WriteString(`"`)
+	case "cmd":
+		var parts []string
+		for _, p := range w {
+			if p.env != "" {
+				parts = append(parts, "%"+p.env+"%")
+				continue
+			}
+			parts = append(parts, cmdEscape(p.text))
+		}
+		s := strings.Join(parts, "")
+		if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:@") == "" {
+			return s
+		}
+		// No empty "" around a leading or trailing %, it would be a quote
+		quoted := `"` + s + `"`
+		if strings.HasPrefix(quoted, `""^%`) {
+			quoted = quoted[2:]
+		}
+		if strings.HasSuffix(quoted, `^%""`) {
+			quoted = quoted[:len(quoted)-2]
+		}
+		return quoted
+	default:
+		text := ""
+		for i, p := range w {
+			if p.env != "" {
+				if text != "" {
+					b.WriteString(shellQuote(text))
+					text = ""
+				}
+				b.WriteString(`"$` + p.env + `"`)
+				continue
+			}
+			text += p.text
+			if i == len(w)-1 && (text != "" || len(w) == 1) {
+				b.WriteString(shellQuote(text))
+			}
+		}
+	}
+	return b.String()
+}
+
+func (w shellWord) text() string {
+	var b strings.Builder
+	for _, p := range w {
+		b.WriteString(p.text)
+	}
+	return b.String()
+}
+
+// psWord quotes s for PowerShell, leaving safe words as they are
+func psWord(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:") == "" {
+		return s
+	}
+	return psString(s)
+}
+
+// cmdEscape escapes s inside a double quoted cmd argument, following the
+// rules of the Microsoft C runtime parsing the command line
+func cmdEscape(s string) string {
+	var b strings.Builder
+	backslashes := 0
+	for _, c := range s {
+		switch c {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			// "" keeps both cmd and the runtime inside the quotes
+			b.WriteString(strings.Repeat(`\`, 2*backslashes) + `""`)
+		case '%':
+			// Escaped outside the quotes, %20 could be expanded as a variable.
+			// A run of % is escaped at once, "" would be a quote.
+			b.WriteString(strings.Repeat(`\`, 2*backslashes))
+			if backslashes == 0 && strings.HasSuffix(b.String(), `^%"`) {
+				escaped := strings.TrimSuffix(b.String(), `"`)
+				b.Reset()
+				b.WriteString(escaped + `^%"`)
+			} else {
+				b.WriteString(`"^%"`)
+			}
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
+			b.WriteRune(c)
+		}
+		backslashes = 0
+	}
+	// Doubled before the closing quote
+	b.WriteString(strings.Repeat(`\`, 2*backslashes))
+	return b.String()
+}
+
+type curlWriter struct {
+	opts     CurlOptions
+	lines    [][]shellWord
+	comments []string
+	envNames map[string]bool
+}
+
+func (w *curlWriter) line(words ...shellWord) {
+	w.lines = append(w.lines, words)
+}
+
+// secret writes a secret value as set by the options
+func (w *curlWriter) secret(value, env string) shellWord {
+	switch w.opts.Secrets {
+	case "redact":
+		return literal(redacted)
+	case "env":
+		return shellWord{{env: w.envName(env)}}
+	}
+	return literal(value)
+}
+
+// envName makes the variable of each secret unique, e.g. the api_key param
+// and the X-API-Key header read API_KEY and API_KEY_2
+func (w *curlWriter) envName(env string) string {
+	if w.envNames == nil {
+		w.envNames = map[string]bool{}
+	}
+	name := env
+	for i := 2; w.envNames[name]; i++ {
+		name = fmt.Sprintf("%s_%d", env, i)
+	}
+	w.envNames[name] = true
+	return name
+}
+
+// field writes a name=value form field, its value being a secret for a
+// name such as password
+func (w *curlWriter) field(f FormField) shellWord {
+	if !secretNamePattern.MatchString(f.Name) {
+		return literal(f.Name + "=" + f.Value)
+	}
+	return append(literal(f.Name+"="), w.secret(f.Value, secretEnvName(f.Name))...)
+}
+
+// Stands for a secret in the JSON body until the body is written
+const secretPlaceholder = "__tanker_secret_%d__"
+
+// jsonBody writes a JSON body, the values of fields such as password or
+// client_secret being secrets
+func (w *curlWriter) jsonBody(payload interface{}) shellWord {
+	if w.opts.Secrets == "" {
+		data, _ := json.Marshal(payload)
+		return literal(string(data))
+	}
+	var secrets [][2]string
+	var mask func(v interface{}) interface{}
+	mask = func(v interface{}) interface{} {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			masked := make(map[string]interface{}, len(node))
+			for k, value := range node {
+				_, nested := value.(map[string]interface{})
+				_, list := value.([]interface{})
+				if secretNamePattern.MatchString(k) && !nested && !list && value != nil {
+					masked[k] = fmt.Sprintf(secretPlaceholder, len(secrets))
+					secrets = append(secrets, [2]string{k, fmt.Sprint(value)})
+					continue
+				}
+				masked[k] = mask(value)
+			}
+			return masked
+		case []interface{}:
+			masked := make([]interface{}, len(node))
+			for i, value := range node {
+				masked[i] = mask(value)
+			}
+			return masked
+		}
+		return v
+	}
+	data, _ := json.Marshal(mask(payload))
+
+	// Secrets in the order of the body, so are the variable names
+	var word shellWord
+	text := string(data)
+	for text != "" {
+		next, index := -1, 0
+		for i := range secrets {
+			if j := strings.Index(text, fmt.Sprintf(secretPlaceholder, i)); j >= 0 && (next < 0 || j < next) {
+				next, index = j, i
+			}
+		}
+		if next < 0 {
+			word = append(word, shellPart{text: text})
+			break
+		}
+		word = append(word, shellPart{text: text[:next]})
+		word = append(word, w.secret(secrets[index][1], secretEnvName(secrets[index][0]))...)
+		text = text[next+len(fmt.Sprintf(secretPlaceholder, index)):]
+	}
+	return word
+}
+
+// hasSecretField reports whether a JSON document has a field such as password
+func hasSecretField(v interface{}) bool {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, value := range node {
+			if secretNamePattern.MatchString(k) || hasSecretField(value) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, value := range node {
+			if hasSecretField(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// secretValue is secret for a header value, keeping its scheme ("Bearer ")
+// and the placeholders of the auth computed when sending
+func (w *curlWriter) secretValue(value, env string) shellWord {
+	if strings.HasPrefix(value, "<") && strings.HasSuffix(value, ">") {
+		return literal(value)
+	}
+	if scheme, credentials, ok := strings.Cut(value, " "); ok && authScheme.MatchString(scheme) && !strings.Contains(credentials, " ") {
+		if strings.HasPrefix(credentials, "<") {
+			return literal(value)
+		}
+		return append(literal(scheme+" "), w.secret(credentials, env)...)
+	}
+	return w.secret(value, env)
+}
+
+func (w *curlWriter) String() string {
+	comment, continuation := "# ", " \\\n  "
+	switch w.opts.Shell {
+	case "powershell":
+		continuation = " `\n  "
+	case "cmd":
+		comment, continuation = "REM ", " ^\n  "
+	}
+	if w.opts.SingleLine {
+		continuation = " "
+	}
+
+	lines := make([]string, len(w.lines))
+	for i, words := range w.lines {
+		quoted := make([]string, len(words))
+		for j, word := range words {
+			quoted[j] = word.quote(w.opts.Shell)
+		}
+		lines[i] = strings.Join(quoted, " ")
+	}
+	command := strings.Join(lines, continuation)
+	// Comments on their own lines, a comment would swallow the command after it
+	var b strings.Builder
+	for _, c := range w.comments {
+		b.WriteString(comment + c + "\n")
+	}
+	return b.String() + command
+}
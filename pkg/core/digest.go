@@ -0,0 +1,235 @@
+package core
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+}
+
+/*
+digestSession
+Last challenge received from a server, reused for the following calls with
+an increasing nonce count until the server asks for a new one
+*/
+type digestSession struct {
+	challenge digestChallenge
+	nc        uint32
+}
+
+var digestSessions = struct {
+	sync.Mutex
+	m map[string]*digestSession
+}{m: map[string]*digestSession{}}
+
+func digestSessionKey(req *http.Request, username string) string {
+	return req.URL.Scheme + "://" + req.URL.Host + "\n" + username
+}
+
+/*
+authorizeDigest adds the Authorization header when a challenge is already
+known for the server
+*/
+func authorizeDigest(req *http.Request, auth *AuthConfig) {
+	digestSessions.Lock()
+	defer digestSessions.Unlock()
+	session, ok := digestSessions.m[digestSessionKey(req, auth.Username)]
+	if !ok {
+		return
+	}
+	session.nc++
+	req.Header.Set("Authorization", session.challenge.authorization(req, auth.Username, auth.Password, session.nc))
+}
+
+/*
+storeDigestChallenge records the challenge of a 401 response, and reports
+whether the request can be replayed with it
+*/
+func storeDigestChallenge(req *http.Request, resp *http.Response, auth *AuthConfig) bool {
+	challenge, ok := parseDigestChallenges(resp.Header.Values("WWW-Authenticate"))
+	if !ok {
+		return false
+	}
+	digestSessions.Lock()
+	defer digestSessions.Unlock()
+	key := digestSessionKey(req, auth.Username)
+	if previous, ok := digestSessions.m[key]; ok && previous.challenge.nonce == challenge.nonce {
+		// Same nonce rejected again: the credentials are wrong
+		delete(digestSessions.m, key)
+		return false
+	}
+	digestSessions.m[key] = &digestSession{challenge: challenge}
+	return true
+}
+
+func (c digestChallenge) authorization(req *http.Request, username, password string, nc uint32) string {
+	h := md5.New
+	algorithm := strings.ToUpper(c.algorithm)
+	if strings.HasPrefix(algorithm, "SHA-256") {
+		h = sha256.New
+	}
+	cnonce := randomString(12)
+	ncValue := fmt.Sprintf("%08x", nc)
+	uri := req.URL.RequestURI()
+
+	ha1 := digestHash(h, username+":"+c.realm+":"+password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = digestHash(h, ha1+":"+c.nonce+":"+cnonce)
+	}
+	ha2 := digestHash(h, req.Method+":"+uri)
+
+	var response string
+	if c.qop != "" {
+		response = digestHash(h, strings.Join([]string{ha1, c.nonce, ncValue, cnonce, "auth", ha2}, ":"))
+	} else {
+		response = digestHash(h, ha1+":"+c.nonce+":"+ha2)
+	}
+
+	parts := []string{
+		"username=" + digestQuote(username),
+		"realm=" + digestQuote(c.realm),
+		"nonce=" + digestQuote(c.nonce),
+		"uri=" + digestQuote(uri),
+		"response=" + digestQuote(response),
+	}
+	if c.algorithm != "" {
+		parts = append(parts, "algorithm="+c.algorithm)
+	}
+	if c.qop != "" {
+		parts = append(parts, "qop=auth", "nc="+ncValue, "cnonce="+digestQuote(cnonce))
+	}
+	if c.opaque != "" {
+		parts = append(parts, "opaque="+digestQuote(c.opaque))
+	}
+	return "Digest " + strings.Join(parts, ", ")
+}
+
+// digestQuote writes a quoted-string, escaping its quotes and backslashes
+// (RFC 7616, section 3.4)
+func digestQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func digestHash(h func() hash.Hash, s string) string {
+	hh := h()
+	hh.Write([]byte(s))
+	return hex.EncodeToString(hh.Sum(nil))
+}
+
+/*
+parseDigestChallenges picks the strongest supported Digest challenge among
+the WWW-Authenticate headers (SHA-256 is preferred over MD5)
+*/
+func parseDigestChallenges(headers []string) (digestChallenge, bool) {
+	var best digestChallenge
+	found := false
+	for _, header := range headers {
+		for _, params := range splitChallenges(header) {
+			algorithm := strings.ToUpper(params["algorithm"])
+			switch algorithm {
+			case "", "MD5", "MD5-SESS", "SHA-256", "SHA-256-SESS":
+			default:
+				continue
+			}
+			qop := ""
+			if params["qop"] != "" {
+				supported := false
+				for _, q := range strings.Split(params["qop"], ",") {
+					if strings.TrimSpace(q) == "auth" {
+						supported = true
+					}
+				}
+				if !supported {
+					continue
+				}
+				qop = "auth"
+			}
+			challenge := digestChallenge{
+				realm:     params["realm"],
+				nonce:     params["nonce"],
+				opaque:    params["opaque"],
+				algorithm: params["algorithm"],
+				qop:       qop,
+			}
+			if !found || (strings.HasPrefix(algorithm, "SHA-256") && !strings.HasPrefix(strings.ToUpper(best.algorithm), "SHA-256")) {
+				best = challenge
+				found = true
+			}
+		}
+	}
+	return best, found
+}
+
+/*
+splitChallenges parses a WWW-Authenticate header into the parameters of
+each Digest challenge it contains
+*/
+func splitChallenges(header string) []map[string]string {
+	var challenges []map[string]string
+	var current map[string]string
+	s := header
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			break
+		}
+		// Read a token: either an auth scheme or a parameter name
+		end := strings.IndexAny(s, " \t,=")
+		if end == -1 {
+			end = len(s)
+		}
+		token := s[:end]
+		s = s[end:]
+		rest := strings.TrimLeft(s, " \t")
+		if !strings.HasPrefix(rest, "=") {
+			// New auth scheme
+			if strings.EqualFold(token, "Digest") {
+				current = map[string]string{}
+				challenges = append(challenges, current)
+			} else {
+				current = nil
+			}
+			continue
+		}
+		s = strings.TrimLeft(rest[1:], " \t")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexAny(s, " \t,")
+			if end == -1 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = s[end:]
+		}
+		if current != nil {
+			current[strings.ToLower(token)] = value
+		}
+	}
+	return challenges
+}
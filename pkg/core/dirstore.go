@@ -0,0 +1,400 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+DirStorage
+Git friendly backend: one pretty-printed file per request in a directory
+tree mirroring the collections, with deterministic key order so that
+reviewing a change shows only what changed.
+
+	<root>/.tanker.json          schema version, auth profiles and environments
+	<root>/.tanker-state.json    last used dates and active environment, not meant to be committed
+	<root>/<folder>/.folder.json folder defaults
+	<root>/<folder>/<name>.json  requests
+
+Format selects "json" (default) or "yaml" files, both are read. Files
+without a method are not requests and, like every file that the store did
+not read or write, are left alone.
+*/
+type DirStorage struct {
+	Root   string
+	Format string
+	owned  map[string]bool // fichiers lus ou écrits par le store, seuls supprimés par Save
+}
+
+const (
+	dirMetaFile   = ".tanker"
+	dirStateFile  = ".tanker-state.json"
+	dirFolderFile = ".folder"
+	dirLockFile   = ".tanker.lock"
+)
+
+// In order of precedence when several meta files exist
+var dirExtensions = []string{".json", ".yaml", ".yml"}
+
+func isDirExtension(ext string) bool {
+	for _, e := range dirExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+type dirMeta struct {
+	Version      int                    `json:"version"`
+	Profiles     map[string]AuthConfig  `json:"profiles,omitempty"`
+	Environments map[string]Environment `json:"environments,omitempty"`
+}
+
+type dirState struct {
+	Environment string               `json:"environment,omitempty"`
+	LastUsed    map[string]time.Time `json:"lastUsed"`
+}
+
+func (s *DirStorage) ext() string {
+	if s.Format == "yaml" {
+		return ".yaml"
+	}
+	return ".json"
+}
+
+func (s *DirStorage) Lock() (func(), error) {
+	if err := os.MkdirAll(s.Root, 0750); err != nil {
+		return nil, err
+	}
+	return lockFile(filepath.Join(s.Root, dirLockFile))
+}
+
+/*
+Stamp fingerprints the names, sizes and modification times of the files
+*/
+func (s *DirStorage) Stamp() string {
+	h := sha256.New()
+	filepath.WalkDir(s.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() == dirLockFile {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			fmt.Fprintf(h, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *DirStorage) Load() (Contents, bool, error) {
+	c := Contents{
+		Requests: map[string]Request{},
+		Profiles: map[string]AuthConfig{},
+		Folders:  map[string]Folder{},
+	}
+	s.owned = map[string]bool{}
+	if _, err := os.Stat(s.Root); os.IsNotExist(err) {
+		return c, false, nil
+	}
+
+	var meta dirMeta
+	metaFound := false
+	for _, ext := range dirExtensions {
+		path := filepath.Join(s.Root, dirMetaFile+ext)
+		buffer, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// The other meta files are left over from a change of format
+		s.owned[path] = true
+		if metaFound {
+			continue
+		}
+		metaFound = true
+		if err := decodeFile(path, buffer, &meta); err != nil {
+			return c, true, err
+		}
+		if err := checkVersion(path, meta.Version); err != nil {
+			return c, true, err
+		}
+	}
+	if meta.Profiles != nil {
+		c.Profiles = meta.Profiles
+	}
+	c.Environments = meta.Environments
+
+	err := filepath.WalkDir(s.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != s.Root && strings.HasPrefix(name, ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(name)
+		if !isDirExtension(ext) {
+			return nil
+		}
+		rel, _ := filepath.Rel(s.Root, filepath.Dir(path))
+		collection := CleanCollection(filepath.ToSlash(rel))
+		if collection == "." {
+			collection = ""
+		}
+		buffer, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case name == dirFolderFile+ext:
+			var folder Folder
+			if err := decodeFile(path, buffer, &folder); err != nil {
+				return err
+			}
+			c.Folders[collection] = folder
+			s.owned[path] = true
+		case strings.HasPrefix(name, "."):
+			// metadata
+		default:
+			// Other files kept with the requests, e.g. an OpenAPI document
+			var generic interface{}
+			if err := decodeFile(path, buffer, &generic); err != nil {
+				return err
+			}
+			if object, ok := generic.(map[string]interface{}); !ok || object["method"] == nil {
+				return nil
+			}
+			var r Request
+			if err := decodeFile(path, buffer, &r); err != nil {
+				return err
+			}
+			if r.Name == "" {
+				r.Name = strings.TrimSuffix(name, ext)
+			}
+			r.Collection = collection
+			if _, exists := c.Requests[r.Name]; exists {
+				return fmt.Errorf("%s: duplicate request name %q", path, r.Name)
+			}
+			c.Requests[r.Name] = r
+			s.owned[path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return c, true, err
+	}
+
+	state, err := s.readState()
+	if err != nil {
+		return c, true, fmt.Errorf("invalid %s: %w", dirStateFile, err)
+	}
+	c.Environment = state.Environment
+	for name, lastUsed := range state.LastUsed {
+		if r, ok := c.Requests[name]; ok {
+			lastUsed := lastUsed
+			r.LastUsed = &lastUsed
+			c.Requests[name] = r
+		}
+	}
+	return c, true, nil
+}
+
+func (s *DirStorage) Save(c Contents) error {
+	files := map[string][]byte{}
+	add := func(rel string, v interface{}) error {
+		buffer, err := s.encode(v)
+		if err != nil {
+			return err
+		}
+		files[filepath.Join(s.Root, filepath.FromSlash(rel))] = buffer
+		return nil
+	}
+
+	if err := add(dirMetaFile+s.ext(), dirMeta{Version: SchemaVersion, Profiles: c.Profiles, Environments: c.Environments}); err != nil {
+		return err
+	}
+	for path, folder := range c.Folders {
+		if err := add(CleanCollection(path)+"/"+dirFolderFile+s.ext(), folder); err != nil {
+			return err
+		}
+	}
+
+	// Sorted so that colliding file names are always suffixed the same way
+	names := make([]string, 0, len(c.Requests))
+	for name := range c.Requests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	state := dirState{Environment: c.Environment, LastUsed: map[string]time.Time{}}
+	taken := map[string]bool{}
+	for _, name := range names {
+		r := c.Requests[name]
+		dir := CleanCollection(r.Collection)
+		base := requestFileName(name)
+		rel := strings.TrimPrefix(dir+"/"+base+s.ext(), "/")
+		for i := 2; taken[strings.ToLower(rel)]; i++ {
+			rel = strings.TrimPrefix(fmt.Sprintf("%s/%s-%d%s", dir, base, i, s.ext()), "/")
+		}
+		taken[strings.ToLower(rel)] = true
+
+		// Derived from the location and kept out of the reviewed files
+		if r.LastUsed != nil {
+			state.LastUsed[name] = *r.LastUsed
+		}
+		r.Collection, r.LastUsed = "", nil
+		if err := add(rel, r); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(s.Root, 0750); err != nil {
+		return err
+	}
+	stateBuffer, _ := json.MarshalIndent(state, "", "  ")
+	files[filepath.Join(s.Root, dirStateFile)] = stateBuffer
+	if _, err := os.Stat(filepath.Join(s.Root, ".gitignore")); os.IsNotExist(err) {
+		files[filepath.Join(s.Root, ".gitignore")] = []byte(dirLockFile + "\n" + dirStateFile + "\n*.tmp\n")
+	}
+
+	for path, content := range files {
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, content) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(path, content, 0600); err != nil {
+			return err
+		}
+	}
+	return s.removeStale(files)
+}
+
+// removeStale deletes the files of deleted or moved requests and folders,
+// then the directories left empty. The files written are owned by the store
+// from then on.
+func (s *DirStorage) removeStale(keep map[string][]byte) error {
+	var dirs []string
+	err := filepath.WalkDir(s.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != s.Root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, path)
+			return nil
+		}
+		if _, ok := keep[path]; !ok && s.owned[path] {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.owned = map[string]bool{}
+	for path := range keep {
+		if isDirExtension(filepath.Ext(path)) && filepath.Base(path) != dirStateFile {
+			s.owned[path] = true
+		}
+	}
+	// Deepest first
+	for i := len(dirs) - 1; i > 0; i-- {
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			os.Remove(dirs[i])
+		}
+	}
+	return nil
+}
+
+// readState reads the state file, also in its first format: a bare map of
+// last used dates
+func (s *DirStorage) readState() (dirState, error) {
+	var raw map[string]json.RawMessage
+	if _, err := readJSONFile(filepath.Join(s.Root, dirStateFile), &raw); err != nil {
+		return dirState{}, err
+	}
+	var state dirState
+	if lastUsed, ok := raw["lastUsed"]; ok && len(lastUsed) > 0 && lastUsed[0] == '{' {
+		buffer, _ := json.Marshal(raw)
+		return state, json.Unmarshal(buffer, &state)
+	}
+	state.LastUsed = map[string]time.Time{}
+	for name, value := range raw {
+		var t time.Time
+		if err := json.Unmarshal(value, &t); err != nil {
+			return state, err
+		}
+		state.LastUsed[name] = t
+	}
+	return state, nil
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._ -]+`)
+
+func requestFileName(name string) string {
+	base := strings.TrimSpace(unsafeNameChars.ReplaceAllString(name, "_"))
+	if base == "" || strings.HasPrefix(base, ".") {
+		base = "_" + base
+	}
+	return base
+}
+
+// encode pretty prints v, JSON object keys and YAML mapping keys are sorted
+func (s *DirStorage) encode(v interface{}) ([]byte, error) {
+	buffer, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if s.Format != "yaml" {
+		return append(buffer, '\n'), nil
+	}
+	// Go through JSON to keep the json field names
+	var generic interface{}
+	if err := json.Unmarshal(buffer, &generic); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(generic); err != nil {
+		return nil, err
+	}
+	encoder.Close()
+	return out.Bytes(), nil
+}
+
+func decodeFile(path string, buffer []byte, v interface{}) error {
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		var generic interface{}
+		if err := yaml.Unmarshal(buffer, &generic); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		var err error
+		if buffer, err = json.Marshal(generic); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := json.Unmarshal(buffer, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
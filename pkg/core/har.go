@@ -0,0 +1,390 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+HAR
+Import of the HTTP archives saved by browser devtools, and export of the
+execution history as HAR 1.2 to open it in devtools or other analyzers.
+*/
+
+type harLog struct {
+	Log struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         Timings     `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []harPair    `json:"cookies"`
+	Headers     []harPair    `json:"headers"`
+	QueryString []harPair    `json:"queryString"`
+	PostData    *harPostData `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Cookies     []harPair  `json:"cookies"`
+	Headers     []harPair  `json:"headers"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int64      `json:"bodySize"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []harPostParam `json:"params,omitempty"`
+}
+
+type harPostParam struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+/*
+HARFilter
+Selects the HAR entries to import, empty lists matching everything.
+Domains match their subdomains, content types match the start of the
+response MIME type ("application/json", "image/").
+*/
+type HARFilter struct {
+	Domains      []string
+	Methods      []string
+	ContentTypes []string
+}
+
+func (f HARFilter) match(e harEntry) bool {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	matchAny := func(values []string, test func(string) bool) bool {
+		if len(values) == 0 {
+			return true
+		}
+		for _, v := range values {
+			if v = strings.ToLower(strings.TrimSpace(v)); v != "" && test(v) {
+				return true
+			}
+		}
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	mimeType := strings.ToLower(e.Response.Content.MimeType)
+	return matchAny(f.Domains, func(d string) bool { return host == d || strings.HasSuffix(host, "."+d) }) &&
+		matchAny(f.Methods, func(m string) bool { return strings.EqualFold(e.Request.Method, m) }) &&
+		matchAny(f.ContentTypes, func(t string) bool { return strings.HasPrefix(mimeType, t) })
+}
+
+// Headers set by the browser or by the HTTP client
+var harSkippedHeaders = map[string]bool{
+	"host": true, "content-length": true, "connection": true, "accept-encoding": true,
+	"upgrade-insecure-requests": true, "priority": true, "te": true, "keep-alive": true,
+}
+
+/*
+ImportHAR converts the entries of a HAR file matching the filter into
+requests, in one folder per host under collection ("har" by default).
+Repeated calls, same method, URL and body, are imported once.
+*/
+func ImportHAR(data []byte, collection string, filter HARFilter) (Import, error) {
+	var har harLog
+	if err := json.Unmarshal(data, &har); err != nil {
+		return Import{}, fmt.Errorf("invalid HAR file: %w", err)
+	}
+	if har.Log.Entries == nil {
+		return Import{}, fmt.Errorf("not a HAR file, no log.entries")
+	}
+	collection = CleanCollection(collection)
+	if collection == "" {
+		collection = "har"
+	}
+
+	imp := Import{}
+	seen := map[string]bool{}
+	duplicates, filtered := 0, 0
+	for _, e := range har.Log.Entries {
+		if !filter.match(e) {
+			filtered++
+			continue
+		}
+		key := e.Request.Method + " " + e.Request.URL
+		if e.Request.PostData != nil {
+			key += "\n" + e.Request.PostData.Text
+		}
+		if seen[key] {
+			duplicates++
+			continue
+		}
+		seen[key] = true
+		r := imp.harRequest(e.Request)
+		r.Name = imp.uniqueName(DefaultRequestName(r.Method, e.Request.URL))
+		if u, err := url.Parse(e.Request.URL); err == nil {
+			r.Collection = CleanCollection(collection + "/" + u.Host)
+		}
+		imp.Requests = append(imp.Requests, r)
+	}
+	if filtered > 0 {
+		imp.warnf("%d entries excluded by the filters", filtered)
+	}
+	if duplicates > 0 {
+		imp.warnf("%d repeated calls imported once", duplicates)
+	}
+	return imp, nil
+}
+
+func (imp *Import) harRequest(h harRequest) Request {
+	r := Request{Method: strings.ToUpper(h.Method), URL: h.URL, Headers: map[string]interface{}{}}
+	for _, header := range h.Headers {
+		name := strings.ToLower(header.Name)
+		if harSkippedHeaders[name] || strings.HasPrefix(name, ":") || strings.HasPrefix(name, "sec-") {
+			continue
+		}
+		r.Headers[header.Name] = header.Value
+	}
+	liftBearerHeader(&r)
+
+	// Query parameters are editable when no name is repeated
+	if u, err := url.Parse(h.URL); err == nil && u.RawQuery != "" {
+		values := u.Query()
+		repeated := false
+		for _, v := range values {
+			repeated = repeated || len(v) > 1
+		}
+		if !repeated {
+			r.Params = map[string]interface{}{}
+			for k, v := range values {
+				r.Params[k] = v[0]
+			}
+			u.RawQuery = ""
+			r.URL = u.String()
+		}
+	}
+
+	if h.PostData == nil {
+		return r
+	}
+	switch {
+	case h.PostData.Text != "":
+		setCurlBody(&r, h.PostData.Text, false)
+	case len(h.PostData.Params) > 0:
+		r.BodyType = "form"
+		if strings.HasPrefix(h.PostData.MimeType, "multipart/") {
+			r.BodyType = "multipart"
+		}
+		for _, p := range h.PostData.Params {
+			field := FormField{Name: p.Name, Value: p.Value, ContentType: p.ContentType}
+			if p.FileName != "" {
+				field.File, field.Value = p.FileName, ""
+				imp.warnf("%s %s: the file %s of the form field %s must be available locally", r.Method, h.URL, p.FileName, p.Name)
+			}
+			r.Form = append(r.Form, field)
+		}
+	}
+	if r.BodyType == "multipart" {
+		// The boundary is generated when sending
+		for name := range r.Headers {
+			if strings.EqualFold(name, "Content-Type") {
+				delete(r.Headers, name)
+			}
+		}
+	}
+	return r
+}
+
+/*
+ExportHAR writes executions of the history as HAR 1.2, oldest first, version
+being the one of the creator. Auth headers, computed when sending, are not
+part of the history and are left out.
+*/
+func ExportHAR(entries []HistoryEntry, version string) ([]byte, error) {
+	var har harLog
+	har.Log.Version = "1.2"
+	har.Log.Creator = harCreator{Name: "http-tanker", Version: version}
+	har.Log.Entries = []harEntry{}
+
+	sorted := append([]HistoryEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+	for _, e := range sorted {
+		har.Log.Entries = append(har.Log.Entries, harFromHistory(e))
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(har); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func harFromHistory(e HistoryEntry) harEntry {
+	r := e.Request
+	entry := harEntry{Comment: e.Name, Error: e.Error}
+
+	u, err := url.Parse(r.URL)
+	if err == nil {
+		q := u.Query()
+		for k, v := range r.Params {
+			if s, ok := v.(string); ok {
+				q.Add(k, s)
+			}
+		}
+		u.RawQuery = q.Encode()
+		entry.Request.URL = u.String()
+		for _, k := range sortedKeys(q) {
+			for _, v := range q[k] {
+				entry.Request.QueryString = append(entry.Request.QueryString, harPair{Name: k, Value: v})
+			}
+		}
+	} else {
+		entry.Request.URL = r.URL
+	}
+	entry.Request.Method = r.Method
+	entry.Request.HTTPVersion = "HTTP/1.1"
+	entry.Request.Cookies, entry.Request.Headers = []harPair{}, []harPair{}
+	if entry.Request.QueryString == nil {
+		entry.Request.QueryString = []harPair{}
+	}
+	contentType := ""
+	for _, k := range sortedKeys(r.Headers) {
+		value := fmt.Sprint(r.Headers[k])
+		entry.Request.Headers = append(entry.Request.Headers, harPair{Name: k, Value: value})
+		if strings.EqualFold(k, "Content-Type") {
+			contentType = value
+		}
+	}
+	entry.Request.HeadersSize, entry.Request.BodySize = -1, 0
+	if r.BodyType == "multipart" {
+		post := &harPostData{MimeType: "multipart/form-data"}
+		for _, f := range r.Form {
+			post.Params = append(post.Params, harPostParam{Name: f.Name, Value: f.Value, FileName: f.File, ContentType: f.ContentType})
+		}
+		entry.Request.PostData, entry.Request.BodySize = post, -1
+	} else if body, encodedType, err := r.EncodeBody(); err == nil && body != nil {
+		if contentType == "" {
+			contentType = encodedType
+		}
+		post := &harPostData{MimeType: contentType, Text: string(body)}
+		if r.BodyType == "form" {
+			for _, f := range r.Form {
+				post.Params = append(post.Params, harPostParam{Name: f.Name, Value: f.Value})
+			}
+		}
+		entry.Request.PostData, entry.Request.BodySize = post, len(body)
+	}
+
+	entry.Response.Cookies, entry.Response.Headers = []harPair{}, []harPair{}
+	entry.Response.HeadersSize, entry.Response.BodySize = -1, -1
+	entry.Timings = Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	resp := e.Response
+	if resp == nil {
+		// Failed before a response, HAR still requires one
+		entry.StartedDateTime = e.Time
+		entry.Response.HTTPVersion = "HTTP/1.1"
+		return entry
+	}
+
+	entry.Time = float64(resp.ExecutionTimeMillisec)
+	if resp.Timings != nil {
+		entry.Timings = *resp.Timings
+		entry.Time = 0
+		for _, phase := range []float64{entry.Timings.Blocked, entry.Timings.DNS, entry.Timings.Connect, entry.Timings.Send, entry.Timings.Wait, entry.Timings.Receive} {
+			if phase > 0 {
+				entry.Time += phase
+			}
+		}
+	} else {
+		// Older entries only know the total
+		entry.Timings.Wait = entry.Time
+	}
+	// Executions are recorded when they end
+	entry.StartedDateTime = e.Time.Add(-time.Duration(entry.Time * float64(time.Millisecond)))
+
+	entry.Response.Status = resp.StatusCode
+	entry.Response.StatusText = strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode)))
+	entry.Response.HTTPVersion = resp.Proto
+	if entry.Response.HTTPVersion == "" {
+		entry.Response.HTTPVersion = "HTTP/1.1"
+	}
+	for _, k := range sortedKeys(resp.Headers) {
+		for _, v := range resp.Headers[k] {
+			entry.Response.Headers = append(entry.Response.Headers, harPair{Name: k, Value: v})
+			if strings.EqualFold(k, "Location") {
+				entry.Response.RedirectURL = v
+			}
+		}
+	}
+	content := &entry.Response.Content
+	content.MimeType = resp.Headers.Get("Content-Type")
+	if content.MimeType == "" {
+		content.MimeType = resp.ContentType
+	}
+	switch {
+	case resp.JsonBody != nil:
+		buffer, _ := json.Marshal(resp.JsonBody)
+		content.Text = string(buffer)
+	case resp.BodySize > 0 && resp.Body == "":
+		content.Comment = "binary content not recorded"
+	default:
+		content.Text = resp.Body
+	}
+	content.Size = int64(len(content.Text))
+	if resp.BodySize > 0 {
+		content.Size = resp.BodySize
+	}
+	if e.BodyTruncated {
+		content.Comment = "truncated in the history"
+	}
+	return entry
+}
@@ -0,0 +1,334 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response bodies larger than this are truncated in the history
+const DefaultHistoryBodyLimit = 64 * 1024
+
+/*
+HistoryEntry
+One execution: the request as it was sent (folders and auth profile
+applied, secrets masked), the response or the error, and the timings
+*/
+type HistoryEntry struct {
+	ID            string    `json:"id"`
+	Time          time.Time `json:"time"`
+	Name          string    `json:"name,omitempty"`
+	Request       Request   `json:"request"`
+	Response      *Response `json:"response,omitempty"`
+	BodyTruncated bool      `json:"bodyTruncated,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+/*
+Summary one line description of the entry
+*/
+func (e HistoryEntry) Summary() string {
+	name := e.Name
+	if name == "" {
+		name = "(ad-hoc)"
+	}
+	result := "ERROR " + e.Error
+	if e.Response != nil {
+		result = fmt.Sprintf("%d (%d ms)", e.Response.StatusCode, e.Response.ExecutionTimeMillisec)
+	}
+	return e.Time.Local().Format("2006-01-02 15:04:05") + " [" + e.Request.Method + "] " + name + " → " + result
+}
+
+func (db *Database) historyDir() string {
+	return filepath.Join(db.DatabaseDir, "history")
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+/*
+RecordHistory stores an execution and prunes the entries older than the
+retention period
+*/
+func (db *Database) RecordHistory(name string, r Request, resp *Response, callErr error) (HistoryEntry, error) {
+	now := time.Now()
+	entry := HistoryEntry{
+		ID:      strconv.FormatInt(now.UnixNano(), 10) + "-" + strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), "_"),
+		Time:    now.UTC(),
+		Name:    name,
+		Request: MaskedRequest(r),
+	}
+	// The credentials are not written to the history, see HistoryRequest
+	entry.ID = strings.TrimSuffix(entry.ID, "-")
+	if callErr != nil {
+		entry.Error = callErr.Error()
+	}
+	if resp != nil {
+		stored := *resp
+		entry.BodyTruncated = capResponseBody(&stored, db.historyBodyLimit())
+		entry.Response = &stored
+	}
+
+	if err := os.MkdirAll(db.historyDir(), 0750); err != nil {
+		return entry, err
+	}
+	buffer, err := json.Marshal(entry)
+	if err != nil {
+		return entry, err
+	}
+	if err := os.WriteFile(filepath.Join(db.historyDir(), entry.ID+".json"), buffer, 0600); err != nil {
+		return entry, err
+	}
+	return entry, db.PruneHistory()
+}
+
+/*
+HistoryRequest returns the request of an entry ready to be sent again. The
+secrets masked in the history are taken from the auth profile or the saved
+request of the entry.
+*/
+func (db *Database) HistoryRequest(entry HistoryEntry) (Request, error) {
+	r := entry.Request
+	if r.Auth == nil && !hasSecrets(r) {
+		return r, nil
+	}
+	var resolved Request
+	if saved, ok := db.Data[entry.Name]; ok {
+		var err error
+		if resolved, err = db.ResolveRequest(saved); err != nil {
+			return Request{}, err
+		}
+	}
+	if r.AuthProfile != "" {
+		profile, err := db.ResolveRequest(Request{AuthProfile: r.AuthProfile})
+		if err != nil {
+			return Request{}, err
+		}
+		resolved.Auth = profile.Auth
+	}
+	if r.Auth != nil {
+		r.Auth = resolved.Auth
+	}
+	if (r.Auth == nil) != (entry.Request.Auth == nil) || !restoreSecrets(&r, resolved) {
+		return Request{}, fmt.Errorf("the credentials of execution %s are not recorded in the history, send the request again", entry.ID)
+	}
+	return r, nil
+}
+
+// hasSecrets reports whether a request has fields masked by MaskedRequest,
+// besides its auth
+func hasSecrets(r Request) bool {
+	for _, f := range r.Form {
+		if f.File == "" && f.Value != "" && secretNamePattern.MatchString(f.Name) {
+			return true
+		}
+	}
+	var body interface{}
+	if r.BodyType == "raw" && json.Unmarshal([]byte(r.Body), &body) == nil && hasSecretField(body) {
+		return true
+	}
+	return hasSecretField(r.Headers) || hasSecretField(r.Params) || hasSecretField(r.Payload)
+}
+
+func restoredFields(masked, source map[string]interface{}) (map[string]interface{}, bool) {
+	if masked == nil {
+		return nil, true
+	}
+	restored := make(map[string]interface{}, len(masked))
+	for k, v := range masked {
+		if secretNamePattern.MatchString(k) {
+			var ok bool
+			if v, ok = source[k]; !ok {
+				return nil, false
+			}
+		}
+		restored[k] = v
+	}
+	return restored, true
+}
+
+// restoreSecrets replaces the masked fields of r by the ones of the request
+// it was sent from, false when one of them is missing there
+func restoreSecrets(r *Request, from Request) bool {
+	var ok bool
+	if r.Headers, ok = restoredFields(r.Headers, from.Headers); !ok {
+		return false
+	}
+	if r.Params, ok = restoredFields(r.Params, from.Params); !ok {
+		return false
+	}
+
+	if r.Form != nil {
+		form := make([]FormField, len(r.Form))
+		for i, f := range r.Form {
+			if f.File == "" && f.Value != "" && secretNamePattern.MatchString(f.Name) {
+				found := false
+				for _, source := range from.Form {
+					if source.Name == f.Name && source.File == "" {
+						f.Value, found = source.Value, true
+						break
+					}
+				}
+				if !found {
+					return false
+				}
+			}
+			form[i] = f
+		}
+		r.Form = form
+	}
+
+	// The JSON bodies are taken as a whole
+	if hasSecretField(r.Payload) {
+		if !hasSecretField(from.Payload) {
+			return false
+		}
+		r.Payload = from.Payload
+	}
+	var body interface{}
+	if r.BodyType == "raw" && json.Unmarshal([]byte(r.Body), &body) == nil && hasSecretField(body) {
+		if from.BodyType != "raw" || from.Body == "" {
+			return false
+		}
+		r.Body = from.Body
+	}
+	return true
+}
+
+func (db *Database) historyBodyLimit() int {
+	if db.HistoryBodyLimit > 0 {
+		return db.HistoryBodyLimit
+	}
+	return DefaultHistoryBodyLimit
+}
+
+func capResponseBody(resp *Response, limit int) bool {
+	if resp.JsonBody != nil {
+		buffer, _ := json.Marshal(resp.JsonBody)
+		if len(buffer) <= limit {
+			return false
+		}
+		resp.JsonBody = nil
+		resp.Body = string(buffer)
+	}
+	if len(resp.Body) > limit {
+		resp.Body = strings.ToValidUTF8(resp.Body[:limit], "")
+		return true
+	}
+	return false
+}
+
+// Entry IDs start with their creation time in nanoseconds
+func historyTime(id string) (time.Time, bool) {
+	prefix, _, _ := strings.Cut(id, "-")
+	ns, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns), true
+}
+
+func (db *Database) historyIDs() ([]string, error) {
+	files, err := os.ReadDir(db.historyDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, f := range files {
+		id, ok := strings.CutSuffix(f.Name(), ".json")
+		if _, valid := historyTime(id); ok && valid {
+			ids = append(ids, id)
+		}
+	}
+	// Newest first
+	sort.Slice(ids, func(i, j int) bool {
+		ti, _ := historyTime(ids[i])
+		tj, _ := historyTime(ids[j])
+		return ti.After(tj)
+	})
+	return ids, nil
+}
+
+/*
+ListHistory returns the most recent executions first, optionally for a
+single request name, limit <= 0 meaning no limit
+*/
+func (db *Database) ListHistory(name string, limit int) ([]HistoryEntry, error) {
+	ids, err := db.historyIDs()
+	if err != nil {
+		return nil, err
+	}
+	var entries []HistoryEntry
+	for _, id := range ids {
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+		entry, err := db.GetHistory(id)
+		if err != nil {
+			continue
+		}
+		if name == "" || entry.Name == name {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+/*
+GetHistory loads a single execution
+*/
+func (db *Database) GetHistory(id string) (HistoryEntry, error) {
+	var entry HistoryEntry
+	if _, ok := historyTime(id); !ok || strings.ContainsAny(id, `/\`) {
+		return entry, fmt.Errorf("invalid history id %q", id)
+	}
+	buffer, err := os.ReadFile(filepath.Join(db.historyDir(), id+".json"))
+	if os.IsNotExist(err) {
+		return entry, fmt.Errorf("history entry %q not found", id)
+	}
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(buffer, &entry); err != nil {
+		return entry, fmt.Errorf("invalid history entry %q: %w", id, err)
+	}
+	return entry, nil
+}
+
+/*
+PruneHistory removes the executions older than the retention period. A zero
+retention keeps everything.
+*/
+func (db *Database) PruneHistory() error {
+	if db.HistoryRetention <= 0 {
+		return nil
+	}
+	ids, err := db.historyIDs()
+	if err != nil {
+		return err
+	}
+	limit := time.Now().Add(-db.HistoryRetention)
+	for _, id := range ids {
+		if t, _ := historyTime(id); t.Before(limit) {
+			if err := os.Remove(filepath.Join(db.historyDir(), id+".json")); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ClearHistory removes every recorded execution
+*/
+func (db *Database) ClearHistory() error {
+	return os.RemoveAll(db.historyDir())
+}
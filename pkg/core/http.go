@@ -7,10 +7,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
+	"net/http/httptrace"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PierreKieffer/http-tanker/pkg/color"
@@ -26,7 +27,83 @@ type Response struct {
 	ContentType           string                 `json:"contentType,omitempty"`
 	BodySize              int64                  `json:"bodySize,omitempty"`
 	ExecutionTimeMillisec int64                  `json:"executionTimeMillisec,omitempty"`
+	Timings               *Timings               `json:"timings,omitempty"`
 	savedFile             string
+	raw                   string // body texte tel que reçu, pour les assertions
+}
+
+/*
+Timings
+Phases of an execution in milliseconds, as in HAR files. -1 marks a phase
+that did not happen, e.g. no DNS lookup on a reused connection.
+*/
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"` // TLS included
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// timingTrace records the instants of an execution. The callbacks can run
+// on other goroutines, even after the response was returned.
+type timingTrace struct {
+	mu                                                                     sync.Mutex
+	start, dnsStart, dnsDone, connectStart, connectDone, tlsStart, tlsDone time.Time
+	gotConn, wroteRequest, firstByte                                       time.Time
+}
+
+func (t *timingTrace) trace() *httptrace.ClientTrace {
+	record := func(instant *time.Time) {
+		now := time.Now()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		*instant = now
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { record(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { record(&t.dnsDone) },
+		ConnectStart:         func(string, string) { record(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { record(&t.connectDone) },
+		TLSHandshakeStart:    func() { record(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { record(&t.tlsDone) },
+		GotConn:              func(httptrace.GotConnInfo) { record(&t.gotConn) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { record(&t.wroteRequest) },
+		GotFirstResponseByte: func() { record(&t.firstByte) },
+	}
+}
+
+func (t *timingTrace) timings(end time.Time) *Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	phase := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() {
+			return -1
+		}
+		return float64(to.Sub(from).Microseconds()) / 1000
+	}
+	timings := &Timings{
+		DNS:     phase(t.dnsStart, t.dnsDone),
+		Connect: phase(t.connectStart, t.connectDone),
+		SSL:     phase(t.tlsStart, t.tlsDone),
+		Send:    phase(t.gotConn, t.wroteRequest),
+		Wait:    phase(t.wroteRequest, t.firstByte),
+		Receive: phase(t.firstByte, end),
+	}
+	if timings.SSL >= 0 && timings.Connect >= 0 {
+		// net/http traces the TCP connection alone
+		timings.Connect += timings.SSL
+	}
+	// Time before the connection was available, minus the lookup and the connection
+	timings.Blocked = phase(t.start, t.gotConn)
+	for _, spent := range []float64{timings.DNS, timings.Connect} {
+		if spent > 0 && timings.Blocked >= spent {
+			timings.Blocked -= spent
+		}
+	}
+	return timings
 }
 
 func (r *Response) IsBinaryContent() bool {
@@ -62,6 +139,32 @@ func (r *Response) SaveToFile(path string) error {
 	return nil
 }
 
+/*
+WriteBody writes the response body as received, JSON indented, binary content
+streamed from its temp file
+*/
+func (r *Response) WriteBody(w io.Writer) error {
+	if r.savedFile != "" {
+		f, err := os.Open(r.savedFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	}
+	if r.JsonBody != nil {
+		data, err := json.MarshalIndent(r.JsonBody, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+	_, err := io.WriteString(w, r.Body)
+	return err
+}
+
 func (r *Response) Cleanup() {
 	if r.savedFile != "" {
 		os.Remove(r.savedFile)
@@ -135,20 +238,61 @@ func (r *Request) CallHTTP() (Response, error) {
 		client = insecureClient
 	}
 
-	var body io.Reader
-	switch r.Method {
-	case "POST", "PUT", "PATCH":
-		jsonPayload, err := json.Marshal(r.Payload)
+	req, err := r.newHTTPRequest(client)
+	if err != nil {
+		return Response{}, err
+	}
+
+	start := time.Now()
+	trace := &timingTrace{start: start}
+	resp, err := client.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace.trace())))
+	if err != nil {
+		return Response{}, err
+	}
+
+	// Some auth schemes can recover from a 401 and replay the call once
+	if resp.StatusCode == http.StatusUnauthorized && r.retryAuth(req, resp) {
+		resp.Body.Close()
+		req, err = r.newHTTPRequest(client)
+		if err != nil {
+			return Response{}, err
+		}
+		trace = &timingTrace{start: time.Now()}
+		resp, err = client.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace.trace())))
 		if err != nil {
 			return Response{}, err
 		}
-		body = bytes.NewBuffer(jsonPayload)
 	}
+	defer resp.Body.Close()
 
-	req, err := http.NewRequest(r.Method, r.URL, body)
+	duration := time.Since(start)
+
+	response, err := BuildResponse(resp, duration.Milliseconds())
 	if err != nil {
 		return Response{}, err
 	}
+	response.Timings = trace.timings(time.Now())
+
+	return response, nil
+}
+
+func (r *Request) newHTTPRequest(client *http.Client) (*http.Request, error) {
+	var body io.Reader
+	payload, contentType, err := r.EncodeBody()
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(r.Method, r.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 
 	if len(r.Params) > 0 {
 		q := req.URL.Query()
@@ -167,6 +311,10 @@ func (r *Request) CallHTTP() (Response, error) {
 			}
 		}
 	}
+	// The boundary must match the encoded body
+	if strings.HasPrefix(contentType, "multipart/") {
+		req.Header.Set("Content-Type", contentType)
+	}
 
 	if r.Auth != nil {
 		switch r.Auth.Type {
@@ -180,24 +328,51 @@ func (r *Request) CallHTTP() (Response, error) {
 				header = "X-API-Key"
 			}
 			req.Header.Set(header, r.Auth.Key)
+		case "oauth2":
+			token, err := Tokens.Token(r.Auth, client)
+			if err != nil {
+				return nil, fmt.Errorf("oauth2: %w", err)
+			}
+			req.Header.Set("Authorization", token.AuthorizationHeader())
+		case "digest":
+			authorizeDigest(req, r.Auth)
+		case "aws-sigv4":
+			if err := signAWSRequest(req, payload, r.Auth); err != nil {
+				return nil, fmt.Errorf("aws-sigv4: %w", err)
+			}
+		case "hmac":
+			if err := signHMAC(req, payload, r.Auth); err != nil {
+				return nil, fmt.Errorf("hmac: %w", err)
+			}
+		case "jwt":
+			token, err := MintJWT(r.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: %w", err)
+			}
+			if r.Auth.Header != "" {
+				req.Header.Set(r.Auth.Header, token)
+			} else {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
 		}
 	}
 
-	start := time.Now()
-	resp, err := client.Do(req)
-	if err != nil {
-		return Response{}, err
-	}
-	defer resp.Body.Close()
-
-	duration := time.Since(start)
+	return req, nil
+}
 
-	response, err := BuildResponse(resp, duration.Milliseconds())
-	if err != nil {
-		return Response{}, err
+func (r *Request) retryAuth(req *http.Request, resp *http.Response) bool {
+	if r.Auth == nil {
+		return false
 	}
-
-	return response, nil
+	switch r.Auth.Type {
+	case "oauth2":
+		// The rejected token is dropped from the cache and a fresh one is fetched
+		Tokens.Invalidate(r.Auth)
+		return true
+	case "digest":
+		return storeDigestChallenge(req, resp, r.Auth)
+	}
+	return false
 }
 
 func BuildResponse(resp *http.Response, duration int64) (Response, error) {
@@ -216,6 +391,7 @@ func BuildResponse(resp *http.Response, duration int64) (Response, error) {
 		if err != nil {
 			return Response{}, err
 		}
+		response.raw = string(bodyBytes)
 		var jsonResponse map[string]interface{}
 		if json.Unmarshal(bodyBytes, &jsonResponse) == nil {
 			response.JsonBody = jsonResponse
@@ -264,61 +440,3 @@ func DisplayResponse(r Response) {
 	lines = append(lines, "Execution time : "+strconv.FormatInt(r.ExecutionTimeMillisec, 10)+" ms")
 	DrawBox("Response details", lines)
 }
-
-func (r *Request) CurlCommand() string {
-	parts := make([]string, 0, 6+2*len(r.Headers))
-	parts = append(parts, "curl")
-	if r.Insecure {
-		parts = append(parts, "-k")
-	}
-	parts = append(parts, "-X", r.Method)
-
-	// Auth
-	if r.Auth != nil {
-		switch r.Auth.Type {
-		case "bearer":
-			parts = append(parts, "-H", "'Authorization: Bearer "+r.Auth.Token+"'")
-		case "basic":
-			parts = append(parts, "-u", "'"+r.Auth.Username+":"+r.Auth.Password+"'")
-		case "api-key":
-			header := r.Auth.Header
-			if header == "" {
-				header = "X-API-Key"
-			}
-			parts = append(parts, "-H", "'"+header+": "+r.Auth.Key+"'")
-		}
-	}
-
-	// Build URL with query params
-	targetURL := r.URL
-	if len(r.Params) > 0 {
-		q := url.Values{}
-		for k, v := range r.Params {
-			if s, ok := v.(string); ok {
-				q.Add(k, s)
-			}
-		}
-		targetURL = targetURL + "?" + q.Encode()
-	}
-	parts = append(parts, "'"+targetURL+"'")
-
-	// Headers
-	if len(r.Headers) > 0 {
-		for k, v := range r.Headers {
-			if s, ok := v.(string); ok {
-				parts = append(parts, "-H", "'"+k+": "+s+"'")
-			}
-		}
-	}
-
-	// Body for POST/PUT
-	switch r.Method {
-	case "POST", "PUT", "PATCH":
-		if len(r.Payload) > 0 {
-			jsonPayload, _ := json.Marshal(r.Payload)
-			parts = append(parts, "-d", "'"+string(jsonPayload)+"'")
-		}
-	}
-
-	return strings.Join(parts, " \\\n  ")
-}
@@ -0,0 +1,602 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*
+HTTP files
+The .http / .rest format of the VS Code REST Client and of the JetBrains
+HTTP client: requests separated by ### lines, @name = value file
+variables and {{name}} references.
+
+	@baseUrl = https://api.example.com
+
+	### List users
+	# @name listUsers
+	GET {{baseUrl}}/users?page=1
+	Accept: application/json
+
+	### Create a user
+	POST {{baseUrl}}/users
+	Content-Type: application/json
+
+	{"name": "bob"}
+*/
+
+/*
+HTTPFile
+Requests and file variables read from a .http file
+*/
+type HTTPFile struct {
+	Variables map[string]string
+	Requests  []Request
+	Warnings  []string
+}
+
+var (
+	httpFileVariable = regexp.MustCompile(`^@([\w.-]+)\s*=\s*(.*)$`)
+	httpFileComment  = regexp.MustCompile(`^(#|//)\s*(.*)$`)
+	httpFileVersion  = regexp.MustCompile(`^HTTP/[\d.]+$`)
+	httpMethods      = map[string]bool{
+		"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true,
+		"HEAD": true, "OPTIONS": true, "TRACE": true, "CONNECT": true,
+	}
+)
+
+/*
+ParseHTTPFile reads the requests of a .http file. Bodies and multipart
+files given as "< path" are relative to dir.
+*/
+func ParseHTTPFile(data []byte, dir string) (HTTPFile, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	imp := Import{}
+	variables := map[string]string{}
+
+	var blocks [][]string
+	var titles []string
+	current, title := []string{}, ""
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "###") {
+			blocks, titles = append(blocks, current), append(titles, title)
+			current, title = []string{}, strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+			continue
+		}
+		current = append(current, line)
+	}
+	blocks, titles = append(blocks, current), append(titles, title)
+
+	for i, block := range blocks {
+		r, ok, err := imp.httpFileRequest(block, titles[i], dir, variables)
+		if err != nil {
+			return HTTPFile{}, err
+		}
+		if ok {
+			imp.Requests = append(imp.Requests, r)
+		}
+	}
+	if len(imp.Requests) == 0 {
+		return HTTPFile{}, fmt.Errorf("no request found in the file")
+	}
+	return HTTPFile{Variables: variables, Requests: imp.Requests, Warnings: imp.Warnings}, nil
+}
+
+// httpFileRequest parses one ### block, reporting false when it holds no request
+func (imp *Import) httpFileRequest(lines []string, title, dir string, variables map[string]string) (Request, bool, error) {
+	r := Request{Headers: map[string]interface{}{}}
+	name := ""
+	i := 0
+
+	// Comments, directives and variables before the request line
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		if m := httpFileVariable.FindStringSubmatch(line); m != nil {
+			variables[m[1]] = strings.TrimSpace(m[2])
+			continue
+		}
+		if m := httpFileComment.FindStringSubmatch(line); m != nil {
+			directive, value, _ := strings.Cut(m[2], " ")
+			switch directive {
+			case "@name":
+				name = strings.TrimSpace(value)
+			case "@insecure":
+				r.Insecure = true
+			case "@prompt":
+				imp.warnf("%s: prompted variables are not supported, define %s with @%s = value", title, strings.TrimSpace(value), strings.TrimSpace(value))
+			}
+			continue
+		}
+		break
+	}
+	if i == len(lines) {
+		return r, false, nil
+	}
+
+	// Request line, optionally followed by ?query and &query lines, the
+	// HTTP version ending the last one
+	requestLine := strings.TrimSpace(lines[i])
+	for i++; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "?") && !strings.HasPrefix(line, "&") {
+			break
+		}
+		requestLine += line
+	}
+	fields := strings.Fields(requestLine)
+	r.Method = "GET"
+	if httpMethods[strings.ToUpper(fields[0])] {
+		r.Method, fields = strings.ToUpper(fields[0]), fields[1:]
+	}
+	if len(fields) > 0 && httpFileVersion.MatchString(fields[len(fields)-1]) {
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) == 0 {
+		return r, false, fmt.Errorf("line %q: missing URL", requestLine)
+	}
+	r.URL, r.Params = splitQuery(strings.Join(fields, " "))
+	if name == "" {
+		name = title
+	}
+	if name == "" {
+		name = DefaultRequestName(r.Method, r.URL)
+	}
+	r.Name = imp.uniqueName(name)
+
+	// Headers until the first blank line
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			i++
+			break
+		}
+		if httpFileComment.MatchString(line) {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			imp.warnf("%s: invalid header %q ignored", r.Name, line)
+			continue
+		}
+		r.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	httpFileAuth(&r)
+
+	// The body is the rest of the block, without the response handlers
+	var body []string
+	handler := false
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case handler:
+			handler = !strings.HasSuffix(trimmed, "%}")
+			continue
+		case strings.HasPrefix(trimmed, "> {%"):
+			handler = !strings.HasSuffix(trimmed, "%}") || trimmed == "> {%"
+			imp.warnf("%s: response handler scripts are not supported", r.Name)
+			continue
+		case strings.HasPrefix(trimmed, ">> ") || strings.HasPrefix(trimmed, "<> ") || strings.HasPrefix(trimmed, "> "):
+			continue
+		}
+		body = append(body, line)
+	}
+	for len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "" {
+		body = body[:len(body)-1]
+	}
+	if strings.Contains(strings.Join(lines, "\n"), ".response.") {
+		imp.warnf("%s: references to the response of another request are not supported", r.Name)
+	}
+	if len(body) > 0 {
+		imp.httpFileBody(&r, body, dir)
+	}
+	return r, true, nil
+}
+
+// splitQuery moves the query of a URL into editable params when no name is
+// repeated, leaving {{references}} untouched
+func splitQuery(rawURL string) (string, map[string]interface{}) {
+	base, query, found := strings.Cut(rawURL, "?")
+	if !found || query == "" {
+		return rawURL, nil
+	}
+	params := map[string]interface{}{}
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(k); err == nil {
+			k = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(v); err == nil {
+			v = unescaped
+		}
+		if _, repeated := params[k]; repeated {
+			return rawURL, nil
+		}
+		params[k] = v
+	}
+	return base, params
+}
+
+// httpFileAuth turns the Authorization shorthands of the REST Client,
+// "Basic user password" and "Digest user password", into auth
+func httpFileAuth(r *Request) {
+	liftBearerHeader(r)
+	for name, v := range r.Headers {
+		if !strings.EqualFold(name, "Authorization") {
+			continue
+		}
+		scheme, credentials, _ := strings.Cut(fmt.Sprint(v), " ")
+		scheme = strings.ToLower(scheme)
+		if scheme != "basic" && scheme != "digest" {
+			continue
+		}
+		user, password, ok := strings.Cut(strings.TrimSpace(credentials), " ")
+		if !ok && scheme == "basic" {
+			// user:password in clear, an already encoded header stays as it is
+			user, password, ok = strings.Cut(credentials, ":")
+		}
+		if ok {
+			r.Auth = &AuthConfig{Type: scheme, Username: strings.TrimSpace(user), Password: strings.TrimSpace(password)}
+			delete(r.Headers, name)
+		}
+	}
+}
+
+func (imp *Import) httpFileBody(r *Request, lines []string, dir string) {
+	contentType := ""
+	for name, v := range r.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			contentType = fmt.Sprint(v)
+		}
+	}
+	media, params, _ := mime.ParseMediaType(contentType)
+	if media == "multipart/form-data" && params["boundary"] != "" {
+		imp.httpFileMultipart(r, lines, params["boundary"], dir)
+		return
+	}
+
+	body := strings.Join(lines, "\n")
+	if path, ok := httpFileInclude(body); ok {
+		content, err := os.ReadFile(httpFilePath(dir, path))
+		if err != nil {
+			imp.warnf("%s: body not imported: %v", r.Name, err)
+			return
+		}
+		body = string(content)
+	}
+	switch {
+	case media == "application/x-www-form-urlencoded":
+		// Fields may be written one per line, starting with &
+		joined := ""
+		for _, line := range strings.Split(body, "\n") {
+			joined += strings.TrimSpace(line)
+		}
+		setCurlBody(r, joined, true)
+	case media != "":
+		setCurlBody(r, body, false)
+	default:
+		var payload map[string]interface{}
+		if json.Unmarshal([]byte(body), &payload) == nil {
+			setCurlBody(r, body, false)
+		} else {
+			r.BodyType, r.Body = "raw", body
+		}
+	}
+}
+
+// httpFileInclude reports the path of a body written as "< path" or "<@ path"
+func httpFileInclude(body string) (string, bool) {
+	body = strings.TrimSpace(body)
+	if strings.Contains(body, "\n") || !strings.HasPrefix(body, "<") {
+		return "", false
+	}
+	path := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(body, "<"), "@"))
+	return path, path != "" && !strings.HasPrefix(path, "?") && !strings.HasPrefix(path, "!")
+}
+
+func httpFilePath(dir, path string) string {
+	if dir == "" || filepath.IsAbs(path) || strings.Contains(path, "{{") {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func (imp *Import) httpFileMultipart(r *Request, lines []string, boundary, dir string) {
+	var field *FormField
+	var content []string
+	inHeaders := false
+	flush := func() {
+		if field == nil {
+			return
+		}
+		value := strings.Join(content, "\n")
+		if path, ok := httpFileInclude(value); ok {
+			field.File = httpFilePath(dir, path)
+		} else {
+			field.Value = strings.TrimRight(value, "\n")
+		}
+		r.Form = append(r.Form, *field)
+		field, content = nil, nil
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "--"+boundary || trimmed == "--"+boundary+"--" {
+			flush()
+			field, inHeaders = &FormField{}, true
+			if trimmed == "--"+boundary+"--" {
+				field = nil
+			}
+			continue
+		}
+		if field == nil {
+			continue
+		}
+		if inHeaders {
+			if trimmed == "" {
+				inHeaders = false
+				continue
+			}
+			key, value, _ := strings.Cut(trimmed, ":")
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "content-disposition":
+				_, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+				if err == nil {
+					field.Name = params["name"]
+				}
+			case "content-type":
+				field.ContentType = strings.TrimSpace(value)
+			}
+			continue
+		}
+		content = append(content, line)
+	}
+	flush()
+
+	r.BodyType = "multipart"
+	for name := range r.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			// The boundary is generated when sending
+			delete(r.Headers, name)
+		}
+	}
+	for i, f := range r.Form {
+		if f.ContentType != "" && f.File == "" {
+			imp.warnf("%s: content type of the field %s ignored", r.Name, f.Name)
+			r.Form[i].ContentType = ""
+		}
+	}
+}
+
+/*
+Names lists the requests of the file
+*/
+func (f HTTPFile) Names() []string {
+	names := make([]string, len(f.Requests))
+	for i, r := range f.Requests {
+		names[i] = r.Name
+	}
+	return names
+}
+
+/*
+Request returns a request of the file with its references replaced. File
+variables override vars, e.g. those of the active environment, and may
+reference them.
+*/
+func (f HTTPFile) Request(name string, vars map[string]string) (Request, error) {
+	for _, r := range f.Requests {
+		if r.Name != name {
+			continue
+		}
+		resolved := map[string]string{}
+		for k, v := range vars {
+			resolved[k] = v
+		}
+		// A few passes for the file variables referencing each other
+		for pass := 0; pass < 3; pass++ {
+			for k, v := range f.Variables {
+				resolved[k] = Substitute(v, resolved)
+			}
+		}
+		if r.Auth != nil {
+			auth := *r.Auth
+			r.Auth = &auth
+		}
+		return applyVariables(r, resolved), nil
+	}
+	return Request{}, fmt.Errorf("request %q not found in the file", name)
+}
+
+/*
+ImportHTTPFile converts a .http file into requests of collection ("http"
+by default), the file variables becoming variables of that folder
+*/
+func ImportHTTPFile(data []byte, collection string) (Import, error) {
+	f, err := ParseHTTPFile(data, "")
+	if err != nil {
+		return Import{}, err
+	}
+	collection = CleanCollection(collection)
+	if collection == "" {
+		collection = "http"
+	}
+	imp := Import{Warnings: f.Warnings}
+	for _, r := range f.Requests {
+		r.Collection = collection
+		imp.Requests = append(imp.Requests, r)
+	}
+	if len(f.Variables) > 0 {
+		imp.Folders = map[string]Folder{collection: {Variables: f.Variables}}
+	}
+	return imp, nil
+}
+
+const httpFileBoundary = "----HttpTankerBoundary"
+
+/*
+ExportHTTPFile writes the requests of a collection, every request for "",
+as a .http file. Folder variables become file variables, folder defaults
+are applied to each request and auth profiles are inlined. The returned
+warnings list what could not be exported.
+*/
+func (db *Database) ExportHTTPFile(collection string) ([]byte, []string, error) {
+	collection = CleanCollection(collection)
+	var warnings []string
+	warnf := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	var b strings.Builder
+	variables := db.collectionVariables(collection, warnf)
+	for _, k := range sortedKeys(variables) {
+		fmt.Fprintf(&b, "@%s = %s\n", k, variables[k])
+	}
+
+	exported := 0
+	for _, n := range sortedKeys(db.Data) {
+		if !InCollection(db.Data[n].Collection, collection) {
+			continue
+		}
+		exported++
+		r := db.applyFolders(db.Data[n])
+		if r.AuthProfile != "" {
+			if p, ok := db.Profiles[r.AuthProfile]; ok {
+				r.Auth = &p
+			} else {
+				warnf("%s: auth profile %s not found", n, r.AuthProfile)
+			}
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		writeHTTPRequest(&b, r, warnf)
+	}
+	if exported == 0 {
+		return nil, nil, fmt.Errorf("no request to export")
+	}
+	return []byte(b.String()), warnings, nil
+}
+
+// writeHTTPRequest writes one request of a .http file
+func writeHTTPRequest(b *strings.Builder, r Request, warnf func(string, ...interface{})) {
+	fmt.Fprintf(b, "### %s\n# @name %s\n", r.Name, r.Name)
+	if r.Insecure {
+		b.WriteString("# @insecure\n")
+	}
+	if len(r.Tags) > 0 {
+		warnf("%s: tags not exported", r.Name)
+	}
+
+	target := r.URL
+	if len(r.Params) > 0 {
+		var query []string
+		for _, k := range sortedKeys(r.Params) {
+			query = append(query, httpFileEscape(k)+"="+httpFileEscape(fmt.Sprint(r.Params[k])))
+		}
+		separator := "?"
+		if strings.Contains(target, "?") {
+			separator = "&"
+		}
+		target += separator + strings.Join(query, "&")
+	}
+	fmt.Fprintf(b, "%s %s\n", r.Method, target)
+
+	headers := map[string]interface{}{}
+	for k, v := range r.Headers {
+		headers[k] = v
+	}
+	if r.Auth != nil {
+		switch r.Auth.Type {
+		case "bearer":
+			headers["Authorization"] = "Bearer " + r.Auth.Token
+		case "basic":
+			headers["Authorization"] = "Basic " + r.Auth.Username + " " + r.Auth.Password
+		case "digest":
+			headers["Authorization"] = "Digest " + r.Auth.Username + " " + r.Auth.Password
+		case "api-key":
+			header := r.Auth.Header
+			if header == "" {
+				header = "X-API-Key"
+			}
+			headers[header] = r.Auth.Key
+		default:
+			warnf("%s: %s auth has no .http equivalent, not exported", r.Name, r.Auth.Type)
+		}
+	}
+	hasContentType := false
+	for k := range headers {
+		hasContentType = hasContentType || strings.EqualFold(k, "Content-Type")
+	}
+
+	var body string
+	switch r.BodyType {
+	case "raw":
+		body = r.Body
+	case "form":
+		var pairs []string
+		for _, f := range r.Form {
+			pairs = append(pairs, httpFileEscape(f.Name)+"="+httpFileEscape(f.Value))
+		}
+		body = strings.Join(pairs, "&")
+		if !hasContentType {
+			headers["Content-Type"] = "application/x-www-form-urlencoded"
+		}
+	case "multipart":
+		for k := range headers {
+			if strings.EqualFold(k, "Content-Type") {
+				delete(headers, k)
+			}
+		}
+		headers["Content-Type"] = "multipart/form-data; boundary=" + httpFileBoundary
+		var parts strings.Builder
+		for _, f := range r.Form {
+			fmt.Fprintf(&parts, "--%s\n", httpFileBoundary)
+			if f.File != "" {
+				fmt.Fprintf(&parts, "Content-Disposition: form-data; name=%q; filename=%q\n", f.Name, filepath.Base(f.File))
+				if f.ContentType != "" {
+					fmt.Fprintf(&parts, "Content-Type: %s\n", f.ContentType)
+				}
+				fmt.Fprintf(&parts, "\n< %s\n", f.File)
+				continue
+			}
+			fmt.Fprintf(&parts, "Content-Disposition: form-data; name=%q\n\n%s\n", f.Name, f.Value)
+		}
+		fmt.Fprintf(&parts, "--%s--", httpFileBoundary)
+		body = parts.String()
+	default:
+		if r.Payload != nil {
+			buffer, _ := json.MarshalIndent(r.Payload, "", "  ")
+			body = string(buffer)
+			if !hasContentType {
+				headers["Content-Type"] = "application/json"
+			}
+		}
+	}
+
+	for _, k := range sortedKeys(headers) {
+		fmt.Fprintf(b, "%s: %v\n", k, headers[k])
+	}
+	if body != "" {
+		fmt.Fprintf(b, "\n%s\n", body)
+	}
+}
+
+// httpFileEscape escapes a query value, leaving {{references}} readable
+func httpFileEscape(s string) string {
+	if strings.Contains(s, "{{") {
+		return strings.NewReplacer("&", "%26", "=", "%3D", " ", "%20", "#", "%23").Replace(s)
+	}
+	return url.QueryEscape(s)
+}
@@ -0,0 +1,250 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+/*
+Import
+Requests, folder defaults and environments read from another tool. Saving
+an import never silently replaces existing requests: they are skipped
+unless overwrite is set, and the report lists what happened to each one.
+Requests with a source (e.g. an OpenAPI operation) are merged with their
+previous import instead, keeping the fields edited since.
+*/
+type Import struct {
+	Requests     []Request
+	Folders      map[string]Folder
+	Environments map[string]Environment
+	Warnings     []string // features that could not be mapped
+}
+
+/*
+RequestSource
+Where an imported request comes from. Hashes fingerprint each imported
+field so that a re-import can tell local edits from spec changes.
+*/
+type RequestSource struct {
+	Document  string            `json:"document"`  // e.g. the title of an OpenAPI spec
+	Operation string            `json:"operation"` // e.g. "GET /users/{id}"
+	Hashes    map[string]string `json:"hashes,omitempty"`
+}
+
+type ImportReport struct {
+	Added    []string `json:"added"`
+	Updated  []string `json:"updated,omitempty"`
+	Skipped  []string `json:"skipped,omitempty"` // already existing, not overwritten
+	Kept     []string `json:"kept,omitempty"`    // locally edited fields left as they are, "<request>: <field>"
+	Removed  []string `json:"removed,omitempty"` // no longer in the source document, left in place
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func (imp *Import) warnf(format string, args ...interface{}) {
+	imp.Warnings = append(imp.Warnings, fmt.Sprintf(format, args...))
+}
+
+// uniqueName suffixes a name already used by another request of the import
+func (imp *Import) uniqueName(name string) string {
+	taken := func(candidate string) bool {
+		for _, r := range imp.Requests {
+			if r.Name == candidate {
+				return true
+			}
+		}
+		return false
+	}
+	candidate := name
+	for i := 2; taken(candidate); i++ {
+		candidate = fmt.Sprintf("%s %d", name, i)
+	}
+	if candidate != name {
+		imp.warnf("%q renamed %q, request names must be unique", name, candidate)
+	}
+	return candidate
+}
+
+// Fields of an imported request merged separately on re-import
+var sourceFields = []string{"url", "params", "headers", "body", "auth"}
+
+func sourceField(r Request, field string) interface{} {
+	switch field {
+	case "url":
+		return r.URL
+	case "params":
+		return r.Params
+	case "headers":
+		return r.Headers
+	case "body":
+		return []interface{}{r.BodyType, r.Payload, r.Body, r.Form}
+	case "auth":
+		return []interface{}{r.Auth, r.AuthProfile}
+	}
+	return nil
+}
+
+func copySourceField(dst *Request, src Request, field string) {
+	switch field {
+	case "url":
+		dst.URL = src.URL
+	case "params":
+		dst.Params = src.Params
+	case "headers":
+		dst.Headers = src.Headers
+	case "body":
+		dst.BodyType, dst.Payload, dst.Body, dst.Form = src.BodyType, src.Payload, src.Body, src.Form
+	case "auth":
+		dst.Auth, dst.AuthProfile = src.Auth, src.AuthProfile
+	}
+}
+
+func sourceHash(r Request, field string) string {
+	buffer, _ := json.Marshal(sourceField(r, field))
+	sum := sha256.Sum256(buffer)
+	return hex.EncodeToString(sum[:8])
+}
+
+func sourceKey(s *RequestSource) string {
+	return s.Document + "\x00" + s.Operation
+}
+
+// freeName returns name, suffixed when a saved request already uses it
+func (db *Database) freeName(name string) string {
+	candidate := name
+	for i := 2; ; i++ {
+		if _, exists := db.Data[candidate]; !exists {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s %d", name, i)
+	}
+}
+
+/*
+SaveImport saves an import in a single write
+*/
+func (db *Database) SaveImport(imp Import, overwrite bool) (ImportReport, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	report := ImportReport{Added: []string{}, Warnings: imp.Warnings}
+
+	imported := map[string]string{} // source key -> request name
+	documents := map[string]bool{}
+	for name, r := range db.Data {
+		if r.Source != nil {
+			imported[sourceKey(r.Source)] = name
+		}
+	}
+	seen := map[string]bool{}
+
+	for _, r := range imp.Requests {
+		if r.Source != nil {
+			// Hashed as it will read back from the disk, empty fields omitted
+			r = cloneRequest(r)
+			source := *r.Source
+			source.Hashes = map[string]string{}
+			for _, field := range sourceFields {
+				source.Hashes[field] = sourceHash(r, field)
+			}
+			r.Source = &source
+			documents[source.Document] = true
+			seen[sourceKey(&source)] = true
+			if name, ok := imported[sourceKey(&source)]; ok {
+				db.mergeImported(name, r, &report)
+				continue
+			}
+			r.Name = db.freeName(r.Name)
+		}
+		if _, exists := db.Data[r.Name]; exists {
+			if !overwrite {
+				report.Skipped = append(report.Skipped, r.Name)
+				continue
+			}
+			r.LastUsed = db.Data[r.Name].LastUsed
+			report.Updated = append(report.Updated, r.Name)
+		} else {
+			report.Added = append(report.Added, r.Name)
+		}
+		db.Data[r.Name] = r
+	}
+	for key, name := range imported {
+		if source := db.Data[name].Source; documents[source.Document] && !seen[key] {
+			report.Removed = append(report.Removed, name)
+		}
+	}
+
+	for path, folder := range imp.Folders {
+		existing, exists := db.Folders[path]
+		if !exists || overwrite {
+			db.Folders[path] = folder
+			continue
+		}
+		// Keep the local settings, adding what is new
+		for k, v := range folder.Variables {
+			if _, ok := existing.Variables[k]; !ok {
+				if existing.Variables == nil {
+					existing.Variables = map[string]string{}
+				}
+				existing.Variables[k] = v
+			}
+		}
+		if existing.Auth == nil && existing.AuthProfile == "" {
+			existing.Auth = folder.Auth
+		}
+		if existing.BaseURL == "" {
+			existing.BaseURL = folder.BaseURL
+		}
+		db.Folders[path] = existing
+	}
+	for name, env := range imp.Environments {
+		existing, exists := db.Environments[name]
+		if !exists || overwrite {
+			db.Environments[name] = env
+			continue
+		}
+		// Its values are kept, only the missing variables are added
+		report.Skipped = append(report.Skipped, "environment "+name)
+		if existing.Variables == nil {
+			existing.Variables = map[string]string{}
+		}
+		for k, v := range env.Variables {
+			if _, ok := existing.Variables[k]; !ok {
+				existing.Variables[k] = v
+			}
+		}
+		db.Environments[name] = existing
+	}
+	sort.Strings(report.Added)
+	sort.Strings(report.Updated)
+	sort.Strings(report.Skipped)
+	sort.Strings(report.Kept)
+	sort.Strings(report.Removed)
+	return report, db.saveLocked()
+}
+
+// mergeImported updates the fields of a previously imported request that
+// were not edited since its last import
+func (db *Database) mergeImported(name string, r Request, report *ImportReport) {
+	local := db.Data[name]
+	merged := cloneRequest(local)
+	updated := false
+	for _, field := range sourceFields {
+		current := sourceHash(local, field)
+		if current == r.Source.Hashes[field] {
+			continue
+		}
+		if local.Source.Hashes[field] != current {
+			report.Kept = append(report.Kept, name+": "+field)
+			continue
+		}
+		copySourceField(&merged, r, field)
+		updated = true
+	}
+	merged.Source = r.Source
+	db.Data[name] = merged
+	if updated {
+		report.Updated = append(report.Updated, name)
+	}
+}
@@ -0,0 +1,352 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expirySkew renews tokens slightly before they actually expire so that a
+// token is never sent while it is about to be rejected.
+const expirySkew = 30 * time.Second
+
+type OAuthToken struct {
+	Key          string    `json:"key"`
+	TokenURL     string    `json:"tokenUrl"`
+	ClientID     string    `json:"clientId,omitempty"`
+	Scopes       []string  `json:"scopes,omitempty"`
+	AccessToken  string    `json:"accessToken"`
+	TokenType    string    `json:"tokenType,omitempty"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+func (t OAuthToken) Expired() bool {
+	if t.Expiry.IsZero() {
+		return false
+	}
+	return time.Now().Add(expirySkew).After(t.Expiry)
+}
+
+func (t OAuthToken) AuthorizationHeader() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+/*
+TokenCache
+OAuth 2.0 access tokens indexed by token endpoint, client and scopes.
+When a file is attached, the cache is shared with other tanker processes
+using the same database directory.
+*/
+type TokenCache struct {
+	mu     sync.Mutex
+	file   string
+	tokens map[string]OAuthToken
+	base   snapshot
+}
+
+var Tokens = &TokenCache{tokens: map[string]OAuthToken{}}
+
+/*
+SetFile attaches a persistent file to the cache and loads its content
+*/
+func (c *TokenCache) SetFile(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.file = path
+	return c.loadLocked()
+}
+
+func (c *TokenCache) loadLocked() error {
+	if c.file == "" {
+		return nil
+	}
+	tokens := map[string]OAuthToken{}
+	exists, err := readJSONFile(c.file, &tokens)
+	if err != nil || !exists {
+		return err
+	}
+	c.tokens = tokens
+	c.base = takeSnapshot(tokens)
+	return nil
+}
+
+func (c *TokenCache) saveLocked() error {
+	if c.file == "" {
+		return nil
+	}
+	// The MCP servers and the TUI share the cache file
+	unlock, err := lockFile(c.file + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var disk map[string]OAuthToken
+	if _, err := readJSONFile(c.file, &disk); err != nil {
+		return err
+	}
+	c.tokens = mergeChanges(c.base, c.tokens, disk)
+
+	buffer, err := json.Marshal(c.tokens)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.file, buffer, 0600); err != nil {
+		return err
+	}
+	c.base = takeSnapshot(c.tokens)
+	return nil
+}
+
+/*
+List returns the cached tokens sorted by token endpoint
+*/
+func (c *TokenCache) List() ([]OAuthToken, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadLocked(); err != nil {
+		return nil, err
+	}
+	list := make([]OAuthToken, 0, len(c.tokens))
+	for _, t := range c.tokens {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].TokenURL != list[j].TokenURL {
+			return list[i].TokenURL < list[j].TokenURL
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list, nil
+}
+
+/*
+Clear removes a single token by key, or every token when key is empty
+*/
+func (c *TokenCache) Clear(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadLocked(); err != nil {
+		return err
+	}
+	if key == "" {
+		c.tokens = map[string]OAuthToken{}
+	} else {
+		if _, ok := c.tokens[key]; !ok {
+			return fmt.Errorf("token %q not found", key)
+		}
+		delete(c.tokens, key)
+	}
+	return c.saveLocked()
+}
+
+/*
+Invalidate drops the access token of an auth config, keeping its refresh
+token so the next call can renew it
+*/
+func (c *TokenCache) Invalidate(auth *AuthConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := TokenKey(auth)
+	t, ok := c.tokens[key]
+	if !ok {
+		return
+	}
+	if t.RefreshToken == "" {
+		delete(c.tokens, key)
+	} else {
+		t.AccessToken = ""
+		c.tokens[key] = t
+	}
+	c.saveLocked()
+}
+
+/*
+Peek returns the cached token of an auth config if it is still valid
+*/
+func (c *TokenCache) Peek(auth *AuthConfig) (OAuthToken, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+	t, ok := c.tokens[TokenKey(auth)]
+	if !ok || t.AccessToken == "" || t.Expired() {
+		return OAuthToken{}, false
+	}
+	return t, true
+}
+
+/*
+Token returns a valid access token for the auth config, fetching or
+refreshing it when needed
+*/
+func (c *TokenCache) Token(auth *AuthConfig, client *http.Client) (OAuthToken, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadLocked(); err != nil {
+		return OAuthToken{}, err
+	}
+
+	key := TokenKey(auth)
+	cached, ok := c.tokens[key]
+	if ok && cached.AccessToken != "" && !cached.Expired() {
+		return cached, nil
+	}
+	if !ok && auth.AccessToken != "" {
+		// Seed the cache with the tokens stored in the auth config after a login
+		cached = OAuthToken{AccessToken: auth.AccessToken, RefreshToken: auth.RefreshToken}
+		if auth.TokenExpiry != nil {
+			cached.Expiry = *auth.TokenExpiry
+		}
+		if !cached.Expired() {
+			return c.putLocked(auth, cached)
+		}
+		ok = true
+	}
+
+	var token OAuthToken
+	var err error
+	refreshToken := auth.RefreshToken
+	if ok && cached.RefreshToken != "" {
+		refreshToken = cached.RefreshToken
+	}
+	if refreshToken != "" {
+		token, err = requestToken(auth, client, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+		})
+		if err == nil && token.RefreshToken == "" {
+			token.RefreshToken = refreshToken
+		}
+	}
+	if refreshToken == "" || err != nil {
+		if grantType(auth) != "client_credentials" {
+			if err != nil {
+				return OAuthToken{}, err
+			}
+			if grantType(auth) == "authorization_code" {
+				return OAuthToken{}, fmt.Errorf("not logged in, run the OAuth login first")
+			}
+			return OAuthToken{}, fmt.Errorf("no refresh token available for grant %q", grantType(auth))
+		}
+		token, err = requestToken(auth, client, url.Values{
+			"grant_type": {"client_credentials"},
+		})
+		if err != nil {
+			return OAuthToken{}, err
+		}
+	}
+
+	return c.putLocked(auth, token)
+}
+
+func (c *TokenCache) putLocked(auth *AuthConfig, token OAuthToken) (OAuthToken, error) {
+	token.Key = TokenKey(auth)
+	token.TokenURL = auth.TokenURL
+	token.ClientID = auth.ClientID
+	token.Scopes = auth.Scopes
+	c.tokens[token.Key] = token
+	if err := c.saveLocked(); err != nil {
+		return OAuthToken{}, err
+	}
+	return token, nil
+}
+
+/*
+TokenKey identifies the cache entry of an auth config
+*/
+func TokenKey(auth *AuthConfig) string {
+	scopes := append([]string(nil), auth.Scopes...)
+	sort.Strings(scopes)
+	h := sha256.Sum256([]byte(strings.Join([]string{
+		auth.TokenURL,
+		auth.ClientID,
+		strings.Join(scopes, " "),
+		auth.Audience,
+		grantType(auth),
+	}, "\n")))
+	return hex.EncodeToString(h[:8])
+}
+
+func grantType(auth *AuthConfig) string {
+	if auth.GrantType == "" {
+		return "client_credentials"
+	}
+	return auth.GrantType
+}
+
+func requestToken(auth *AuthConfig, client *http.Client, form url.Values) (OAuthToken, error) {
+	if auth.TokenURL == "" {
+		return OAuthToken{}, fmt.Errorf("missing token URL")
+	}
+	if len(auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+	if auth.Audience != "" {
+		form.Set("audience", auth.Audience)
+	}
+	if auth.ClientSecret == "" && auth.ClientID != "" {
+		// Public clients identify themselves in the body
+		form.Set("client_id", auth.ClientID)
+	}
+
+	req, err := http.NewRequest("POST", auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OAuthToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if auth.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(auth.ClientID), url.QueryEscape(auth.ClientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return OAuthToken{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return OAuthToken{}, err
+	}
+
+	var payload struct {
+		AccessToken      string      `json:"access_token"`
+		TokenType        string      `json:"token_type"`
+		RefreshToken     string      `json:"refresh_token"`
+		ExpiresIn        json.Number `json:"expires_in"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return OAuthToken{}, fmt.Errorf("token endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if resp.StatusCode != http.StatusOK || payload.AccessToken == "" {
+		if payload.Error != "" {
+			return OAuthToken{}, fmt.Errorf("token endpoint returned %s: %s %s", resp.Status, payload.Error, payload.ErrorDescription)
+		}
+		return OAuthToken{}, fmt.Errorf("token endpoint returned %s without access token", resp.Status)
+	}
+
+	token := OAuthToken{
+		AccessToken:  payload.AccessToken,
+		TokenType:    payload.TokenType,
+		RefreshToken: payload.RefreshToken,
+	}
+	if seconds, err := payload.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		token.Expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,662 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+OpenAPI
+Import of OpenAPI 3.x and Swagger 2 specifications, in JSON or YAML. Every
+operation becomes a request in a folder named after its first tag, path
+parameters become {{variables}}, servers become environments providing
+{{baseUrl}} and security schemes become auth. Bodies come from the
+examples of the spec, or are generated from the schemas.
+Requests remember their operation, so importing a new version of the spec
+updates them without losing the fields edited locally.
+*/
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Maximum depth of a generated example, schemas may be recursive
+const openAPIExampleDepth = 8
+
+type openAPIDoc struct {
+	root       map[string]interface{}
+	swagger    bool
+	collection string
+	imp        *Import
+	warned     map[string]bool
+}
+
+/*
+ImportOpenAPI reads an OpenAPI 3.x or Swagger 2 specification. The requests
+are placed under collection, by default the title of the spec.
+*/
+func ImportOpenAPI(data []byte, collection string) (Import, error) {
+	var raw interface{}
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return Import{}, fmt.Errorf("invalid OpenAPI document: %w", err)
+		}
+	} else if err := yaml.Unmarshal(data, &raw); err != nil {
+		return Import{}, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	root, _ := normalizeYAML(raw).(map[string]interface{})
+	d := &openAPIDoc{root: root, imp: &Import{Folders: map[string]Folder{}, Environments: map[string]Environment{}}, warned: map[string]bool{}}
+	switch {
+	case strings.HasPrefix(openAPIString(root["openapi"]), "3."):
+	case strings.HasPrefix(openAPIString(root["swagger"]), "2."):
+		d.swagger = true
+	default:
+		return Import{}, fmt.Errorf("not an OpenAPI 3 or Swagger 2 document")
+	}
+
+	title := openAPIString(openAPIObject(root["info"])["title"])
+	d.collection = CleanCollection(collection)
+	if d.collection == "" {
+		d.collection = CleanCollection(strings.ReplaceAll(title, "/", "-"))
+	}
+	if d.collection == "" {
+		d.collection = "openapi"
+	}
+	if title == "" {
+		title = d.collection
+	}
+
+	rootFolder := Folder{Variables: map[string]string{}}
+	d.servers(&rootFolder)
+	global, hasGlobal := root["security"]
+	if hasGlobal {
+		var params map[string]interface{}
+		rootFolder.Auth = d.securityAuth(global, "security", &params)
+		if params != nil {
+			// Query API keys can't be folder defaults, requests get them
+			rootFolder.Auth = nil
+		}
+	}
+	if _, ok := root["webhooks"]; ok {
+		d.imp.warnf("webhooks not imported")
+	}
+
+	paths := openAPIObject(root["paths"])
+	for _, path := range sortedKeys(paths) {
+		item := d.resolve(paths[path])
+		for _, method := range openAPIMethods {
+			op := openAPIObject(item[method])
+			if op == nil {
+				continue
+			}
+			r := d.operation(path, method, item, op, rootFolder.Variables)
+			security, own := op["security"]
+			if !own {
+				security, own = global, hasGlobal && rootFolder.Auth == nil
+			}
+			where := strings.ToUpper(method) + " " + path
+			if own {
+				if list := openAPIList(security); len(list) == 0 && rootFolder.Auth != nil {
+					d.imp.warnf("%s: \"no auth\" can't override the auth of the collection", where)
+				}
+				r.Auth = d.securityAuth(security, where, &r.Params)
+			}
+			r.Source = &RequestSource{Document: title, Operation: where}
+			r.Name = d.imp.uniqueName(r.Name)
+			d.imp.Requests = append(d.imp.Requests, r)
+		}
+	}
+	if len(rootFolder.Variables) == 0 {
+		rootFolder.Variables = nil
+	}
+	if rootFolder.Variables != nil || rootFolder.Auth != nil {
+		d.imp.Folders[d.collection] = rootFolder
+	}
+	return *d.imp, nil
+}
+
+// normalizeYAML turns the maps decoded by yaml, whose keys may be numbers
+// (e.g. response codes), into JSON objects
+func normalizeYAML(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = normalizeYAML(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			out[fmt.Sprint(k)] = normalizeYAML(item)
+		}
+		return out
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalizeYAML(item)
+		}
+		return value
+	}
+	return v
+}
+
+func openAPIObject(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func openAPIList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+func openAPIString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	}
+	buffer, _ := json.Marshal(v)
+	return string(buffer)
+}
+
+func (d *openAPIDoc) warnOnce(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if !d.warned[message] {
+		d.warned[message] = true
+		d.imp.Warnings = append(d.imp.Warnings, message)
+	}
+}
+
+// resolve follows the local $ref of an object, "#/components/schemas/User"
+func (d *openAPIDoc) resolve(v interface{}) map[string]interface{} {
+	m := openAPIObject(v)
+	for i := 0; i < 16 && m != nil; i++ {
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			d.warnOnce("external reference %s not resolved", ref)
+			return nil
+		}
+		var target interface{} = d.root
+		for _, part := range strings.Split(ref[2:], "/") {
+			part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+			part, _ = url.PathUnescape(part)
+			target = openAPIObject(target)[part]
+		}
+		if target == nil {
+			d.warnOnce("reference %s not found", ref)
+		}
+		m = openAPIObject(target)
+	}
+	return m
+}
+
+// servers sets the {{baseUrl}} of the collection and adds one environment
+// per server
+func (d *openAPIDoc) servers(folder *Folder) {
+	type server struct{ url, label string }
+	var servers []server
+	if d.swagger {
+		host := openAPIString(d.root["host"])
+		basePath := strings.TrimRight(openAPIString(d.root["basePath"]), "/")
+		if host == "" {
+			d.imp.warnf("no host in the spec, set the baseUrl variable")
+			return
+		}
+		schemes := openAPIList(d.root["schemes"])
+		if len(schemes) == 0 {
+			schemes = []interface{}{"https"}
+		}
+		for _, scheme := range schemes {
+			u := openAPIString(scheme) + "://" + host + basePath
+			servers = append(servers, server{url: u, label: u})
+		}
+	} else {
+		for _, s := range openAPIList(d.root["servers"]) {
+			s := openAPIObject(s)
+			u := strings.TrimRight(openAPIString(s["url"]), "/")
+			for name, variable := range openAPIObject(s["variables"]) {
+				u = strings.ReplaceAll(u, "{"+name+"}", openAPIString(openAPIObject(variable)["default"]))
+			}
+			label := openAPIString(s["description"])
+			if label == "" {
+				label = u
+			}
+			if !strings.Contains(u, "://") {
+				d.imp.warnf("relative server URL %q, set the baseUrl variable", u)
+			}
+			servers = append(servers, server{url: u, label: label})
+		}
+		if len(servers) == 0 {
+			d.imp.warnf("no server in the spec, set the baseUrl variable")
+			return
+		}
+	}
+	folder.Variables["baseUrl"] = servers[0].url
+	for _, s := range servers {
+		d.imp.Environments[d.collection+" ("+s.label+")"] = Environment{Variables: map[string]string{"baseUrl": s.url}}
+	}
+}
+
+// operation converts an operation, parameters of the path item included
+func (d *openAPIDoc) operation(path, method string, item, op map[string]interface{}, variables map[string]string) Request {
+	where := strings.ToUpper(method) + " " + path
+	r := Request{
+		Method:  strings.ToUpper(method),
+		Headers: map[string]interface{}{},
+		Params:  map[string]interface{}{},
+	}
+	r.Name = openAPIString(op["operationId"])
+	if r.Name == "" {
+		r.Name = strings.ToLower(method) + " " + path
+	}
+	r.Collection = d.collection
+	if tags := openAPIList(op["tags"]); len(tags) > 0 {
+		r.Collection = CleanCollection(d.collection + "/" + strings.ReplaceAll(openAPIString(tags[0]), "/", "-"))
+	}
+
+	// Operation parameters override the path item ones
+	params := map[string]map[string]interface{}{}
+	var order []string
+	for _, list := range [][]interface{}{openAPIList(item["parameters"]), openAPIList(op["parameters"])} {
+		for _, p := range list {
+			p := d.resolve(p)
+			if p == nil {
+				continue
+			}
+			key := openAPIString(p["in"]) + ":" + openAPIString(p["name"])
+			if _, ok := params[key]; !ok {
+				order = append(order, key)
+			}
+			params[key] = p
+		}
+	}
+
+	target := path
+	var cookies []string
+	var formParams []map[string]interface{}
+	for _, key := range order {
+		p := params[key]
+		name := openAPIString(p["name"])
+		value, hasValue := d.parameterExample(p)
+		required, _ := p["required"].(bool)
+		switch openAPIString(p["in"]) {
+		case "path":
+			target = strings.ReplaceAll(target, "{"+name+"}", "{{"+name+"}}")
+			if _, ok := variables[name]; !ok && hasValue {
+				variables[name] = value
+			}
+		case "query":
+			if required || hasValue {
+				r.Params[name] = value
+			}
+		case "header":
+			if required || hasValue {
+				r.Headers[name] = value
+			}
+		case "cookie":
+			if required || hasValue {
+				cookies = append(cookies, name+"="+value)
+			}
+		case "body":
+			d.jsonBody(&r, d.example(p["schema"], 0), "application/json")
+		case "formData":
+			formParams = append(formParams, p)
+		}
+	}
+	if len(cookies) > 0 {
+		r.Headers["Cookie"] = strings.Join(cookies, "; ")
+	}
+	r.URL = "{{baseUrl}}" + target
+	if len(r.Params) == 0 {
+		r.Params = nil
+	}
+
+	if len(formParams) > 0 {
+		d.swaggerForm(&r, op, formParams, where)
+	}
+	if body := d.resolve(op["requestBody"]); body != nil {
+		d.requestBody(&r, body, where)
+	}
+	return r
+}
+
+// parameterExample returns the example of a parameter as a string
+func (d *openAPIDoc) parameterExample(p map[string]interface{}) (string, bool) {
+	var value interface{}
+	switch {
+	case p["example"] != nil:
+		value = p["example"]
+	case p["x-example"] != nil:
+		value = p["x-example"]
+	case len(openAPIObject(p["examples"])) > 0:
+		examples := openAPIObject(p["examples"])
+		value = d.resolve(examples[sortedKeys(examples)[0]])["value"]
+	case p["schema"] != nil:
+		value = d.example(p["schema"], openAPIExampleDepth-1)
+		if s := d.resolve(p["schema"]); s["example"] == nil && s["default"] == nil && s["enum"] == nil {
+			return "", false
+		}
+	case p["default"] != nil || p["enum"] != nil:
+		// Swagger 2 parameters carry their schema
+		value = d.example(p, openAPIExampleDepth-1)
+	default:
+		return "", false
+	}
+	if list, ok := value.([]interface{}); ok {
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = openAPIString(item)
+		}
+		return strings.Join(parts, ","), value != nil
+	}
+	return openAPIString(value), value != nil
+}
+
+// requestBody sets the body of an OpenAPI 3 operation, preferring JSON
+func (d *openAPIDoc) requestBody(r *Request, body map[string]interface{}, where string) {
+	content := openAPIObject(body["content"])
+	if len(content) == 0 {
+		return
+	}
+	types := sortedKeys(content)
+	chosen := types[0]
+	for _, preferred := range []func(string) bool{
+		func(t string) bool { return t == "application/json" || strings.HasSuffix(t, "+json") },
+		func(t string) bool { return t == "application/x-www-form-urlencoded" },
+		func(t string) bool { return t == "multipart/form-data" },
+	} {
+		found := false
+		for _, t := range types {
+			if preferred(t) {
+				chosen, found = t, true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	media := openAPIObject(content[chosen])
+	var value interface{}
+	switch {
+	case media["example"] != nil:
+		value = media["example"]
+	case len(openAPIObject(media["examples"])) > 0:
+		examples := openAPIObject(media["examples"])
+		value = d.resolve(examples[sortedKeys(examples)[0]])["value"]
+	default:
+		value = d.example(media["schema"], 0)
+	}
+
+	switch {
+	case strings.Contains(chosen, "json"):
+		d.jsonBody(r, value, chosen)
+	case chosen == "application/x-www-form-urlencoded" || chosen == "multipart/form-data":
+		r.BodyType = "form"
+		if chosen == "multipart/form-data" {
+			r.BodyType = "multipart"
+		}
+		schema := d.schemaProperties(media["schema"], 0)
+		values := openAPIObject(value)
+		for _, name := range sortedKeys(schema) {
+			property := d.resolve(schema[name])
+			field := FormField{Name: name}
+			if format := openAPIString(property["format"]); format == "binary" || format == "base64" {
+				d.imp.warnf("%s: set the file of the form field %s", where, name)
+			} else if v, ok := values[name]; ok {
+				field.Value = openAPIString(v)
+			}
+			r.Form = append(r.Form, field)
+		}
+	default:
+		r.Headers["Content-Type"] = chosen
+		if s, ok := value.(string); ok {
+			r.BodyType, r.Body = "raw", s
+		} else {
+			d.imp.warnf("%s: no example for the %s body", where, chosen)
+		}
+	}
+}
+
+// jsonBody keeps an object as the payload, other JSON values as a raw body
+func (d *openAPIDoc) jsonBody(r *Request, value interface{}, contentType string) {
+	if value == nil {
+		return
+	}
+	r.Headers["Content-Type"] = contentType
+	jsonMethod := r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH"
+	if object, ok := value.(map[string]interface{}); ok && jsonMethod {
+		r.Payload = object
+		return
+	}
+	buffer, _ := json.MarshalIndent(value, "", "  ")
+	r.BodyType, r.Body = "raw", string(buffer)
+}
+
+// swaggerForm sets the body of a Swagger 2 operation from its formData parameters
+func (d *openAPIDoc) swaggerForm(r *Request, op map[string]interface{}, params []map[string]interface{}, where string) {
+	r.BodyType = "form"
+	consumes := openAPIList(op["consumes"])
+	if consumes == nil {
+		consumes = openAPIList(d.root["consumes"])
+	}
+	for _, c := range consumes {
+		if openAPIString(c) == "multipart/form-data" {
+			r.BodyType = "multipart"
+		}
+	}
+	for _, p := range params {
+		name := openAPIString(p["name"])
+		field := FormField{Name: name}
+		if openAPIString(p["type"]) == "file" {
+			r.BodyType = "multipart"
+			d.imp.warnf("%s: set the file of the form field %s", where, name)
+		} else {
+			field.Value, _ = d.parameterExample(p)
+		}
+		r.Form = append(r.Form, field)
+	}
+}
+
+// schemaProperties merges the properties of a schema and of its allOf parts
+func (d *openAPIDoc) schemaProperties(schema interface{}, depth int) map[string]interface{} {
+	s := d.resolve(schema)
+	properties := map[string]interface{}{}
+	if s == nil || depth > openAPIExampleDepth {
+		return properties
+	}
+	for _, part := range openAPIList(s["allOf"]) {
+		for k, v := range d.schemaProperties(part, depth+1) {
+			properties[k] = v
+		}
+	}
+	for k, v := range openAPIObject(s["properties"]) {
+		properties[k] = v
+	}
+	return properties
+}
+
+// example returns the example of a schema, generated from its type when the
+// spec has none
+func (d *openAPIDoc) example(schema interface{}, depth int) interface{} {
+	s := d.resolve(schema)
+	if s == nil || depth > openAPIExampleDepth {
+		return nil
+	}
+	for _, key := range []string{"example", "default", "const"} {
+		if v, ok := s[key]; ok {
+			return v
+		}
+	}
+	if examples := openAPIList(s["examples"]); len(examples) > 0 {
+		return examples[0]
+	}
+	if enum := openAPIList(s["enum"]); len(enum) > 0 {
+		return enum[0]
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alternatives := openAPIList(s[key]); len(alternatives) > 0 {
+			return d.example(alternatives[0], depth+1)
+		}
+	}
+
+	schemaType := openAPIString(s["type"])
+	if types := openAPIList(s["type"]); len(types) > 0 {
+		// OpenAPI 3.1 lists the types, e.g. ["string", "null"]
+		schemaType = openAPIString(types[0])
+		if schemaType == "null" && len(types) > 1 {
+			schemaType = openAPIString(types[1])
+		}
+	}
+	if schemaType == "" && (s["properties"] != nil || s["allOf"] != nil) {
+		schemaType = "object"
+	}
+	switch schemaType {
+	case "object":
+		out := map[string]interface{}{}
+		for _, part := range openAPIList(s["allOf"]) {
+			if object, ok := d.example(part, depth+1).(map[string]interface{}); ok {
+				for k, v := range object {
+					out[k] = v
+				}
+			}
+		}
+		properties := openAPIObject(s["properties"])
+		for _, name := range sortedKeys(properties) {
+			if readOnly, _ := d.resolve(properties[name])["readOnly"].(bool); readOnly {
+				continue
+			}
+			if v := d.example(properties[name], depth+1); v != nil {
+				out[name] = v
+			}
+		}
+		return out
+	case "array":
+		if item := d.example(s["items"], depth+1); item != nil {
+			return []interface{}{item}
+		}
+		return []interface{}{}
+	case "string":
+		switch openAPIString(s["format"]) {
+		case "date-time":
+			return "2024-01-01T00:00:00Z"
+		case "date":
+			return "2024-01-01"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+		case "uri", "url":
+			return "https://example.com"
+		case "binary", "byte", "base64":
+			return ""
+		}
+		return "string"
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	}
+	return nil
+}
+
+// securityAuth converts the first supported alternative of a security
+// requirement. API keys sent in the query are added to params.
+func (d *openAPIDoc) securityAuth(security interface{}, where string, params *map[string]interface{}) *AuthConfig {
+	schemes := openAPIObject(d.root["securityDefinitions"])
+	if !d.swagger {
+		schemes = openAPIObject(openAPIObject(d.root["components"])["securitySchemes"])
+	}
+	for _, requirement := range openAPIList(security) {
+		requirement := openAPIObject(requirement)
+		names := sortedKeys(requirement)
+		if len(names) == 0 {
+			// {} makes the auth optional
+			return nil
+		}
+		if len(names) > 1 {
+			d.warnOnce("%s: only the %s scheme of a combined security requirement is imported", where, names[0])
+		}
+		scheme := d.resolve(schemes[names[0]])
+		if scheme == nil {
+			d.warnOnce("%s: security scheme %s not found", where, names[0])
+			continue
+		}
+		var scopes []string
+		for _, scope := range openAPIList(requirement[names[0]]) {
+			scopes = append(scopes, openAPIString(scope))
+		}
+		if auth, ok := d.schemeAuth(names[0], scheme, scopes, params); ok {
+			return auth
+		}
+	}
+	return nil
+}
+
+// schemeAuth converts a security scheme, secrets being {{variables}}
+func (d *openAPIDoc) schemeAuth(name string, scheme map[string]interface{}, scopes []string, params *map[string]interface{}) (*AuthConfig, bool) {
+	switch schemeType := openAPIString(scheme["type"]); schemeType {
+	case "http", "basic":
+		switch strings.ToLower(openAPIString(scheme["scheme"])) {
+		case "bearer":
+			return &AuthConfig{Type: "bearer", Token: "{{token}}"}, true
+		case "digest":
+			return &AuthConfig{Type: "digest", Username: "{{username}}", Password: "{{password}}"}, true
+		case "basic", "":
+			return &AuthConfig{Type: "basic", Username: "{{username}}", Password: "{{password}}"}, true
+		}
+		d.warnOnce("security scheme %s: HTTP %s auth not supported", name, scheme["scheme"])
+	case "apiKey":
+		header := openAPIString(scheme["name"])
+		switch openAPIString(scheme["in"]) {
+		case "header":
+			return &AuthConfig{Type: "api-key", Header: header, Key: "{{apiKey}}"}, true
+		case "query":
+			if *params == nil {
+				*params = map[string]interface{}{}
+			}
+			(*params)[header] = "{{apiKey}}"
+			return nil, true
+		}
+		d.warnOnce("security scheme %s: API key sent in a cookie not supported", name)
+	case "oauth2":
+		auth := &AuthConfig{Type: "oauth2", ClientID: "{{clientId}}", ClientSecret: "{{clientSecret}}", Scopes: scopes}
+		if d.swagger {
+			auth.TokenURL, auth.AuthURL = openAPIString(scheme["tokenUrl"]), openAPIString(scheme["authorizationUrl"])
+			switch openAPIString(scheme["flow"]) {
+			case "application":
+				auth.GrantType, auth.AuthURL = "client_credentials", ""
+				return auth, true
+			case "accessCode":
+				auth.GrantType = "authorization_code"
+				return auth, true
+			}
+		} else {
+			flows := openAPIObject(scheme["flows"])
+			if flow := openAPIObject(flows["clientCredentials"]); flow != nil {
+				auth.GrantType, auth.TokenURL = "client_credentials", openAPIString(flow["tokenUrl"])
+				return auth, true
+			}
+			if flow := openAPIObject(flows["authorizationCode"]); flow != nil {
+				auth.GrantType = "authorization_code"
+				auth.TokenURL, auth.AuthURL = openAPIString(flow["tokenUrl"]), openAPIString(flow["authorizationUrl"])
+				return auth, true
+			}
+		}
+		d.warnOnce("security scheme %s: OAuth 2.0 flow not supported, a bearer {{token}} is used", name)
+		return &AuthConfig{Type: "bearer", Token: "{{token}}"}, true
+	case "openIdConnect":
+		d.warnOnce("security scheme %s: OpenID Connect discovery not supported, a bearer {{token}} is used", name)
+		return &AuthConfig{Type: "bearer", Token: "{{token}}"}, true
+	default:
+		d.warnOnce("security scheme %s: %s auth not supported", name, schemeType)
+	}
+	return nil, false
+}
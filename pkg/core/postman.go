@@ -0,0 +1,854 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+/*
+Postman
+Import and export of Postman collections (format v2.1) and environments.
+Postman folders become collection paths, collection variables become the
+variables of the root folder and environments are kept as they are.
+Scripts, saved examples and unsupported auth types are reported as
+warnings.
+*/
+
+const PostmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Auth     *postmanAuth      `json:"auth,omitempty"`
+	Event    []postmanEvent    `json:"event,omitempty"`
+	Variable []postmanVariable `json:"variable,omitempty"`
+}
+
+type postmanInfo struct {
+	PostmanID string `json:"_postman_id,omitempty"`
+	Name      string `json:"name"`
+	Schema    string `json:"schema"`
+}
+
+type postmanItem struct {
+	Name     string            `json:"name"`
+	Item     []postmanItem     `json:"item,omitempty"` // folder
+	Request  *postmanRequest   `json:"request,omitempty"`
+	Auth     *postmanAuth      `json:"auth,omitempty"` // folder auth
+	Event    []postmanEvent    `json:"event,omitempty"`
+	Variable []postmanVariable `json:"variable,omitempty"`
+	Response []json.RawMessage `json:"response,omitempty"`
+
+	ProtocolProfileBehavior map[string]interface{} `json:"protocolProfileBehavior,omitempty"`
+}
+
+type postmanRequest struct {
+	Method string       `json:"method"`
+	Header []postmanKV  `json:"header"`
+	Body   *postmanBody `json:"body,omitempty"`
+	URL    postmanURL   `json:"url"`
+	Auth   *postmanAuth `json:"auth,omitempty"`
+}
+
+// UnmarshalJSON accepts the short form of a request, a bare URL
+func (r *postmanRequest) UnmarshalJSON(data []byte) error {
+	var raw string
+	if json.Unmarshal(data, &raw) == nil {
+		*r = postmanRequest{Method: "GET", URL: postmanURL{Raw: raw}}
+		return nil
+	}
+	type plain postmanRequest
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+type postmanURL struct {
+	Raw      string      `json:"raw"`
+	Protocol string      `json:"protocol,omitempty"`
+	Host     []string    `json:"host,omitempty"`
+	Port     string      `json:"port,omitempty"`
+	Path     []string    `json:"path,omitempty"`
+	Query    []postmanKV `json:"query,omitempty"`
+	Variable []postmanKV `json:"variable,omitempty"`
+}
+
+// UnmarshalJSON accepts a URL given as a string and hosts or paths given as
+// a single string
+func (u *postmanURL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if json.Unmarshal(data, &raw) == nil {
+		*u = postmanURL{Raw: raw}
+		return nil
+	}
+	var object struct {
+		Raw      string          `json:"raw"`
+		Query    []postmanKV     `json:"query"`
+		Variable []postmanKV     `json:"variable"`
+		Host     json.RawMessage `json:"host"`
+		Path     json.RawMessage `json:"path"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*u = postmanURL{Raw: object.Raw, Query: object.Query, Variable: object.Variable}
+	u.Host = stringOrList(object.Host, ".")
+	u.Path = stringOrList(object.Path, "/")
+	return nil
+}
+
+func stringOrList(data json.RawMessage, separator string) []string {
+	var list []string
+	if json.Unmarshal(data, &list) == nil {
+		return list
+	}
+	var s string
+	if json.Unmarshal(data, &s) == nil && s != "" {
+		return strings.Split(strings.Trim(s, separator), separator)
+	}
+	// Path segments may also be objects {type, value}
+	var objects []struct {
+		Value string `json:"value"`
+	}
+	if json.Unmarshal(data, &objects) == nil {
+		for _, o := range objects {
+			list = append(list, o.Value)
+		}
+	}
+	return list
+}
+
+type postmanKV struct {
+	Key         string          `json:"key"`
+	Value       string          `json:"value"`
+	Disabled    bool            `json:"disabled,omitempty"`
+	Type        string          `json:"type,omitempty"`        // "text" or "file" for formdata
+	Src         json.RawMessage `json:"src,omitempty"`         // file path(s) for formdata
+	ContentType string          `json:"contentType,omitempty"` // formdata
+}
+
+type postmanBody struct {
+	Mode       string          `json:"mode"`
+	Raw        string          `json:"raw,omitempty"`
+	Urlencoded []postmanKV     `json:"urlencoded,omitempty"`
+	Formdata   []postmanKV     `json:"formdata,omitempty"`
+	File       json.RawMessage `json:"file,omitempty"`
+	GraphQL    *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql,omitempty"`
+	Options  *postmanBodyOptions `json:"options,omitempty"`
+	Disabled bool                `json:"disabled,omitempty"`
+}
+
+type postmanBodyOptions struct {
+	Raw struct {
+		Language string `json:"language"`
+	} `json:"raw"`
+}
+
+type postmanAuth struct {
+	Type   string             `json:"type"`
+	Bearer []postmanAuthParam `json:"bearer,omitempty"`
+	Basic  []postmanAuthParam `json:"basic,omitempty"`
+	Digest []postmanAuthParam `json:"digest,omitempty"`
+	APIKey []postmanAuthParam `json:"apikey,omitempty"`
+	OAuth2 []postmanAuthParam `json:"oauth2,omitempty"`
+	AWSv4  []postmanAuthParam `json:"awsv4,omitempty"`
+}
+
+type postmanAuthParam struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+	Type  string      `json:"type"`
+}
+
+type postmanEvent struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec []string `json:"exec"`
+	} `json:"script"`
+}
+
+type postmanVariable struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Type     string      `json:"type,omitempty"`
+	Disabled bool        `json:"disabled,omitempty"`
+}
+
+type postmanEnvironment struct {
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name"`
+	Values []struct {
+		Key     string      `json:"key"`
+		Value   interface{} `json:"value"`
+		Type    string      `json:"type,omitempty"`
+		Enabled *bool       `json:"enabled,omitempty"`
+	} `json:"values"`
+	Scope string `json:"_postman_variable_scope,omitempty"`
+}
+
+/*
+ImportPostman reads a Postman collection v2.1 or a Postman environment.
+The requests of a collection are placed under collection, by default the
+collection name.
+*/
+func ImportPostman(data []byte, collection string) (Import, error) {
+	var probe struct {
+		Info   *postmanInfo    `json:"info"`
+		Values json.RawMessage `json:"values"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return Import{}, fmt.Errorf("invalid Postman file: %w", err)
+	}
+	switch {
+	case probe.Info != nil:
+		if !strings.Contains(probe.Info.Schema, "v2.1") {
+			return Import{}, fmt.Errorf("unsupported Postman schema %q, export the collection as Collection v2.1", probe.Info.Schema)
+		}
+		var c postmanCollection
+		if err := json.Unmarshal(data, &c); err != nil {
+			return Import{}, fmt.Errorf("invalid Postman collection: %w", err)
+		}
+		return importPostmanCollection(c, collection), nil
+	case probe.Values != nil:
+		var env postmanEnvironment
+		if err := json.Unmarshal(data, &env); err != nil {
+			return Import{}, fmt.Errorf("invalid Postman environment: %w", err)
+		}
+		return importPostmanEnvironment(env), nil
+	}
+	return Import{}, fmt.Errorf("not a Postman collection or environment")
+}
+
+func importPostmanEnvironment(env postmanEnvironment) Import {
+	name := env.Name
+	if name == "" {
+		name = env.Scope
+	}
+	vars := map[string]string{}
+	for _, v := range env.Values {
+		if v.Enabled == nil || *v.Enabled {
+			vars[v.Key] = postmanString(v.Value)
+		}
+	}
+	return Import{Environments: map[string]Environment{name: {Variables: vars}}}
+}
+
+func importPostmanCollection(c postmanCollection, collection string) Import {
+	imp := Import{Folders: map[string]Folder{}}
+	root := CleanCollection(collection)
+	if root == "" {
+		root = CleanCollection(strings.ReplaceAll(c.Info.Name, "/", "-"))
+	}
+
+	folder := Folder{}
+	if len(c.Variable) > 0 {
+		folder.Variables = map[string]string{}
+		for _, v := range c.Variable {
+			if !v.Disabled {
+				folder.Variables[v.Key] = postmanString(v.Value)
+			}
+		}
+	}
+	inherited := false
+	if c.Auth != nil {
+		folder.Auth, _ = imp.postmanAuth(c.Auth, c.Info.Name, nil)
+		inherited = folder.Auth != nil
+	}
+	imp.postmanEvents(c.Event, c.Info.Name)
+	if root != "" && (folder.Auth != nil || folder.Variables != nil) {
+		imp.Folders[root] = folder
+	}
+	imp.postmanItems(c.Item, root, inherited)
+	return imp
+}
+
+func (imp *Import) postmanItems(items []postmanItem, path string, inherited bool) {
+	for _, item := range items {
+		where := strings.TrimPrefix(path+"/"+item.Name, "/")
+		imp.postmanEvents(item.Event, where)
+		if item.Request == nil {
+			// Folder
+			sub := CleanCollection(path + "/" + strings.ReplaceAll(item.Name, "/", "-"))
+			folderInherited := inherited
+			if item.Auth != nil {
+				auth, none := imp.postmanAuth(item.Auth, where, nil)
+				if auth != nil {
+					imp.Folders[sub] = Folder{Auth: auth}
+					folderInherited = true
+				} else if none && inherited {
+					imp.warnf("%s: \"no auth\" can't override the auth of the parent folder", where)
+				}
+			}
+			for _, v := range item.Variable {
+				f := imp.Folders[sub]
+				if f.Variables == nil {
+					f.Variables = map[string]string{}
+				}
+				f.Variables[v.Key] = postmanString(v.Value)
+				imp.Folders[sub] = f
+			}
+			imp.postmanItems(item.Item, sub, folderInherited)
+			continue
+		}
+		r := imp.postmanRequest(item, where)
+		r.Collection = path
+		if r.Auth == nil && item.Request.Auth != nil && item.Request.Auth.Type == "noauth" && inherited {
+			imp.warnf("%s: \"no auth\" can't override the auth of the parent folder", where)
+		}
+		r.Name = imp.uniqueName(item.Name)
+		imp.Requests = append(imp.Requests, r)
+	}
+}
+
+func (imp *Import) postmanEvents(events []postmanEvent, where string) {
+	for _, event := range events {
+		if strings.TrimSpace(strings.Join(event.Script.Exec, "")) == "" {
+			continue
+		}
+		kind := "test script"
+		if event.Listen == "prerequest" {
+			kind = "pre-request script"
+		}
+		imp.warnf("%s: %s not imported", where, kind)
+	}
+}
+
+func (imp *Import) postmanRequest(item postmanItem, where string) Request {
+	pr := item.Request
+	r := Request{
+		Method:  strings.ToUpper(pr.Method),
+		Headers: map[string]interface{}{},
+	}
+	if r.Method == "" {
+		r.Method = "GET"
+	}
+	r.URL = postmanRawURL(pr.URL)
+	if len(item.Response) > 0 {
+		imp.warnf("%s: %d saved example response(s) not imported", where, len(item.Response))
+	}
+	if item.ProtocolProfileBehavior != nil {
+		if strict, ok := item.ProtocolProfileBehavior["strictSSL"].(bool); ok && !strict {
+			r.Insecure = true
+		}
+		if disabled, ok := item.ProtocolProfileBehavior["disableTLSVerification"].(bool); ok && disabled {
+			r.Insecure = true
+		}
+	}
+
+	for _, h := range pr.Header {
+		if !h.Disabled && h.Key != "" {
+			r.Headers[h.Key] = h.Value
+		}
+	}
+	if pr.Auth != nil {
+		r.Auth, _ = imp.postmanAuth(pr.Auth, where, &r)
+	}
+	if r.Auth == nil {
+		liftBearerHeader(&r)
+	}
+	if pr.Body != nil && !pr.Body.Disabled {
+		imp.postmanBody(pr.Body, &r, where)
+	}
+	return r
+}
+
+// postmanRawURL rebuilds the URL, replacing the :name path variables by their
+// value or by a {{name}} reference
+func postmanRawURL(u postmanURL) string {
+	raw := u.Raw
+	if raw == "" && len(u.Host) > 0 {
+		raw = strings.Join(u.Host, ".")
+		if u.Protocol != "" {
+			raw = u.Protocol + "://" + raw
+		}
+		if u.Port != "" {
+			raw += ":" + u.Port
+		}
+		if len(u.Path) > 0 {
+			raw += "/" + strings.Join(u.Path, "/")
+		}
+		var query []string
+		for _, q := range u.Query {
+			if !q.Disabled {
+				query = append(query, q.Key+"="+q.Value)
+			}
+		}
+		if len(query) > 0 {
+			raw += "?" + strings.Join(query, "&")
+		}
+	}
+	for _, v := range u.Variable {
+		value := v.Value
+		if value == "" {
+			value = "{{" + v.Key + "}}"
+		}
+		raw = replacePathVariable(raw, ":"+v.Key, value)
+	}
+	return raw
+}
+
+func replacePathVariable(raw, placeholder, value string) string {
+	path, query, hasQuery := strings.Cut(raw, "?")
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment == placeholder {
+			segments[i] = value
+		}
+	}
+	path = strings.Join(segments, "/")
+	if hasQuery {
+		return path + "?" + query
+	}
+	return path
+}
+
+func (imp *Import) postmanBody(body *postmanBody, r *Request, where string) {
+	hasContentType := false
+	for name := range r.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			hasContentType = true
+		}
+	}
+	switch body.Mode {
+	case "raw":
+		if body.Raw == "" {
+			return
+		}
+		language := ""
+		if body.Options != nil {
+			language = body.Options.Raw.Language
+		}
+		if !hasContentType {
+			// Postman adds it from the language of the body
+			switch language {
+			case "json":
+				r.Headers["Content-Type"] = "application/json"
+			case "xml":
+				r.Headers["Content-Type"] = "application/xml"
+			case "html":
+				r.Headers["Content-Type"] = "text/html"
+			case "javascript":
+				r.Headers["Content-Type"] = "application/javascript"
+			default:
+				r.Headers["Content-Type"] = "text/plain"
+			}
+		}
+		var payload map[string]interface{}
+		jsonMethod := r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH"
+		if jsonMethod && json.Unmarshal([]byte(body.Raw), &payload) == nil {
+			r.Payload = payload
+			return
+		}
+		r.BodyType, r.Body = "raw", body.Raw
+	case "urlencoded":
+		r.BodyType = "form"
+		for _, field := range body.Urlencoded {
+			if !field.Disabled {
+				r.Form = append(r.Form, FormField{Name: field.Key, Value: field.Value})
+			}
+		}
+	case "formdata":
+		r.BodyType = "multipart"
+		for _, field := range body.Formdata {
+			if field.Disabled {
+				continue
+			}
+			f := FormField{Name: field.Key, ContentType: field.ContentType}
+			if field.Type == "file" {
+				files := stringOrList(field.Src, "\x00")
+				if len(files) > 1 {
+					imp.warnf("%s: only the first file of the form field %s is imported", where, field.Key)
+				}
+				if len(files) > 0 {
+					f.File = files[0]
+				} else {
+					imp.warnf("%s: form field %s has no file", where, field.Key)
+				}
+			} else {
+				f.Value = field.Value
+			}
+			r.Form = append(r.Form, f)
+		}
+	case "graphql":
+		if body.GraphQL == nil {
+			return
+		}
+		r.Payload = map[string]interface{}{"query": body.GraphQL.Query}
+		if strings.TrimSpace(body.GraphQL.Variables) != "" {
+			var variables interface{}
+			if json.Unmarshal([]byte(body.GraphQL.Variables), &variables) == nil {
+				r.Payload["variables"] = variables
+			} else {
+				imp.warnf("%s: invalid GraphQL variables not imported", where)
+			}
+		}
+		if !hasContentType {
+			r.Headers["Content-Type"] = "application/json"
+		}
+	case "file":
+		imp.warnf("%s: binary file body not imported", where)
+	case "":
+	default:
+		imp.warnf("%s: %s body not imported", where, body.Mode)
+	}
+}
+
+// postmanAuth converts a Postman auth. none reports an explicit "no auth".
+// An API key sent in the query is added to the params of r when given.
+func (imp *Import) postmanAuth(a *postmanAuth, where string, r *Request) (auth *AuthConfig, none bool) {
+	get := func(params []postmanAuthParam, key string) string {
+		for _, p := range params {
+			if p.Key == key {
+				return postmanString(p.Value)
+			}
+		}
+		return ""
+	}
+	switch a.Type {
+	case "noauth":
+		return nil, true
+	case "bearer":
+		return &AuthConfig{Type: "bearer", Token: get(a.Bearer, "token")}, false
+	case "basic":
+		return &AuthConfig{Type: "basic", Username: get(a.Basic, "username"), Password: get(a.Basic, "password")}, false
+	case "digest":
+		return &AuthConfig{Type: "digest", Username: get(a.Digest, "username"), Password: get(a.Digest, "password")}, false
+	case "apikey":
+		key, value := get(a.APIKey, "key"), get(a.APIKey, "value")
+		if get(a.APIKey, "in") == "query" {
+			if r == nil {
+				imp.warnf("%s: API key sent in the query can only be imported on requests", where)
+				return nil, false
+			}
+			if r.Params == nil {
+				r.Params = map[string]interface{}{}
+			}
+			r.Params[key] = value
+			return nil, false
+		}
+		return &AuthConfig{Type: "api-key", Header: key, Key: value}, false
+	case "awsv4":
+		return &AuthConfig{
+			Type:         "aws-sigv4",
+			AccessKey:    get(a.AWSv4, "accessKey"),
+			SecretKey:    get(a.AWSv4, "secretKey"),
+			SessionToken: get(a.AWSv4, "sessionToken"),
+			Region:       get(a.AWSv4, "region"),
+			Service:      get(a.AWSv4, "service"),
+		}, false
+	case "oauth2":
+		auth := &AuthConfig{
+			Type:         "oauth2",
+			TokenURL:     get(a.OAuth2, "accessTokenUrl"),
+			ClientID:     get(a.OAuth2, "clientId"),
+			ClientSecret: get(a.OAuth2, "clientSecret"),
+			Scopes:       strings.Fields(get(a.OAuth2, "scope")),
+			AuthURL:      get(a.OAuth2, "authUrl"),
+			RedirectURL:  get(a.OAuth2, "redirect_uri"),
+			RefreshToken: get(a.OAuth2, "refreshToken"),
+			AccessToken:  get(a.OAuth2, "accessToken"),
+		}
+		switch grant := get(a.OAuth2, "grant_type"); grant {
+		case "", "client_credentials":
+			auth.GrantType = "client_credentials"
+		case "authorization_code", "authorization_code_with_pkce":
+			auth.GrantType = "authorization_code"
+		default:
+			if auth.AccessToken == "" {
+				imp.warnf("%s: OAuth 2.0 grant %s not supported, auth not imported", where, grant)
+				return nil, false
+			}
+			imp.warnf("%s: OAuth 2.0 grant %s not supported, its access token is used as a bearer token", where, grant)
+			return &AuthConfig{Type: "bearer", Token: auth.AccessToken}, false
+		}
+		if auth.TokenURL == "" && auth.AccessToken != "" {
+			return &AuthConfig{Type: "bearer", Token: auth.AccessToken}, false
+		}
+		return auth, false
+	case "inherit", "":
+		return nil, false
+	}
+	imp.warnf("%s: %s auth not supported, not imported", where, a.Type)
+	return nil, false
+}
+
+func postmanString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	}
+	buffer, _ := json.Marshal(v)
+	return string(buffer)
+}
+
+/*
+ExportPostman writes the requests of a collection, every request for "", as
+a Postman collection v2.1. Folder headers and base URLs are applied to each
+request and auth profiles are inlined, Postman having neither. The returned
+warnings list what could not be exported.
+*/
+func (db *Database) ExportPostman(collection string) ([]byte, []string, error) {
+	collection = CleanCollection(collection)
+	name := "http-tanker"
+	if collection != "" {
+		name = collection[strings.LastIndex(collection, "/")+1:]
+	}
+	c := postmanCollection{Info: postmanInfo{Name: name, Schema: PostmanSchema}}
+	var warnings []string
+	warnf := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	exportAuth := func(auth *AuthConfig, profile, where string) *postmanAuth {
+		if profile != "" {
+			p, ok := db.Profiles[profile]
+			if !ok {
+				warnf("%s: auth profile %s not found", where, profile)
+				return nil
+			}
+			auth = &p
+		}
+		if auth == nil {
+			return nil
+		}
+		a, ok := postmanAuthFrom(*auth)
+		if !ok {
+			warnf("%s: %s auth has no Postman equivalent, not exported", where, auth.Type)
+		}
+		return a
+	}
+
+	variables := db.collectionVariables(collection, warnf)
+	if root, ok := db.Folders[collection]; ok && collection != "" {
+		c.Auth = exportAuth(root.Auth, root.AuthProfile, collection)
+	}
+	for _, k := range sortedKeys(variables) {
+		c.Variable = append(c.Variable, postmanVariable{Key: k, Value: variables[k], Type: "string"})
+	}
+
+	paths := []string{}
+	for _, path := range db.Collections() {
+		if path != collection && InCollection(path, collection) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	names := make([]string, 0, len(db.Data))
+	for n, r := range db.Data {
+		if InCollection(r.Collection, collection) {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	tree := map[string][]string{} // folder path -> request names
+	for _, n := range names {
+		path := CleanCollection(db.Data[n].Collection)
+		tree[path] = append(tree[path], n)
+	}
+	var build func(path string) []postmanItem
+	build = func(path string) []postmanItem {
+		items := []postmanItem{}
+		for _, sub := range paths {
+			if parent := sub[:max(strings.LastIndex(sub, "/"), 0)]; parent == path {
+				item := postmanItem{Name: sub[strings.LastIndex(sub, "/")+1:], Item: build(sub)}
+				if f, ok := db.Folders[sub]; ok {
+					item.Auth = exportAuth(f.Auth, f.AuthProfile, sub)
+				}
+				items = append(items, item)
+			}
+		}
+		for _, n := range tree[path] {
+			items = append(items, db.postmanItem(db.Data[n], exportAuth, warnf))
+		}
+		return items
+	}
+	c.Item = build(collection)
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c); err != nil {
+		return nil, warnings, err
+	}
+	return buffer.Bytes(), warnings, nil
+}
+
+// collectionVariables merges the variables of every folder of a collection,
+// the deepest value winning, for formats with a single set of variables
+func (db *Database) collectionVariables(collection string, warnf func(string, ...interface{})) map[string]string {
+	variables := map[string]string{}
+	for _, path := range db.Collections() {
+		if !InCollection(path, collection) {
+			continue
+		}
+		for k, v := range db.Folders[path].Variables {
+			if previous, ok := variables[k]; ok && previous != v {
+				warnf("variable %s has different values in several folders, %q is exported", k, v)
+			}
+			variables[k] = v
+		}
+	}
+	return variables
+}
+
+func (db *Database) postmanItem(r Request, exportAuth func(*AuthConfig, string, string) *postmanAuth, warnf func(string, ...interface{})) postmanItem {
+	// Folder headers and base URL, the auth stays inherited
+	resolved := db.applyFolders(Request{URL: r.URL, Headers: r.Headers, Collection: r.Collection, Auth: &AuthConfig{}})
+	pr := &postmanRequest{Method: r.Method, Header: []postmanKV{}}
+	for _, k := range sortedKeys(resolved.Headers) {
+		pr.Header = append(pr.Header, postmanKV{Key: k, Value: fmt.Sprint(resolved.Headers[k])})
+	}
+
+	raw := resolved.URL
+	if len(r.Params) > 0 {
+		var query []string
+		for _, k := range sortedKeys(r.Params) {
+			query = append(query, url.QueryEscape(k)+"="+url.QueryEscape(fmt.Sprint(r.Params[k])))
+		}
+		separator := "?"
+		if strings.Contains(raw, "?") {
+			separator = "&"
+		}
+		raw += separator + strings.Join(query, "&")
+	}
+	pr.URL = postmanURL{Raw: raw}
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		pr.URL.Protocol = u.Scheme
+		pr.URL.Host = strings.Split(u.Hostname(), ".")
+		pr.URL.Port = u.Port()
+		pr.URL.Path = strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+		for _, pair := range strings.Split(u.RawQuery, "&") {
+			if pair == "" {
+				continue
+			}
+			k, v, _ := strings.Cut(pair, "=")
+			k, _ = url.QueryUnescape(k)
+			v, _ = url.QueryUnescape(v)
+			pr.URL.Query = append(pr.URL.Query, postmanKV{Key: k, Value: v})
+		}
+	}
+
+	if r.Auth != nil || r.AuthProfile != "" {
+		pr.Auth = exportAuth(r.Auth, r.AuthProfile, r.Name)
+	}
+
+	switch r.BodyType {
+	case "raw":
+		pr.Body = &postmanBody{Mode: "raw", Raw: r.Body}
+	case "form":
+		pr.Body = &postmanBody{Mode: "urlencoded"}
+		for _, f := range r.Form {
+			pr.Body.Urlencoded = append(pr.Body.Urlencoded, postmanKV{Key: f.Name, Value: f.Value})
+		}
+	case "multipart":
+		pr.Body = &postmanBody{Mode: "formdata"}
+		for _, f := range r.Form {
+			field := postmanKV{Key: f.Name, Value: f.Value, Type: "text", ContentType: f.ContentType}
+			if f.File != "" {
+				src, _ := json.Marshal(f.File)
+				field.Type, field.Value, field.Src = "file", "", src
+			}
+			pr.Body.Formdata = append(pr.Body.Formdata, field)
+		}
+	default:
+		if r.Payload != nil {
+			buffer, _ := json.MarshalIndent(r.Payload, "", "  ")
+			pr.Body = &postmanBody{Mode: "raw", Raw: string(buffer), Options: &postmanBodyOptions{}}
+			pr.Body.Options.Raw.Language = "json"
+		}
+	}
+
+	item := postmanItem{Name: r.Name, Request: pr}
+	if r.Insecure {
+		item.ProtocolProfileBehavior = map[string]interface{}{"strictSSL": false}
+	}
+	if len(r.Tags) > 0 {
+		warnf("%s: tags not exported", r.Name)
+	}
+	return item
+}
+
+// postmanAuthFrom converts an auth, reporting false when Postman has no equivalent
+func postmanAuthFrom(auth AuthConfig) (*postmanAuth, bool) {
+	param := func(key, value string) postmanAuthParam {
+		return postmanAuthParam{Key: key, Value: value, Type: "string"}
+	}
+	switch auth.Type {
+	case "bearer":
+		return &postmanAuth{Type: "bearer", Bearer: []postmanAuthParam{param("token", auth.Token)}}, true
+	case "basic":
+		return &postmanAuth{Type: "basic", Basic: []postmanAuthParam{param("username", auth.Username), param("password", auth.Password)}}, true
+	case "digest":
+		return &postmanAuth{Type: "digest", Digest: []postmanAuthParam{param("username", auth.Username), param("password", auth.Password)}}, true
+	case "api-key":
+		header := auth.Header
+		if header == "" {
+			header = "X-API-Key"
+		}
+		return &postmanAuth{Type: "apikey", APIKey: []postmanAuthParam{param("key", header), param("value", auth.Key), param("in", "header")}}, true
+	case "aws-sigv4":
+		return &postmanAuth{Type: "awsv4", AWSv4: []postmanAuthParam{
+			param("accessKey", auth.AccessKey), param("secretKey", auth.SecretKey), param("sessionToken", auth.SessionToken),
+			param("region", auth.Region), param("service", auth.Service),
+		}}, true
+	case "oauth2":
+		grant := auth.GrantType
+		if grant == "" {
+			grant = "client_credentials"
+		}
+		params := []postmanAuthParam{
+			param("grant_type", grant), param("accessTokenUrl", auth.TokenURL),
+			param("clientId", auth.ClientID), param("clientSecret", auth.ClientSecret),
+			param("scope", strings.Join(auth.Scopes, " ")),
+		}
+		if auth.AuthURL != "" {
+			params = append(params, param("authUrl", auth.AuthURL), param("redirect_uri", auth.RedirectURL))
+		}
+		return &postmanAuth{Type: "oauth2", OAuth2: params}, true
+	}
+	return nil, false
+}
+
+/*
+ExportPostmanEnvironment writes an environment in the Postman format
+*/
+func (db *Database) ExportPostmanEnvironment(name string) ([]byte, error) {
+	env, ok := db.Environments[name]
+	if !ok {
+		return nil, fmt.Errorf("environment %q not found", name)
+	}
+	type value struct {
+		Key     string `json:"key"`
+		Value   string `json:"value"`
+		Type    string `json:"type"`
+		Enabled bool   `json:"enabled"`
+	}
+	out := struct {
+		Name   string  `json:"name"`
+		Values []value `json:"values"`
+		Scope  string  `json:"_postman_variable_scope"`
+	}{Name: name, Values: []value{}, Scope: "environment"}
+	for _, k := range sortedKeys(env.Variables) {
+		out.Values = append(out.Values, value{Key: k, Value: env.Variables[k], Type: "default", Enabled: true})
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
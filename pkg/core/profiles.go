@@ -0,0 +1,108 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+Auth profiles
+Named AuthConfig stored with the requests, that requests reference by name
+through Request.AuthProfile instead of embedding their own secrets
+*/
+
+/*
+ProfileNames returns the auth profile names sorted alphabetically
+*/
+func (db *Database) ProfileNames() []string {
+	names := make([]string, 0, len(db.Profiles))
+	for name := range db.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+ProfileUsers returns the requests and folders (suffixed with "/")
+referencing an auth profile
+*/
+func (db *Database) ProfileUsers(profile string) []string {
+	var users []string
+	for name, r := range db.Data {
+		if r.AuthProfile == profile {
+			users = append(users, name)
+		}
+	}
+	for path, f := range db.Folders {
+		if f.AuthProfile == profile {
+			users = append(users, path+"/")
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+/*
+SaveProfile creates or replaces an auth profile
+*/
+func (db *Database) SaveProfile(name string, auth AuthConfig) error {
+	if name == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.Profiles == nil {
+		db.Profiles = map[string]AuthConfig{}
+	}
+	db.Profiles[name] = auth
+	return db.saveLocked()
+}
+
+/*
+DeleteProfile removes an auth profile that no request references anymore
+*/
+func (db *Database) DeleteProfile(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.Profiles[name]; !ok {
+		return fmt.Errorf("auth profile %q not found", name)
+	}
+	if users := db.ProfileUsers(name); len(users) > 0 {
+		return fmt.Errorf("auth profile %q is used by %v", name, strings.Join(users, ", "))
+	}
+	delete(db.Profiles, name)
+	return db.saveLocked()
+}
+
+/*
+Resolve returns a saved request ready to be executed, with its auth
+profile and variables applied
+*/
+func (db *Database) Resolve(name string) (Request, error) {
+	r, ok := db.Data[name]
+	if !ok {
+		return Request{}, fmt.Errorf("request %q not found", name)
+	}
+	return db.ResolveRequest(r)
+}
+
+/*
+ResolveRequest applies the folder defaults, the auth profile and the
+variables of a request
+*/
+func (db *Database) ResolveRequest(r Request) (Request, error) {
+	r = db.applyFolders(r)
+	if r.AuthProfile != "" {
+		auth, ok := db.Profiles[r.AuthProfile]
+		if !ok {
+			return Request{}, fmt.Errorf("auth profile %q not found", r.AuthProfile)
+		}
+		r.Auth = &auth
+	} else if r.Auth != nil {
+		auth := *r.Auth
+		r.Auth = &auth
+	}
+	return applyVariables(r, db.Variables(r)), nil
+}
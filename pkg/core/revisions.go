@@ -0,0 +1,524 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Revisions
+Every save records a revision of the requests it changed, in
+<DatabaseDir>/revisions/<request>/. A deleted request keeps its log: its
+last revision holds the deleted content, which makes the trash.
+*/
+
+// Revisions kept per request
+const DefaultRevisionLimit = 50
+
+const (
+	RevisionCreate   = "create"
+	RevisionUpdate   = "update"
+	RevisionDelete   = "delete"
+	RevisionRename   = "rename"
+	RevisionRestore  = "restore"
+	RevisionRollback = "rollback"
+)
+
+/*
+Revision
+State of a request after a change, or the deleted content for a deletion
+*/
+type Revision struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Name    string    `json:"name"`
+	Action  string    `json:"action"`
+	From    string    `json:"from,omitempty"` // previous name of a renamed request, revision of a rollback
+	Request Request   `json:"request"`
+}
+
+type revisionNote struct {
+	action string
+	from   string
+}
+
+/*
+Summary one line description of the revision
+*/
+func (r Revision) Summary() string {
+	summary := r.Time.Local().Format("2006-01-02 15:04:05") + " " + r.Action
+	if r.From != "" {
+		summary += " (from " + r.From + ")"
+	}
+	return summary + " [" + r.Request.Method + "] " + r.Request.URL
+}
+
+func (db *Database) revisionDir(name string) string {
+	return filepath.Join(db.DatabaseDir, "revisions", requestFileName(name))
+}
+
+func (db *Database) revisionLimit() int {
+	if db.RevisionLimit > 0 {
+		return db.RevisionLimit
+	}
+	return DefaultRevisionLimit
+}
+
+// revisionContent is the part of a request tracked by the revisions
+func revisionContent(r Request) string {
+	r.LastUsed = nil
+	buffer, _ := json.Marshal(r)
+	return string(buffer)
+}
+
+/*
+localChanges returns the requests added, changed or deleted since the
+last load or save, with their previous content. Running a request only
+updates LastUsed, which is not a change.
+*/
+func (db *Database) localChanges() map[string]*Request {
+	changes := map[string]*Request{}
+	if db.base == nil {
+		return changes
+	}
+	previous := map[string]*Request{}
+	for name, encoded := range db.base["data"] {
+		var r Request
+		if json.Unmarshal([]byte(encoded), &r) == nil {
+			previous[name] = &r
+		}
+	}
+	for name, r := range db.Data {
+		if p, ok := previous[name]; !ok || revisionContent(*p) != revisionContent(r) {
+			changes[name] = p
+		}
+	}
+	for name, p := range previous {
+		if _, ok := db.Data[name]; !ok {
+			changes[name] = p
+		}
+	}
+	return changes
+}
+
+/*
+recordRevisions logs the changes once saved. It is best effort: the save
+succeeded, a failure only loses revisions.
+*/
+func (db *Database) recordRevisions(changes map[string]*Request) {
+	notes := db.notes
+	db.notes = nil
+	// The log follows a renamed request
+	for name, note := range notes {
+		if _, moved := changes[note.from]; note.action == RevisionRename && moved {
+			db.moveRevisions(note.from, name)
+			delete(changes, note.from)
+		}
+	}
+	for name, previous := range changes {
+		note := notes[name]
+		r, exists := db.Data[name]
+		switch {
+		case !exists && previous != nil:
+			r, note.action = *previous, RevisionDelete
+		case !exists:
+			continue
+		case note.action != "":
+		case previous == nil:
+			note.action = RevisionCreate
+		default:
+			note.action = RevisionUpdate
+		}
+		r.LastUsed = nil
+		db.writeRevision(Revision{Name: name, Action: note.action, From: note.from, Request: r})
+	}
+}
+
+func (db *Database) moveRevisions(from, to string) error {
+	revisions, err := db.Revisions(from)
+	if err != nil {
+		return err
+	}
+	dir := db.revisionDir(to)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	for _, rev := range revisions {
+		rev.Name = to
+		buffer, err := json.Marshal(rev)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, rev.ID+".json"), buffer, 0600); err != nil {
+			return err
+		}
+	}
+	if db.revisionDir(from) == dir {
+		return nil
+	}
+	return db.purgeRevisions(from)
+}
+
+func (db *Database) writeRevision(rev Revision) error {
+	dir := db.revisionDir(rev.Name)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	now := time.Now()
+	rev.Time = now.UTC()
+	ns := now.UnixNano()
+	for {
+		rev.ID = strconv.FormatInt(ns, 10)
+		if _, err := os.Stat(filepath.Join(dir, rev.ID+".json")); os.IsNotExist(err) {
+			break
+		}
+		ns++
+	}
+	buffer, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, rev.ID+".json"), buffer, 0600); err != nil {
+		return err
+	}
+	return db.pruneRevisions(rev.Name)
+}
+
+func (db *Database) pruneRevisions(name string) error {
+	revisions, err := db.Revisions(name)
+	if err != nil {
+		return err
+	}
+	for i := db.revisionLimit(); i < len(revisions); i++ {
+		if err := os.Remove(filepath.Join(db.revisionDir(name), revisions[i].ID+".json")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+Revisions returns the revisions of a request, most recent first
+*/
+func (db *Database) Revisions(name string) ([]Revision, error) {
+	dir := db.revisionDir(name)
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var revisions []Revision
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		buffer, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		var rev Revision
+		// Different names may share a sanitized directory
+		if json.Unmarshal(buffer, &rev) == nil && rev.Name == name {
+			revisions = append(revisions, rev)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		a, _ := strconv.ParseInt(revisions[i].ID, 10, 64)
+		b, _ := strconv.ParseInt(revisions[j].ID, 10, 64)
+		return a > b
+	})
+	return revisions, nil
+}
+
+/*
+GetRevision loads a single revision of a request
+*/
+func (db *Database) GetRevision(name, id string) (Revision, error) {
+	revisions, err := db.Revisions(name)
+	if err != nil {
+		return Revision{}, err
+	}
+	for _, rev := range revisions {
+		if rev.ID == id {
+			return rev, nil
+		}
+	}
+	return Revision{}, fmt.Errorf("revision %q of request %q not found", id, name)
+}
+
+/*
+DiffRevisions compares two revisions of a request, an empty id standing
+for the current version
+*/
+func (db *Database) DiffRevisions(name, fromID, toID string) (string, error) {
+	side := func(id string) (*Request, error) {
+		if id == "" {
+			if r, ok := db.Data[name]; ok {
+				return &r, nil
+			}
+			return nil, nil
+		}
+		rev, err := db.GetRevision(name, id)
+		if err != nil {
+			return nil, err
+		}
+		if rev.Action == RevisionDelete {
+			return nil, nil
+		}
+		return &rev.Request, nil
+	}
+	from, err := side(fromID)
+	if err != nil {
+		return "", err
+	}
+	to, err := side(toID)
+	if err != nil {
+		return "", err
+	}
+	return DiffRequests(from, to), nil
+}
+
+/*
+DiffRequests line diff of the JSON of two requests, nil meaning absent
+*/
+func DiffRequests(from, to *Request) string {
+	text := func(r *Request) []string {
+		if r == nil {
+			return nil
+		}
+		copy := *r
+		copy.LastUsed = nil
+		buffer, _ := json.MarshalIndent(copy, "", "    ")
+		return strings.Split(string(buffer), "\n")
+	}
+	return diffLines(text(from), text(to))
+}
+
+// diffLines prefixes the lines with "- ", "+ " or "  " following their
+// longest common subsequence
+func diffLines(a, b []string) string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return out.String()
+}
+
+func (db *Database) note(name string, note revisionNote) {
+	if db.notes == nil {
+		db.notes = map[string]revisionNote{}
+	}
+	db.notes[name] = note
+}
+
+/*
+Rollback restores a request as it was at a revision
+*/
+func (db *Database) Rollback(name, id string) error {
+	rev, err := db.GetRevision(name, id)
+	if err != nil {
+		return err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, exists := db.Data[name]; !exists && rev.Action == RevisionDelete {
+		return db.restoreLocked(rev)
+	}
+	r := rev.Request
+	r.Name = name
+	if current, ok := db.Data[name]; ok {
+		r.LastUsed = current.LastUsed
+	}
+	db.Data[name] = r
+	db.note(name, revisionNote{action: RevisionRollback, from: id})
+	return db.saveLocked()
+}
+
+/*
+Undo reverts the last change of a request: restores it when it was deleted,
+otherwise rolls back to its previous revision
+*/
+func (db *Database) Undo(name string) error {
+	revisions, err := db.Revisions(name)
+	if err != nil {
+		return err
+	}
+	if len(revisions) > 0 && revisions[0].Action == RevisionDelete {
+		return db.Restore(name)
+	}
+	if len(revisions) < 2 {
+		return fmt.Errorf("nothing to undo for request %q", name)
+	}
+	return db.Rollback(name, revisions[1].ID)
+}
+
+/*
+Trash returns the last revision of the deleted requests, most recent first
+*/
+func (db *Database) Trash() ([]Revision, error) {
+	dirs, err := os.ReadDir(filepath.Join(db.DatabaseDir, "revisions"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, dir := range dirs {
+		files, _ := os.ReadDir(filepath.Join(db.DatabaseDir, "revisions", dir.Name()))
+		for _, f := range files {
+			var rev Revision
+			if _, err := readJSONFile(filepath.Join(db.DatabaseDir, "revisions", dir.Name(), f.Name()), &rev); err == nil && rev.Name != "" {
+				names[rev.Name] = true
+			}
+		}
+	}
+	var trash []Revision
+	for name := range names {
+		if _, exists := db.Data[name]; exists {
+			continue
+		}
+		revisions, err := db.Revisions(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(revisions) > 0 && revisions[0].Action == RevisionDelete {
+			trash = append(trash, revisions[0])
+		}
+	}
+	sort.Slice(trash, func(i, j int) bool {
+		return trash[i].Time.After(trash[j].Time)
+	})
+	return trash, nil
+}
+
+/*
+Restore brings a deleted request back from the trash
+*/
+func (db *Database) Restore(name string) error {
+	revisions, err := db.Revisions(name)
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 || revisions[0].Action != RevisionDelete {
+		return fmt.Errorf("request %q is not in the trash", name)
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.restoreLocked(revisions[0])
+}
+
+func (db *Database) restoreLocked(rev Revision) error {
+	if _, exists := db.Data[rev.Name]; exists {
+		return fmt.Errorf("a request named %q already exists, rename it first", rev.Name)
+	}
+	db.Data[rev.Name] = rev.Request
+	db.note(rev.Name, revisionNote{action: RevisionRestore})
+	return db.saveLocked()
+}
+
+/*
+EmptyTrash permanently deletes the requests in the trash and their revisions
+*/
+func (db *Database) EmptyTrash() error {
+	trash, err := db.Trash()
+	if err != nil {
+		return err
+	}
+	for _, rev := range trash {
+		if err := db.purgeRevisions(rev.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *Database) purgeRevisions(name string) error {
+	revisions, err := db.Revisions(name)
+	if err != nil {
+		return err
+	}
+	dir := db.revisionDir(name)
+	for _, rev := range revisions {
+		if err := os.Remove(filepath.Join(dir, rev.ID+".json")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	os.Remove(dir)
+	return nil
+}
+
+/*
+Update replaces the request saved as oldName by r, renaming it when r.Name
+differs. A rename never overwrites another request.
+*/
+func (db *Database) Update(oldName string, r Request) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	current, ok := db.Data[oldName]
+	if !ok {
+		return fmt.Errorf("request %q not found", oldName)
+	}
+	if r.Name == "" {
+		return fmt.Errorf("request name can't be empty")
+	}
+	if r.Name != oldName {
+		if _, exists := db.Data[r.Name]; exists {
+			return fmt.Errorf("a request named %q already exists", r.Name)
+		}
+		delete(db.Data, oldName)
+		db.note(r.Name, revisionNote{action: RevisionRename, from: oldName})
+	}
+	if r.LastUsed == nil {
+		r.LastUsed = current.LastUsed
+	}
+	db.Data[r.Name] = r
+	return db.saveLocked()
+}
+
+/*
+Rename renames a request, refusing to overwrite another one
+*/
+func (db *Database) Rename(oldName, newName string) error {
+	db.mu.Lock()
+	r, ok := db.Data[oldName]
+	db.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("request %q not found", oldName)
+	}
+	r.Name = newName
+	return db.Update(oldName, r)
+}
@@ -0,0 +1,317 @@
+package core
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Collection runner
+RunCollection executes the requests of a folder as a test suite and
+evaluates their assertions. The report is written as JUnit XML, TAP or JSON
+for the CI.
+*/
+
+/*
+RunOptions
+Which requests are run and how
+*/
+type RunOptions struct {
+	Tag      string // seulement les requêtes avec ce tag
+	Parallel int    // requêtes envoyées en même temps, 1 (défaut) pour l'ordre de la collection
+	Bail     bool   // arrête au premier échec, les requêtes suivantes sont ignorées
+}
+
+/*
+RunResult
+Outcome of a request of the run. A request without assertions passes when
+its status is below 400.
+*/
+type RunResult struct {
+	Name         string            `json:"name"`
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`
+	StatusCode   int               `json:"statusCode,omitempty"`
+	TimeMillisec int64             `json:"timeMillisec"`
+	Error        string            `json:"error,omitempty"` // la requête n'a pas pu être envoyée
+	Skipped      bool              `json:"skipped,omitempty"`
+	Assertions   []AssertionResult `json:"assertions,omitempty"`
+}
+
+/*
+RunReport
+Results of a run, in the order of the collection
+*/
+type RunReport struct {
+	Collection       string      `json:"collection"`
+	Environment      string      `json:"environment,omitempty"`
+	Started          time.Time   `json:"started"`
+	DurationMillisec int64       `json:"durationMillisec"`
+	Passed           int         `json:"passed"`
+	Failed           int         `json:"failed"`
+	Skipped          int         `json:"skipped"`
+	Results          []RunResult `json:"results"`
+}
+
+/*
+Passed reports whether the request was sent and met its assertions
+*/
+func (r RunResult) Passed() bool {
+	if r.Error != "" || r.Skipped {
+		return false
+	}
+	if len(r.Assertions) == 0 {
+		return r.StatusCode < 400
+	}
+	for _, a := range r.Assertions {
+		if !a.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Failures the reasons why the request failed
+*/
+func (r RunResult) Failures() []string {
+	var failures []string
+	switch {
+	case r.Error != "":
+		failures = append(failures, r.Error)
+	case len(r.Assertions) == 0 && r.StatusCode >= 400:
+		failures = append(failures, fmt.Sprintf("status %d", r.StatusCode))
+	}
+	for _, a := range r.Assertions {
+		if !a.Passed {
+			failures = append(failures, a.Message)
+		}
+	}
+	return failures
+}
+
+/*
+RunCollection runs the requests of a collection, "" for every request, and
+reports the results as they come to progress, which may be nil
+*/
+func (db *Database) RunCollection(collection string, opts RunOptions, progress func(RunResult)) (RunReport, error) {
+	collection = CleanCollection(collection)
+	var requests []Request
+	for _, name := range db.Search("", opts.Tag, "") {
+		if r := db.Data[name]; InCollection(r.Collection, collection) {
+			requests = append(requests, r)
+		}
+	}
+	if len(requests) == 0 {
+		return RunReport{}, fmt.Errorf("no request to run in %q", collection)
+	}
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	report := RunReport{
+		Collection:  collection,
+		Environment: db.CurrentEnvironment(),
+		Started:     time.Now(),
+		Results:     make([]RunResult, len(requests)),
+	}
+	var (
+		mu      sync.Mutex
+		failed  bool
+		wg      sync.WaitGroup
+		workers = make(chan struct{}, parallel)
+	)
+	for i, r := range requests {
+		workers <- struct{}{}
+		mu.Lock()
+		bail := opts.Bail && failed
+		mu.Unlock()
+		if bail {
+			<-workers
+			report.Results[i] = RunResult{Name: r.Name, Method: r.Method, URL: r.URL, Skipped: true}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, r Request) {
+			defer func() { <-workers; wg.Done() }()
+			result := db.runRequest(r)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Results[i] = result
+			failed = failed || !result.Passed()
+			if progress != nil {
+				progress(result)
+			}
+		}(i, r)
+	}
+	wg.Wait()
+
+	for _, result := range report.Results {
+		switch {
+		case result.Skipped:
+			report.Skipped++
+		case result.Passed():
+			report.Passed++
+		default:
+			report.Failed++
+		}
+	}
+	report.DurationMillisec = time.Since(report.Started).Milliseconds()
+	return report, nil
+}
+
+// runRequest executes a request of the run and records it in the history
+func (db *Database) runRequest(saved Request) RunResult {
+	result := RunResult{Name: saved.Name, Method: saved.Method, URL: saved.URL}
+	r, err := db.ResolveRequest(saved)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.URL = r.URL
+
+	resp, err := r.CallHTTP()
+	var recorded *Response
+	if err == nil {
+		recorded = &resp
+	}
+	if _, recordErr := db.RecordHistory(r.Name, r, recorded, err); recordErr != nil && err == nil {
+		err = fmt.Errorf("history not recorded: %w", recordErr)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Cleanup()
+	result.StatusCode = resp.StatusCode
+	result.TimeMillisec = resp.ExecutionTimeMillisec
+	result.Assertions = CheckAssertions(r.Assertions, resp)
+	return result
+}
+
+/*
+JSON the report as indented JSON
+*/
+func (report RunReport) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	return append(data, '\n'), err
+}
+
+/*
+TAP the report in the Test Anything Protocol, version 13
+*/
+func (report RunReport) TAP() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(report.Results))
+	for i, result := range report.Results {
+		description := result.Method + " " + result.Name
+		switch {
+		case result.Skipped:
+			fmt.Fprintf(&b, "ok %d - %s # SKIP after a failure\n", i+1, description)
+		case result.Passed():
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, description)
+		default:
+			fmt.Fprintf(&b, "not ok %d - %s\n  ---\n  failures:\n", i+1, description)
+			for _, failure := range result.Failures() {
+				fmt.Fprintf(&b, "    - %q\n", failure)
+			}
+			if result.StatusCode != 0 {
+				fmt.Fprintf(&b, "  status: %d\n", result.StatusCode)
+			}
+			b.WriteString("  ...\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	Output    *junitOutput  `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitOutput struct {
+	Text string `xml:",chardata"`
+}
+
+/*
+JUnit the report as JUnit XML, one test case per request
+*/
+func (report RunReport) JUnit() ([]byte, error) {
+	name := report.Collection
+	if name == "" {
+		name = "http-tanker"
+	}
+	suite := junitSuite{
+		Name:      name,
+		Tests:     len(report.Results),
+		Skipped:   report.Skipped,
+		Time:      seconds(report.DurationMillisec),
+		Timestamp: report.Started.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, result := range report.Results {
+		c := junitCase{Name: result.Method + " " + result.Name, Classname: name, Time: seconds(result.TimeMillisec)}
+		switch {
+		case result.Skipped:
+			c.Skipped = &struct{}{}
+		case result.Error != "":
+			c.Error = &junitFailure{Message: result.Error, Text: result.Error}
+			suite.Errors++
+		case !result.Passed():
+			failures := result.Failures()
+			c.Failure = &junitFailure{Message: failures[0], Text: strings.Join(failures, "\n")}
+			suite.Failures++
+		}
+		if len(result.Assertions) > 0 {
+			var lines []string
+			for _, a := range result.Assertions {
+				status := "PASS"
+				if !a.Passed {
+					status = "FAIL"
+				}
+				lines = append(lines, status+" "+a.Assertion.String())
+			}
+			c.Output = &junitOutput{Text: strings.Join(lines, "\n")}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	data, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+func seconds(millisec int64) string {
+	return fmt.Sprintf("%.3f", float64(millisec)/1000)
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*
+Schema
+The database file is an envelope carrying its schema version. Files written
+by older versions are upgraded by the migrations on Load, after a backup.
+Files written by a newer version are refused: saving them would drop what
+this version does not know about.
+*/
+
+// Version of the database files written by this build
+const SchemaVersion = 3
+
+type envelope struct {
+	Version      int                    `json:"version"`
+	Requests     map[string]Request     `json:"requests"`
+	Profiles     map[string]AuthConfig  `json:"profiles,omitempty"`
+	Folders      map[string]Folder      `json:"folders,omitempty"`
+	Environments map[string]Environment `json:"environments,omitempty"`
+	Environment  string                 `json:"environment,omitempty"`
+}
+
+/*
+Migration upgrades a raw database document from version From to From+1
+*/
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(dir string, doc []byte) ([]byte, error)
+	Absorbs     func(dir string) []string // files merged into the document, moved to backups once upgraded
+}
+
+var Migrations = []Migration{
+	{
+		From:        1,
+		Description: "wrap the requests map in a versioned envelope, absorb the auth profiles and collections files",
+		Apply:       migrateEnvelope,
+		Absorbs: func(dir string) []string {
+			return []string{legacyFile(dir, "profiles"), legacyFile(dir, "folders")}
+		},
+	},
+	{
+		From: 2,
+		// Older versions would drop the variables and environments when saving
+		Description: "add environments, folder variables and the request body types",
+		Apply: func(dir string, doc []byte) ([]byte, error) {
+			return setVersion(doc, 3)
+		},
+	},
+}
+
+func setVersion(doc []byte, version int) ([]byte, error) {
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &out); err != nil {
+		return nil, err
+	}
+	out["version"] = json.RawMessage(fmt.Sprint(version))
+	return json.Marshal(out)
+}
+
+// Sidecar files of schema version 1
+func legacyFile(dir, key string) string {
+	name := map[string]string{"profiles": "http-tanker-profiles.json", "folders": "http-tanker-collections.json"}[key]
+	return filepath.Join(dir, name)
+}
+
+func migrateEnvelope(dir string, doc []byte) ([]byte, error) {
+	out := map[string]json.RawMessage{
+		"version":  json.RawMessage("2"),
+		"requests": json.RawMessage(doc),
+	}
+	for _, key := range []string{"profiles", "folders"} {
+		path := legacyFile(dir, key)
+		buffer, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(buffer) {
+			return nil, fmt.Errorf("invalid %s file", path)
+		}
+		out[key] = buffer
+	}
+	return json.Marshal(out)
+}
+
+/*
+schemaVersion detects the version of a raw document: version 1 files are a
+bare map of requests, without a numeric "version" field
+*/
+func schemaVersion(doc []byte) (int, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &probe); err != nil {
+		return 0, err
+	}
+	var version int
+	if raw, ok := probe["version"]; ok && json.Unmarshal(raw, &version) == nil {
+		return version, nil
+	}
+	return 1, nil
+}
+
+/*
+checkVersion refuses the files written by a newer version
+*/
+func checkVersion(path string, version int) error {
+	if version > SchemaVersion {
+		return fmt.Errorf("%s uses schema version %d but this version of http-tanker only supports up to %d, please upgrade http-tanker", path, version, SchemaVersion)
+	}
+	return nil
+}
+
+/*
+migrate upgrades a raw document of the database file in dir to
+SchemaVersion, returning its original version
+*/
+func migrate(path string, doc []byte) ([]byte, int, error) {
+	version, err := schemaVersion(doc)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid database file: %w", err)
+	}
+	if err := checkVersion(path, version); err != nil {
+		return nil, version, err
+	}
+	from := version
+	for version < SchemaVersion {
+		var m *Migration
+		for i := range Migrations {
+			if Migrations[i].From == version {
+				m = &Migrations[i]
+			}
+		}
+		if m == nil {
+			return nil, from, fmt.Errorf("no migration from schema version %d", version)
+		}
+		if doc, err = m.Apply(filepath.Dir(path), doc); err != nil {
+			return nil, from, fmt.Errorf("migration from schema version %d failed: %w", version, err)
+		}
+		version++
+	}
+	return doc, from, nil
+}
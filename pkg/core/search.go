@@ -0,0 +1,193 @@
+package core
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	SortByName     = "name"
+	SortByMethod   = "method"
+	SortByLastUsed = "lastUsed"
+)
+
+var SortOrders = []string{SortByName, SortByMethod, SortByLastUsed}
+
+/*
+FuzzyScore
+Score how well query matches text, case insensitive: a substring match ranks
+first, then a subsequence match with bonuses for consecutive characters and
+word starts. Returns -1 when text does not contain every query character in
+order.
+*/
+func FuzzyScore(query, text string) int {
+	query, text = strings.ToLower(strings.TrimSpace(query)), strings.ToLower(text)
+	if query == "" {
+		return 0
+	}
+	if i := strings.Index(text, query); i >= 0 {
+		return 1000 - i
+	}
+
+	q := []rune(query)
+	score, qi := 0, 0
+	prevMatch, prev := false, ' '
+	for _, c := range text {
+		if qi < len(q) && c == q[qi] {
+			score++
+			if prevMatch {
+				score += 5
+			}
+			if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+				score += 3
+			}
+			qi++
+			prevMatch = true
+		} else {
+			prevMatch = false
+		}
+		prev = c
+	}
+	if qi < len(q) {
+		return -1
+	}
+	return score
+}
+
+/*
+MatchScore returns the best fuzzy score of a query against the name, URL and
+tags of a request, -1 when nothing matches
+*/
+func (r Request) MatchScore(query string) int {
+	best := -1
+	for _, field := range append([]string{r.Name, r.URL}, r.Tags...) {
+		if score := FuzzyScore(query, field); score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+/*
+HasTag reports whether a request carries a tag, case insensitive
+*/
+func (r Request) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ParseTags splits a comma or space separated tag list, dropping duplicates
+*/
+func ParseTags(s string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range strings.FieldsFunc(s, func(c rune) bool { return c == ',' || unicode.IsSpace(c) }) {
+		tag = strings.TrimPrefix(tag, "#")
+		if tag != "" && !seen[strings.ToLower(tag)] {
+			seen[strings.ToLower(tag)] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+/*
+Tags returns every tag used by the saved requests, sorted alphabetically
+*/
+func (db *Database) Tags() []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, r := range db.Data {
+		for _, tag := range r.Tags {
+			if !seen[strings.ToLower(tag)] {
+				seen[strings.ToLower(tag)] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+/*
+SortRequests sorts request names in place: by collection then name (default),
+by method then name, or most recently used first
+*/
+func (db *Database) SortRequests(names []string, by string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		a, b := db.Data[names[i]], db.Data[names[j]]
+		switch by {
+		case SortByMethod:
+			if a.Method != b.Method {
+				return a.Method < b.Method
+			}
+		case SortByLastUsed:
+			if !lastUsed(a).Equal(lastUsed(b)) {
+				return lastUsed(a).After(lastUsed(b))
+			}
+		default:
+			if ca, cb := CleanCollection(a.Collection), CleanCollection(b.Collection); ca != cb {
+				return ca < cb
+			}
+		}
+		return names[i] < names[j]
+	})
+}
+
+func lastUsed(r Request) time.Time {
+	if r.LastUsed == nil {
+		return time.Time{}
+	}
+	return *r.LastUsed
+}
+
+/*
+Search returns the names of the requests matching a fuzzy query and a tag,
+both optional. Without an explicit sort order, query results are ranked by
+relevance.
+*/
+func (db *Database) Search(query, tag, by string) []string {
+	scores := map[string]int{}
+	var names []string
+	for name, r := range db.Data {
+		if tag != "" && !r.HasTag(tag) {
+			continue
+		}
+		score := r.MatchScore(query)
+		if score < 0 {
+			continue
+		}
+		scores[name] = score
+		names = append(names, name)
+	}
+	db.SortRequests(names, by)
+	if by == "" && strings.TrimSpace(query) != "" {
+		sort.SliceStable(names, func(i, j int) bool {
+			return scores[names[i]] > scores[names[j]]
+		})
+	}
+	return names
+}
+
+/*
+Touch records that a request has just been executed
+*/
+func (db *Database) Touch(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	r, ok := db.Data[name]
+	if !ok {
+		return nil
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	r.LastUsed = &now
+	db.Data[name] = r
+	return db.saveLocked()
+}
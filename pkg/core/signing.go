@@ -0,0 +1,270 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultHMACTemplate = "{method}\n{path}\n{timestamp}\n{body}"
+	DefaultJWTClaims    = `{"iat": {now}, "exp": {exp}, "jti": "{jti}"}`
+)
+
+/*
+signHMAC
+Sign the canonical string built from the template and send the signature
+in the configured header. Template placeholders: {method}, {path}, {query},
+{host}, {timestamp}, {nonce}, {body} and {bodySha256}.
+*/
+func signHMAC(req *http.Request, payload []byte, auth *AuthConfig) error {
+	h, err := hmacHash(auth.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := randomString(12)
+	template := auth.Template
+	if template == "" {
+		template = DefaultHMACTemplate
+	}
+	canonical := strings.NewReplacer(
+		"{method}", req.Method,
+		"{path}", req.URL.EscapedPath(),
+		"{query}", req.URL.RawQuery,
+		"{host}", req.URL.Host,
+		"{timestamp}", timestamp,
+		"{nonce}", nonce,
+		"{bodySha256}", sha256Hex(payload),
+		"{body}", string(payload),
+	).Replace(template)
+
+	mac := hmac.New(h, []byte(auth.Secret))
+	mac.Write([]byte(canonical))
+	signature, err := encodeSignature(mac.Sum(nil), auth.Encoding)
+	if err != nil {
+		return err
+	}
+
+	header := auth.Header
+	if header == "" {
+		header = "X-Signature"
+	}
+	req.Header.Set(header, auth.SignaturePrefix+signature)
+	if auth.TimestampHeader != "" {
+		req.Header.Set(auth.TimestampHeader, timestamp)
+	}
+	if auth.NonceHeader != "" {
+		req.Header.Set(auth.NonceHeader, nonce)
+	}
+	return nil
+}
+
+func hmacHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToLower(strings.ReplaceAll(algorithm, "-", "")) {
+	case "", "sha256", "hmacsha256":
+		return sha256.New, nil
+	case "sha1", "hmacsha1":
+		return sha1.New, nil
+	case "sha512", "hmacsha512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported HMAC algorithm %q", algorithm)
+}
+
+func encodeSignature(sum []byte, encoding string) (string, error) {
+	switch strings.ToLower(encoding) {
+	case "", "hex":
+		return hex.EncodeToString(sum), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(sum), nil
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString(sum), nil
+	}
+	return "", fmt.Errorf("unsupported signature encoding %q", encoding)
+}
+
+/*
+MintJWT
+Build and sign a short-lived JWT from the claims template. Template
+placeholders: {now}, {exp} (now + TTL) and {jti} (random identifier).
+*/
+func MintJWT(auth *AuthConfig) (string, error) {
+	alg, err := jwtAlgorithm(auth.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := 5 * time.Minute
+	if auth.TTL != "" {
+		d, err := time.ParseDuration(auth.TTL)
+		if err != nil {
+			return "", fmt.Errorf("invalid TTL: %w", err)
+		}
+		ttl = d
+	}
+	now := time.Now()
+	template := auth.Claims
+	if template == "" {
+		template = DefaultJWTClaims
+	}
+	claimsJSON := strings.NewReplacer(
+		"{now}", strconv.FormatInt(now.Unix(), 10),
+		"{exp}", strconv.FormatInt(now.Add(ttl).Unix(), 10),
+		"{jti}", randomString(12),
+	).Replace(template)
+	var claims map[string]interface{}
+	if err := json.Unmarshal([]byte(claimsJSON), &claims); err != nil {
+		return "", fmt.Errorf("invalid claims template: %w", err)
+	}
+	claimsBytes, _ := json.Marshal(claims)
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if auth.KeyID != "" {
+		header["kid"] = auth.KeyID
+	}
+	headerBytes, _ := json.Marshal(header)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerBytes) + "." + base64.RawURLEncoding.EncodeToString(claimsBytes)
+	signature, err := signJWT(alg, auth, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// JWT algorithms as written in the header
+var jwtAlgorithms = []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// jwtAlgorithm returns the canonical name of an algorithm, matched whatever
+// its case: verifiers reject "EDDSA"
+func jwtAlgorithm(name string) (string, error) {
+	if name == "" {
+		return "HS256", nil
+	}
+	for _, alg := range jwtAlgorithms {
+		if strings.EqualFold(name, alg) {
+			return alg, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported JWT algorithm %q", name)
+}
+
+func signJWT(alg string, auth *AuthConfig, input []byte) ([]byte, error) {
+	var hashFunc crypto.Hash
+	switch alg[max(0, len(alg)-3):] {
+	case "256":
+		hashFunc = crypto.SHA256
+	case "384":
+		hashFunc = crypto.SHA384
+	case "512":
+		hashFunc = crypto.SHA512
+	}
+
+	if strings.HasPrefix(alg, "HS") {
+		secret := []byte(auth.Secret)
+		if auth.KeyFile != "" {
+			b, err := os.ReadFile(auth.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			secret = []byte(strings.TrimRight(string(b), "\r\n"))
+		}
+		if hashFunc == 0 {
+			return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+		}
+		mac := hmac.New(hashFunc.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+
+	key, err := loadPrivateKey(auth.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if alg == "EdDSA" {
+		k, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("EdDSA requires an Ed25519 private key")
+		}
+		return ed25519.Sign(k, input), nil
+	}
+	if hashFunc == 0 {
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+	}
+	h := hashFunc.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		k, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an RSA private key", alg)
+		}
+		if alg[0] == 'P' {
+			return rsa.SignPSS(rand.Reader, k, hashFunc, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.SignPKCS1v15(rand.Reader, k, hashFunc, digest)
+	case strings.HasPrefix(alg, "ES"):
+		k, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an ECDSA private key", alg)
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses the fixed-size r || s encoding
+		size := (k.Curve.Params().BitSize + 7) / 8
+		out := make([]byte, 2*size)
+		r.FillBytes(out[:size])
+		s.FillBytes(out[size:])
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+}
+
+func loadPrivateKey(path string) (crypto.PrivateKey, error) {
+	if path == "" {
+		return nil, fmt.Errorf("missing private key file")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key in %s", path)
+}
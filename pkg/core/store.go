@@ -0,0 +1,373 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+Store
+The database files are shared by every process using the same database
+directory (the TUI and any number of MCP servers). Writes hold an exclusive
+lock on a lock file, merge the local changes with the current content of
+the files and replace them atomically.
+*/
+
+const lockTimeout = 10 * time.Second
+
+/*
+lockFile takes an exclusive lock shared with the other processes, the
+returned function releases it
+*/
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("database is locked by another process (%s)", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return func() {
+		unlock(f)
+		f.Close()
+	}, nil
+}
+
+/*
+writeFileAtomic writes to a temporary file in the same directory then
+renames it, so readers never see a partially written file
+*/
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+/*
+readJSONFile decodes a JSON file, exists is false when the file is missing
+*/
+func readJSONFile(path string, v interface{}) (exists bool, err error) {
+	buffer, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	return true, json.Unmarshal(buffer, v)
+}
+
+// snapshot keeps the JSON encoding of every entry as last read from, or
+// written to, the disk. It is the common ancestor of the merges.
+type snapshot map[string]string
+
+func takeSnapshot[T any](m map[string]T) snapshot {
+	s := make(snapshot, len(m))
+	for k, v := range m {
+		buffer, _ := json.Marshal(v)
+		s[k] = string(buffer)
+	}
+	return s
+}
+
+/*
+mergeChanges three-way merges entries: an entry changed (or added, or
+deleted) locally since the snapshot keeps the local version, any other
+entry takes the version on disk, which includes the changes of the other
+processes
+*/
+func mergeChanges[T any](base snapshot, local, disk map[string]T) map[string]T {
+	merged := make(map[string]T, len(disk))
+	keys := map[string]bool{}
+	for k := range base {
+		keys[k] = true
+	}
+	for k := range local {
+		keys[k] = true
+	}
+	for k := range disk {
+		keys[k] = true
+	}
+	for k := range keys {
+		l, inLocal := local[k]
+		b, inBase := base[k]
+		changed := inLocal != inBase
+		if inLocal && inBase {
+			buffer, _ := json.Marshal(l)
+			changed = string(buffer) != b
+		}
+		if changed {
+			if inLocal {
+				merged[k] = l
+			}
+		} else if d, inDisk := disk[k]; inDisk {
+			merged[k] = d
+		}
+	}
+	return merged
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+/*
+Contents
+Everything a Storage persists
+*/
+type Contents struct {
+	Requests     map[string]Request
+	Profiles     map[string]AuthConfig
+	Folders      map[string]Folder
+	Environments map[string]Environment
+	Environment  string // active environment
+}
+
+/*
+Storage
+Backend persisting the database. Save is always called while holding Lock,
+and Stamp changes whenever another process saves.
+*/
+type Storage interface {
+	Load() (c Contents, exists bool, err error)
+	Save(c Contents) error
+	Lock() (unlock func(), err error)
+	Stamp() string
+}
+
+/*
+FileStorage
+Default backend: a single versioned JSON file
+*/
+type FileStorage struct {
+	Path string
+}
+
+func (s *FileStorage) Lock() (func(), error) {
+	return lockFile(filepath.Join(filepath.Dir(s.Path), ".http-tanker.lock"))
+}
+
+func (s *FileStorage) Stamp() string {
+	return fmt.Sprint(stampFile(s.Path))
+}
+
+/*
+Load reads the database file, upgraded in memory when it uses an older schema
+*/
+func (s *FileStorage) Load() (Contents, bool, error) {
+	doc, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return Contents{}, false, nil
+	}
+	if err != nil {
+		return Contents{}, true, err
+	}
+	if doc, _, err = migrate(s.Path, doc); err != nil {
+		return Contents{}, true, err
+	}
+	var env envelope
+	if err := json.Unmarshal(doc, &env); err != nil {
+		return Contents{}, true, fmt.Errorf("invalid database file: %w", err)
+	}
+	return Contents{
+		Requests:     env.Requests,
+		Profiles:     env.Profiles,
+		Folders:      env.Folders,
+		Environments: env.Environments,
+		Environment:  env.Environment,
+	}, true, nil
+}
+
+func (s *FileStorage) Save(c Contents) error {
+	buffer, err := json.Marshal(envelope{
+		Version:      SchemaVersion,
+		Requests:     c.Requests,
+		Profiles:     c.Profiles,
+		Folders:      c.Folders,
+		Environments: c.Environments,
+		Environment:  c.Environment,
+	})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, buffer, 0600)
+}
+
+/*
+Upgrade migrates the database file on disk when it uses an older schema,
+keeping a backup of every file it replaces
+*/
+func (s *FileStorage) Upgrade() error {
+	doc, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	version, err := schemaVersion(doc)
+	if err != nil || version >= SchemaVersion {
+		// Errors and newer versions are reported by Load
+		return nil
+	}
+
+	unlock, err := s.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another process may have upgraded it meanwhile
+	if doc, err = os.ReadFile(s.Path); err != nil {
+		return err
+	}
+	upgraded, from, err := migrate(s.Path, doc)
+	if err != nil || from == SchemaVersion {
+		return err
+	}
+
+	suffix := fmt.Sprintf(".v%d-%s.bak", from, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(s.Path+suffix, doc, 0600); err != nil {
+		return fmt.Errorf("database backup failed: %w", err)
+	}
+	if err := writeFileAtomic(s.Path, upgraded, 0600); err != nil {
+		return err
+	}
+	for _, m := range Migrations {
+		if m.From < from || m.Absorbs == nil {
+			continue
+		}
+		for _, path := range m.Absorbs(filepath.Dir(s.Path)) {
+			if err := os.Rename(path, path+suffix); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (db *Database) storage() Storage {
+	if db.Storage == nil {
+		db.Storage = &FileStorage{Path: db.DatabaseFile}
+	}
+	return db.Storage
+}
+
+// snapshotLocked records the state just read from, or written to, the storage
+func (db *Database) snapshotLocked() {
+	db.base = map[string]snapshot{
+		"data":         takeSnapshot(db.Data),
+		"profiles":     takeSnapshot(db.Profiles),
+		"folders":      takeSnapshot(db.Folders),
+		"environments": takeSnapshot(db.Environments),
+	}
+	db.baseEnvironment = db.Environment
+	db.stamp = db.storage().Stamp()
+}
+
+/*
+Changed reports whether another process modified the database since it
+was last loaded or saved
+*/
+func (db *Database) Changed() bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.storage().Stamp() != db.stamp
+}
+
+/*
+Sync reloads the database when another process modified it, and reports
+whether it did
+*/
+func (db *Database) Sync() (bool, error) {
+	if !db.Changed() {
+		return false, nil
+	}
+	return true, db.Load()
+}
+
+/*
+commitLocked writes the database under the cross-process lock. With merge,
+the local changes are merged into the current content of the storage
+instead of overwriting the changes of the other processes.
+*/
+func (db *Database) commitLocked(merge bool) error {
+	storage := db.storage()
+	unlock, err := storage.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	changes := db.localChanges()
+	if merge {
+		// Also refuses a storage upgraded meanwhile by a newer version
+		disk, _, err := storage.Load()
+		if err != nil {
+			return err
+		}
+		db.Data = mergeChanges(db.base["data"], db.Data, disk.Requests)
+		db.Profiles = mergeChanges(db.base["profiles"], db.Profiles, disk.Profiles)
+		db.Folders = mergeChanges(db.base["folders"], db.Folders, disk.Folders)
+		db.Environments = mergeChanges(db.base["environments"], db.Environments, disk.Environments)
+		if db.Environment == db.baseEnvironment {
+			db.Environment = disk.Environment
+		}
+	}
+
+	err = storage.Save(Contents{
+		Requests:     db.Data,
+		Profiles:     db.Profiles,
+		Folders:      db.Folders,
+		Environments: db.Environments,
+		Environment:  db.Environment,
+	})
+	if err != nil {
+		return err
+	}
+	db.snapshotLocked()
+	db.recordRevisions(changes)
+	return nil
+}
@@ -0,0 +1,237 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Variables
+{{name}} references are replaced when a request is resolved, from the
+variables of its folders (the deepest folder wins) overridden by the
+active environment. Unknown references are left as they are.
+*/
+
+/*
+Environment
+Named set of variables, e.g. one per deployment: local, staging, production
+*/
+type Environment struct {
+	Variables map[string]string `json:"variables"`
+}
+
+var variablePattern = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+/*
+Variables returns the variables visible from a request
+*/
+func (db *Database) Variables(r Request) map[string]string {
+	vars := map[string]string{}
+	parts := strings.Split(CleanCollection(r.Collection), "/")
+	for i := range parts {
+		if folder, ok := db.Folders[strings.Join(parts[:i+1], "/")]; ok {
+			for k, v := range folder.Variables {
+				vars[k] = v
+			}
+		}
+	}
+	if env, ok := db.Environments[db.CurrentEnvironment()]; ok {
+		for k, v := range env.Variables {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+/*
+CurrentEnvironment returns the environment the variables are resolved from:
+EnvironmentUsed when set, the active environment otherwise
+*/
+func (db *Database) CurrentEnvironment() string {
+	if db.EnvironmentUsed != "" {
+		return db.EnvironmentUsed
+	}
+	return db.Environment
+}
+
+/*
+Substitute replaces the {{name}} references of s, including the dynamic
+variables {{$guid}}, {{$timestamp}}, {{$isoTimestamp}} and {{$randomInt}}
+*/
+func Substitute(s string, vars map[string]string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return variablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := variablePattern.FindStringSubmatch(ref)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		if v, ok := dynamicVariable(name); ok {
+			return v
+		}
+		return ref
+	})
+}
+
+func dynamicVariable(name string) (string, bool) {
+	switch name {
+	case "$guid", "$randomUUID":
+		b := make([]byte, 16)
+		rand.Read(b)
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), true
+	case "$timestamp":
+		return strconv.FormatInt(time.Now().Unix(), 10), true
+	case "$isoTimestamp":
+		return time.Now().UTC().Format(time.RFC3339), true
+	case "$randomInt":
+		n, _ := rand.Int(rand.Reader, big.NewInt(1001))
+		return n.String(), true
+	}
+	return "", false
+}
+
+// substituteValue replaces the references in the strings of a decoded JSON
+// value, returning a copy
+func substituteValue(v interface{}, vars map[string]string) interface{} {
+	switch value := v.(type) {
+	case string:
+		return Substitute(value, vars)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			out[Substitute(k, vars)] = substituteValue(item, vars)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			out[i] = substituteValue(item, vars)
+		}
+		return out
+	}
+	return v
+}
+
+func substituteMap(m map[string]interface{}, vars map[string]string) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	return substituteValue(m, vars).(map[string]interface{})
+}
+
+/*
+applyVariables returns the request with its references replaced
+*/
+func applyVariables(r Request, vars map[string]string) Request {
+	r.URL = Substitute(r.URL, vars)
+	r.Params = substituteMap(r.Params, vars)
+	r.Headers = substituteMap(r.Headers, vars)
+	r.Payload = substituteMap(r.Payload, vars)
+	r.Body = Substitute(r.Body, vars)
+	if r.Form != nil {
+		form := make([]FormField, len(r.Form))
+		for i, field := range r.Form {
+			field.Name = Substitute(field.Name, vars)
+			field.Value = Substitute(field.Value, vars)
+			field.File = Substitute(field.File, vars)
+			form[i] = field
+		}
+		r.Form = form
+	}
+	if r.Auth != nil {
+		// Every string field of the auth may hold a reference
+		var generic map[string]interface{}
+		buffer, _ := json.Marshal(r.Auth)
+		json.Unmarshal(buffer, &generic)
+		buffer, _ = json.Marshal(substituteValue(generic, vars))
+		var auth AuthConfig
+		if json.Unmarshal(buffer, &auth) == nil {
+			r.Auth = &auth
+		}
+	}
+	return r
+}
+
+/*
+MissingVariables lists the references left in a resolved request
+*/
+func MissingVariables(r Request) []string {
+	buffer, _ := json.Marshal(r)
+	seen := map[string]bool{}
+	var missing []string
+	for _, match := range variablePattern.FindAllStringSubmatch(string(buffer), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			missing = append(missing, match[1])
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+/*
+EnvironmentNames returns the environment names sorted alphabetically
+*/
+func (db *Database) EnvironmentNames() []string {
+	names := make([]string, 0, len(db.Environments))
+	for name := range db.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+SaveEnvironment creates or replaces an environment
+*/
+func (db *Database) SaveEnvironment(name string, env Environment) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if name == "" {
+		return fmt.Errorf("environment name can't be empty")
+	}
+	if env.Variables == nil {
+		env.Variables = map[string]string{}
+	}
+	db.Environments[name] = env
+	return db.saveLocked()
+}
+
+/*
+DeleteEnvironment removes an environment, deactivating it when active
+*/
+func (db *Database) DeleteEnvironment(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.Environments[name]; !ok {
+		return fmt.Errorf("environment %q not found", name)
+	}
+	delete(db.Environments, name)
+	if db.Environment == name {
+		db.Environment = ""
+	}
+	return db.saveLocked()
+}
+
+/*
+UseEnvironment activates an environment, "" deactivating the current one
+*/
+func (db *Database) UseEnvironment(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.Environments[name]; name != "" && !ok {
+		return fmt.Errorf("environment %q not found", name)
+	}
+	db.Environment = name
+	return db.saveLocked()
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+/*
+Workspaces
+A project keeps its requests in a .tanker/ directory, discovered from the
+current directory or any parent like git does. Requests are stored one
+file per request under .tanker/requests so they can be committed, while
+history and tokens stay local.
+*/
+
+const WorkspaceDirName = ".tanker"
+
+// Maximum number of recent workspaces remembered
+const maxRecentWorkspaces = 10
+
+type Workspace struct {
+	Path       string    `json:"path"`
+	LastOpened time.Time `json:"lastOpened"`
+}
+
+/*
+FindWorkspace looks for a .tanker directory in start and its parents
+*/
+func FindWorkspace(start string) (string, bool) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", false
+	}
+	for {
+		candidate := filepath.Join(dir, WorkspaceDirName)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+/*
+InitWorkspace creates an empty workspace in dir, format being "json" or "yaml"
+*/
+func InitWorkspace(dir string, format string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, WorkspaceDirName)
+	if err := os.MkdirAll(path, 0750); err != nil {
+		return "", err
+	}
+	gitignore := "# Local state, the requests/ directory is meant to be committed\nhistory/\nrevisions/\nhttp-tanker-tokens.json*\n.http-tanker.lock\n"
+	if _, err := os.Stat(filepath.Join(path, ".gitignore")); os.IsNotExist(err) {
+		if err := os.WriteFile(filepath.Join(path, ".gitignore"), []byte(gitignore), 0644); err != nil {
+			return "", err
+		}
+	}
+
+	storage := &DirStorage{Root: filepath.Join(path, "requests"), Format: format}
+	if _, exists, err := storage.Load(); err != nil || exists {
+		return path, err
+	}
+	unlock, err := storage.Lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	return path, storage.Save(Contents{})
+}
+
+/*
+OpenWorkspace returns the database of a workspace, InitDB still has to be
+called
+*/
+func OpenWorkspace(path string) *Database {
+	root := filepath.Join(path, "requests")
+	format := "json"
+	if _, err := os.Stat(filepath.Join(root, dirMetaFile+".yaml")); err == nil {
+		format = "yaml"
+	}
+	return &Database{
+		DatabaseDir:  path,
+		DatabaseFile: filepath.Join(path, "http-tanker-data.json"),
+		Storage:      &DirStorage{Root: root, Format: format},
+	}
+}
+
+func recentWorkspacesFile(homeDir string) string {
+	return filepath.Join(homeDir, "http-tanker-workspaces.json")
+}
+
+/*
+RecentWorkspaces returns the workspaces opened lately, most recent first,
+skipping the ones that no longer exist
+*/
+func RecentWorkspaces(homeDir string) []Workspace {
+	var recent []Workspace
+	readJSONFile(recentWorkspacesFile(homeDir), &recent)
+	existing := recent[:0]
+	for _, w := range recent {
+		if info, err := os.Stat(w.Path); err == nil && info.IsDir() {
+			existing = append(existing, w)
+		}
+	}
+	sort.SliceStable(existing, func(i, j int) bool {
+		return existing[i].LastOpened.After(existing[j].LastOpened)
+	})
+	return existing
+}
+
+/*
+RememberWorkspace records a workspace at the top of the recent workspaces
+*/
+func RememberWorkspace(homeDir string, path string) error {
+	recent := []Workspace{{Path: path, LastOpened: time.Now().UTC()}}
+	for _, w := range RecentWorkspaces(homeDir) {
+		if w.Path != path && len(recent) < maxRecentWorkspaces {
+			recent = append(recent, w)
+		}
+	}
+	if err := os.MkdirAll(homeDir, 0750); err != nil {
+		return err
+	}
+	buffer, err := json.Marshal(recent)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(recentWorkspacesFile(homeDir), buffer, 0600)
+}
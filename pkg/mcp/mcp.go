@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/PierreKieffer/http-tanker/pkg/core"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -33,16 +36,49 @@ func registerTools(s *server.MCPServer, db *core.Database) {
 	s.AddTool(saveRequestTool(), saveRequestHandler(db))
 	s.AddTool(deleteRequestTool(), deleteRequestHandler(db))
 	s.AddTool(curlCommandTool(), curlCommandHandler(db))
+	s.AddTool(listOAuthTokensTool(), listOAuthTokensHandler())
+	s.AddTool(clearOAuthTokensTool(), clearOAuthTokensHandler())
+	s.AddTool(listAuthProfilesTool(), listAuthProfilesHandler(db))
+	s.AddTool(saveAuthProfileTool(), saveAuthProfileHandler(db))
+	s.AddTool(deleteAuthProfileTool(), deleteAuthProfileHandler(db))
+	s.AddTool(getHistoryTool(), getHistoryHandler(db))
+	s.AddTool(rerunHistoryTool(), rerunHistoryHandler(db))
+	s.AddTool(duplicateRequestTool(), duplicateRequestHandler(db))
+	s.AddTool(renameRequestTool(), renameRequestHandler(db))
+	s.AddTool(bulkRequestsTool(), bulkRequestsHandler(db))
+	s.AddTool(importCurlTool(), importCurlHandler(db))
+	s.AddTool(listRevisionsTool(), listRevisionsHandler(db))
+	s.AddTool(diffRevisionsTool(), diffRevisionsHandler(db))
+	s.AddTool(rollbackRequestTool(), rollbackRequestHandler(db))
+	s.AddTool(listTrashTool(), listTrashHandler(db))
+	s.AddTool(restoreRequestTool(), restoreRequestHandler(db))
+	s.AddTool(importPostmanTool(), importPostmanHandler(db))
+	s.AddTool(exportPostmanTool(), exportPostmanHandler(db))
+	s.AddTool(importOpenAPITool(), importOpenAPIHandler(db))
+	s.AddTool(importHARTool(), importHARHandler(db))
+	s.AddTool(exportHARTool(), exportHARHandler(db))
+	s.AddTool(importHTTPFileTool(), importHTTPFileHandler(db))
+	s.AddTool(exportHTTPFileTool(), exportHTTPFileHandler(db))
+	s.AddTool(runHTTPFileTool(), runHTTPFileHandler(db))
+	s.AddTool(runCollectionTool(), runCollectionHandler(db))
+	s.AddTool(listEnvironmentsTool(), listEnvironmentsHandler(db))
+	s.AddTool(saveEnvironmentTool(), saveEnvironmentHandler(db))
+	s.AddTool(setEnvironmentTool(), setEnvironmentHandler(db))
+	s.AddTool(deleteEnvironmentTool(), deleteEnvironmentHandler(db))
 }
 
 // --- list_requests ---
 
 func listRequestsTool() mcp.Tool {
 	return mcp.NewTool("list_requests",
-		mcp.WithDescription("List all saved HTTP requests with their names, methods, and URLs"),
+		mcp.WithDescription("List all saved HTTP requests with their names, methods, URLs and collection folders, sorted by collection then name"),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithDestructiveHintAnnotation(false),
 		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("collection", mcp.Description("Only list the requests of this collection path (e.g. \"shop/orders\"), including its subfolders")),
+		mcp.WithString("query", mcp.Description("Fuzzy search on request name, URL and tags; results are ranked by relevance unless sort is set")),
+		mcp.WithString("tag", mcp.Description("Only list the requests carrying this tag")),
+		mcp.WithString("sort", mcp.Description("Sort order (default: collection then name)"), mcp.Enum(core.SortOrders...)),
 	)
 }
 
@@ -53,22 +89,42 @@ func listRequestsHandler(db *core.Database) server.ToolHandlerFunc {
 		}
 
 		type entry struct {
-			Name   string `json:"name"`
-			Method string `json:"method"`
-			URL    string `json:"url"`
+			Name       string     `json:"name"`
+			Method     string     `json:"method"`
+			URL        string     `json:"url"`
+			Collection string     `json:"collection,omitempty"`
+			Tags       []string   `json:"tags,omitempty"`
+			LastUsed   *time.Time `json:"lastUsed,omitempty"`
 		}
 
-		entries := make([]entry, 0, len(db.Data))
-		for _, r := range db.Data {
+		collection := core.CleanCollection(request.GetString("collection", ""))
+		names := db.Search(request.GetString("query", ""), request.GetString("tag", ""), request.GetString("sort", ""))
+		entries := make([]entry, 0, len(names))
+		for _, name := range names {
+			r := db.Data[name]
+			if !core.InCollection(r.Collection, collection) {
+				continue
+			}
 			entries = append(entries, entry{
-				Name:   r.Name,
-				Method: r.Method,
-				URL:    r.URL,
+				Name:       r.Name,
+				Method:     r.Method,
+				URL:        r.URL,
+				Collection: core.CleanCollection(r.Collection),
+				Tags:       r.Tags,
+				LastUsed:   r.LastUsed,
 			})
 		}
 
+		var collections []string
+		for _, c := range db.Collections() {
+			if core.InCollection(c, collection) {
+				collections = append(collections, c)
+			}
+		}
+
 		return mcp.NewToolResultJSON(map[string]interface{}{
-			"requests": entries,
+			"requests":    entries,
+			"collections": collections,
 		})
 	}
 }
@@ -128,12 +184,12 @@ func sendRequestHandler(db *core.Database) server.ToolHandlerFunc {
 			return nil, fmt.Errorf("failed to load database: %w", err)
 		}
 
-		r, ok := db.Data[name]
-		if !ok {
-			return mcp.NewToolResultError(fmt.Sprintf("request %q not found", name)), nil
+		r, err := db.Resolve(name)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		resp, err := r.CallHTTP()
+		resp, err := execute(db, name, r)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("HTTP request failed: %v", err)), nil
 		}
@@ -146,7 +202,7 @@ func sendRequestHandler(db *core.Database) server.ToolHandlerFunc {
 // --- send_custom_request ---
 
 func sendCustomRequestTool() mcp.Tool {
-	return mcp.NewTool("send_custom_request",
+	opts := []mcp.ToolOption{
 		mcp.WithDescription("Execute an ad-hoc HTTP request without saving it. For binary responses (images, PDFs, archives...), only metadata is returned. Use output_file to save binary content to disk. When authentication is needed, prefer using the auth_* fields (auth_type, auth_token, etc.) instead of manually setting Authorization headers."),
 		mcp.WithDestructiveHintAnnotation(false),
 		mcp.WithOpenWorldHintAnnotation(true),
@@ -157,13 +213,8 @@ func sendCustomRequestTool() mcp.Tool {
 		mcp.WithString("headers", mcp.Description("HTTP headers as a JSON object string, e.g. {\"Content-Type\": \"application/json\"}")),
 		mcp.WithBoolean("insecure", mcp.Description("Skip TLS certificate verification (default: false)")),
 		mcp.WithString("output_file", mcp.Description("File path to save binary response content (e.g. /tmp/image.png). Only used for binary responses.")),
-		mcp.WithString("auth_type", mcp.Description("Authentication type: bearer, basic, or api-key")),
-		mcp.WithString("auth_token", mcp.Description("Bearer token (when auth_type is bearer)")),
-		mcp.WithString("auth_username", mcp.Description("Username (when auth_type is basic)")),
-		mcp.WithString("auth_password", mcp.Description("Password (when auth_type is basic)")),
-		mcp.WithString("auth_key", mcp.Description("API key value (when auth_type is api-key)")),
-		mcp.WithString("auth_header", mcp.Description("Header name for API key (default: X-API-Key, when auth_type is api-key)")),
-	)
+	}
+	return mcp.NewTool("send_custom_request", append(opts, authOptions()...)...)
 }
 
 func sendCustomRequestHandler(db *core.Database) server.ToolHandlerFunc {
@@ -197,8 +248,17 @@ func sendCustomRequestHandler(db *core.Database) server.ToolHandlerFunc {
 			r.Headers = map[string]interface{}{}
 		}
 		r.Auth = parseAuth(request)
+		r.AuthProfile = request.GetString("auth_profile", "")
+		if r.AuthProfile != "" {
+			if err := db.Load(); err != nil {
+				return nil, fmt.Errorf("failed to load database: %w", err)
+			}
+			if r, err = db.ResolveRequest(r); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
 
-		resp, err := r.CallHTTP()
+		resp, err := execute(db, "", r)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("HTTP request failed: %v", err)), nil
 		}
@@ -211,7 +271,7 @@ func sendCustomRequestHandler(db *core.Database) server.ToolHandlerFunc {
 // --- save_request ---
 
 func saveRequestTool() mcp.Tool {
-	return mcp.NewTool("save_request",
+	opts := []mcp.ToolOption{
 		mcp.WithDescription("Save a new HTTP request to the database. This tool overwrites any existing request with the same name. Before calling this tool, you MUST ask the user if they want to add query parameters, a request body (payload), authentication (auth_type, auth_token, etc.), and/or headers. Always propose authentication before headers to avoid the user manually setting auth headers. Always propose these optional fields explicitly during the creation workflow, even though they are not required."),
 		mcp.WithDestructiveHintAnnotation(false),
 		mcp.WithOpenWorldHintAnnotation(false),
@@ -222,13 +282,11 @@ func saveRequestTool() mcp.Tool {
 		mcp.WithString("payload", mcp.Description("Request body as a JSON object string (for POST/PUT/PATCH)")),
 		mcp.WithString("headers", mcp.Description("HTTP headers as a JSON object string")),
 		mcp.WithBoolean("insecure", mcp.Description("Skip TLS certificate verification (default: false)")),
-		mcp.WithString("auth_type", mcp.Description("Authentication type: bearer, basic, or api-key")),
-		mcp.WithString("auth_token", mcp.Description("Bearer token (when auth_type is bearer)")),
-		mcp.WithString("auth_username", mcp.Description("Username (when auth_type is basic)")),
-		mcp.WithString("auth_password", mcp.Description("Password (when auth_type is basic)")),
-		mcp.WithString("auth_key", mcp.Description("API key value (when auth_type is api-key)")),
-		mcp.WithString("auth_header", mcp.Description("Header name for API key (default: X-API-Key, when auth_type is api-key)")),
-	)
+		mcp.WithString("tags", mcp.Description("Comma separated tags used to search and filter requests (e.g. \"smoke, billing\")")),
+		mcp.WithString("collection", mcp.Description("Collection folder path (e.g. \"shop/orders\"). The request inherits the base URL, headers and auth defaults of its folders; a relative url is joined to the inherited base URL.")),
+		mcp.WithString("assertions", mcp.Description("Checks of the response run by run_collection, as a JSON array of {type, target, operator, value}. type: status, header (target: header name), jsonpath (target: e.g. $.items[0].id), time (value in ms) or body. operator: equals (default), not_equals, contains (default for body), not_contains, matches (regex), exists, not_exists, lt (default for time), lte, gt or gte. A status value may be a class such as 2xx.")),
+	}
+	return mcp.NewTool("save_request", append(opts, authOptions()...)...)
 }
 
 func saveRequestHandler(db *core.Database) server.ToolHandlerFunc {
@@ -267,10 +325,24 @@ func saveRequestHandler(db *core.Database) server.ToolHandlerFunc {
 			r.Headers = map[string]interface{}{}
 		}
 		r.Auth = parseAuth(request)
+		r.AuthProfile = request.GetString("auth_profile", "")
+		r.Collection = core.CleanCollection(request.GetString("collection", ""))
+		r.Tags = core.ParseTags(request.GetString("tags", ""))
+		if assertions := request.GetString("assertions", ""); assertions != "" {
+			if err := json.Unmarshal([]byte(assertions), &r.Assertions); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("invalid assertions JSON: %v", err)), nil
+			}
+			if err := core.ValidateAssertions(r.Assertions); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
 
 		if err := db.Load(); err != nil {
 			return nil, fmt.Errorf("failed to load database: %w", err)
 		}
+		if _, ok := db.Profiles[r.AuthProfile]; r.AuthProfile != "" && !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("auth profile %q not found", r.AuthProfile)), nil
+		}
 
 		db.Data[name] = r
 		if err := db.Save(); err != nil {
@@ -285,7 +357,7 @@ func saveRequestHandler(db *core.Database) server.ToolHandlerFunc {
 
 func deleteRequestTool() mcp.Tool {
 	return mcp.NewTool("delete_request",
-		mcp.WithDescription("Delete a saved HTTP request by name"),
+		mcp.WithDescription("Delete a saved HTTP request by name. The request is moved to the trash and can be brought back with restore_request."),
 		mcp.WithDestructiveHintAnnotation(true),
 		mcp.WithOpenWorldHintAnnotation(false),
 		mcp.WithString("name", mcp.Required(), mcp.Description("Name of the request to delete")),
@@ -311,19 +383,174 @@ func deleteRequestHandler(db *core.Database) server.ToolHandlerFunc {
 			return nil, fmt.Errorf("failed to delete request: %w", err)
 		}
 
-		return mcp.NewToolResultText(fmt.Sprintf("Request %q deleted successfully", name)), nil
+		return mcp.NewToolResultText(fmt.Sprintf("Request %q moved to the trash", name)), nil
+	}
+}
+
+// --- duplicate_request ---
+
+func duplicateRequestTool() mcp.Tool {
+	return mcp.NewTool("duplicate_request",
+		mcp.WithDescription("Save a copy of a saved request under a new name, in the same collection. Fails if the new name is already used."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Name of the request to copy")),
+		mcp.WithString("new_name", mcp.Description("Name of the copy (default: \"<name> copy\")")),
+	)
+}
+
+func duplicateRequestHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: name"), nil
+		}
+
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		newName := request.GetString("new_name", db.CopyName(name))
+		if err := db.Duplicate(name, newName); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Request %q duplicated as %q", name, newName)), nil
+	}
+}
+
+// --- rename_request ---
+
+func renameRequestTool() mcp.Tool {
+	return mcp.NewTool("rename_request",
+		mcp.WithDescription("Rename a saved request. Fails if the new name is already used, its revisions follow the request."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Current name of the request")),
+		mcp.WithString("new_name", mcp.Required(), mcp.Description("New name")),
+	)
+}
+
+func renameRequestHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: name"), nil
+		}
+		newName, err := request.RequireString("new_name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: new_name"), nil
+		}
+
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		if err := db.Rename(name, newName); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Request %q renamed to %q", name, newName)), nil
+	}
+}
+
+// --- bulk_requests ---
+
+func bulkRequestsTool() mcp.Tool {
+	return mcp.NewTool("bulk_requests",
+		mcp.WithDescription("Apply an action to several saved requests at once: delete (to the trash), move to a collection, add or remove tags, export to a file, or run them one after the other. Nothing is changed if one of the names is unknown."),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(true),
+		mcp.WithArray("names", mcp.Required(), mcp.WithStringItems(), mcp.Description("Names of the requests")),
+		mcp.WithString("action", mcp.Required(), mcp.Enum("delete", "move", "tag", "untag", "export", "run")),
+		mcp.WithString("collection", mcp.Description("Target collection path for move, empty for the root")),
+		mcp.WithString("tags", mcp.Description("Comma separated tags for tag and untag")),
+		mcp.WithString("output_file", mcp.Description("File written by export")),
+	)
+}
+
+func bulkRequestsHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		names, err := request.RequireStringSlice("names")
+		if err != nil || len(names) == 0 {
+			return mcp.NewToolResultError("missing required parameter: names"), nil
+		}
+		action, err := request.RequireString("action")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: action"), nil
+		}
+
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+
+		switch action {
+		case "delete":
+			err = db.DeleteRequests(names)
+		case "move":
+			err = db.MoveRequests(names, request.GetString("collection", ""))
+		case "tag":
+			err = db.TagRequests(names, core.ParseTags(request.GetString("tags", "")), nil)
+		case "untag":
+			err = db.TagRequests(names, nil, core.ParseTags(request.GetString("tags", "")))
+		case "export":
+			outputFile := request.GetString("output_file", "")
+			if outputFile == "" {
+				return mcp.NewToolResultError("missing required parameter for export: output_file"), nil
+			}
+			var content []byte
+			if content, err = db.ExportRequests(names); err == nil {
+				err = os.WriteFile(outputFile, content, 0600)
+			}
+		case "run":
+			return runRequests(db, names)
+		default:
+			return mcp.NewToolResultError(fmt.Sprintf("unknown action %q", action)), nil
+		}
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("%s done for %d request(s)", action, len(names))), nil
+	}
+}
+
+// runRequests sends the requests one after the other and summarizes the results
+func runRequests(db *core.Database, names []string) (*mcp.CallToolResult, error) {
+	type result struct {
+		Name                  string `json:"name"`
+		StatusCode            int    `json:"statusCode,omitempty"`
+		ExecutionTimeMillisec int64  `json:"executionTimeMillisec,omitempty"`
+		Error                 string `json:"error,omitempty"`
+	}
+	results := make([]result, 0, len(names))
+	for _, name := range names {
+		r, err := db.Resolve(name)
+		if err != nil {
+			results = append(results, result{Name: name, Error: err.Error()})
+			continue
+		}
+		resp, err := execute(db, name, r)
+		if err != nil {
+			results = append(results, result{Name: name, Error: err.Error()})
+			continue
+		}
+		resp.Cleanup()
+		results = append(results, result{Name: name, StatusCode: resp.StatusCode, ExecutionTimeMillisec: resp.ExecutionTimeMillisec})
 	}
+	return mcp.NewToolResultJSON(map[string]interface{}{
+		"results": results,
+	})
 }
 
 // --- curl_command ---
 
 func curlCommandTool() mcp.Tool {
 	return mcp.NewTool("curl_command",
-		mcp.WithDescription("Generate the equivalent cURL command for a saved HTTP request"),
+		mcp.WithDescription("Generate the equivalent cURL command for a saved HTTP request, or the equivalent code in another language. Auth, params, headers, body and the insecure flag are honoured; auth computed when sending (JWT, OAuth2, HMAC, AWS SigV4) is left as a placeholder with a TODO comment."),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithDestructiveHintAnnotation(false),
 		mcp.WithOpenWorldHintAnnotation(false),
 		mcp.WithString("name", mcp.Required(), mcp.Description("Name of the saved request")),
+		mcp.WithString("language", mcp.Enum(core.CodeLanguageNames()...), mcp.Description("Target language or tool (default: curl)")),
+		mcp.WithString("shell", mcp.Enum(core.CurlShells...), mcp.Description("Shell the cURL command is quoted for (default: posix)")),
+		mcp.WithString("secrets", mcp.Enum("redact", "env"), mcp.Description("Mask tokens, passwords and API keys (redact) or read them from environment variables such as $TOKEN (env). Written in clear by default")),
+		mcp.WithBoolean("single_line", mcp.Description("cURL only: write the command on a single line (default: false)")),
 	)
 }
 
@@ -338,77 +565,1191 @@ func curlCommandHandler(db *core.Database) server.ToolHandlerFunc {
 			return nil, fmt.Errorf("failed to load database: %w", err)
 		}
 
-		r, ok := db.Data[name]
-		if !ok {
-			return mcp.NewToolResultError(fmt.Sprintf("request %q not found", name)), nil
+		r, err := db.Resolve(name)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		return mcp.NewToolResultText(r.CurlCommand()), nil
+		language := request.GetString("language", "curl")
+		var code string
+		if language == "curl" {
+			code, err = r.Curl(core.CurlOptions{
+				Shell:      request.GetString("shell", ""),
+				Secrets:    request.GetString("secrets", ""),
+				SingleLine: request.GetBool("single_line", false),
+			})
+		} else {
+			code, err = r.GenerateCodeWith(language, core.CodeOptions{Secrets: request.GetString("secrets", "")})
+		}
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(code), nil
 	}
 }
 
-// --- helpers ---
+// --- list_oauth_tokens ---
 
-func formatResponseResult(resp core.Response, outputFile string) (*mcp.CallToolResult, error) {
-	contentType := resp.Headers.Get("Content-Type")
-	if !core.IsTextContent(contentType) && contentType != "" {
-		result := map[string]interface{}{
-			"status":                resp.Status,
-			"statusCode":            resp.StatusCode,
-			"proto":                 resp.Proto,
-			"headers":               resp.Headers,
-			"contentType":           resp.ContentType,
-			"bodySize":              resp.BodySize,
-			"body":                  "[Binary content not included]",
-			"executionTimeMillisec": resp.ExecutionTimeMillisec,
+func listOAuthTokensTool() mcp.Tool {
+	return mcp.NewTool("list_oauth_tokens",
+		mcp.WithDescription("List cached OAuth 2.0 tokens with their token endpoint, client ID, scopes and expiry. Secrets are masked."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+	)
+}
+
+func listOAuthTokensHandler() server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tokens, err := core.Tokens.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load token cache: %w", err)
 		}
 
-		if outputFile != "" && resp.IsBinaryContent() {
-			if err := resp.SaveToFile(outputFile); err != nil {
-				result["saveError"] = err.Error()
-			} else {
-				result["savedTo"] = outputFile
+		type entry struct {
+			Key             string   `json:"key"`
+			TokenURL        string   `json:"tokenUrl"`
+			ClientID        string   `json:"clientId,omitempty"`
+			Scopes          []string `json:"scopes,omitempty"`
+			AccessToken     string   `json:"accessToken,omitempty"`
+			Expiry          string   `json:"expiry,omitempty"`
+			Expired         bool     `json:"expired"`
+			HasRefreshToken bool     `json:"hasRefreshToken"`
+		}
+
+		entries := make([]entry, 0, len(tokens))
+		for _, t := range tokens {
+			e := entry{
+				Key:             t.Key,
+				TokenURL:        t.TokenURL,
+				ClientID:        t.ClientID,
+				Scopes:          t.Scopes,
+				Expired:         t.AccessToken == "" || t.Expired(),
+				HasRefreshToken: t.RefreshToken != "",
 			}
+			if t.AccessToken != "" {
+				e.AccessToken = maskSecret(t.AccessToken)
+			}
+			if !t.Expiry.IsZero() {
+				e.Expiry = t.Expiry.Format(time.RFC3339)
+			}
+			entries = append(entries, e)
 		}
-		resp.Cleanup()
 
-		return mcp.NewToolResultJSON(result)
+		return mcp.NewToolResultJSON(map[string]interface{}{
+			"tokens": entries,
+		})
 	}
-	return mcp.NewToolResultJSON(resp)
 }
 
-func parseAuth(request mcp.CallToolRequest) *core.AuthConfig {
-	authType := request.GetString("auth_type", "")
-	if authType == "" {
-		return nil
+// --- clear_oauth_tokens ---
+
+func clearOAuthTokensTool() mcp.Tool {
+	return mcp.NewTool("clear_oauth_tokens",
+		mcp.WithDescription("Remove cached OAuth 2.0 tokens. Without key, the whole cache is cleared. The next request will fetch a new token."),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("key", mcp.Description("Key of a single token to remove, as returned by list_oauth_tokens")),
+	)
+}
+
+func clearOAuthTokensHandler() server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		key := request.GetString("key", "")
+		if err := core.Tokens.Clear(key); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if key == "" {
+			return mcp.NewToolResultText("OAuth token cache cleared"), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("OAuth token %q removed", key)), nil
 	}
-	switch authType {
-	case "bearer":
-		return &core.AuthConfig{
-			Type:  "bearer",
-			Token: request.GetString("auth_token", ""),
+}
+
+// --- list_auth_profiles ---
+
+func listAuthProfilesTool() mcp.Tool {
+	return mcp.NewTool("list_auth_profiles",
+		mcp.WithDescription("List the named auth profiles that saved requests can reference, with their type, masked secrets and the requests using them"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+	)
+}
+
+func listAuthProfilesHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
 		}
-	case "basic":
-		return &core.AuthConfig{
-			Type:     "basic",
-			Username: request.GetString("auth_username", ""),
-			Password: request.GetString("auth_password", ""),
+
+		type entry struct {
+			Name    string   `json:"name"`
+			Type    string   `json:"type"`
+			Summary []string `json:"summary"`
+			UsedBy  []string `json:"usedBy"`
 		}
-	case "api-key":
-		return &core.AuthConfig{
-			Type:   "api-key",
-			Key:    request.GetString("auth_key", ""),
-			Header: request.GetString("auth_header", "X-API-Key"),
+
+		names := db.ProfileNames()
+		entries := make([]entry, 0, len(names))
+		for _, name := range names {
+			auth := db.Profiles[name]
+			summary := core.AuthLines(&auth)
+			for i, line := range summary {
+				summary[i] = strings.Join(strings.Fields(line), " ")
+			}
+			entries = append(entries, entry{
+				Name:    name,
+				Type:    auth.Type,
+				Summary: summary,
+				UsedBy:  db.ProfileUsers(name),
+			})
 		}
-	default:
-		return nil
+
+		return mcp.NewToolResultJSON(map[string]interface{}{
+			"profiles": entries,
+		})
 	}
 }
 
-func parseOptionalJSON(request mcp.CallToolRequest, key string, target *map[string]interface{}) error {
-	str := request.GetString(key, "")
-	if str == "" {
-		return nil
+// --- save_auth_profile ---
+
+func saveAuthProfileTool() mcp.Tool {
+	opts := []mcp.ToolOption{
+		mcp.WithDescription("Create or replace a named auth profile. Every saved request referencing the profile (auth_profile) picks up the change, which makes credential rotation a single update."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Unique name for the auth profile")),
 	}
-	return json.Unmarshal([]byte(str), target)
+	// skip auth_profile, a profile cannot reference another one
+	return mcp.NewTool("save_auth_profile", append(opts, authOptions()[1:]...)...)
+}
+
+func saveAuthProfileHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: name"), nil
+		}
+		auth := parseAuth(request)
+		if auth == nil {
+			return mcp.NewToolResultError("missing or unsupported auth_type"), nil
+		}
+
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		if err := db.SaveProfile(name, *auth); err != nil {
+			return nil, fmt.Errorf("failed to save auth profile: %w", err)
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Auth profile %q saved successfully", name)), nil
+	}
+}
+
+// --- delete_auth_profile ---
+
+func deleteAuthProfileTool() mcp.Tool {
+	return mcp.NewTool("delete_auth_profile",
+		mcp.WithDescription("Delete a named auth profile. Fails while saved requests still reference it."),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Name of the auth profile to delete")),
+	)
+}
+
+func deleteAuthProfileHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: name"), nil
+		}
+
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		if err := db.DeleteProfile(name); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Auth profile %q deleted successfully", name)), nil
+	}
+}
+
+// --- get_history ---
+
+func getHistoryTool() mcp.Tool {
+	return mcp.NewTool("get_history",
+		mcp.WithDescription("List past executions, most recent first, or get the full details of one execution (request as sent, response status, headers, body, timings, error) by id"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("id", mcp.Description("History entry id, returns the full execution")),
+		mcp.WithString("name", mcp.Description("Only list the executions of this saved request")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of executions listed (default: 20)")),
+	)
+}
+
+func getHistoryHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if id := request.GetString("id", ""); id != "" {
+			entry, err := db.GetHistory(id)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			// Entries recorded by older versions hold the secrets in clear
+			entry.Request = core.MaskedRequest(entry.Request)
+			return mcp.NewToolResultJSON(entry)
+		}
+
+		entries, err := db.ListHistory(request.GetString("name", ""), request.GetInt("limit", 20))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+
+		type summary struct {
+			ID                    string    `json:"id"`
+			Time                  time.Time `json:"time"`
+			Name                  string    `json:"name,omitempty"`
+			Method                string    `json:"method"`
+			URL                   string    `json:"url"`
+			StatusCode            int       `json:"statusCode,omitempty"`
+			ExecutionTimeMillisec int64     `json:"executionTimeMillisec,omitempty"`
+			Error                 string    `json:"error,omitempty"`
+		}
+		summaries := make([]summary, 0, len(entries))
+		for _, e := range entries {
+			s := summary{ID: e.ID, Time: e.Time, Name: e.Name, Method: e.Request.Method, URL: e.Request.URL, Error: e.Error}
+			if e.Response != nil {
+				s.StatusCode = e.Response.StatusCode
+				s.ExecutionTimeMillisec = e.Response.ExecutionTimeMillisec
+			}
+			summaries = append(summaries, s)
+		}
+
+		return mcp.NewToolResultJSON(map[string]interface{}{
+			"executions": summaries,
+		})
+	}
+}
+
+// --- rerun_history ---
+
+func rerunHistoryTool() mcp.Tool {
+	return mcp.NewTool("rerun_history",
+		mcp.WithDescription("Send again a past execution exactly as it was sent (see get_history). The new execution is recorded in the history."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(true),
+		mcp.WithString("id", mcp.Required(), mcp.Description("History entry id")),
+		mcp.WithString("output_file", mcp.Description("File path to save binary response content")),
+	)
+}
+
+func rerunHistoryHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := request.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: id"), nil
+		}
+		entry, err := db.GetHistory(id)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		r, err := db.HistoryRequest(entry)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		resp, err := execute(db, entry.Name, r)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("HTTP request failed: %v", err)), nil
+		}
+
+		outputFile := request.GetString("output_file", "")
+		return formatResponseResult(resp, outputFile)
+	}
+}
+
+// --- import_curl ---
+
+func importCurlTool() mcp.Tool {
+	return mcp.NewTool("import_curl",
+		mcp.WithDescription("Convert a curl command (as copied from docs or browser devtools, multi-line commands supported) into a request. Supports -X, -H, -d/--data-raw/--data-binary/--data-urlencode, -F, -u, -k, --compressed. Local files are not read: -d @file and -F name=<file are rejected, -F name=@file is kept as a reference. The request is saved unless save is false; unsupported options are reported as warnings."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("command", mcp.Required(), mcp.Description("The curl command")),
+		mcp.WithString("name", mcp.Description("Name of the saved request (default: method and URL)")),
+		mcp.WithString("collection", mcp.Description("Collection folder path (e.g. \"shop/orders\")")),
+		mcp.WithBoolean("save", mcp.Description("Save the request (default: true); fails if the name is already used")),
+	)
+}
+
+func importCurlHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		command, err := request.RequireString("command")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: command"), nil
+		}
+		r, warnings, err := core.ParseCurl(command)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		r.Name = request.GetString("name", core.DefaultRequestName(r.Method, r.URL))
+		r.Collection = core.CleanCollection(request.GetString("collection", ""))
+
+		if request.GetBool("save", true) {
+			if err := db.Load(); err != nil {
+				return nil, fmt.Errorf("failed to load database: %w", err)
+			}
+			if err := db.Add(r); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		return mcp.NewToolResultJSON(map[string]interface{}{
+			"request":  r,
+			"warnings": warnings,
+		})
+	}
+}
+
+// --- list_revisions ---
+
+func listRevisionsTool() mcp.Tool {
+	return mcp.NewTool("list_revisions",
+		mcp.WithDescription("List the revisions of a saved request, most recent first. Every change (create, update, rename, delete, restore, rollback) records the request as it was after the change."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Name of the request")),
+	)
+}
+
+func listRevisionsHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: name"), nil
+		}
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		revisions, err := db.Revisions(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read revisions: %w", err)
+		}
+		if revisions == nil {
+			revisions = []core.Revision{}
+		}
+		for i := range revisions {
+			revisions[i].Request.Auth = core.MaskedAuth(revisions[i].Request.Auth)
+		}
+		return mcp.NewToolResultJSON(map[string]interface{}{
+			"revisions": revisions,
+		})
+	}
+}
+
+// --- diff_revisions ---
+
+func diffRevisionsTool() mcp.Tool {
+	return mcp.NewTool("diff_revisions",
+		mcp.WithDescription("Line diff of two revisions of a saved request (see list_revisions). Lines starting with \"- \" are only in from, \"+ \" only in to."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Name of the request")),
+		mcp.WithString("from", mcp.Required(), mcp.Description("Revision id")),
+		mcp.WithString("to", mcp.Description("Revision id (default: the current request)")),
+	)
+}
+
+func diffRevisionsHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: name"), nil
+		}
+		from, err := request.RequireString("from")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: from"), nil
+		}
+
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		diff, err := db.DiffRevisions(name, from, request.GetString("to", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(diff), nil
+	}
+}
+
+// --- rollback_request ---
+
+func rollbackRequestTool() mcp.Tool {
+	return mcp.NewTool("rollback_request",
+		mcp.WithDescription("Restore a saved request as it was at a revision (see list_revisions). Without revision, undo the last change."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Name of the request")),
+		mcp.WithString("revision", mcp.Description("Revision id")),
+	)
+}
+
+func rollbackRequestHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: name"), nil
+		}
+
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		if id := request.GetString("revision", ""); id != "" {
+			err = db.Rollback(name, id)
+		} else {
+			err = db.Undo(name)
+		}
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Request %q rolled back", name)), nil
+	}
+}
+
+// --- list_trash ---
+
+func listTrashTool() mcp.Tool {
+	return mcp.NewTool("list_trash",
+		mcp.WithDescription("List the deleted requests that can be restored with restore_request, most recently deleted first"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+	)
+}
+
+func listTrashHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		trash, err := db.Trash()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the trash: %w", err)
+		}
+
+		type entry struct {
+			Name      string    `json:"name"`
+			DeletedAt time.Time `json:"deletedAt"`
+			Method    string    `json:"method"`
+			URL       string    `json:"url"`
+		}
+		entries := make([]entry, 0, len(trash))
+		for _, rev := range trash {
+			entries = append(entries, entry{Name: rev.Name, DeletedAt: rev.Time, Method: rev.Request.Method, URL: rev.Request.URL})
+		}
+		return mcp.NewToolResultJSON(map[string]interface{}{
+			"trash": entries,
+		})
+	}
+}
+
+// --- restore_request ---
+
+func restoreRequestTool() mcp.Tool {
+	return mcp.NewTool("restore_request",
+		mcp.WithDescription("Restore a deleted request from the trash (see list_trash). Fails if another request now uses the same name."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Name of the deleted request")),
+	)
+}
+
+func restoreRequestHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: name"), nil
+		}
+
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		if err := db.Restore(name); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Request %q restored", name)), nil
+	}
+}
+
+// --- import_postman ---
+
+func importPostmanTool() mcp.Tool {
+	return mcp.NewTool("import_postman",
+		mcp.WithDescription("Import a Postman collection (v2.1) or a Postman environment. Folders become collection paths, collection variables become folder variables, bodies (raw, urlencoded, form-data, GraphQL) and auth (bearer, basic, digest, API key, OAuth 2.0, AWS) are mapped. Scripts, saved examples and other unsupported features are reported as warnings. Existing requests are skipped unless overwrite is true."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("content", mcp.Description("Content of the Postman file (either content or file is required)")),
+		mcp.WithString("file", mcp.Description("Path of the Postman file")),
+		mcp.WithString("collection", mcp.Description("Collection folder receiving the requests (default: the Postman collection name)")),
+		mcp.WithBoolean("overwrite", mcp.Description("Replace the requests and environments with the same name (default: false)")),
+	)
+}
+
+func importPostmanHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		data, err := importSource(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		imp, err := core.ImportPostman(data, request.GetString("collection", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		report, err := db.SaveImport(imp, request.GetBool("overwrite", false))
+		if err != nil {
+			return nil, fmt.Errorf("failed to save database: %w", err)
+		}
+		return mcp.NewToolResultJSON(report)
+	}
+}
+
+// --- export_postman ---
+
+func exportPostmanTool() mcp.Tool {
+	return mcp.NewTool("export_postman",
+		mcp.WithDescription("Export saved requests as a Postman collection v2.1, or an environment as a Postman environment. Folder headers and base URLs are applied to each request and auth profiles are inlined."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("collection", mcp.Description("Collection folder to export (default: every request)")),
+		mcp.WithString("environment", mcp.Description("Export this environment instead of requests")),
+		mcp.WithString("output_file", mcp.Description("File to write; the content is returned when omitted")),
+	)
+}
+
+func exportPostmanHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		var (
+			data     []byte
+			warnings []string
+			err      error
+		)
+		if env := request.GetString("environment", ""); env != "" {
+			data, err = db.ExportPostmanEnvironment(env)
+		} else {
+			data, warnings, err = db.ExportPostman(request.GetString("collection", ""))
+		}
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return exportResult(data, warnings, request.GetString("output_file", ""))
+	}
+}
+
+// --- import_openapi ---
+
+func importOpenAPITool() mcp.Tool {
+	return mcp.NewTool("import_openapi",
+		mcp.WithDescription("Create one request per operation of an OpenAPI 3.x or Swagger 2 spec (JSON or YAML), in folders named after the tags. Path parameters become {{variables}}, servers become environments providing {{baseUrl}}, security schemes become auth with {{token}}-style placeholders, and bodies are generated from the examples or schemas. Re-importing a spec updates the requests but keeps the fields edited locally; the report lists them."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("content", mcp.Description("Content of the spec (either content or file is required)")),
+		mcp.WithString("file", mcp.Description("Path of the spec")),
+		mcp.WithString("collection", mcp.Description("Collection folder receiving the requests (default: the title of the spec)")),
+	)
+}
+
+func importOpenAPIHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		data, err := importSource(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		imp, err := core.ImportOpenAPI(data, request.GetString("collection", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		report, err := db.SaveImport(imp, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save database: %w", err)
+		}
+		return mcp.NewToolResultJSON(report)
+	}
+}
+
+// --- import_har ---
+
+func importHARTool() mcp.Tool {
+	return mcp.NewTool("import_har",
+		mcp.WithDescription("Turn the entries of a HAR file (saved from browser devtools) into requests, one folder per host. Browser-managed headers are dropped and repeated calls are imported once. Existing requests are skipped unless overwrite is true."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("content", mcp.Description("Content of the HAR file (either content or file is required)")),
+		mcp.WithString("file", mcp.Description("Path of the HAR file")),
+		mcp.WithString("collection", mcp.Description("Collection folder receiving the host folders (default: \"har\")")),
+		mcp.WithArray("domains", mcp.WithStringItems(), mcp.Description("Only import these domains, subdomains included")),
+		mcp.WithArray("methods", mcp.WithStringItems(), mcp.Description("Only import these HTTP methods")),
+		mcp.WithArray("content_types", mcp.WithStringItems(), mcp.Description("Only import the entries whose response MIME type starts with one of these, e.g. application/json")),
+		mcp.WithBoolean("overwrite", mcp.Description("Replace the requests with the same name (default: false)")),
+	)
+}
+
+func importHARHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		data, err := importSource(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		filter := core.HARFilter{
+			Domains:      request.GetStringSlice("domains", nil),
+			Methods:      request.GetStringSlice("methods", nil),
+			ContentTypes: request.GetStringSlice("content_types", nil),
+		}
+		imp, err := core.ImportHAR(data, request.GetString("collection", ""), filter)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		report, err := db.SaveImport(imp, request.GetBool("overwrite", false))
+		if err != nil {
+			return nil, fmt.Errorf("failed to save database: %w", err)
+		}
+		return mcp.NewToolResultJSON(report)
+	}
+}
+
+// --- export_har ---
+
+func exportHARTool() mcp.Tool {
+	return mcp.NewTool("export_har",
+		mcp.WithDescription("Export recorded executions, with their timings, as a HAR 1.2 file that browser devtools and other analyzers can open."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Description("Only export the executions of this request")),
+		mcp.WithNumber("limit", mcp.Description("Most recent executions to export (default: 100, 0 for all)")),
+		mcp.WithString("output_file", mcp.Description("File to write; the content is returned when omitted")),
+	)
+}
+
+func exportHARHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		entries, err := db.ListHistory(request.GetString("name", ""), request.GetInt("limit", 100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		data, err := core.ExportHAR(entries, "1.0.0")
+		if err != nil {
+			return nil, err
+		}
+		return exportResult(data, nil, request.GetString("output_file", ""))
+	}
+}
+
+// --- import_http_file ---
+
+func importHTTPFileTool() mcp.Tool {
+	return mcp.NewTool("import_http_file",
+		mcp.WithDescription("Import the requests of a .http / .rest file (VS Code REST Client, JetBrains HTTP client). Requests are named after their # @name or ### title, file variables (@name = value) become variables of the collection folder. Existing requests are skipped unless overwrite is true."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("content", mcp.Description("Content of the file (either content or file is required)")),
+		mcp.WithString("file", mcp.Description("Path of the .http file")),
+		mcp.WithString("collection", mcp.Description("Collection folder receiving the requests (default: \"http\")")),
+		mcp.WithBoolean("overwrite", mcp.Description("Replace the requests with the same name (default: false)")),
+	)
+}
+
+func importHTTPFileHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		data, err := importSource(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		imp, err := core.ImportHTTPFile(data, request.GetString("collection", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		report, err := db.SaveImport(imp, request.GetBool("overwrite", false))
+		if err != nil {
+			return nil, fmt.Errorf("failed to save database: %w", err)
+		}
+		return mcp.NewToolResultJSON(report)
+	}
+}
+
+// --- export_http_file ---
+
+func exportHTTPFileTool() mcp.Tool {
+	return mcp.NewTool("export_http_file",
+		mcp.WithDescription("Export saved requests as a .http file, runnable by the VS Code REST Client and the JetBrains HTTP client. Folder variables become file variables, folder defaults are applied to each request and auth profiles are inlined."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("collection", mcp.Description("Collection folder to export (default: every request)")),
+		mcp.WithString("output_file", mcp.Description("File to write; the content is returned when omitted")),
+	)
+}
+
+func exportHTTPFileHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		data, warnings, err := db.ExportHTTPFile(request.GetString("collection", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return exportResult(data, warnings, request.GetString("output_file", ""))
+	}
+}
+
+// --- run_http_file ---
+
+func runHTTPFileTool() mcp.Tool {
+	return mcp.NewTool("run_http_file",
+		mcp.WithDescription("Run a request of a .http / .rest file without saving it, or list the requests of the file when name is omitted. File variables override those of the environment."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(true),
+		mcp.WithString("file", mcp.Required(), mcp.Description("Path of the .http file; bodies given as < path are relative to it")),
+		mcp.WithString("name", mcp.Description("Name of the request to run, its # @name or ### title")),
+		mcp.WithString("environment", mcp.Description("Environment providing the variables not defined in the file (default: the active one)")),
+		mcp.WithString("output_file", mcp.Description("File path to save binary response content. Only used for binary responses.")),
+	)
+}
+
+func runHTTPFileHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path, err := request.RequireString("file")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: file"), nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		file, err := core.ParseHTTPFile(data, filepath.Dir(path))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		name := request.GetString("name", "")
+		if name == "" {
+			requests := make([]map[string]string, 0, len(file.Requests))
+			for _, r := range file.Requests {
+				requests = append(requests, map[string]string{"name": r.Name, "method": r.Method, "url": r.URL})
+			}
+			return mcp.NewToolResultJSON(map[string]interface{}{
+				"requests":  requests,
+				"variables": file.Variables,
+				"warnings":  file.Warnings,
+			})
+		}
+
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		env := request.GetString("environment", db.Environment)
+		vars := map[string]string{}
+		if env != "" {
+			environment, ok := db.Environments[env]
+			if !ok {
+				return mcp.NewToolResultError(fmt.Sprintf("environment %q not found", env)), nil
+			}
+			vars = environment.Variables
+		}
+		r, err := file.Request(name, vars)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		// Recorded apart from a saved request with the same name
+		resp, err := execute(db, filepath.Base(path)+"#"+name, r)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("HTTP request failed: %v", err)), nil
+		}
+		return formatResponseResult(resp, request.GetString("output_file", ""))
+	}
+}
+
+// --- run_collection ---
+
+func runCollectionTool() mcp.Tool {
+	return mcp.NewTool("run_collection",
+		mcp.WithDescription("Run the requests of a collection as a test suite with the active environment, evaluate their assertions and return the report. A request without assertions passes when its status is below 400."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(true),
+		mcp.WithString("collection", mcp.Description("Collection folder path, including its subfolders (default: every request)")),
+		mcp.WithString("tag", mcp.Description("Only run the requests carrying this tag")),
+		mcp.WithNumber("parallel", mcp.Description("Requests sent at the same time (default: 1, in the order of the collection)")),
+		mcp.WithBoolean("bail", mcp.Description("Stop at the first failure (default: false)")),
+		mcp.WithString("format", mcp.Enum("json", "junit", "tap"), mcp.Description("Report format (default: json)")),
+	)
+}
+
+func runCollectionHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+
+		report, err := db.RunCollection(request.GetString("collection", ""), core.RunOptions{
+			Tag:      request.GetString("tag", ""),
+			Parallel: request.GetInt("parallel", 1),
+			Bail:     request.GetBool("bail", false),
+		}, nil)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		switch request.GetString("format", "json") {
+		case "junit":
+			data, err := report.JUnit()
+			if err != nil {
+				return nil, err
+			}
+			return mcp.NewToolResultText(string(data)), nil
+		case "tap":
+			return mcp.NewToolResultText(string(report.TAP())), nil
+		}
+		return mcp.NewToolResultJSON(report)
+	}
+}
+
+// --- list_environments ---
+
+func listEnvironmentsTool() mcp.Tool {
+	return mcp.NewTool("list_environments",
+		mcp.WithDescription("List the environments and their variables. The variables of the active environment replace the {{name}} references of the requests, overriding folder variables."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+	)
+}
+
+func listEnvironmentsHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		return mcp.NewToolResultJSON(map[string]interface{}{
+			"active":       db.Environment,
+			"environments": db.Environments,
+		})
+	}
+}
+
+// --- save_environment ---
+
+func saveEnvironmentTool() mcp.Tool {
+	return mcp.NewTool("save_environment",
+		mcp.WithDescription("Create or replace an environment."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Name of the environment")),
+		mcp.WithString("variables", mcp.Required(), mcp.Description("Variables as a JSON object of strings, e.g. {\"baseUrl\": \"http://localhost:8080\"}")),
+		mcp.WithBoolean("activate", mcp.Description("Activate the environment (default: false)")),
+	)
+}
+
+func saveEnvironmentHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: name"), nil
+		}
+		var env core.Environment
+		if err := json.Unmarshal([]byte(request.GetString("variables", "")), &env.Variables); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid variables JSON: %v", err)), nil
+		}
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		if err := db.SaveEnvironment(name, env); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if request.GetBool("activate", false) {
+			if err := db.UseEnvironment(name); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Environment %q saved", name)), nil
+	}
+}
+
+// --- set_environment ---
+
+func setEnvironmentTool() mcp.Tool {
+	return mcp.NewTool("set_environment",
+		mcp.WithDescription("Activate an environment, or deactivate the active one with an empty name."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Description("Name of the environment, empty to deactivate")),
+	)
+}
+
+func setEnvironmentHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.GetString("name", "")
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		if err := db.UseEnvironment(name); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if name == "" {
+			return mcp.NewToolResultText("No active environment"), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Environment %q activated", name)), nil
+	}
+}
+
+// --- delete_environment ---
+
+func deleteEnvironmentTool() mcp.Tool {
+	return mcp.NewTool("delete_environment",
+		mcp.WithDescription("Delete an environment, deactivating it when active."),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Name of the environment")),
+	)
+}
+
+func deleteEnvironmentHandler(db *core.Database) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("missing required parameter: name"), nil
+		}
+		if err := db.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+		if err := db.DeleteEnvironment(name); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Environment %q deleted", name)), nil
+	}
+}
+
+// --- helpers ---
+
+// execute sends a resolved request and records it in the history
+func execute(db *core.Database, name string, r core.Request) (core.Response, error) {
+	resp, err := r.CallHTTP()
+	var recorded *core.Response
+	if err == nil {
+		recorded = &resp
+	}
+	if _, recordErr := db.RecordHistory(name, r, recorded, err); recordErr != nil {
+		fmt.Fprintf(os.Stderr, "failed to record history: %v\n", recordErr)
+	}
+	if _, saved := db.Data[name]; saved && err == nil {
+		if touchErr := db.Touch(name); touchErr != nil {
+			fmt.Fprintf(os.Stderr, "failed to save database: %v\n", touchErr)
+		}
+	}
+	return resp, err
+}
+
+func authOptions() []mcp.ToolOption {
+	return []mcp.ToolOption{
+		mcp.WithString("auth_profile", mcp.Description("Name of a saved auth profile to use instead of the auth_* fields (see list_auth_profiles)")),
+		mcp.WithString("auth_type", mcp.Description("Authentication type: bearer, basic, digest, api-key, oauth2, aws-sigv4, hmac or jwt")),
+		mcp.WithString("auth_token", mcp.Description("Bearer token (when auth_type is bearer)")),
+		mcp.WithString("auth_username", mcp.Description("Username (when auth_type is basic or digest)")),
+		mcp.WithString("auth_password", mcp.Description("Password (when auth_type is basic or digest)")),
+		mcp.WithString("auth_key", mcp.Description("API key value (when auth_type is api-key)")),
+		mcp.WithString("auth_header", mcp.Description("Header name for API key (default: X-API-Key, when auth_type is api-key), for the HMAC signature (default: X-Signature) or for the JWT (default: Authorization: Bearer)")),
+		mcp.WithString("auth_grant_type", mcp.Description("OAuth 2.0 grant: client_credentials (default), refresh_token or authorization_code (when auth_type is oauth2). authorization_code requests need an interactive login from the tanker CLI before they can be sent.")),
+		mcp.WithString("auth_authorize_url", mcp.Description("OAuth 2.0 authorize endpoint (when auth_grant_type is authorization_code)")),
+		mcp.WithString("auth_redirect_url", mcp.Description("Loopback redirect URL registered for the client (default: http://127.0.0.1:8085/callback, when auth_grant_type is authorization_code)")),
+		mcp.WithString("auth_token_url", mcp.Description("OAuth 2.0 token endpoint (when auth_type is oauth2)")),
+		mcp.WithString("auth_client_id", mcp.Description("OAuth 2.0 client ID (when auth_type is oauth2)")),
+		mcp.WithString("auth_client_secret", mcp.Description("OAuth 2.0 client secret (when auth_type is oauth2)")),
+		mcp.WithString("auth_scopes", mcp.Description("OAuth 2.0 scopes, space separated (when auth_type is oauth2)")),
+		mcp.WithString("auth_audience", mcp.Description("OAuth 2.0 audience (when auth_type is oauth2)")),
+		mcp.WithString("auth_refresh_token", mcp.Description("OAuth 2.0 refresh token (when auth_grant_type is refresh_token)")),
+		mcp.WithString("auth_aws_service", mcp.Description("AWS service name to sign for, e.g. execute-api, s3, es (when auth_type is aws-sigv4)")),
+		mcp.WithString("auth_aws_region", mcp.Description("AWS region (default: AWS_REGION, when auth_type is aws-sigv4)")),
+		mcp.WithString("auth_aws_access_key", mcp.Description("AWS access key ID. Leave empty to use the AWS environment variables or shared credentials file (when auth_type is aws-sigv4)")),
+		mcp.WithString("auth_aws_secret_key", mcp.Description("AWS secret access key (when auth_type is aws-sigv4)")),
+		mcp.WithString("auth_aws_session_token", mcp.Description("AWS session token for temporary credentials (when auth_type is aws-sigv4)")),
+		mcp.WithString("auth_aws_profile", mcp.Description("Profile of the shared credentials file (when auth_type is aws-sigv4 without access key)")),
+		mcp.WithString("auth_secret", mcp.Description("Shared secret (when auth_type is hmac, or jwt with an HS* algorithm)")),
+		mcp.WithString("auth_algorithm", mcp.Description("hmac: sha256 (default), sha1 or sha512. jwt: HS256 (default), HS384, HS512, RS256, RS384, RS512, PS256, ES256, ES384 or EdDSA")),
+		mcp.WithString("auth_template", mcp.Description("HMAC canonical string template with placeholders {method}, {path}, {query}, {host}, {timestamp}, {nonce}, {body}, {bodySha256} (default: {method}\\n{path}\\n{timestamp}\\n{body})")),
+		mcp.WithString("auth_encoding", mcp.Description("HMAC signature encoding: hex (default), base64 or base64url")),
+		mcp.WithString("auth_signature_prefix", mcp.Description("Prefix prepended to the HMAC signature, e.g. sha256=")),
+		mcp.WithString("auth_timestamp_header", mcp.Description("Header carrying the signed timestamp (when auth_type is hmac)")),
+		mcp.WithString("auth_nonce_header", mcp.Description("Header carrying the signed nonce (when auth_type is hmac)")),
+		mcp.WithString("auth_key_file", mcp.Description("Path of the PEM private key, or of the secret for HS* (when auth_type is jwt)")),
+		mcp.WithString("auth_key_id", mcp.Description("JWT kid header (when auth_type is jwt)")),
+		mcp.WithString("auth_claims", mcp.Description("JWT claims JSON template with placeholders {now}, {exp}, {jti} (when auth_type is jwt)")),
+		mcp.WithString("auth_ttl", mcp.Description("JWT lifetime as a Go duration, e.g. 5m (default), when auth_type is jwt")),
+	}
+}
+
+func maskSecret(s string) string {
+	if len(s) <= 4 {
+		return "****"
+	}
+	return s[:4] + "****"
+}
+
+func formatResponseResult(resp core.Response, outputFile string) (*mcp.CallToolResult, error) {
+	contentType := resp.Headers.Get("Content-Type")
+	if !core.IsTextContent(contentType) && contentType != "" {
+		result := map[string]interface{}{
+			"status":                resp.Status,
+			"statusCode":            resp.StatusCode,
+			"proto":                 resp.Proto,
+			"headers":               resp.Headers,
+			"contentType":           resp.ContentType,
+			"bodySize":              resp.BodySize,
+			"body":                  "[Binary content not included]",
+			"executionTimeMillisec": resp.ExecutionTimeMillisec,
+		}
+
+		if outputFile != "" && resp.IsBinaryContent() {
+			if err := resp.SaveToFile(outputFile); err != nil {
+				result["saveError"] = err.Error()
+			} else {
+				result["savedTo"] = outputFile
+			}
+		}
+		resp.Cleanup()
+
+		return mcp.NewToolResultJSON(result)
+	}
+	return mcp.NewToolResultJSON(resp)
+}
+
+func parseAuth(request mcp.CallToolRequest) *core.AuthConfig {
+	authType := request.GetString("auth_type", "")
+	if authType == "" {
+		return nil
+	}
+	switch authType {
+	case "bearer":
+		return &core.AuthConfig{
+			Type:  "bearer",
+			Token: request.GetString("auth_token", ""),
+		}
+	case "basic", "digest":
+		return &core.AuthConfig{
+			Type:     authType,
+			Username: request.GetString("auth_username", ""),
+			Password: request.GetString("auth_password", ""),
+		}
+	case "api-key":
+		return &core.AuthConfig{
+			Type:   "api-key",
+			Key:    request.GetString("auth_key", ""),
+			Header: request.GetString("auth_header", "X-API-Key"),
+		}
+	case "oauth2":
+		return &core.AuthConfig{
+			Type:         "oauth2",
+			GrantType:    request.GetString("auth_grant_type", "client_credentials"),
+			TokenURL:     request.GetString("auth_token_url", ""),
+			ClientID:     request.GetString("auth_client_id", ""),
+			ClientSecret: request.GetString("auth_client_secret", ""),
+			Scopes:       strings.Fields(strings.ReplaceAll(request.GetString("auth_scopes", ""), ",", " ")),
+			Audience:     request.GetString("auth_audience", ""),
+			RefreshToken: request.GetString("auth_refresh_token", ""),
+			AuthURL:      request.GetString("auth_authorize_url", ""),
+			RedirectURL:  request.GetString("auth_redirect_url", ""),
+		}
+	case "aws-sigv4":
+		return &core.AuthConfig{
+			Type:         "aws-sigv4",
+			Service:      request.GetString("auth_aws_service", ""),
+			Region:       request.GetString("auth_aws_region", ""),
+			AccessKey:    request.GetString("auth_aws_access_key", ""),
+			SecretKey:    request.GetString("auth_aws_secret_key", ""),
+			SessionToken: request.GetString("auth_aws_session_token", ""),
+			Profile:      request.GetString("auth_aws_profile", ""),
+		}
+	case "hmac":
+		return &core.AuthConfig{
+			Type:            "hmac",
+			Secret:          request.GetString("auth_secret", ""),
+			Algorithm:       request.GetString("auth_algorithm", ""),
+			Template:        request.GetString("auth_template", ""),
+			Encoding:        request.GetString("auth_encoding", ""),
+			Header:          request.GetString("auth_header", ""),
+			SignaturePrefix: request.GetString("auth_signature_prefix", ""),
+			TimestampHeader: request.GetString("auth_timestamp_header", ""),
+			NonceHeader:     request.GetString("auth_nonce_header", ""),
+		}
+	case "jwt":
+		return &core.AuthConfig{
+			Type:      "jwt",
+			Secret:    request.GetString("auth_secret", ""),
+			Algorithm: request.GetString("auth_algorithm", ""),
+			KeyFile:   request.GetString("auth_key_file", ""),
+			KeyID:     request.GetString("auth_key_id", ""),
+			Claims:    request.GetString("auth_claims", ""),
+			TTL:       request.GetString("auth_ttl", ""),
+			Header:    request.GetString("auth_header", ""),
+		}
+	default:
+		return nil
+	}
+}
+
+func parseOptionalJSON(request mcp.CallToolRequest, key string, target *map[string]interface{}) error {
+	str := request.GetString(key, "")
+	if str == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(str), target)
+}
+
+// importSource returns the content argument, or the content of the file argument
+func importSource(request mcp.CallToolRequest) ([]byte, error) {
+	if content := request.GetString("content", ""); content != "" {
+		return []byte(content), nil
+	}
+	file := request.GetString("file", "")
+	if file == "" {
+		return nil, fmt.Errorf("either content or file is required")
+	}
+	return os.ReadFile(file)
+}
+
+// exportResult writes an export to outputFile, or returns it when empty
+func exportResult(data []byte, warnings []string, outputFile string) (*mcp.CallToolResult, error) {
+	if outputFile == "" {
+		if len(warnings) == 0 {
+			return mcp.NewToolResultText(string(data)), nil
+		}
+		return mcp.NewToolResultJSON(map[string]interface{}{
+			"content":  string(data),
+			"warnings": warnings,
+		})
+	}
+	if err := os.WriteFile(outputFile, data, 0600); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to write %s: %v", outputFile, err)), nil
+	}
+	return mcp.NewToolResultJSON(map[string]interface{}{
+		"file":     outputFile,
+		"bytes":    len(data),
+		"warnings": warnings,
+	})
 }
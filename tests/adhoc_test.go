@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestParseAdHoc(t *testing.T) {
+	dir := t.TempDir()
+	tags := filepath.Join(dir, "tags.json")
+	os.WriteFile(tags, []byte(`["a","b"]`), 0600)
+
+	r, err := core.ParseAdHoc([]string{"post", ":8080/users", "name=bob", "age:=42", "tags:=@" + tags, `Authorization:Bearer x`, "dry==true", `a\=b=c`}, core.AdHocOptions{})
+	if err != nil {
+		t.Fatalf("ParseAdHoc failed: %v", err)
+	}
+	if r.Method != "POST" || r.URL != "http://localhost:8080/users" || r.Params["dry"] != "true" {
+		t.Fatalf("unexpected request: %+v", r)
+	}
+	if r.Headers["Authorization"] != "Bearer x" || r.Headers["Content-Type"] != "application/json" {
+		t.Fatalf("unexpected headers: %v", r.Headers)
+	}
+	if r.Payload["name"] != "bob" || r.Payload["age"] != 42.0 || len(r.Payload["tags"].([]interface{})) != 2 || r.Payload["a=b"] != "c" {
+		t.Fatalf("unexpected payload: %v", r.Payload)
+	}
+
+	// Data items make a POST, a JSON body is sent raw with other methods
+	if r, _ := core.ParseAdHoc([]string{"example.com/x", "q=1"}, core.AdHocOptions{}); r.Method != "POST" || r.URL != "http://example.com/x" {
+		t.Fatalf("unexpected request: %+v", r)
+	}
+	if r, _ := core.ParseAdHoc([]string{"DELETE", ":/x", "id:=1"}, core.AdHocOptions{}); r.BodyType != "raw" || r.Body != `{"id":1}` || r.URL != "http://localhost/x" {
+		t.Fatalf("unexpected body: %+v", r)
+	}
+
+	upload := filepath.Join(dir, "cat.png")
+	os.WriteFile(upload, []byte("png"), 0600)
+	r, err = core.ParseAdHoc([]string{":8080/upload", "title=cat", "image@" + upload + ";type=image/png", "X-Empty;"}, core.AdHocOptions{})
+	if err != nil {
+		t.Fatalf("ParseAdHoc failed: %v", err)
+	}
+	if r.BodyType != "multipart" || len(r.Form) != 2 || r.Form[1].File != upload || r.Form[1].ContentType != "image/png" || r.Headers["X-Empty"] != "" {
+		t.Fatalf("unexpected multipart: %+v", r)
+	}
+	if r, _ := core.ParseAdHoc([]string{":8080", "q=a b"}, core.AdHocOptions{Form: true}); r.BodyType != "form" || r.Form[0].Value != "a b" {
+		t.Fatalf("unexpected form: %+v", r)
+	}
+
+	r, err = core.ParseAdHoc([]string{"PUT", ":8080/raw"}, core.AdHocOptions{Body: []byte(`{"raw":true}`)})
+	if err != nil || r.Body != `{"raw":true}` || r.Headers["Content-Type"] != "application/json" {
+		t.Fatalf("unexpected stdin body: %v %+v", err, r)
+	}
+
+	invalid := []struct {
+		args []string
+		opts core.AdHocOptions
+	}{
+		{[]string{"GET"}, core.AdHocOptions{}},
+		{[]string{":8080", "nothing"}, core.AdHocOptions{}},
+		{[]string{":8080", "n:={bad"}, core.AdHocOptions{}},
+		{[]string{":8080", "f@/does/not/exist"}, core.AdHocOptions{}},
+		{[]string{":8080", "n:=1"}, core.AdHocOptions{Form: true}},
+		{[]string{":8080", "a=1"}, core.AdHocOptions{Body: []byte("x")}},
+	}
+	for _, c := range invalid {
+		if _, err := core.ParseAdHoc(c.args, c.opts); err == nil {
+			t.Errorf("expected an error for %v %+v", c.args, c.opts)
+		}
+	}
+
+	if !core.IsAdHocRequest([]string{"POST", ":8080"}) || !core.IsAdHocRequest([]string{":8080/x"}) || core.IsAdHocRequest([]string{"list"}) {
+		t.Fatal("IsAdHocRequest misdetects the arguments")
+	}
+	// Every URL detected is understood the same way by ParseAdHoc
+	urls := map[string]string{
+		"example.com/x":         "http://example.com/x",
+		"localhost:3000?q=1":    "http://localhost:3000?q=1",
+		"api:8080/health":       "http://api:8080/health",
+		"[::1]:8080/x":          "http://[::1]:8080/x",
+		"https://example.com":   "https://example.com",
+		"{{base}}/users":        "{{base}}/users",
+		"sub.example.co.uk:443": "http://sub.example.co.uk:443",
+	}
+	for arg, expected := range urls {
+		if !core.IsAdHocRequest([]string{arg}) {
+			t.Errorf("%s not detected as an ad-hoc request", arg)
+		}
+		if r, err := core.ParseAdHoc([]string{"GET", arg}, core.AdHocOptions{}); err != nil || r.URL != expected {
+			t.Errorf("%s: unexpected URL %q (%v), expected %s", arg, r.URL, err, expected)
+		}
+	}
+	for _, arg := range []string{"users", "my request", "./file.json"} {
+		if core.IsAdHocRequest([]string{arg}) {
+			t.Errorf("%s detected as an ad-hoc request", arg)
+		}
+		if _, err := core.ParseAdHoc([]string{"GET", arg}, core.AdHocOptions{}); err == nil {
+			t.Errorf("expected an error for the URL %s", arg)
+		}
+	}
+}
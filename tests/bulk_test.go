@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestDuplicate(t *testing.T) {
+	database := openRevisionsDatabase(t)
+
+	name := database.CopyName("get-example")
+	if name != "get-example copy" {
+		t.Fatalf("unexpected copy name %q", name)
+	}
+	if err := database.Duplicate("get-example", name); err != nil {
+		t.Fatalf("Duplicate failed: %v", err)
+	}
+	if database.CopyName("get-example") != "get-example copy 2" {
+		t.Fatalf("copy name not incremented")
+	}
+	if err := database.Duplicate("get-example", "post-example"); err == nil {
+		t.Fatal("duplicate over an existing request must fail")
+	}
+
+	// The copy does not share its maps with the original
+	copy := database.Data[name]
+	copy.Headers["X-Copy"] = "1"
+	if _, shared := database.Data["get-example"].Headers["X-Copy"]; shared {
+		t.Fatal("copy shares its headers with the original")
+	}
+}
+
+func TestBulkOperations(t *testing.T) {
+	database := openRevisionsDatabase(t)
+	names := []string{"get-example", "post-example"}
+
+	if err := database.MoveRequests(append(names, "missing"), "shop"); err == nil {
+		t.Fatal("unknown names must be refused")
+	}
+	if database.Data["get-example"].Collection != "" {
+		t.Fatal("refused move was half applied")
+	}
+
+	if err := database.MoveRequests(names, "/shop/orders/"); err != nil {
+		t.Fatalf("MoveRequests failed: %v", err)
+	}
+	if err := database.TagRequests(names, []string{"smoke", "billing"}, nil); err != nil {
+		t.Fatalf("TagRequests failed: %v", err)
+	}
+	if err := database.TagRequests(names[:1], nil, []string{"SMOKE"}); err != nil {
+		t.Fatalf("TagRequests failed: %v", err)
+	}
+	for _, name := range names {
+		if database.Data[name].Collection != "shop/orders" {
+			t.Fatalf("%s not moved: %q", name, database.Data[name].Collection)
+		}
+	}
+	if tags := database.Data["get-example"].Tags; len(tags) != 1 || tags[0] != "billing" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if tags := database.Data["post-example"].Tags; len(tags) != 2 {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	database.SaveFolder("shop", core.Folder{BaseURL: "https://shop.example.com"})
+	content, err := database.ExportRequests(names)
+	if err != nil {
+		t.Fatalf("ExportRequests failed: %v", err)
+	}
+	var exported struct {
+		Version  int                     `json:"version"`
+		Requests map[string]core.Request `json:"requests"`
+		Folders  map[string]core.Folder  `json:"folders"`
+	}
+	if err := json.Unmarshal(content, &exported); err != nil {
+		t.Fatalf("invalid export: %v", err)
+	}
+	if exported.Version != core.SchemaVersion || len(exported.Requests) != 2 || exported.Folders["shop"].BaseURL == "" {
+		t.Fatalf("unexpected export: %s", content)
+	}
+
+	if err := database.DeleteRequests(names); err != nil {
+		t.Fatalf("DeleteRequests failed: %v", err)
+	}
+	if trash, _ := database.Trash(); len(trash) != 2 {
+		t.Fatalf("expected 2 requests in the trash, got %d", len(trash))
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestGenerateCode(t *testing.T) {
+	r := core.Request{
+		Method:   "POST",
+		URL:      "https://api.example.com/users",
+		Params:   map[string]interface{}{"dry": "true"},
+		Headers:  map[string]interface{}{"X-Trace": "it's"},
+		Payload:  map[string]interface{}{"name": "bob", "age": 42.0, "admin": false},
+		Auth:     &core.AuthConfig{Type: "bearer", Token: "t0k3n"},
+		Insecure: true,
+	}
+	expected := map[string][]string{
+		"go":         {`"crypto/tls"`, `http.NewRequest("POST", "https://api.example.com/users?dry=true", body)`, `req.Header.Set("Authorization", "Bearer t0k3n")`, `"age": 42`, "InsecureSkipVerify: true"},
+		"python":     {`"dry": "true",`, `"X-Trace": "it's",`, `"admin": False,`, "json=payload", "verify=False"},
+		"fetch":      {`fetch("https://api.example.com/users?dry=true"`, `"Authorization": "Bearer t0k3n",`, "JSON.stringify(", "NODE_TLS_REJECT_UNAUTHORIZED=0"},
+		"axios":      {`method: "post"`, `"dry": "true",`, `"name": "bob"`, "rejectUnauthorized: false"},
+		"httpie":     {"--verify=no", `'X-Trace:it'\''s'`, "dry==true", "age:=42", "admin:=false", "name=bob"},
+		"wget":       {"--method=POST", "--no-check-certificate", `--header='X-Trace: it'\''s'`, `"name": "bob"`},
+		"powershell": {"-Method Post", `'X-Trace' = 'it''s'`, "@'\n{", "-SkipCertificateCheck"},
+	}
+	for language, fragments := range expected {
+		code, err := r.GenerateCode(language)
+		if err != nil {
+			t.Fatalf("GenerateCode(%s) failed: %v", language, err)
+		}
+		for _, fragment := range fragments {
+			if !strings.Contains(code, fragment) {
+				t.Errorf("%s: missing %q in:\n%s", language, fragment, code)
+			}
+		}
+	}
+
+	if _, err := r.GenerateCode("cobol"); err == nil {
+		t.Fatal("expected an error for an unknown language")
+	}
+	if len(core.CodeLanguages) != 8 || core.CodeLanguages[0].Name != "curl" {
+		t.Fatalf("unexpected languages: %v", core.CodeLanguageNames())
+	}
+}
+
+func TestGenerateCodeBodiesAndAuth(t *testing.T) {
+	form := core.Request{
+		Method:   "PUT",
+		URL:      "http://localhost/form",
+		BodyType: "form",
+		Form:     []core.FormField{{Name: "q", Value: "a b"}},
+		Auth:     &core.AuthConfig{Type: "basic", Username: "bob", Password: "s3cret"},
+	}
+	multipart := core.Request{
+		Method:   "POST",
+		URL:      "http://localhost/upload",
+		BodyType: "multipart",
+		Form:     []core.FormField{{Name: "title", Value: "cat"}, {Name: "image", File: "/tmp/cat.png", ContentType: "image/png"}},
+		Auth:     &core.AuthConfig{Type: "digest", Username: "bob", Password: "s3cret"},
+	}
+	terminator := core.Request{Method: "POST", URL: "http://localhost/raw", BodyType: "raw", Body: "'@ x"}
+	signed := core.Request{Method: "GET", URL: "http://localhost/", Auth: &core.AuthConfig{Type: "hmac", Secret: "x"}}
+
+	cases := []struct {
+		request   core.Request
+		language  string
+		fragments []string
+	}{
+		{form, "go", []string{`form.Add("q", "a b")`, `req.SetBasicAuth("bob", "s3cret")`, "application/x-www-form-urlencoded"}},
+		{form, "python", []string{`("q", "a b"),`, `auth=("bob", "s3cret")`}},
+		{form, "fetch", []string{`new URLSearchParams("q=a+b")`, `btoa("bob:s3cret")`}},
+		{form, "httpie", []string{"--form", "--auth=bob:s3cret", "'q=a b'"}},
+		{form, "wget", []string{"--auth-no-challenge", "--body-data=q=a+b", "Content-Type: application/x-www-form-urlencoded"}},
+		{form, "powershell", []string{"[ordered]@{", "ToBase64String", "-ContentType 'application/x-www-form-urlencoded'"}},
+		{multipart, "go", []string{`header1.Set("Content-Disposition", "form-data; name=\"image\"; filename=\"cat.png\"")`, `header1.Set("Content-Type", "image/png")`, "writer.CreatePart(header1)", "writer.FormDataContentType()", "no digest auth"}},
+		{multipart, "python", []string{`("image", ("cat.png", open("/tmp/cat.png", "rb"), "image/png")),`, `HTTPDigestAuth("bob", "s3cret")`}},
+		{multipart, "axios", []string{"fs.createReadStream(\"/tmp/cat.png\")", "...form.getHeaders()"}},
+		{multipart, "httpie", []string{"--multipart", "--auth-type=digest", "'image@/tmp/cat.png;type=image/png'"}},
+		{multipart, "wget", []string{"can't send multipart"}},
+		{multipart, "powershell", []string{"Get-Item -Path '/tmp/cat.png'", "-Credential $credential"}},
+		{terminator, "powershell", []string{`$body = '''@ x'`}},
+		{signed, "python", []string{"# TODO: the HMAC signature", `"X-Signature": "<hmac-signature>"`}},
+	}
+	for _, c := range cases {
+		code, err := c.request.GenerateCode(c.language)
+		if err != nil {
+			t.Fatalf("GenerateCode(%s) failed: %v", c.language, err)
+		}
+		for _, fragment := range c.fragments {
+			if !strings.Contains(code, fragment) {
+				t.Errorf("%s %s: missing %q in:\n%s", c.request.URL, c.language, fragment, code)
+			}
+		}
+	}
+}
+
+func TestGenerateCodeSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"cached-secret","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer srv.Close()
+	if err := core.Tokens.SetFile(filepath.Join(t.TempDir(), "tokens.json")); err != nil {
+		t.Fatalf("SetFile failed: %v", err)
+	}
+	defer core.Tokens.Clear("")
+	oauth := &core.AuthConfig{Type: "oauth2", TokenURL: srv.URL, ClientID: "app", ClientSecret: "app-secret"}
+	if _, err := core.Tokens.Token(oauth, srv.Client()); err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+
+	// Tokens computed when sending are never written out
+	for _, auth := range []*core.AuthConfig{oauth, {Type: "jwt", Secret: "jwt-secret"}} {
+		r := core.Request{Method: "GET", URL: "http://localhost/", Auth: auth}
+		for _, language := range core.CodeLanguageNames() {
+			code, err := r.GenerateCode(language)
+			if err != nil {
+				t.Fatalf("GenerateCode(%s) failed: %v", language, err)
+			}
+			if strings.Contains(code, "cached-secret") || strings.Contains(code, "eyJ") || !strings.Contains(code, "TODO: the") {
+				t.Errorf("%s %s: computed token written out:\n%s", auth.Type, language, code)
+			}
+		}
+	}
+
+	r := core.Request{
+		Method:  "POST",
+		URL:     "http://localhost/login",
+		Params:  map[string]interface{}{"api_key": "k3y"},
+		Payload: map[string]interface{}{"user": "bob", "password": "pa55"},
+		Auth:    &core.AuthConfig{Type: "bearer", Token: "t0k3n"},
+	}
+	cases := []struct {
+		language  string
+		secrets   string
+		fragments []string
+	}{
+		{"python", "redact", []string{`"api_key": "***"`, `"Authorization": "Bearer ***"`, `"password": "***"`}},
+		{"go", "env", []string{`"os"`, `"http://localhost/login?api_key="+os.Getenv("API_KEY")`, `"Bearer "+os.Getenv("TOKEN")`, `\"password\": \"" + os.Getenv("PASSWORD") + "\"`}},
+		{"python", "env", []string{"import os\n", `"api_key": os.environ["API_KEY"],`, `"password": os.environ["PASSWORD"],`}},
+		{"fetch", "env", []string{`"Authorization": "Bearer " + process.env.TOKEN,`, `"password": process.env.PASSWORD,`}},
+		{"httpie", "env", []string{`'api_key=='"$API_KEY"`, `'Authorization:Bearer '"$TOKEN"`, `'password='"$PASSWORD"`}},
+		{"powershell", "env", []string{`'Authorization' = "Bearer ${env:TOKEN}"`, "`\"password`\": `\"${env:PASSWORD}`\""}},
+	}
+	for _, c := range cases {
+		code, err := r.GenerateCodeWith(c.language, core.CodeOptions{Secrets: c.secrets})
+		if err != nil {
+			t.Fatalf("GenerateCodeWith(%s) failed: %v", c.language, err)
+		}
+		for _, fragment := range c.fragments {
+			if !strings.Contains(code, fragment) {
+				t.Errorf("%s %s: missing %q in:\n%s", c.language, c.secrets, fragment, code)
+			}
+		}
+		for _, secret := range []string{"k3y", "t0k3n", "pa55", "__tanker"} {
+			if strings.Contains(code, secret) {
+				t.Errorf("%s %s: %s written in:\n%s", c.language, c.secrets, secret, code)
+			}
+		}
+	}
+	if _, err := r.GenerateCodeWith("go", core.CodeOptions{Secrets: "vault"}); err == nil {
+		t.Fatal("expected an error for an unknown secrets mode")
+	}
+}
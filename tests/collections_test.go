@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestCollectionsInheritance(t *testing.T) {
+	dir := t.TempDir()
+	database := &core.Database{
+		DatabaseDir:  dir,
+		DatabaseFile: filepath.Join(dir, "http-tanker-data-test.json"),
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+
+	database.SaveFolder("shop", core.Folder{
+		BaseURL: "https://api.example.com/v1",
+		Headers: map[string]interface{}{"Accept": "application/json", "X-Team": "shop"},
+		Auth:    &core.AuthConfig{Type: "bearer", Token: "shop-token"},
+	})
+	database.SaveFolder("shop/orders", core.Folder{
+		Headers: map[string]interface{}{"X-Team": "orders"},
+	})
+	database.Data["list-orders"] = core.Request{
+		Name:       "list-orders",
+		Method:     "GET",
+		URL:        "/orders",
+		Headers:    map[string]interface{}{"Accept": "text/csv"},
+		Collection: "/shop/orders/",
+	}
+	if err := database.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := database.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	r, err := database.Resolve("list-orders")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if r.URL != "https://api.example.com/v1/orders" {
+		t.Errorf("unexpected URL %s", r.URL)
+	}
+	if r.Headers["Accept"] != "text/csv" || r.Headers["X-Team"] != "orders" {
+		t.Errorf("unexpected headers %v", r.Headers)
+	}
+	if r.Auth == nil || r.Auth.Token != "shop-token" {
+		t.Errorf("folder auth not inherited, got %+v", r.Auth)
+	}
+	if folder := database.AuthFolder(database.Data["list-orders"]); folder != "shop" {
+		t.Errorf("unexpected auth folder %q", folder)
+	}
+
+	if got := database.Subfolders(""); !reflect.DeepEqual(got, []string{"shop"}) {
+		t.Errorf("unexpected root subfolders %v", got)
+	}
+	if got := database.FolderRequests("shop/orders"); !reflect.DeepEqual(got, []string{"list-orders"}) {
+		t.Errorf("unexpected folder requests %v", got)
+	}
+	if err := database.DeleteFolder("shop"); err == nil {
+		t.Errorf("DeleteFolder should refuse a non empty collection")
+	}
+}
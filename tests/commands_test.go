@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+// buildTanker compiles the tanker binary in a temporary directory
+func buildTanker(t *testing.T) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "tanker")
+	build := exec.Command("go", "build", "-o", bin, "github.com/PierreKieffer/http-tanker")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, output)
+	}
+	return bin
+}
+
+// runTanker runs a subcommand on a database and returns its exit code and stdout
+func runTanker(t *testing.T, bin string, db *core.Database, args ...string) (int, string) {
+	t.Helper()
+	// A command falling through to the interactive interface would wait forever
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, append([]string{"-db", db.DatabaseDir}, args...)...)
+	var stdout strings.Builder
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit):
+		return exit.ExitCode(), stdout.String()
+	case err != nil:
+		t.Fatalf("%v failed: %v", args, err)
+	}
+	return 0, stdout.String()
+}
+
+func TestCommands(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the tanker binary")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/dev", "/prod":
+			w.Write([]byte(strings.TrimPrefix(req.URL.Path, "/")))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+
+	db := openRevisionsDatabase(t)
+	db.Environments["dev"] = core.Environment{Variables: map[string]string{"stage": "dev"}}
+	db.Environments["prod"] = core.Environment{Variables: map[string]string{"stage": "prod"}}
+	db.Environment = "dev"
+	db.Data["stage"] = core.Request{Name: "stage", Method: "GET", URL: server.URL + "/{{stage}}"}
+	db.Data["missing"] = core.Request{Name: "missing", Method: "GET", URL: server.URL + "/missing"}
+	db.Data["checked"] = core.Request{Name: "checked", Method: "GET", URL: server.URL + "/dev", Assertions: []core.Assertion{{Type: "body", Value: "prod"}}}
+	if err := db.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	bin := buildTanker(t)
+
+	cases := []struct {
+		args []string
+		code int
+		out  string
+	}{
+		{[]string{"run", "-output", "body", "stage"}, 0, "dev"},
+		{[]string{"run", "stage", "-env", "prod", "-output", "body"}, 0, "prod"},
+		{[]string{"run", "unknown"}, 1, ""},
+		{[]string{"run"}, 2, ""},
+		{[]string{"run", "-env", "staging", "stage"}, 2, ""},
+		{[]string{"run", "-output", "xml", "stage"}, 2, ""},
+		{[]string{"run", "-output", "body", "checked"}, 3, "dev"},
+		{[]string{"run", "missing"}, 4, ""},
+		{[]string{"test", "-env", "prod", "-report", "tap"}, 3, "not ok"},
+		{[]string{"show", "-resolved", "-env", "prod", "-output", "json", "stage"}, 0, "/prod"},
+		{[]string{"list", "-output", "json"}, 0, `"checked"`},
+		{[]string{"delete", "unknown"}, 1, ""},
+	}
+	for _, c := range cases {
+		code, out := runTanker(t, bin, db, c.args...)
+		if code != c.code || !strings.Contains(out, c.out) {
+			t.Errorf("%v: exit code %d, expected %d, output %q", c.args, code, c.code, out)
+		}
+	}
+
+	// init and -mcp are handled after the global flags as well
+	dir := t.TempDir()
+	if code, out := runTanker(t, bin, db, "init", dir); code != 0 || !strings.Contains(out, "Initialized") {
+		t.Errorf("init after -db: exit code %d, output %q", code, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, core.WorkspaceDirName)); err != nil {
+		t.Errorf("workspace not created: %v", err)
+	}
+	if code, _ := runTanker(t, bin, db, "-mcp", "run", "stage"); code != 2 {
+		t.Errorf("-mcp with a command: exit code %d, expected 2", code)
+	}
+
+	// -env only applies to the command, the active environment is kept
+	if err := db.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if db.Environment != "dev" || db.Data["stage"].LastUsed == nil {
+		t.Fatalf("unexpected database after the commands: environment %q, %+v", db.Environment, db.Data["stage"])
+	}
+
+	// An unsaved ad-hoc request leaves the saved request of the same name alone
+	homonym := core.DefaultRequestName("GET", server.URL+"/prod")
+	db.Data[homonym] = core.Request{Name: homonym, Method: "GET", URL: server.URL + "/prod"}
+	if err := db.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if code, out := runTanker(t, bin, db, "GET", server.URL+"/prod"); code != 0 {
+		t.Fatalf("ad-hoc request: exit code %d, output %q", code, out)
+	}
+	if err := db.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if db.Data[homonym].LastUsed != nil {
+		t.Errorf("ad-hoc request recorded as a use of %q", homonym)
+	}
+	if entries, _ := db.ListHistory(homonym, 0); len(entries) != 0 {
+		t.Errorf("ad-hoc request recorded in the history of %q: %+v", homonym, entries)
+	}
+	if entries, _ := db.ListHistory("adhoc:"+homonym, 0); len(entries) != 1 {
+		t.Errorf("expected the ad-hoc request in the history, got %+v", entries)
+	}
+}
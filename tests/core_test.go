@@ -1,8 +1,11 @@
 package tests
 
 import (
+	"bytes"
 	"encoding/json"
 	"github.com/PierreKieffer/http-tanker/pkg/core"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 )
@@ -103,3 +106,36 @@ func TestDeleteRequest(t *testing.T) {
 		t.Errorf("TestDeleteRequest failed\ngot:  %s\nwant: %s", string(jsonData), must)
 	}
 }
+
+func TestResponseWriteBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":1}`))
+		case "/binary":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G'})
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	for path, expected := range map[string]string{"/json": "{\n  \"id\": 1\n}\n", "/binary": "\x89PNG", "/text": "hello"} {
+		r := core.Request{Method: "GET", URL: server.URL + path}
+		response, err := r.CallHTTP()
+		if err != nil {
+			t.Fatalf("CallHTTP failed: %v", err)
+		}
+		var body bytes.Buffer
+		if err := response.WriteBody(&body); err != nil {
+			t.Fatalf("WriteBody failed: %v", err)
+		}
+		response.Cleanup()
+		if body.String() != expected {
+			t.Errorf("%s: unexpected body %q", path, body.String())
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestParseCurlJSON(t *testing.T) {
+	command := `curl 'https://api.example.com/users' \
+  -H 'Content-Type: application/json' \
+  -H "Authorization: Bearer abc123" \
+  --data-raw '{"name":"bob","age":42}' \
+  --compressed -k`
+
+	r, warnings, err := core.ParseCurl(command)
+	if err != nil {
+		t.Fatalf("ParseCurl failed: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if r.Method != "POST" || r.URL != "https://api.example.com/users" || !r.Insecure {
+		t.Fatalf("unexpected request: %+v", r)
+	}
+	if r.Payload["name"] != "bob" || r.Payload["age"] != float64(42) {
+		t.Fatalf("unexpected payload: %v", r.Payload)
+	}
+	if r.Auth == nil || r.Auth.Type != "bearer" || r.Auth.Token != "abc123" {
+		t.Fatalf("bearer header not turned into auth: %+v", r.Auth)
+	}
+	if _, ok := r.Headers["Authorization"]; ok {
+		t.Fatal("authorization header kept")
+	}
+}
+
+func TestParseCurlOptions(t *testing.T) {
+	r, warnings, err := core.ParseCurl(`curl -sSL -XPUT -u admin:secret --digest "http://localhost:8080/items?id=1" -d "a=1" -d 'b=two words' --max-time 5 --frobnicate`)
+	if err != nil {
+		t.Fatalf("ParseCurl failed: %v", err)
+	}
+	if r.Method != "PUT" || r.URL != "http://localhost:8080/items?id=1" {
+		t.Fatalf("unexpected request: %+v", r)
+	}
+	if r.Auth == nil || r.Auth.Type != "digest" || r.Auth.Username != "admin" || r.Auth.Password != "secret" {
+		t.Fatalf("unexpected auth: %+v", r.Auth)
+	}
+	if r.BodyType != "form" || len(r.Form) != 2 || r.Form[1].Value != "two words" {
+		t.Fatalf("unexpected form: %q %+v", r.BodyType, r.Form)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "--frobnicate") {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+
+	r, _, err = core.ParseCurl(`curl -G https://example.com/search --data-urlencode "q=a&b" -I`)
+	if err != nil {
+		t.Fatalf("ParseCurl failed: %v", err)
+	}
+	if r.Method != "HEAD" || r.URL != "https://example.com/search?q=a%26b" {
+		t.Fatalf("unexpected request: %s %s", r.Method, r.URL)
+	}
+
+	r, _, err = core.ParseCurl(`curl example.com/raw -H 'Content-Type: text/plain' --data-binary 'hello world'`)
+	if err != nil {
+		t.Fatalf("ParseCurl failed: %v", err)
+	}
+	if r.URL != "http://example.com/raw" || r.BodyType != "raw" || r.Body != "hello world" {
+		t.Fatalf("unexpected request: %+v", r)
+	}
+
+	if _, _, err := core.ParseCurl(`wget https://example.com`); err == nil {
+		t.Fatal("expected an error for a non curl command")
+	}
+	if _, _, err := core.ParseCurl(`curl -H 'unterminated`); err == nil {
+		t.Fatal("expected an error for invalid quoting")
+	}
+}
+
+func TestParseCurlFiles(t *testing.T) {
+	dir := t.TempDir()
+	body := filepath.Join(dir, "body.json")
+	os.WriteFile(body, []byte(`{"id": 1}`), 0600)
+	note := filepath.Join(dir, "note.txt")
+	os.WriteFile(note, []byte("hello"), 0600)
+
+	commands := []string{
+		`curl -X POST http://localhost/ -d @` + body,
+		`curl http://localhost/ --data-binary @` + body,
+		`curl http://localhost/ --json @` + body,
+		`curl http://localhost/ -F 'note=<` + note + `'`,
+	}
+	for _, command := range commands {
+		// Local files are only read when asked
+		if r, _, err := core.ParseCurl(command); err == nil {
+			t.Errorf("expected an error for %s, got %+v", command, r)
+		}
+		if _, _, err := core.ParseCurlWith(command, core.CurlImportOptions{ReadFiles: true}); err != nil {
+			t.Errorf("ParseCurlWith(%s) failed: %v", command, err)
+		}
+	}
+
+	r, _, err := core.ParseCurlWith(commands[3], core.CurlImportOptions{ReadFiles: true})
+	if err != nil || r.Form[0].Value != "hello" {
+		t.Fatalf("unexpected form: %v %+v", err, r.Form)
+	}
+	// A file to upload stays a reference
+	r, _, err = core.ParseCurl(`curl http://localhost/ -F avatar=@` + note)
+	if err != nil || r.Form[0].File != note || r.Form[0].Value != "" {
+		t.Fatalf("unexpected upload: %v %+v", err, r.Form)
+	}
+}
+
+func TestParseCurlRoundTrip(t *testing.T) {
+	original := core.Request{
+		Method:  "POST",
+		URL:     "https://httpbin.org/post",
+		Headers: map[string]interface{}{"Accept": "application/json", "Content-Type": "application/json"},
+		Payload: map[string]interface{}{"foo": "bar"},
+		Auth:    &core.AuthConfig{Type: "basic", Username: "user", Password: "pass"},
+	}
+	r, _, err := core.ParseCurl(original.CurlCommand())
+	if err != nil {
+		t.Fatalf("ParseCurl failed: %v", err)
+	}
+	if r.Method != original.Method || r.URL != original.URL || r.Payload["foo"] != "bar" || r.Headers["Accept"] != "application/json" {
+		t.Fatalf("round trip changed the request: %+v", r)
+	}
+	if r.Auth == nil || r.Auth.Username != "user" || r.Auth.Password != "pass" {
+		t.Fatalf("round trip changed the auth: %+v", r.Auth)
+	}
+}
+
+func TestCurlExportQuoting(t *testing.T) {
+	r := core.Request{
+		Method:   "POST",
+		URL:      "https://api.example.com/users",
+		Headers:  map[string]interface{}{"X-Note": `it's "quoted" $HOME`},
+		Payload:  map[string]interface{}{"name": "O'Brien"},
+		Insecure: true,
+	}
+	parsed, _, err := core.ParseCurl(r.CurlCommand())
+	if err != nil {
+		t.Fatalf("ParseCurl failed: %v", err)
+	}
+	if parsed.Headers["X-Note"] != `it's "quoted" $HOME` || parsed.Payload["name"] != "O'Brien" || !parsed.Insecure {
+		t.Fatalf("quoting changed the request: %+v", parsed)
+	}
+
+	cases := []struct {
+		options   core.CurlOptions
+		fragments []string
+	}{
+		{core.CurlOptions{Shell: "powershell"}, []string{"curl.exe -X POST", "-k `\n", `-H 'X-Note: it''s "quoted" $HOME'`, `--data-raw '{"name":"O''Brien"}'`}},
+		{core.CurlOptions{Shell: "cmd"}, []string{"curl -X POST", "-k ^\n", `-H "X-Note: it's ""quoted"" $HOME"`, `--data-raw "{""name"":""O'Brien""}"`}},
+		{core.CurlOptions{SingleLine: true}, []string{"curl -X POST https://api.example.com/users -k -H"}},
+	}
+	for _, c := range cases {
+		command, err := r.Curl(c.options)
+		if err != nil {
+			t.Fatalf("Curl(%+v) failed: %v", c.options, err)
+		}
+		for _, fragment := range c.fragments {
+			if !strings.Contains(command, fragment) {
+				t.Errorf("%+v: missing %q in:\n%s", c.options, fragment, command)
+			}
+		}
+		if c.options.SingleLine && strings.Contains(command, "\n") {
+			t.Errorf("expected a single line: %s", command)
+		}
+	}
+
+	if _, err := r.Curl(core.CurlOptions{Shell: "fish"}); err == nil {
+		t.Fatal("expected an error for an unknown shell")
+	}
+
+	// cmd can't pass a body on several lines
+	raw := core.Request{Method: "PUT", URL: "http://localhost/", BodyType: "raw", Body: "a\nb"}
+	command, _ := raw.Curl(core.CurlOptions{Shell: "cmd"})
+	if !strings.HasPrefix(command, "REM ") || !strings.Contains(command, "--data-binary @body.txt") {
+		t.Fatalf("unexpected cmd command: %s", command)
+	}
+}
+
+func TestCurlExportSecrets(t *testing.T) {
+	r := core.Request{
+		Method:  "GET",
+		URL:     "https://api.example.com/items",
+		Params:  map[string]interface{}{"api_key": "k3y", "page": "2"},
+		Headers: map[string]interface{}{"X-API-Key": "abc", "Accept": "application/json"},
+		Auth:    &core.AuthConfig{Type: "bearer", Token: "t0k3n"},
+	}
+	clear := r.CurlCommand()
+	for _, secret := range []string{"k3y", "abc", "Bearer t0k3n"} {
+		if !strings.Contains(clear, secret) {
+			t.Fatalf("missing %q in:\n%s", secret, clear)
+		}
+	}
+
+	redacted, _ := r.Curl(core.CurlOptions{Secrets: "redact"})
+	for _, secret := range []string{"k3y", "abc", "t0k3n"} {
+		if strings.Contains(redacted, secret) {
+			t.Fatalf("secret %q not redacted:\n%s", secret, redacted)
+		}
+	}
+	for _, fragment := range []string{"api_key=***", "page=2", "'Authorization: Bearer ***'", "'Accept: application/json'"} {
+		if !strings.Contains(redacted, fragment) {
+			t.Fatalf("missing %q in:\n%s", fragment, redacted)
+		}
+	}
+
+	templated := map[string][]string{
+		"posix":      {`api_key='"$API_KEY"'&page=2'`, `-H 'X-API-Key: '"$API_KEY_2"`, `-H 'Authorization: Bearer '"$TOKEN"`},
+		"powershell": {`"X-API-Key: ${env:API_KEY_2}"`, `"Authorization: Bearer ${env:TOKEN}"`},
+		"cmd":        {`"X-API-Key: %API_KEY_2%"`, `"Authorization: Bearer %TOKEN%"`},
+	}
+	for shell, fragments := range templated {
+		command, _ := r.Curl(core.CurlOptions{Shell: shell, Secrets: "env"})
+		for _, fragment := range fragments {
+			if !strings.Contains(command, fragment) {
+				t.Errorf("%s: missing %q in:\n%s", shell, fragment, command)
+			}
+		}
+	}
+
+	basic := core.Request{Method: "GET", URL: "http://localhost/", Auth: &core.AuthConfig{Type: "basic", Username: "bob", Password: "s3cret"}}
+	command, _ := basic.Curl(core.CurlOptions{Secrets: "env"})
+	if !strings.Contains(command, `-u bob:"$PASSWORD"`) {
+		t.Fatalf("password not templated: %s", command)
+	}
+
+	// Body fields such as password are secrets too
+	bodies := []struct {
+		request  core.Request
+		options  core.CurlOptions
+		fragment string
+	}{
+		{core.Request{Method: "POST", URL: "http://localhost/", Payload: map[string]interface{}{"user": "bob", "auth": map[string]interface{}{"client_secret": "s3cret"}}},
+			core.CurlOptions{Secrets: "env"}, `'{"auth":{"client_secret":"'"$CLIENT_SECRET"'"},"user":"bob"}'`},
+		{core.Request{Method: "POST", URL: "http://localhost/", BodyType: "raw", Body: `{"password": "s3cret"}`},
+			core.CurlOptions{Secrets: "redact"}, `'{"password":"***"}'`},
+		{core.Request{Method: "POST", URL: "http://localhost/", BodyType: "form", Form: []core.FormField{{Name: "username", Value: "bob"}, {Name: "password", Value: "s3cret"}}},
+			core.CurlOptions{Secrets: "env"}, `--data-urlencode password="$PASSWORD"`},
+		{core.Request{Method: "POST", URL: "http://localhost/", BodyType: "multipart", Form: []core.FormField{{Name: "api_key", Value: "s3cret"}}},
+			core.CurlOptions{Secrets: "redact", Shell: "cmd"}, `--form-string "api_key=***"`},
+	}
+	for _, c := range bodies {
+		command, _ := c.request.Curl(c.options)
+		if !strings.Contains(command, c.fragment) || strings.Contains(command, "s3cret") {
+			t.Errorf("missing %q in:\n%s", c.fragment, command)
+		}
+	}
+}
+
+func TestCurlExportCmdPercent(t *testing.T) {
+	r := core.Request{Method: "GET", URL: "http://localhost/a%20b", Params: map[string]interface{}{"q": "100%"}}
+	command, _ := r.Curl(core.CurlOptions{Shell: "cmd"})
+	// % is escaped outside the quotes, "" would be a literal quote
+	if !strings.Contains(command, `"http://localhost/a"^%"20b?q=100"^%"25"`) {
+		t.Fatalf("unexpected cmd command: %s", command)
+	}
+	r = core.Request{Method: "GET", URL: "http://localhost/", Headers: map[string]interface{}{"X-Rate": "%%"}}
+	if command, _ := r.Curl(core.CurlOptions{Shell: "cmd"}); !strings.Contains(command, `-H "X-Rate: "^%^%`) {
+		t.Fatalf("unexpected cmd command: %s", command)
+	}
+}
+
+func TestCurlExportNotes(t *testing.T) {
+	hmac := core.Request{Method: "POST", URL: "http://localhost/hook", Auth: &core.AuthConfig{Type: "hmac", Secret: "s3cret"}}
+	cases := []struct {
+		options core.CurlOptions
+		prefix  string
+	}{
+		{core.CurlOptions{}, "# TODO: the HMAC signature"},
+		{core.CurlOptions{Shell: "powershell"}, "# TODO: the HMAC signature"},
+		{core.CurlOptions{Shell: "cmd", SingleLine: true}, "REM TODO: the HMAC signature"},
+	}
+	for _, c := range cases {
+		command, _ := hmac.Curl(c.options)
+		lines := strings.Split(command, "\n")
+		if !strings.HasPrefix(lines[0], c.prefix) || !strings.Contains(command, "<hmac-signature>") {
+			t.Errorf("%+v: unexpected command:\n%s", c.options, command)
+		}
+		// The command itself stays on one line after the notes
+		if c.options.SingleLine && (len(lines) != 2 || !strings.HasPrefix(lines[1], "curl ")) {
+			t.Errorf("expected the notes before a single line:\n%s", command)
+		}
+	}
+
+	// curl signs AWS requests, only the missing credentials are noted
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "none"))
+	aws := core.Request{Method: "GET", URL: "https://s3.amazonaws.com/", Auth: &core.AuthConfig{Type: "aws-sigv4", Service: "s3", Region: "eu-west-3"}}
+	command := aws.CurlCommand()
+	if !strings.HasPrefix(command, "# TODO: no AWS credentials configured") || !strings.Contains(command, "--aws-sigv4 aws:amz:eu-west-3:s3") || strings.Contains(command, "sign the request") {
+		t.Fatalf("unexpected command:\n%s", command)
+	}
+}
+
+func TestMultipartBody(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "avatar.txt")
+	os.WriteFile(file, []byte("file content"), 0600)
+
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("invalid multipart body: %v", err)
+			return
+		}
+		upload, _, err := req.FormFile("avatar")
+		if err != nil {
+			t.Errorf("missing file: %v", err)
+			return
+		}
+		content, _ := io.ReadAll(upload)
+		received = map[string]string{"name": req.FormValue("name"), "avatar": string(content)}
+	}))
+	defer server.Close()
+
+	r, _, err := core.ParseCurl(`curl ` + server.URL + ` -H 'Content-Type: multipart/form-data' -F name=bob -F "avatar=@` + file + `;type=text/plain"`)
+	if err != nil {
+		t.Fatalf("ParseCurl failed: %v", err)
+	}
+	if r.Method != "POST" || r.BodyType != "multipart" {
+		t.Fatalf("unexpected request: %+v", r)
+	}
+	if _, err := r.CallHTTP(); err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	if received["name"] != "bob" || received["avatar"] != "file content" {
+		t.Fatalf("unexpected form received: %v", received)
+	}
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+var digestParam = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|([^,\s]*))`)
+
+func digestServer(algorithm string, h func() hash.Hash, ncs *[]string, challenges *int) *httptest.Server {
+	const realm, nonce = "appliance", "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	sum := func(s string) string {
+		hh := h()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := map[string]string{}
+		for _, m := range digestParam.FindAllStringSubmatch(r.Header.Get("Authorization"), -1) {
+			params[m[1]] = m[2] + m[3]
+		}
+		ha1 := sum("admin:" + realm + ":s3cret")
+		ha2 := sum(r.Method + ":" + params["uri"])
+		expected := sum(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+		if params["response"] == "" || params["response"] != expected || params["uri"] != r.URL.RequestURI() {
+			*challenges++
+			w.Header().Add("WWW-Authenticate", `Basic realm="`+realm+`"`)
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", qop="auth,auth-int", algorithm=%s, nonce="%s", opaque="5ccc069c"`, realm, algorithm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		*ncs = append(*ncs, params["nc"])
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestDigestAuth(t *testing.T) {
+	for _, tc := range []struct {
+		algorithm string
+		h         func() hash.Hash
+	}{
+		{"MD5", md5.New},
+		{"SHA-256", sha256.New},
+	} {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			var ncs []string
+			var challenges int
+			srv := digestServer(tc.algorithm, tc.h, &ncs, &challenges)
+			defer srv.Close()
+
+			r := core.Request{
+				Method: "GET",
+				URL:    srv.URL + "/status",
+				Params: map[string]interface{}{"verbose": "1"},
+				Auth:   &core.AuthConfig{Type: "digest", Username: "admin", Password: "s3cret"},
+			}
+			for i := 0; i < 3; i++ {
+				resp, err := r.CallHTTP()
+				if err != nil {
+					t.Fatalf("CallHTTP failed: %v", err)
+				}
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("call %d: got status %d, want 200", i, resp.StatusCode)
+				}
+			}
+
+			// Only the first call is challenged, the nonce count then increases
+			if challenges != 1 {
+				t.Errorf("got %d challenges, want 1", challenges)
+			}
+			want := []string{"00000001", "00000002", "00000003"}
+			if fmt.Sprint(ncs) != fmt.Sprint(want) {
+				t.Errorf("got nonce counts %v, want %v", ncs, want)
+			}
+		})
+	}
+}
+
+func TestDigestAuthWrongPassword(t *testing.T) {
+	var ncs []string
+	var challenges int
+	srv := digestServer("MD5", md5.New, &ncs, &challenges)
+	defer srv.Close()
+
+	r := core.Request{
+		Method: "GET",
+		URL:    srv.URL,
+		Auth:   &core.AuthConfig{Type: "digest", Username: "admin", Password: "wrong"},
+	}
+	resp, err := r.CallHTTP()
+	if err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got status %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestDigestAuthQuoting(t *testing.T) {
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorization = r.Header.Get("Authorization"); authorization == "" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="lab \"west\" \\ 2", nonce="abc", opaque="o\"1"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	r := core.Request{
+		Method: "GET",
+		URL:    srv.URL,
+		Auth:   &core.AuthConfig{Type: "digest", Username: `corp\ad"min`, Password: "s3cret"},
+	}
+	if _, err := r.CallHTTP(); err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	for _, want := range []string{`username="corp\\ad\"min"`, `realm="lab \"west\" \\ 2"`, `opaque="o\"1"`} {
+		if !strings.Contains(authorization, want) {
+			t.Errorf("got %s, want %s", authorization, want)
+		}
+	}
+}
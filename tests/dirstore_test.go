@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestDirStorage(t *testing.T) {
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			root := filepath.Join(dir, "requests")
+			database := &core.Database{
+				DatabaseDir:  dir,
+				DatabaseFile: filepath.Join(dir, "http-tanker-data-test.json"),
+				Storage:      &core.DirStorage{Root: root, Format: format},
+			}
+			if err := database.InitDB(); err != nil {
+				t.Fatalf("InitDB failed: %v", err)
+			}
+
+			database.Data["list orders"] = core.Request{
+				Name:       "list orders",
+				Method:     "GET",
+				URL:        "/orders",
+				Headers:    map[string]interface{}{"b": "2", "a": "1"},
+				Collection: "shop/orders",
+			}
+			if err := database.SaveFolder("shop", core.Folder{BaseURL: "https://shop.example.com"}); err != nil {
+				t.Fatalf("SaveFolder failed: %v", err)
+			}
+			if err := database.Touch("list orders"); err != nil {
+				t.Fatalf("Touch failed: %v", err)
+			}
+
+			ext := "." + format
+			file := filepath.Join(root, "shop", "orders", "list orders"+ext)
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("request file missing: %v", err)
+			}
+			// Sorted keys, no churn from the last used date
+			first, second := `"a": "1"`, `"b": "2"`
+			if format == "yaml" {
+				first, second = `a: "1"`, `b: "2"`
+			}
+			i, j := strings.Index(string(content), first), strings.Index(string(content), second)
+			if strings.Contains(string(content), "lastUsed") || i < 0 || i > j {
+				t.Errorf("unexpected content:\n%s", content)
+			}
+			if _, err := os.Stat(filepath.Join(root, "shop", ".folder"+ext)); err != nil {
+				t.Errorf("folder defaults file missing: %v", err)
+			}
+
+			// A fresh process reads the same tree back
+			reader := &core.Database{DatabaseDir: dir, Storage: &core.DirStorage{Root: root, Format: format}}
+			if err := reader.Load(); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			r, err := reader.Resolve("list orders")
+			if err != nil || r.URL != "https://shop.example.com/orders" || reader.Data["list orders"].LastUsed == nil {
+				t.Fatalf("unexpected request %+v %v", r, err)
+			}
+
+			// Saving again without change rewrites nothing
+			info, _ := os.Stat(file)
+			if err := reader.Save(); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			if after, _ := os.Stat(file); !after.ModTime().Equal(info.ModTime()) {
+				t.Errorf("unchanged request file rewritten")
+			}
+
+			// Deleting removes the file and the empty folders
+			if err := reader.Delete("list orders"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(root, "shop", "orders")); !os.IsNotExist(err) {
+				t.Errorf("empty folder not removed")
+			}
+		})
+	}
+}
+
+func TestDirStorageKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "requests")
+	storage := &core.DirStorage{Root: root}
+	database := &core.Database{DatabaseDir: dir, Storage: storage}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	database.Data["users"] = core.Request{Name: "users", Method: "GET", URL: "/users", Collection: "api"}
+	if err := database.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Files of the repository that the store never read nor wrote
+	others := []string{filepath.Join(root, ".prettierrc.json"), filepath.Join(root, "api", "schema.json")}
+	for _, path := range others {
+		os.WriteFile(path, []byte("{}"), 0600)
+	}
+	// A meta file left over from a change of format is ignored
+	os.WriteFile(filepath.Join(root, ".tanker.yaml"), []byte("version: 999\n"), 0600)
+
+	reader := &core.Database{DatabaseDir: dir, Storage: &core.DirStorage{Root: root}}
+	if err := reader.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(root, "api", "openapi.yaml"), []byte("openapi: 3.0.0\n"), 0600)
+	others = append(others, filepath.Join(root, "api", "openapi.yaml"))
+	if err := reader.Delete("users"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "api", "users.json")); !os.IsNotExist(err) {
+		t.Errorf("request file not removed")
+	}
+	for _, path := range others {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("unrelated file removed: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, ".tanker.yaml")); !os.IsNotExist(err) {
+		t.Errorf("stale meta file not removed")
+	}
+}
@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const harFile = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "WebInspector", "version": "537.36"},
+    "entries": [
+      {
+        "startedDateTime": "2024-05-01T10:00:00.000Z",
+        "request": {
+          "method": "GET",
+          "url": "https://api.example.com/users?page=2&sort=name",
+          "headers": [
+            {"name": ":authority", "value": "api.example.com"},
+            {"name": "Accept", "value": "application/json"},
+            {"name": "Authorization", "value": "Bearer t0k3n"},
+            {"name": "sec-fetch-mode", "value": "cors"},
+            {"name": "Accept-Encoding", "value": "gzip, br"}
+          ]
+        },
+        "response": {"status": 200, "content": {"mimeType": "application/json; charset=utf-8"}}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:01.000Z",
+        "request": {"method": "GET", "url": "https://api.example.com/users?page=2&sort=name", "headers": []},
+        "response": {"status": 200, "content": {"mimeType": "application/json"}}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:02.000Z",
+        "request": {
+          "method": "POST",
+          "url": "https://auth.example.com/login",
+          "headers": [{"name": "Content-Type", "value": "application/x-www-form-urlencoded"}],
+          "postData": {"mimeType": "application/x-www-form-urlencoded", "text": "user=bob&remember=1"}
+        },
+        "response": {"status": 302, "content": {"mimeType": "text/html"}}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:03.000Z",
+        "request": {
+          "method": "POST",
+          "url": "https://api.example.com/orders",
+          "headers": [{"name": "Content-Type", "value": "application/json"}],
+          "postData": {"mimeType": "application/json", "text": "{\"item\":\"book\",\"quantity\":2}"}
+        },
+        "response": {"status": 201, "content": {"mimeType": "application/json"}}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:04.000Z",
+        "request": {"method": "GET", "url": "https://cdn.other.net/logo.png", "headers": []},
+        "response": {"status": 200, "content": {"mimeType": "image/png"}}
+      }
+    ]
+  }
+}`
+
+func TestImportHAR(t *testing.T) {
+	imp, err := core.ImportHAR([]byte(harFile), "", core.HARFilter{})
+	if err != nil {
+		t.Fatalf("ImportHAR failed: %v", err)
+	}
+	if len(imp.Requests) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(imp.Requests))
+	}
+	requests := map[string]core.Request{}
+	for _, r := range imp.Requests {
+		requests[r.Name] = r
+	}
+
+	users := requests["get api.example.com/users"]
+	if users.Collection != "har/api.example.com" || users.URL != "https://api.example.com/users" || users.Params["page"] != "2" {
+		t.Fatalf("unexpected request: %+v", users)
+	}
+	if len(users.Headers) != 1 || users.Headers["Accept"] != "application/json" {
+		t.Fatalf("browser headers not dropped: %v", users.Headers)
+	}
+	if users.Auth == nil || users.Auth.Token != "t0k3n" {
+		t.Fatalf("bearer header not turned into auth: %+v", users.Auth)
+	}
+	if login := requests["post auth.example.com/login"]; login.BodyType != "form" || len(login.Form) != 2 || login.Form[0].Value != "bob" {
+		t.Fatalf("unexpected form: %+v", login)
+	}
+	if order := requests["post api.example.com/orders"]; order.Payload["item"] != "book" {
+		t.Fatalf("unexpected payload: %+v", order)
+	}
+	if !strings.Contains(strings.Join(imp.Warnings, "\n"), "1 repeated calls") {
+		t.Errorf("missing warning about repeated calls: %v", imp.Warnings)
+	}
+
+	imp, err = core.ImportHAR([]byte(harFile), "debug", core.HARFilter{Domains: []string{"example.com"}, Methods: []string{"post"}, ContentTypes: []string{"application/json"}})
+	if err != nil {
+		t.Fatalf("ImportHAR failed: %v", err)
+	}
+	if len(imp.Requests) != 1 || imp.Requests[0].Name != "post api.example.com/orders" || imp.Requests[0].Collection != "debug/api.example.com" {
+		t.Fatalf("filters not applied: %+v", imp.Requests)
+	}
+
+	if _, err := core.ImportHAR([]byte(`{"foo": 1}`), "", core.HARFilter{}); err == nil {
+		t.Fatal("expected an error for a non HAR document")
+	}
+}
+
+func TestExportHAR(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+
+	db := openRevisionsDatabase(t)
+	r := core.Request{
+		Name:    "create",
+		Method:  "POST",
+		URL:     server.URL + "/items",
+		Params:  map[string]interface{}{"dry": "true"},
+		Headers: map[string]interface{}{"Content-Type": "application/json"},
+		Payload: map[string]interface{}{"name": "book"},
+	}
+	resp, err := r.CallHTTP()
+	if err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	if resp.Timings == nil || resp.Timings.Wait < 5 || resp.Timings.Connect < 0 {
+		t.Fatalf("unexpected timings: %+v", resp.Timings)
+	}
+	if _, err := db.RecordHistory("create", r, &resp, nil); err != nil {
+		t.Fatalf("RecordHistory failed: %v", err)
+	}
+	entries, _ := db.ListHistory("", 0)
+	data, err := core.ExportHAR(entries, "test")
+	if err != nil {
+		t.Fatalf("ExportHAR failed: %v", err)
+	}
+
+	var har struct {
+		Log struct {
+			Version string
+			Entries []struct {
+				StartedDateTime time.Time
+				Time            float64
+				Comment         string
+				Request         struct {
+					URL         string
+					QueryString []struct{ Name, Value string }
+					PostData    struct{ MimeType, Text string }
+				}
+				Response struct {
+					Status  int
+					Content struct {
+						MimeType, Text string
+					}
+				}
+				Timings map[string]float64
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatalf("invalid HAR: %v", err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 1 {
+		t.Fatalf("unexpected log: %s", data)
+	}
+	e := har.Log.Entries[0]
+	if e.Comment != "create" || e.Request.URL != server.URL+"/items?dry=true" || len(e.Request.QueryString) != 1 {
+		t.Fatalf("unexpected request: %+v", e.Request)
+	}
+	if e.Request.PostData.MimeType != "application/json" || e.Request.PostData.Text != `{"name":"book"}` {
+		t.Fatalf("unexpected post data: %+v", e.Request.PostData)
+	}
+	if e.Response.Status != 201 || e.Response.Content.Text != `{"id":1}` {
+		t.Fatalf("unexpected response: %+v", e.Response)
+	}
+	for _, phase := range []string{"blocked", "dns", "connect", "ssl", "send", "wait", "receive"} {
+		if _, ok := e.Timings[phase]; !ok {
+			t.Errorf("missing timing %s", phase)
+		}
+	}
+	if e.Time < e.Timings["wait"] || e.StartedDateTime.After(entries[0].Time) {
+		t.Fatalf("inconsistent times: %v %v", e.Time, e.StartedDateTime)
+	}
+
+	// Imported back, the export gives the same request
+	imp, err := core.ImportHAR(data, "", core.HARFilter{})
+	if err != nil || len(imp.Requests) != 1 || imp.Requests[0].Payload["name"] != "book" || imp.Requests[0].Params["dry"] != "true" {
+		t.Fatalf("round trip failed: %v %+v", err, imp.Requests)
+	}
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestHistory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	database := &core.Database{
+		DatabaseDir:      dir,
+		DatabaseFile:     filepath.Join(dir, "http-tanker-data-test.json"),
+		HistoryRetention: time.Hour,
+		HistoryBodyLimit: 10,
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+
+	r := core.Request{Name: "big", Method: "GET", URL: srv.URL}
+	resp, err := r.CallHTTP()
+	if err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	entry, err := database.RecordHistory("big", r, &resp, nil)
+	if err != nil {
+		t.Fatalf("RecordHistory failed: %v", err)
+	}
+	if _, err := database.RecordHistory("other", core.Request{Method: "GET", URL: "http://127.0.0.1:1"}, nil, fmt.Errorf("connection refused")); err != nil {
+		t.Fatalf("RecordHistory failed: %v", err)
+	}
+
+	got, err := database.GetHistory(entry.ID)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if !got.BodyTruncated || got.Response.Body != "xxxxxxxxxx" || got.Request.URL != srv.URL {
+		t.Errorf("unexpected entry %+v", got)
+	}
+
+	all, _ := database.ListHistory("", 0)
+	if len(all) != 2 || all[0].Name != "other" || all[0].Error != "connection refused" {
+		t.Errorf("unexpected history %+v", all)
+	}
+	if mine, _ := database.ListHistory("big", 0); len(mine) != 1 {
+		t.Errorf("expected 1 execution of big, got %d", len(mine))
+	}
+
+	// Entries older than the retention period are pruned
+	database.HistoryRetention = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if err := database.PruneHistory(); err != nil {
+		t.Fatalf("PruneHistory failed: %v", err)
+	}
+	if all, _ := database.ListHistory("", 0); len(all) != 0 {
+		t.Errorf("expected pruned history, got %d entries", len(all))
+	}
+}
+
+func TestHistorySecrets(t *testing.T) {
+	database := openRevisionsDatabase(t)
+	database.Profiles["ci"] = core.AuthConfig{Type: "basic", Username: "ci", Password: "hunter2-secret"}
+	database.Data["me"] = core.Request{Name: "me", Method: "GET", URL: "http://localhost/me", Auth: &core.AuthConfig{Type: "bearer", Token: "{{token}}"}}
+	database.Data["login"] = core.Request{
+		Name:    "login",
+		Method:  "POST",
+		URL:     "http://localhost/login",
+		Headers: map[string]interface{}{"X-API-Key": "key-secret", "Accept": "application/json"},
+		Params:  map[string]interface{}{"access_token": "param-secret", "page": "1"},
+		Payload: map[string]interface{}{"user": "bob", "credentials": map[string]interface{}{"password": "body-secret"}},
+	}
+	database.Environments["dev"] = core.Environment{Variables: map[string]string{"token": "eyJhbGciOi.secret"}}
+	database.Environment = "dev"
+
+	sent, _ := database.Resolve("me")
+	entry, err := database.RecordHistory("me", sent, nil, fmt.Errorf("connection refused"))
+	if err != nil {
+		t.Fatalf("RecordHistory failed: %v", err)
+	}
+	profiled, _ := database.RecordHistory("", core.Request{Method: "GET", URL: "http://localhost", AuthProfile: "ci", Auth: &core.AuthConfig{Type: "basic", Username: "ci", Password: "hunter2-secret"}}, nil, nil)
+	adHoc, _ := database.RecordHistory("", core.Request{Method: "GET", URL: "http://localhost", Auth: &core.AuthConfig{Type: "bearer", Token: "adhoc-secret"}}, nil, nil)
+	login, _ := database.Resolve("login")
+	fields, _ := database.RecordHistory("login", login, nil, nil)
+	form, _ := database.RecordHistory("", core.Request{
+		Method:   "POST",
+		URL:      "http://localhost",
+		Headers:  map[string]interface{}{"Authorization": "Bearer header-secret", "Cookie": "sid=cookie-secret"},
+		BodyType: "form",
+		Form:     []core.FormField{{Name: "client_secret", Value: "form-secret"}, {Name: "grant_type", Value: "client_credentials"}},
+	}, nil, nil)
+
+	// The secrets are masked on disk
+	for _, e := range []core.HistoryEntry{entry, profiled, adHoc, fields, form} {
+		buffer, err := os.ReadFile(filepath.Join(database.DatabaseDir, "history", e.ID+".json"))
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		for _, secret := range []string{"hunter2-secret", "eyJhbGciOi.secret", "adhoc-secret", "key-secret", "param-secret", "body-secret", "header-secret", "cookie-secret", "form-secret"} {
+			if strings.Contains(string(buffer), secret) {
+				t.Fatalf("%s recorded in the history: %s", secret, buffer)
+			}
+		}
+	}
+	if stored, _ := database.GetHistory(fields.ID); stored.Request.Headers["Accept"] != "application/json" || stored.Request.Params["page"] != "1" || stored.Request.Payload["user"] != "bob" {
+		t.Fatalf("fields other than the secrets masked: %+v", stored.Request)
+	}
+
+	// and restored from the saved request or the profile to send it again
+	if r, err := database.HistoryRequest(entry); err != nil || r.Auth.Token != "eyJhbGciOi.secret" {
+		t.Fatalf("unexpected rerun: %v %+v", err, r.Auth)
+	}
+	if r, err := database.HistoryRequest(profiled); err != nil || r.Auth.Password != "hunter2-secret" {
+		t.Fatalf("unexpected rerun: %v %+v", err, r.Auth)
+	}
+	stored, _ := database.GetHistory(fields.ID)
+	if r, err := database.HistoryRequest(stored); err != nil || r.Headers["X-API-Key"] != "key-secret" || r.Params["access_token"] != "param-secret" || fmt.Sprint(r.Payload["credentials"]) != "map[password:body-secret]" {
+		t.Fatalf("unexpected rerun: %v %+v", err, r)
+	}
+	for _, e := range []core.HistoryEntry{adHoc, form} {
+		if _, err := database.HistoryRequest(e); err == nil {
+			t.Fatalf("expected an error for the masked credentials of an ad-hoc execution: %+v", e.Request)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const httpFile = `@host = https://api.example.com
+@baseUrl = {{host}}/v1
+
+### List users
+GET {{baseUrl}}/users
+    ?page=2
+    &sort=name HTTP/1.1
+Accept: application/json
+Authorization: Bearer {{token}}
+
+###
+# @name createUser
+POST {{baseUrl}}/users
+Content-Type: application/json
+
+{"name": "bob", "age": 42}
+
+> {%
+    client.global.set("id", response.body.id);
+%}
+
+###
+// @name login
+POST https://auth.example.com/login
+Content-Type: application/x-www-form-urlencoded
+Authorization: Basic admin s3cret
+
+user=bob
+&remember=1
+
+### upload
+POST {{baseUrl}}/photos
+Content-Type: multipart/form-data; boundary=WebAppBoundary
+
+--WebAppBoundary
+Content-Disposition: form-data; name="title"
+
+cat
+--WebAppBoundary
+Content-Disposition: form-data; name="image"; filename="cat.png"
+Content-Type: image/png
+
+< ./cat.png
+--WebAppBoundary--
+
+###
+https://api.example.com/health
+`
+
+func TestParseHTTPFile(t *testing.T) {
+	f, err := core.ParseHTTPFile([]byte(httpFile), "/data")
+	if err != nil {
+		t.Fatalf("ParseHTTPFile failed: %v", err)
+	}
+	if strings.Join(f.Names(), ",") != "List users,createUser,login,upload,get api.example.com/health" {
+		t.Fatalf("unexpected names: %v", f.Names())
+	}
+	if f.Variables["baseUrl"] != "{{host}}/v1" {
+		t.Fatalf("unexpected variables: %v", f.Variables)
+	}
+
+	list := f.Requests[0]
+	if list.URL != "{{baseUrl}}/users" || list.Params["page"] != "2" || list.Params["sort"] != "name" {
+		t.Fatalf("unexpected request line: %+v", list)
+	}
+	if list.Headers["Accept"] != "application/json" || list.Auth == nil || list.Auth.Token != "{{token}}" {
+		t.Fatalf("unexpected headers: %+v %+v", list.Headers, list.Auth)
+	}
+	if create := f.Requests[1]; create.Payload["name"] != "bob" || create.Body != "" {
+		t.Fatalf("unexpected body: %+v", create)
+	}
+	login := f.Requests[2]
+	if login.BodyType != "form" || len(login.Form) != 2 || login.Form[1].Value != "1" {
+		t.Fatalf("unexpected form: %+v", login)
+	}
+	if login.Auth == nil || login.Auth.Type != "basic" || login.Auth.Password != "s3cret" {
+		t.Fatalf("unexpected auth: %+v", login.Auth)
+	}
+	upload := f.Requests[3]
+	if upload.BodyType != "multipart" || len(upload.Form) != 2 || upload.Form[0].Value != "cat" {
+		t.Fatalf("unexpected multipart: %+v", upload)
+	}
+	if upload.Form[1].File != "/data/cat.png" || upload.Form[1].ContentType != "image/png" || upload.Headers["Content-Type"] != nil {
+		t.Fatalf("unexpected file field: %+v", upload)
+	}
+	if health := f.Requests[4]; health.Method != "GET" || health.URL != "https://api.example.com/health" {
+		t.Fatalf("unexpected request: %+v", health)
+	}
+	if !strings.Contains(strings.Join(f.Warnings, "\n"), "response handler") {
+		t.Errorf("missing warning for the response handler: %v", f.Warnings)
+	}
+
+	// File variables win over those given
+	r, err := f.Request("List users", map[string]string{"host": "http://localhost", "token": "t0k3n"})
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if r.URL != "https://api.example.com/v1/users" || r.Auth.Token != "t0k3n" {
+		t.Fatalf("variables not resolved: %+v", r)
+	}
+	if f.Requests[0].Auth.Token != "{{token}}" {
+		t.Fatal("resolving a request changed the file")
+	}
+	if _, err := f.Request("missing", nil); err == nil {
+		t.Fatal("expected an error for an unknown request")
+	}
+	if _, err := core.ParseHTTPFile([]byte("# nothing here\n"), ""); err == nil {
+		t.Fatal("expected an error for a file without request")
+	}
+}
+
+func TestRunHTTPFileRequestWithBodyFile(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buffer := make([]byte, 64)
+		n, _ := req.Body.Read(buffer)
+		received = req.Header.Get("X-Env") + " " + string(buffer[:n])
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "body.txt"), []byte("hello {{who}}"), 0600)
+	content := "@url = " + server.URL + "\n\n### send\nPUT {{url}}/echo\nContent-Type: text/plain\nX-Env: {{stage}}\n\n< ./body.txt\n"
+	f, err := core.ParseHTTPFile([]byte(content), dir)
+	if err != nil {
+		t.Fatalf("ParseHTTPFile failed: %v", err)
+	}
+	r, err := f.Request("send", map[string]string{"stage": "ci", "who": "world", "url": "http://ignored"})
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if _, err := r.CallHTTP(); err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	if received != "ci hello world" {
+		t.Fatalf("unexpected request received: %q", received)
+	}
+}
+
+func TestExportHTTPFile(t *testing.T) {
+	db := openRevisionsDatabase(t)
+	db.SaveFolder("shop", core.Folder{
+		Variables: map[string]string{"baseUrl": "https://shop.example.com"},
+		Headers:   map[string]interface{}{"X-Client": "tanker"},
+		Auth:      &core.AuthConfig{Type: "bearer", Token: "{{token}}"},
+	})
+	requests := []core.Request{
+		{Name: "list orders", Method: "GET", URL: "{{baseUrl}}/orders", Collection: "shop", Params: map[string]interface{}{"status": "open", "q": "{{query}}"}},
+		{Name: "create order", Method: "POST", URL: "{{baseUrl}}/orders", Collection: "shop", Payload: map[string]interface{}{"item": "book"}},
+		{Name: "upload", Method: "POST", URL: "{{baseUrl}}/files", Collection: "shop", BodyType: "multipart",
+			Form: []core.FormField{{Name: "kind", Value: "invoice"}, {Name: "file", File: "/tmp/invoice.pdf"}}},
+		{Name: "signed", Method: "GET", URL: "https://other.example.com", Auth: &core.AuthConfig{Type: "hmac", Secret: "x"}},
+	}
+	for _, r := range requests {
+		if err := db.Add(r); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	data, warnings, err := db.ExportHTTPFile("shop")
+	if err != nil {
+		t.Fatalf("ExportHTTPFile failed: %v", err)
+	}
+	text := string(data)
+	for _, expected := range []string{
+		"@baseUrl = https://shop.example.com\n",
+		"### list orders\n# @name list orders\nGET {{baseUrl}}/orders?q={{query}}&status=open\nAuthorization: Bearer {{token}}\nX-Client: tanker\n",
+		"Content-Type: multipart/form-data; boundary=",
+		"< /tmp/invoice.pdf",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("missing %q in:\n%s", expected, text)
+		}
+	}
+	if strings.Contains(text, "signed") || len(warnings) != 0 {
+		t.Fatalf("request outside of the folder exported: %v\n%s", warnings, text)
+	}
+
+	// Read back, the export gives the same requests
+	f, err := core.ParseHTTPFile(data, "")
+	if err != nil {
+		t.Fatalf("ParseHTTPFile failed: %v", err)
+	}
+	if strings.Join(f.Names(), ",") != "create order,list orders,upload" {
+		t.Fatalf("unexpected names: %v", f.Names())
+	}
+	if create := f.Requests[0]; create.Payload["item"] != "book" || create.Auth == nil || create.Auth.Token != "{{token}}" {
+		t.Fatalf("round trip failed: %+v", create)
+	}
+	if list := f.Requests[1]; list.Params["q"] != "{{query}}" || list.URL != "{{baseUrl}}/orders" {
+		t.Fatalf("round trip failed: %+v", list)
+	}
+	if upload := f.Requests[2]; len(upload.Form) != 2 || upload.Form[0].Value != "invoice" || upload.Form[1].File != "/tmp/invoice.pdf" {
+		t.Fatalf("round trip failed: %+v", upload.Form)
+	}
+
+	_, warnings, _ = db.ExportHTTPFile("")
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "hmac") {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+
+	imp, err := core.ImportHTTPFile(data, "")
+	if err != nil || len(imp.Requests) != 3 || imp.Requests[0].Collection != "http" || imp.Folders["http"].Variables["baseUrl"] != "https://shop.example.com" {
+		t.Fatalf("ImportHTTPFile failed: %v %+v", err, imp)
+	}
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "client" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "read write" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_request"}`)
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	// The API rejects the first token to exercise the 401 refresh path
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer api.Close()
+
+	if err := core.Tokens.SetFile(filepath.Join(t.TempDir(), "tokens.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := core.Request{
+		Method:  "GET",
+		URL:     api.URL,
+		Headers: map[string]interface{}{},
+		Auth: &core.AuthConfig{
+			Type:         "oauth2",
+			TokenURL:     tokenServer.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+			Scopes:       []string{"read", "write"},
+		},
+	}
+
+	resp, err := r.CallHTTP()
+	if err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", resp.StatusCode)
+	}
+
+	// The refreshed token is served from the cache
+	if _, err := r.CallHTTP(); err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&issued); n != 2 {
+		t.Errorf("token endpoint called %d times, want 2", n)
+	}
+
+	tokens, err := core.Tokens.List()
+	if err != nil || len(tokens) != 1 || tokens[0].AccessToken != "token-2" {
+		t.Fatalf("unexpected token cache content: %+v (%v)", tokens, err)
+	}
+
+	if err := core.Tokens.Clear(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := core.Tokens.Peek(r.Auth); ok {
+		t.Errorf("token still cached after Clear")
+	}
+}
+
+func TestOAuth2AuthorizationCodePKCE(t *testing.T) {
+	var challenge string
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/authorize":
+			q := r.URL.Query()
+			if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "cli" {
+				http.Error(w, "bad authorize request", http.StatusBadRequest)
+				return
+			}
+			challenge = q.Get("code_challenge")
+			http.Redirect(w, r, q.Get("redirect_uri")+"?code=the-code&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+		case "/token":
+			r.ParseForm()
+			sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if r.Form.Get("grant_type") != "authorization_code" || r.Form.Get("code") != "the-code" ||
+				base64.RawURLEncoding.EncodeToString(sum[:]) != challenge || r.Form.Get("client_id") != "cli" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant"}`)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"user-token","refresh_token":"user-refresh","expires_in":600}`)
+		}
+	}))
+	defer authServer.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer user-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer api.Close()
+
+	if err := core.Tokens.SetFile(filepath.Join(t.TempDir(), "tokens.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &core.AuthConfig{
+		Type:        "oauth2",
+		GrantType:   "authorization_code",
+		AuthURL:     authServer.URL + "/authorize",
+		TokenURL:    authServer.URL + "/token",
+		ClientID:    "cli",
+		RedirectURL: "http://127.0.0.1:0/callback",
+	}
+
+	// The browser is simulated by following the authorize redirect, after
+	// stray requests to the callback that must not end the login
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	strays := make(chan int, 2)
+	err := core.Authorize(ctx, auth, false, func(authorizeURL string) {
+		go func() {
+			u, _ := url.Parse(authorizeURL)
+			callback := u.Query().Get("redirect_uri")
+			for _, query := range []string{"", "?code=forged&state=other"} {
+				resp, err := http.Get(callback + query)
+				if err == nil {
+					resp.Body.Close()
+					strays <- resp.StatusCode
+				}
+			}
+			resp, err := http.Get(authorizeURL)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+	})
+	if err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	if auth.AccessToken != "user-token" || auth.RefreshToken != "user-refresh" || auth.TokenExpiry == nil {
+		t.Fatalf("tokens not stored in auth config: %+v", auth)
+	}
+	close(strays)
+	for status := range strays {
+		if status != http.StatusBadRequest {
+			t.Errorf("stray callback answered %d, want 400", status)
+		}
+	}
+
+	// A fresh process only has the auth config to rely on
+	core.Tokens.Clear("")
+	r := core.Request{Method: "GET", URL: api.URL, Auth: auth}
+	resp, err := r.CallHTTP()
+	if err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("got status %d, want 204", resp.StatusCode)
+	}
+}
@@ -0,0 +1,298 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const petstoreV1 = `
+openapi: 3.0.3
+info:
+  title: Petstore
+  version: 1.0.0
+servers:
+  - url: https://petstore.example.com/{version}
+    description: production
+    variables:
+      version:
+        default: v1
+  - url: http://localhost:8080/v1
+security:
+  - bearerAuth: []
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      tags: [pets]
+      parameters:
+        - name: limit
+          in: query
+          schema: {type: integer, example: 10}
+        - name: cursor
+          in: query
+          schema: {type: string}
+      responses:
+        200:
+          description: ok
+    post:
+      operationId: createPet
+      tags: [pets]
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+      responses:
+        201:
+          description: created
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema: {type: string}
+    get:
+      operationId: getPet
+      tags: [pets]
+      responses:
+        200:
+          description: ok
+  /health:
+    get:
+      security: []
+      responses:
+        200:
+          description: ok
+  /admin/keys:
+    post:
+      operationId: createKey
+      security:
+        - apiKey: []
+      requestBody:
+        content:
+          application/x-www-form-urlencoded:
+            schema:
+              type: object
+              properties:
+                label: {type: string, example: ci}
+      responses:
+        200:
+          description: ok
+components:
+  securitySchemes:
+    bearerAuth:
+      type: http
+      scheme: bearer
+    apiKey:
+      type: apiKey
+      in: header
+      name: X-Admin-Key
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        id: {type: integer, readOnly: true}
+        name: {type: string, example: Rex}
+        tags:
+          type: array
+          items: {type: string}
+        owner:
+          $ref: '#/components/schemas/Owner'
+    Owner:
+      type: object
+      properties:
+        email: {type: string, format: email}
+        pets:
+          type: array
+          items:
+            $ref: '#/components/schemas/Pet'
+`
+
+func TestImportOpenAPI3(t *testing.T) {
+	imp, err := core.ImportOpenAPI([]byte(petstoreV1), "")
+	if err != nil {
+		t.Fatalf("ImportOpenAPI failed: %v", err)
+	}
+	requests := map[string]core.Request{}
+	for _, r := range imp.Requests {
+		requests[r.Name] = r
+	}
+	if len(requests) != 5 {
+		t.Fatalf("expected 5 requests, got %d", len(requests))
+	}
+
+	root := imp.Folders["Petstore"]
+	if root.Variables["baseUrl"] != "https://petstore.example.com/v1" || root.Auth == nil || root.Auth.Type != "bearer" {
+		t.Fatalf("unexpected root folder: %+v", root)
+	}
+	if env := imp.Environments["Petstore (production)"]; env.Variables["baseUrl"] != "https://petstore.example.com/v1" {
+		t.Fatalf("servers not turned into environments: %+v", imp.Environments)
+	}
+	if _, ok := imp.Environments["Petstore (http://localhost:8080/v1)"]; !ok {
+		t.Fatalf("servers not turned into environments: %+v", imp.Environments)
+	}
+
+	list := requests["listPets"]
+	if list.Collection != "Petstore/pets" || list.URL != "{{baseUrl}}/pets" || list.Params["limit"] != "10" || list.Params["cursor"] != nil {
+		t.Fatalf("unexpected request: %+v", list)
+	}
+	if get := requests["getPet"]; get.URL != "{{baseUrl}}/pets/{{petId}}" || get.Auth != nil {
+		t.Fatalf("unexpected request: %+v", get)
+	}
+	create := requests["createPet"]
+	if create.Payload["name"] != "Rex" || create.Payload["id"] != nil || create.Headers["Content-Type"] != "application/json" {
+		t.Fatalf("unexpected generated payload: %+v", create.Payload)
+	}
+	owner, _ := create.Payload["owner"].(map[string]interface{})
+	if owner["email"] != "user@example.com" {
+		t.Fatalf("unexpected nested example: %+v", create.Payload)
+	}
+	key := requests["createKey"]
+	if key.Auth == nil || key.Auth.Type != "api-key" || key.Auth.Header != "X-Admin-Key" || key.Auth.Key != "{{apiKey}}" {
+		t.Fatalf("unexpected auth: %+v", key.Auth)
+	}
+	if key.BodyType != "form" || len(key.Form) != 1 || key.Form[0].Value != "ci" {
+		t.Fatalf("unexpected form: %+v", key.Form)
+	}
+	if health := requests["get /health"]; health.Collection != "Petstore" || health.Source == nil || health.Source.Operation != "GET /health" {
+		t.Fatalf("unexpected request: %+v", health)
+	}
+	if !strings.Contains(strings.Join(imp.Warnings, "\n"), "no auth") {
+		t.Errorf("missing warning for the unauthenticated operation: %v", imp.Warnings)
+	}
+}
+
+func TestReimportOpenAPIKeepsLocalEdits(t *testing.T) {
+	db := openRevisionsDatabase(t)
+	imp, err := core.ImportOpenAPI([]byte(petstoreV1), "")
+	if err != nil {
+		t.Fatalf("ImportOpenAPI failed: %v", err)
+	}
+	if _, err := db.SaveImport(imp, false); err != nil {
+		t.Fatalf("SaveImport failed: %v", err)
+	}
+
+	// Local edits: new headers on listPets, getPet renamed and moved
+	list := db.Data["listPets"]
+	list.Headers = map[string]interface{}{"X-Trace": "1"}
+	if err := db.Update("listPets", list); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := db.Rename("getPet", "Get one pet"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	root := db.Folders["Petstore"]
+	root.Variables["baseUrl"] = "http://127.0.0.1:9000"
+	db.SaveFolder("Petstore", root)
+
+	v2 := strings.NewReplacer(
+		"schema: {type: integer, example: 10}", "schema: {type: integer, example: 50}",
+		"  /health:", "  /pets/{petId}/photos:\n    get:\n      operationId: listPhotos\n      responses:\n        200:\n          description: ok\n  /health:",
+		"operationId: getPet\n      tags: [pets]", "operationId: getPet\n      tags: [pets]\n      parameters:\n        - name: X-Version\n          in: header\n          required: true\n          schema: {type: string, example: '2'}",
+		"  /admin/keys:\n    post:\n      operationId: createKey", "  /admin/keys:\n    put:\n      operationId: createKey",
+	).Replace(petstoreV1)
+	imp, err = core.ImportOpenAPI([]byte(v2), "")
+	if err != nil {
+		t.Fatalf("ImportOpenAPI failed: %v", err)
+	}
+	report, err := db.SaveImport(imp, false)
+	if err != nil {
+		t.Fatalf("SaveImport failed: %v", err)
+	}
+
+	list = db.Data["listPets"]
+	if list.Params["limit"] != "50" || list.Headers["X-Trace"] != "1" {
+		t.Fatalf("re-import clobbered the local edit or missed the update: %+v", list)
+	}
+	if get, ok := db.Data["Get one pet"]; !ok || get.Headers["X-Version"] != "2" {
+		t.Fatalf("renamed request not updated: %+v", db.Data["Get one pet"])
+	}
+	if _, ok := db.Data["getPet"]; ok {
+		t.Fatal("renamed request imported again")
+	}
+	if _, ok := db.Data["listPhotos"]; !ok {
+		t.Fatal("new operation not added")
+	}
+	if db.Folders["Petstore"].Variables["baseUrl"] != "http://127.0.0.1:9000" {
+		t.Fatal("local folder variable clobbered")
+	}
+	if len(report.Added) != 2 || report.Added[0] != "createKey 2" || report.Added[1] != "listPhotos" {
+		t.Fatalf("unexpected added requests: %+v", report)
+	}
+	if len(report.Kept) != 1 || report.Kept[0] != "listPets: headers" {
+		t.Fatalf("unexpected kept fields: %+v", report.Kept)
+	}
+	if len(report.Removed) != 1 || report.Removed[0] != "createKey" {
+		t.Fatalf("unexpected removed requests: %+v", report.Removed)
+	}
+}
+
+func TestImportSwagger2(t *testing.T) {
+	spec := `{
+  "swagger": "2.0",
+  "info": {"title": "Legacy", "version": "1"},
+  "host": "legacy.example.com",
+  "basePath": "/api",
+  "schemes": ["https"],
+  "securityDefinitions": {
+    "key": {"type": "apiKey", "in": "query", "name": "api_key"},
+    "oauth": {"type": "oauth2", "flow": "application", "tokenUrl": "https://auth.example.com/token", "scopes": {"read": ""}}
+  },
+  "security": [{"key": []}],
+  "paths": {
+    "/items/{id}": {
+      "put": {
+        "operationId": "updateItem",
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "type": "integer", "x-example": 7},
+          {"name": "body", "in": "body", "schema": {"$ref": "#/definitions/Item"}}
+        ]
+      }
+    },
+    "/upload": {
+      "post": {
+        "operationId": "upload",
+        "consumes": ["multipart/form-data"],
+        "security": [{"oauth": ["read"]}],
+        "parameters": [
+          {"name": "file", "in": "formData", "type": "file"},
+          {"name": "title", "in": "formData", "type": "string", "default": "photo"}
+        ]
+      }
+    }
+  },
+  "definitions": {
+    "Item": {"type": "object", "properties": {"name": {"type": "string"}, "price": {"type": "number"}}}
+  }
+}`
+	imp, err := core.ImportOpenAPI([]byte(spec), "legacy")
+	if err != nil {
+		t.Fatalf("ImportOpenAPI failed: %v", err)
+	}
+	requests := map[string]core.Request{}
+	for _, r := range imp.Requests {
+		requests[r.Name] = r
+	}
+	root := imp.Folders["legacy"]
+	if root.Variables["baseUrl"] != "https://legacy.example.com/api" || root.Variables["id"] != "7" || root.Auth != nil {
+		t.Fatalf("unexpected root folder: %+v", root)
+	}
+	update := requests["updateItem"]
+	if update.URL != "{{baseUrl}}/items/{{id}}" || update.Params["api_key"] != "{{apiKey}}" || update.Payload["name"] != "string" {
+		t.Fatalf("unexpected request: %+v", update)
+	}
+	upload := requests["upload"]
+	if upload.BodyType != "multipart" || len(upload.Form) != 2 || upload.Form[1].Value != "photo" {
+		t.Fatalf("unexpected form: %+v", upload)
+	}
+	if upload.Auth == nil || upload.Auth.GrantType != "client_credentials" || upload.Auth.TokenURL != "https://auth.example.com/token" || len(upload.Auth.Scopes) != 1 {
+		t.Fatalf("unexpected auth: %+v", upload.Auth)
+	}
+
+	if _, err := core.ImportOpenAPI([]byte("openapi: 2.0\n"), ""); err == nil {
+		t.Fatal("expected an error for an unknown version")
+	}
+}
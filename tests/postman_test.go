@@ -0,0 +1,274 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+const postmanCollection = `{
+  "info": {
+    "name": "Shop API",
+    "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+  },
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]},
+  "variable": [{"key": "baseUrl", "value": "https://shop.example.com"}],
+  "item": [
+    {
+      "name": "Users",
+      "item": [
+        {
+          "name": "Get user",
+          "request": {
+            "method": "GET",
+            "header": [{"key": "Accept", "value": "application/json"}, {"key": "X-Debug", "value": "1", "disabled": true}],
+            "url": {"raw": "{{baseUrl}}/users/:id?verbose=true", "host": ["{{baseUrl}}"], "path": ["users", ":id"], "variable": [{"key": "id", "value": ""}]}
+          },
+          "event": [{"listen": "test", "script": {"exec": ["pm.test('ok', () => {})"]}}],
+          "response": [{"name": "200"}]
+        },
+        {
+          "name": "Create user",
+          "request": {
+            "method": "POST",
+            "header": [],
+            "body": {"mode": "raw", "raw": "{\"name\": \"bob\"}", "options": {"raw": {"language": "json"}}},
+            "url": "{{baseUrl}}/users"
+          }
+        }
+      ]
+    },
+    {
+      "name": "Login",
+      "request": {
+        "method": "POST",
+        "header": [],
+        "auth": {"type": "noauth"},
+        "body": {"mode": "urlencoded", "urlencoded": [{"key": "user", "value": "bob"}, {"key": "debug", "value": "1", "disabled": true}]},
+        "url": "{{baseUrl}}/login"
+      }
+    },
+    {
+      "name": "Upload",
+      "request": {
+        "method": "POST",
+        "header": [],
+        "auth": {"type": "basic", "basic": [{"key": "username", "value": "admin"}, {"key": "password", "value": "secret"}]},
+        "body": {"mode": "formdata", "formdata": [{"key": "title", "value": "cat", "type": "text"}, {"key": "image", "type": "file", "src": "/tmp/cat.png"}]},
+        "url": "{{baseUrl}}/upload"
+      }
+    },
+    {
+      "name": "Signed",
+      "request": {
+        "method": "GET",
+        "auth": {"type": "hawk", "hawk": []},
+        "url": "{{baseUrl}}/signed"
+      }
+    }
+  ]
+}`
+
+func TestImportPostmanCollection(t *testing.T) {
+	imp, err := core.ImportPostman([]byte(postmanCollection), "")
+	if err != nil {
+		t.Fatalf("ImportPostman failed: %v", err)
+	}
+	requests := map[string]core.Request{}
+	for _, r := range imp.Requests {
+		requests[r.Name] = r
+	}
+	if len(requests) != 5 {
+		t.Fatalf("expected 5 requests, got %d", len(imp.Requests))
+	}
+
+	root, ok := imp.Folders["Shop API"]
+	if !ok || root.Variables["baseUrl"] != "https://shop.example.com" || root.Auth == nil || root.Auth.Token != "{{token}}" {
+		t.Fatalf("unexpected root folder: %+v", root)
+	}
+
+	get := requests["Get user"]
+	if get.Collection != "Shop API/Users" || get.URL != "{{baseUrl}}/users/{{id}}?verbose=true" {
+		t.Fatalf("unexpected request: %+v", get)
+	}
+	if get.Headers["Accept"] != "application/json" || get.Headers["X-Debug"] != nil {
+		t.Fatalf("unexpected headers: %v", get.Headers)
+	}
+
+	create := requests["Create user"]
+	if create.Payload["name"] != "bob" || create.Headers["Content-Type"] != "application/json" {
+		t.Fatalf("raw JSON body not mapped: %+v", create)
+	}
+	login := requests["Login"]
+	if login.BodyType != "form" || len(login.Form) != 1 || login.Form[0].Value != "bob" {
+		t.Fatalf("urlencoded body not mapped: %+v", login)
+	}
+	upload := requests["Upload"]
+	if upload.BodyType != "multipart" || len(upload.Form) != 2 || upload.Form[1].File != "/tmp/cat.png" {
+		t.Fatalf("form-data body not mapped: %+v", upload)
+	}
+	if upload.Auth == nil || upload.Auth.Type != "basic" || upload.Auth.Password != "secret" {
+		t.Fatalf("basic auth not mapped: %+v", upload.Auth)
+	}
+
+	warnings := strings.Join(imp.Warnings, "\n")
+	for _, expected := range []string{"test script", "saved example", "hawk", "no auth"} {
+		if !strings.Contains(warnings, expected) {
+			t.Errorf("missing warning about %s in:\n%s", expected, warnings)
+		}
+	}
+}
+
+func TestImportPostmanEnvironmentAndVariables(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received = req
+	}))
+	defer server.Close()
+
+	db := openRevisionsDatabase(t)
+	imp, err := core.ImportPostman([]byte(postmanCollection), "shop")
+	if err != nil {
+		t.Fatalf("ImportPostman failed: %v", err)
+	}
+	if _, err := db.SaveImport(imp, false); err != nil {
+		t.Fatalf("SaveImport failed: %v", err)
+	}
+
+	env := `{"name": "local", "values": [
+		{"key": "baseUrl", "value": "` + server.URL + `", "enabled": true},
+		{"key": "token", "value": "abc", "enabled": true},
+		{"key": "unused", "value": "x", "enabled": false}
+	], "_postman_variable_scope": "environment"}`
+	imp, err = core.ImportPostman([]byte(env), "")
+	if err != nil {
+		t.Fatalf("ImportPostman failed: %v", err)
+	}
+	if _, err := db.SaveImport(imp, false); err != nil {
+		t.Fatalf("SaveImport failed: %v", err)
+	}
+	if len(db.Environments["local"].Variables) != 2 {
+		t.Fatalf("unexpected environment: %+v", db.Environments["local"])
+	}
+
+	// Without environment the folder variable applies
+	r, err := db.Resolve("Get user")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if !strings.HasPrefix(r.URL, "https://shop.example.com/users/") {
+		t.Fatalf("folder variable not applied: %s", r.URL)
+	}
+	if missing := core.MissingVariables(r); len(missing) != 2 || missing[0] != "id" || missing[1] != "token" {
+		t.Fatalf("unexpected missing variables: %v", missing)
+	}
+
+	if err := db.UseEnvironment("local"); err != nil {
+		t.Fatalf("UseEnvironment failed: %v", err)
+	}
+	r, err = db.Resolve("Create user")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if _, err := r.CallHTTP(); err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	if received == nil || received.URL.Path != "/users" || received.Header.Get("Authorization") != "Bearer abc" {
+		t.Fatalf("environment not applied: %+v", received)
+	}
+
+	// Saving again skips the existing requests
+	imp, _ = core.ImportPostman([]byte(postmanCollection), "shop")
+	report, err := db.SaveImport(imp, false)
+	if err != nil {
+		t.Fatalf("SaveImport failed: %v", err)
+	}
+	if len(report.Added) != 0 || len(report.Skipped) != 5 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestExportPostmanRoundTrip(t *testing.T) {
+	db := openRevisionsDatabase(t)
+	imp, err := core.ImportPostman([]byte(postmanCollection), "shop")
+	if err != nil {
+		t.Fatalf("ImportPostman failed: %v", err)
+	}
+	if _, err := db.SaveImport(imp, false); err != nil {
+		t.Fatalf("SaveImport failed: %v", err)
+	}
+
+	data, _, err := db.ExportPostman("shop")
+	if err != nil {
+		t.Fatalf("ExportPostman failed: %v", err)
+	}
+	var exported struct {
+		Info struct{ Name, Schema string }
+		Item []struct {
+			Name string
+			Item []json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatalf("invalid export: %v", err)
+	}
+	if exported.Info.Name != "shop" || exported.Info.Schema != core.PostmanSchema {
+		t.Fatalf("unexpected info: %+v", exported.Info)
+	}
+	if len(exported.Item) != 4 || exported.Item[0].Name != "Users" || len(exported.Item[0].Item) != 2 {
+		t.Fatalf("unexpected items: %s", data)
+	}
+
+	again, err := core.ImportPostman(data, "copy")
+	if err != nil {
+		t.Fatalf("re-import failed: %v", err)
+	}
+	requests := map[string]core.Request{}
+	for _, r := range again.Requests {
+		requests[r.Name] = r
+	}
+	if r := requests["Create user"]; r.Collection != "copy/Users" || r.Payload["name"] != "bob" || r.URL != "{{baseUrl}}/users" {
+		t.Fatalf("round trip changed the request: %+v", r)
+	}
+	if r := requests["Upload"]; r.BodyType != "multipart" || r.Form[1].File != "/tmp/cat.png" || r.Auth == nil || r.Auth.Username != "admin" {
+		t.Fatalf("round trip changed the request: %+v", r)
+	}
+	if root := again.Folders["copy"]; root.Variables["baseUrl"] != "https://shop.example.com" || root.Auth == nil || root.Auth.Token != "{{token}}" {
+		t.Fatalf("round trip changed the root folder: %+v", root)
+	}
+
+	db.SaveEnvironment("prod", core.Environment{Variables: map[string]string{"baseUrl": "https://prod"}})
+	data, err = db.ExportPostmanEnvironment("prod")
+	if err != nil {
+		t.Fatalf("ExportPostmanEnvironment failed: %v", err)
+	}
+	imp, err = core.ImportPostman(data, "")
+	if err != nil || imp.Environments["prod"].Variables["baseUrl"] != "https://prod" {
+		t.Fatalf("environment round trip failed: %v %+v", err, imp.Environments)
+	}
+
+	// An existing environment keeps its values and is reported as skipped
+	imp.Environments["prod"].Variables["token"] = "t0k3n"
+	imp.Environments["prod"].Variables["baseUrl"] = "https://other"
+	report, err := db.SaveImport(imp, false)
+	if err != nil || len(report.Skipped) != 1 || report.Skipped[0] != "environment prod" {
+		t.Fatalf("unexpected report: %v %+v", err, report)
+	}
+	if env := db.Environments["prod"]; env.Variables["baseUrl"] != "https://prod" || env.Variables["token"] != "t0k3n" {
+		t.Fatalf("unexpected environment: %+v", env)
+	}
+}
+
+func TestPostmanUnsupportedSchema(t *testing.T) {
+	_, err := core.ImportPostman([]byte(`{"info": {"name": "old", "schema": "https://schema.getpostman.com/json/collection/v2.0.0/collection.json"}, "item": []}`), "")
+	if err == nil || !strings.Contains(err.Error(), "v2.1") {
+		t.Fatalf("expected a schema error, got %v", err)
+	}
+	if _, err := core.ImportPostman([]byte(`{"foo": 1}`), ""); err == nil {
+		t.Fatal("expected an error for an unknown document")
+	}
+}
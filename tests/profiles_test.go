@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestAuthProfiles(t *testing.T) {
+	dir := t.TempDir()
+	database := &core.Database{
+		DatabaseDir:  dir,
+		DatabaseFile: filepath.Join(dir, "http-tanker-data-test.json"),
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+
+	if err := database.SaveProfile("staging", core.AuthConfig{Type: "bearer", Token: "old"}); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+	database.Data["orders"] = core.Request{Name: "orders", Method: "GET", URL: "http://localhost/orders", AuthProfile: "staging"}
+	if err := database.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Rotating the profile is picked up by every request referencing it
+	if err := database.SaveProfile("staging", core.AuthConfig{Type: "bearer", Token: "rotated"}); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+	if err := database.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	r, err := database.Resolve("orders")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if r.Auth == nil || r.Auth.Token != "rotated" {
+		t.Errorf("profile not applied, got %+v", r.Auth)
+	}
+
+	if err := database.DeleteProfile("staging"); err == nil {
+		t.Errorf("DeleteProfile should refuse a profile still in use")
+	}
+	if err := database.Delete("orders"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := database.DeleteProfile("staging"); err != nil {
+		t.Errorf("DeleteProfile failed: %v", err)
+	}
+}
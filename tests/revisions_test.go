@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func openRevisionsDatabase(t *testing.T) *core.Database {
+	dir := t.TempDir()
+	database := &core.Database{DatabaseDir: dir, DatabaseFile: filepath.Join(dir, "http-tanker-data.json")}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	return database
+}
+
+func TestRevisionsAndRollback(t *testing.T) {
+	database := openRevisionsDatabase(t)
+
+	database.Data["users"] = core.Request{Name: "users", Method: "GET", URL: "http://localhost/v1/users"}
+	if err := database.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	updated := database.Data["users"]
+	updated.URL = "http://localhost/v2/users"
+	database.Data["users"] = updated
+	if err := database.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	// Running a request is not a change
+	if err := database.Touch("users"); err != nil {
+		t.Fatalf("Touch failed: %v", err)
+	}
+
+	revisions, err := database.Revisions("users")
+	if err != nil {
+		t.Fatalf("Revisions failed: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Action != core.RevisionUpdate || revisions[1].Action != core.RevisionCreate {
+		t.Fatalf("unexpected revisions: %+v", revisions)
+	}
+
+	diff, err := database.DiffRevisions("users", revisions[1].ID, revisions[0].ID)
+	if err != nil {
+		t.Fatalf("DiffRevisions failed: %v", err)
+	}
+	if !strings.Contains(diff, "-     \"url\": \"http://localhost/v1/users\"") || !strings.Contains(diff, "+     \"url\": \"http://localhost/v2/users\"") {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+
+	if err := database.Rollback("users", revisions[1].ID); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if url := database.Data["users"].URL; url != "http://localhost/v1/users" {
+		t.Fatalf("rollback kept %s", url)
+	}
+	if database.Data["users"].LastUsed == nil {
+		t.Fatal("rollback lost the last used date")
+	}
+	if err := database.Undo("users"); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if url := database.Data["users"].URL; url != "http://localhost/v2/users" {
+		t.Fatalf("undo kept %s", url)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	database := openRevisionsDatabase(t)
+
+	if err := database.Delete("get-example"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	trash, err := database.Trash()
+	if err != nil {
+		t.Fatalf("Trash failed: %v", err)
+	}
+	if len(trash) != 1 || trash[0].Name != "get-example" || trash[0].Request.URL == "" {
+		t.Fatalf("unexpected trash: %+v", trash)
+	}
+
+	if err := database.Restore("get-example"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if _, ok := database.Data["get-example"]; !ok {
+		t.Fatal("request not restored")
+	}
+	if trash, _ := database.Trash(); len(trash) != 0 {
+		t.Fatalf("trash not emptied by the restore: %+v", trash)
+	}
+
+	// A reset goes to the trash too
+	count := len(database.Data)
+	if err := database.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if trash, _ := database.Trash(); len(trash) != count {
+		t.Fatalf("expected %d requests in the trash, got %d", count, len(trash))
+	}
+	if err := database.EmptyTrash(); err != nil {
+		t.Fatalf("EmptyTrash failed: %v", err)
+	}
+	if trash, _ := database.Trash(); len(trash) != 0 {
+		t.Fatalf("trash not emptied: %+v", trash)
+	}
+}
+
+func TestRenameCollision(t *testing.T) {
+	database := openRevisionsDatabase(t)
+
+	r := database.Data["get-example"]
+	r.Name = "post-example"
+	if err := database.Update("get-example", r); err == nil {
+		t.Fatal("rename over an existing request must fail")
+	}
+	if _, ok := database.Data["get-example"]; !ok {
+		t.Fatal("failed rename removed the request")
+	}
+
+	if err := database.Rename("get-example", "renamed"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if _, ok := database.Data["get-example"]; ok {
+		t.Fatal("old name still present")
+	}
+	revisions, err := database.Revisions("renamed")
+	if err != nil {
+		t.Fatalf("Revisions failed: %v", err)
+	}
+	if len(revisions) == 0 || revisions[0].Action != core.RevisionRename || revisions[0].From != "get-example" {
+		t.Fatalf("unexpected revisions: %+v", revisions)
+	}
+	// A rename is not a deletion
+	if trash, _ := database.Trash(); len(trash) != 0 {
+		t.Fatalf("renamed request in the trash: %+v", trash)
+	}
+}
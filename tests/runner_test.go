@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func newRunnerServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/users":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[{"id": 1, "name": "bob"}, {"id": 2, "name": "alice"}]`))
+		case "/health":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status": "ok", "checks": {"db": true}}`))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+}
+
+func TestAssertions(t *testing.T) {
+	server := newRunnerServer()
+	defer server.Close()
+
+	r := core.Request{Method: "GET", URL: server.URL + "/users"}
+	resp, err := r.CallHTTP()
+	if err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+
+	cases := []struct {
+		assertion core.Assertion
+		passed    bool
+	}{
+		{core.Assertion{Type: "status", Value: "200"}, true},
+		{core.Assertion{Type: "status", Value: "2xx"}, true},
+		{core.Assertion{Type: "status", Value: "4xx"}, false},
+		{core.Assertion{Type: "status", Operator: "lt", Value: "300"}, true},
+		{core.Assertion{Type: "header", Target: "content-type", Operator: "contains", Value: "json"}, true},
+		{core.Assertion{Type: "header", Target: "X-Missing", Operator: "not_exists"}, true},
+		{core.Assertion{Type: "jsonpath", Target: "$[0].name", Value: "bob"}, true},
+		{core.Assertion{Type: "jsonpath", Target: "$[-1].id", Value: "2"}, true},
+		{core.Assertion{Type: "jsonpath", Target: "$.length()", Operator: "gte", Value: "2"}, true},
+		{core.Assertion{Type: "jsonpath", Target: "$[1]['name']", Operator: "matches", Value: "^al"}, true},
+		{core.Assertion{Type: "jsonpath", Target: "$[5].id", Operator: "exists"}, false},
+		{core.Assertion{Type: "time", Value: "5000"}, true},
+		{core.Assertion{Type: "body", Value: `"name": "alice"`}, true},
+		{core.Assertion{Type: "body", Operator: "not_contains", Value: "carol"}, true},
+	}
+	for _, c := range cases {
+		result := c.assertion.Check(resp)
+		if result.Passed != c.passed {
+			t.Errorf("%s: passed = %v, expected %v (actual %q, %s)", c.assertion, result.Passed, c.passed, result.Actual, result.Message)
+		}
+	}
+
+	failed := core.Assertion{Type: "jsonpath", Target: "$[0].id", Value: "7"}.Check(resp)
+	if failed.Actual != "1" || failed.Message != "expected jsonpath $[0].id equals 7, got 1" {
+		t.Fatalf("unexpected failure: %+v", failed)
+	}
+
+	for _, invalid := range []core.Assertion{
+		{Type: "cookie"},
+		{Type: "status", Operator: "near", Value: "200"},
+		{Type: "header", Value: "x"},
+		{Type: "jsonpath", Target: "items.id"},
+		{Type: "time", Operator: "equals", Value: "100"},
+		{Type: "status", Operator: "gt", Value: "abc"},
+		{Type: "body", Operator: "matches", Value: "("},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+	if err := core.ValidateAssertions([]core.Assertion{{Type: "status", Value: "200"}, {Type: "nope"}}); err == nil || !strings.HasPrefix(err.Error(), "assertion 2:") {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+}
+
+func TestRunCollection(t *testing.T) {
+	server := newRunnerServer()
+	defer server.Close()
+
+	db := openRevisionsDatabase(t)
+	db.SaveFolder("smoke", core.Folder{Variables: map[string]string{"base": server.URL}})
+	requests := []core.Request{
+		{Name: "a users", Method: "GET", URL: "{{base}}/users", Collection: "smoke", Assertions: []core.Assertion{
+			{Type: "status", Value: "200"},
+			{Type: "jsonpath", Target: "$[0].name", Value: "bob"},
+		}},
+		{Name: "b health", Method: "GET", URL: "{{base}}/health", Collection: "smoke", Tags: []string{"critical"}, Assertions: []core.Assertion{
+			{Type: "jsonpath", Target: "$.checks.db", Value: "false"},
+		}},
+		{Name: "c missing", Method: "GET", URL: "{{base}}/missing", Collection: "smoke"},
+		{Name: "d expected 404", Method: "GET", URL: "{{base}}/missing", Collection: "smoke", Assertions: []core.Assertion{{Type: "status", Value: "404"}}},
+	}
+	for _, r := range requests {
+		if err := db.Add(r); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	for _, parallel := range []int{1, 3} {
+		var progress []string
+		report, err := db.RunCollection("smoke", core.RunOptions{Parallel: parallel}, func(result core.RunResult) {
+			progress = append(progress, result.Name)
+		})
+		if err != nil {
+			t.Fatalf("RunCollection failed: %v", err)
+		}
+		if report.Passed != 2 || report.Failed != 2 || len(progress) != 4 {
+			t.Fatalf("parallel %d: unexpected report: %+v", parallel, report)
+		}
+		// The results keep the order of the collection
+		if report.Results[0].Name != "a users" || report.Results[3].Name != "d expected 404" || report.Results[0].URL != server.URL+"/users" {
+			t.Fatalf("unexpected results: %+v", report.Results)
+		}
+		if failures := report.Results[2].Failures(); len(failures) != 1 || failures[0] != "status 404" {
+			t.Fatalf("unexpected failures: %v", failures)
+		}
+	}
+
+	report, _ := db.RunCollection("smoke", core.RunOptions{Bail: true}, nil)
+	if report.Passed != 1 || report.Failed != 1 || report.Skipped != 2 || !report.Results[3].Skipped {
+		t.Fatalf("bail did not skip the remaining requests: %+v", report)
+	}
+
+	tagged, _ := db.RunCollection("smoke", core.RunOptions{Tag: "critical"}, nil)
+	if len(tagged.Results) != 1 || tagged.Results[0].Name != "b health" {
+		t.Fatalf("unexpected tagged run: %+v", tagged)
+	}
+	if _, err := db.RunCollection("nowhere", core.RunOptions{}, nil); err == nil {
+		t.Fatal("expected an error for an empty collection")
+	}
+
+	history, _ := db.ListHistory("a users", 0)
+	if len(history) != 3 {
+		t.Fatalf("runs not recorded in the history: %d", len(history))
+	}
+}
+
+func TestRunReports(t *testing.T) {
+	report := core.RunReport{
+		Collection: "smoke",
+		Passed:     1,
+		Failed:     2,
+		Results: []core.RunResult{
+			{Name: "ok", Method: "GET", StatusCode: 200, TimeMillisec: 12, Assertions: []core.AssertionResult{{Assertion: core.Assertion{Type: "status", Value: "200"}, Passed: true}}},
+			{Name: "wrong", Method: "POST", StatusCode: 500, Assertions: []core.AssertionResult{{Assertion: core.Assertion{Type: "status", Value: "201"}, Message: "expected status equals 201, got 500"}}},
+			{Name: "down", Method: "GET", Error: "connection refused"},
+		},
+	}
+
+	tap := string(report.TAP())
+	for _, expected := range []string{"TAP version 13\n1..3\n", "ok 1 - GET ok\n", "not ok 2 - POST wrong\n", `    - "expected status equals 201, got 500"`, "not ok 3 - GET down\n"} {
+		if !strings.Contains(tap, expected) {
+			t.Fatalf("missing %q in:\n%s", expected, tap)
+		}
+	}
+
+	data, err := report.JUnit()
+	if err != nil {
+		t.Fatalf("JUnit failed: %v", err)
+	}
+	var junit struct {
+		Suites []struct {
+			Tests    int `xml:"tests,attr"`
+			Failures int `xml:"failures,attr"`
+			Errors   int `xml:"errors,attr"`
+			Cases    []struct {
+				Name    string `xml:"name,attr"`
+				Time    string `xml:"time,attr"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(data, &junit); err != nil {
+		t.Fatalf("invalid JUnit XML: %v\n%s", err, data)
+	}
+	suite := junit.Suites[0]
+	if suite.Tests != 3 || suite.Failures != 1 || suite.Errors != 1 || suite.Cases[0].Time != "0.012" {
+		t.Fatalf("unexpected suite: %+v", suite)
+	}
+	if suite.Cases[1].Failure == nil || suite.Cases[1].Failure.Message != "expected status equals 201, got 500" {
+		t.Fatalf("unexpected failure: %+v", suite.Cases[1])
+	}
+
+	if data, err := report.JSON(); err != nil || !strings.Contains(string(data), `"error": "connection refused"`) {
+		t.Fatalf("unexpected JSON report: %v\n%s", err, data)
+	}
+}
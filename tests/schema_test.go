@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestMigrateBareMap(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "http-tanker-data-test.json")
+	os.WriteFile(file, []byte(`{"legacy":{"name":"legacy","method":"GET","url":"http://localhost","headers":{},"authProfile":"staging"}}`), 0600)
+	os.WriteFile(filepath.Join(dir, "http-tanker-profiles.json"), []byte(`{"staging":{"type":"bearer","token":"secret"}}`), 0600)
+
+	database := &core.Database{DatabaseDir: dir, DatabaseFile: file}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	r, err := database.Resolve("legacy")
+	if err != nil || r.Auth == nil || r.Auth.Token != "secret" {
+		t.Fatalf("migrated request not resolved: %+v %v", r, err)
+	}
+
+	content, _ := os.ReadFile(file)
+	if !strings.Contains(string(content), fmt.Sprintf(`"version":%d`, core.SchemaVersion)) {
+		t.Errorf("file not upgraded: %s", content)
+	}
+	if backups, _ := filepath.Glob(file + ".v1-*.bak"); len(backups) != 1 {
+		t.Errorf("expected a backup of the version 1 file, got %v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "http-tanker-profiles.json")); !os.IsNotExist(err) {
+		t.Errorf("profiles file should have been absorbed")
+	}
+}
+
+func TestRefuseNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "http-tanker-data-test.json")
+	newer := `{"version":99,"requests":{},"somethingNew":true}`
+	os.WriteFile(file, []byte(newer), 0600)
+
+	database := &core.Database{DatabaseDir: dir, DatabaseFile: file}
+	if err := database.InitDB(); err == nil || !strings.Contains(err.Error(), "schema version 99") {
+		t.Fatalf("expected a schema version error, got %v", err)
+	}
+	if content, _ := os.ReadFile(file); string(content) != newer {
+		t.Errorf("newer file modified: %s", content)
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	if core.FuzzyScore("ordr", "list-orders") < 0 {
+		t.Errorf("subsequence should match")
+	}
+	if core.FuzzyScore("xyz", "list-orders") >= 0 {
+		t.Errorf("unexpected match")
+	}
+	if core.FuzzyScore("orders", "list-orders") <= core.FuzzyScore("lors", "list-orders") {
+		t.Errorf("substring should rank before subsequence")
+	}
+}
+
+func TestSearchRequests(t *testing.T) {
+	dir := t.TempDir()
+	database := &core.Database{
+		DatabaseDir:  dir,
+		DatabaseFile: filepath.Join(dir, "http-tanker-data-test.json"),
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	yesterday := time.Now().Add(-24 * time.Hour)
+	database.Data = map[string]core.Request{
+		"create-order": {Name: "create-order", Method: "POST", URL: "https://shop/orders", Tags: []string{"billing"}},
+		"list-orders":  {Name: "list-orders", Method: "GET", URL: "https://shop/orders", Tags: []string{"smoke", "billing"}, LastUsed: &yesterday},
+		"health":       {Name: "health", Method: "GET", URL: "https://shop/health", Tags: []string{"smoke"}},
+	}
+	if err := database.Touch("health"); err != nil {
+		t.Fatalf("Touch failed: %v", err)
+	}
+
+	if got := database.Search("", "smoke", core.SortByName); !reflect.DeepEqual(got, []string{"health", "list-orders"}) {
+		t.Errorf("tag filter: got %v", got)
+	}
+	if got := database.Search("", "", core.SortByLastUsed); !reflect.DeepEqual(got, []string{"health", "list-orders", "create-order"}) {
+		t.Errorf("last used sort: got %v", got)
+	}
+	if got := database.Search("", "", core.SortByMethod); !reflect.DeepEqual(got, []string{"health", "list-orders", "create-order"}) {
+		t.Errorf("method sort: got %v", got)
+	}
+	if got := database.Search("billing", "", ""); !reflect.DeepEqual(got, []string{"create-order", "list-orders"}) {
+		t.Errorf("query on tags: got %v", got)
+	}
+}
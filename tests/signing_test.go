@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestHMACSigning(t *testing.T) {
+	var verified bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, r.ContentLength)
+		r.Body.Read(body)
+		mac := hmac.New(sha256.New, []byte("partner-secret"))
+		mac.Write([]byte(r.Method + "|" + r.URL.Path + "|" + r.Header.Get("X-Timestamp") + "|" + string(body)))
+		expected := "v1=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		verified = r.Header.Get("X-Partner-Signature") == expected
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	r := core.Request{
+		Method:  "POST",
+		URL:     srv.URL + "/webhooks/orders",
+		Payload: map[string]interface{}{"id": 42},
+		Auth: &core.AuthConfig{
+			Type:            "hmac",
+			Secret:          "partner-secret",
+			Template:        "{method}|{path}|{timestamp}|{body}",
+			Encoding:        "base64",
+			Header:          "X-Partner-Signature",
+			SignaturePrefix: "v1=",
+			TimestampHeader: "X-Timestamp",
+		},
+	}
+	if _, err := r.CallHTTP(); err != nil {
+		t.Fatalf("CallHTTP failed: %v", err)
+	}
+	if !verified {
+		t.Errorf("HMAC signature rejected by the server")
+	}
+}
+
+func TestJWTSigning(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+
+	auth := &core.AuthConfig{
+		Type:      "jwt",
+		Algorithm: "RS256",
+		KeyFile:   keyFile,
+		KeyID:     "key-1",
+		Claims:    `{"iss": "tanker", "sub": "svc-orders", "iat": {now}, "exp": {exp}}`,
+		TTL:       "2m",
+	}
+	token, err := core.MintJWT(auth)
+	if err != nil {
+		t.Fatalf("MintJWT failed: %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed token %q", token)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("invalid RS256 signature: %v", err)
+	}
+
+	var header map[string]string
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	json.Unmarshal(headerJSON, &header)
+	if header["alg"] != "RS256" || header["kid"] != "key-1" {
+		t.Errorf("unexpected header %v", header)
+	}
+
+	var claims map[string]interface{}
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(claimsJSON, &claims)
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+	if claims["sub"] != "svc-orders" || time.Duration(exp-iat)*time.Second != 2*time.Minute {
+		t.Errorf("unexpected claims %v", claims)
+	}
+}
+
+func TestJWTAlgorithmName(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	keyFile := filepath.Join(t.TempDir(), "ed25519.pem")
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+
+	// Matched whatever the case, written with its canonical name
+	for _, c := range []struct{ algorithm, keyFile, alg string }{{"eddsa", keyFile, "EdDSA"}, {"hs384", "", "HS384"}, {"", "", "HS256"}} {
+		token, err := core.MintJWT(&core.AuthConfig{Type: "jwt", Algorithm: c.algorithm, KeyFile: c.keyFile, Secret: "s3cret"})
+		if err != nil {
+			t.Fatalf("MintJWT(%s) failed: %v", c.algorithm, err)
+		}
+		parts := strings.Split(token, ".")
+		var header map[string]string
+		headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+		json.Unmarshal(headerJSON, &header)
+		if header["alg"] != c.alg {
+			t.Errorf("%s: unexpected header %v", c.algorithm, header)
+		}
+		if c.alg == "EdDSA" {
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			if !ed25519.Verify(key.Public().(ed25519.PublicKey), []byte(parts[0]+"."+parts[1]), signature) {
+				t.Errorf("invalid EdDSA signature")
+			}
+		}
+	}
+	if _, err := core.MintJWT(&core.AuthConfig{Type: "jwt", Algorithm: "none"}); err == nil {
+		t.Fatal("expected an error for an unsupported algorithm")
+	}
+}
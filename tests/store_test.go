@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func openDatabase(t *testing.T, dir string) *core.Database {
+	database := &core.Database{
+		DatabaseDir:  dir,
+		DatabaseFile: filepath.Join(dir, "http-tanker-data-test.json"),
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	return database
+}
+
+// Two processes sharing the database directory, e.g. the TUI and an MCP server
+func TestConcurrentSavesMerge(t *testing.T) {
+	dir := t.TempDir()
+	tui := openDatabase(t, dir)
+	server := openDatabase(t, dir)
+
+	tui.Data["from-tui"] = core.Request{Name: "from-tui", Method: "GET", URL: "http://localhost/tui"}
+	if err := tui.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if !server.Changed() {
+		t.Errorf("change made by another process not detected")
+	}
+
+	// The server still holds the previous content in memory
+	if err := server.Delete("get-example"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := server.Data["from-tui"]; !ok {
+		t.Errorf("save erased the request added by another process")
+	}
+	if _, ok := server.Data["get-example"]; ok {
+		t.Errorf("local deletion lost")
+	}
+
+	if reloaded, err := tui.Sync(); err != nil || !reloaded {
+		t.Fatalf("Sync failed: reloaded=%v err=%v", reloaded, err)
+	}
+	if _, ok := tui.Data["get-example"]; ok {
+		t.Errorf("deletion made by another process not reloaded")
+	}
+	if reloaded, _ := tui.Sync(); reloaded {
+		t.Errorf("unexpected reload without change")
+	}
+}
+
+func TestParallelSaves(t *testing.T) {
+	dir := t.TempDir()
+	openDatabase(t, dir)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			database := openDatabase(t, dir)
+			name := fmt.Sprintf("request-%d", i)
+			database.Data[name] = core.Request{Name: name, Method: "GET", URL: "http://localhost"}
+			if err := database.Save(); err != nil {
+				t.Errorf("Save failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	database := openDatabase(t, dir)
+	for i := 0; i < 8; i++ {
+		if _, ok := database.Data[fmt.Sprintf("request-%d", i)]; !ok {
+			t.Errorf("request-%d lost", i)
+		}
+	}
+}
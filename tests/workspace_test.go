@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PierreKieffer/http-tanker/pkg/core"
+)
+
+func TestWorkspaceDiscovery(t *testing.T) {
+	project := t.TempDir()
+	nested := filepath.Join(project, "src", "api")
+	if err := os.MkdirAll(nested, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := core.FindWorkspace(nested); found {
+		t.Fatal("found a workspace before init")
+	}
+
+	path, err := core.InitWorkspace(project, "yaml")
+	if err != nil {
+		t.Fatalf("InitWorkspace failed: %v", err)
+	}
+	found, ok := core.FindWorkspace(nested)
+	if !ok || found != path {
+		t.Fatalf("FindWorkspace = %q, %v, expected %q", found, ok, path)
+	}
+
+	// A new workspace starts empty, without the example requests
+	database := core.OpenWorkspace(path)
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	if len(database.Data) != 0 {
+		t.Fatalf("expected an empty workspace, got %d requests", len(database.Data))
+	}
+	database.Data["users"] = core.Request{Name: "users", Method: "GET", URL: "http://localhost/users"}
+	if err := database.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "requests", "users.yaml")); err != nil {
+		t.Fatalf("request file not written: %v", err)
+	}
+
+	// Init again keeps the requests
+	if _, err := core.InitWorkspace(project, "json"); err != nil {
+		t.Fatalf("second InitWorkspace failed: %v", err)
+	}
+	reopened := core.OpenWorkspace(path)
+	if err := reopened.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	if _, ok := reopened.Data["users"]; !ok {
+		t.Fatal("request lost after a second init")
+	}
+}
+
+func TestRecentWorkspaces(t *testing.T) {
+	home := t.TempDir()
+	first, _ := core.InitWorkspace(t.TempDir(), "json")
+	second, _ := core.InitWorkspace(t.TempDir(), "json")
+
+	for _, path := range []string{first, second, first} {
+		if err := core.RememberWorkspace(home, path); err != nil {
+			t.Fatalf("RememberWorkspace failed: %v", err)
+		}
+	}
+	recent := core.RecentWorkspaces(home)
+	if len(recent) != 2 || recent[0].Path != first || recent[1].Path != second {
+		t.Fatalf("unexpected recent workspaces: %+v", recent)
+	}
+
+	os.RemoveAll(second)
+	if recent := core.RecentWorkspaces(home); len(recent) != 1 {
+		t.Fatalf("removed workspace still listed: %+v", recent)
+	}
+}